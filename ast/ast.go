@@ -0,0 +1,286 @@
+// Package ast defines a stable JSON representation of thrift files, for tools not written in Go.
+//
+// The representation mirrors thrifter nodes without cyclic pointers and tokens: every node has a kind, which is its NodeType, e.g. Struct or EnumElement,
+// its NodeID, its span in source, and comments attached to it. Declarations are listed in nodes of File in source order, and their elements nest inside them:
+//
+//	{
+//	  "version": 1,
+//	  "fileName": "user.thrift",
+//	  "nodes": [
+//	    {
+//	      "kind": "Struct",
+//	      "nodeId": "Struct(User)",
+//	      "span": {"start": {"line": 2, "column": 1, "offset": 13}, "end": {"line": 4, "column": 2, "offset": 45}},
+//	      "leadingComments": ["// a user"],
+//	      "doc": "a user",
+//	      "name": "User",
+//	      "fields": [
+//	        {"kind": "Field", "nodeId": "Struct(User).Field(1)", "id": 1, "requiredness": "optional", "type": {"name": "i64"}, "name": "id"}
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// Types are named by base types, identifiers, or map, list and set with their key, value or elem types. Const values have a kind of int, float, ident, string, map or list.
+// Strings, e.g. const values and option values, are unquoted.
+//
+// A File is converted back to a *thrifter.Thrift by printing its source and parsing it, so spans and doc are ignored and the source is formatted canonically.
+// Comments which are not attached to any node, e.g. a file header, are restored before the next declaration.
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/internal/yaml"
+)
+
+// Version is the version of the representation, it increases when the representation changes incompatibly.
+const Version = 1
+
+// File is a serialized thrift file.
+type File struct {
+	Version  int       `json:"version"`
+	FileName string    `json:"fileName"`
+	Nodes    []Decl    `json:"nodes"`
+	Comments []Comment `json:"comments,omitempty"` // comments not attached to any node
+}
+
+// Decl is a top-level declaration, one of *Namespace, *Include, *TypeDef, *Const, *Enum, *Struct and *Service.
+type Decl interface {
+	CommonField() *NodeCommonField
+}
+
+// NodeCommonField holds fields shared by all nodes.
+type NodeCommonField struct {
+	Kind            string   `json:"kind"`
+	NodeID          string   `json:"nodeId,omitempty"`
+	Span            *Span    `json:"span,omitempty"`
+	LeadingComments []string `json:"leadingComments,omitempty"` // raw text of comments, e.g. // comment
+	TrailingComment string   `json:"trailingComment,omitempty"`
+	Doc             string   `json:"doc,omitempty"` // see thrifter.NodeCommonField.Doc
+}
+
+func (c *NodeCommonField) CommonField() *NodeCommonField {
+	return c
+}
+
+// Span is the range of a node in source, End is right after the last character.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Position in source, Line and Column start at 1, Column and Offset count bytes.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+// Comment is a comment not attached to any node.
+type Comment struct {
+	Text string `json:"text"`
+	Span *Span  `json:"span,omitempty"`
+}
+
+type Namespace struct {
+	NodeCommonField
+	Name    string   `json:"name"` // language, e.g. go or *
+	Value   string   `json:"value"`
+	Options []Option `json:"options,omitempty"`
+}
+
+// Include is an include or cpp_include.
+type Include struct {
+	NodeCommonField
+	Path string `json:"path"`
+	Cpp  bool   `json:"cpp,omitempty"`
+}
+
+type TypeDef struct {
+	NodeCommonField
+	Type    *Type    `json:"type"`
+	Name    string   `json:"name"`
+	Options []Option `json:"options,omitempty"`
+}
+
+type Const struct {
+	NodeCommonField
+	Type  *Type       `json:"type"`
+	Name  string      `json:"name"`
+	Value *ConstValue `json:"value"`
+}
+
+type Enum struct {
+	NodeCommonField
+	Name     string         `json:"name"`
+	Elements []*EnumElement `json:"elements"`
+	Options  []Option       `json:"options,omitempty"`
+}
+
+// EnumElement has its effective value, Explicit is false if the value is counted from previous elements.
+type EnumElement struct {
+	NodeCommonField
+	Name     string   `json:"name"`
+	Value    int      `json:"value"`
+	Explicit bool     `json:"explicit,omitempty"`
+	Options  []Option `json:"options,omitempty"`
+}
+
+// Struct is a struct, union or exception, distinguished by Kind.
+type Struct struct {
+	NodeCommonField
+	Name    string   `json:"name"`
+	Fields  []*Field `json:"fields"`
+	Options []Option `json:"options,omitempty"`
+}
+
+type Service struct {
+	NodeCommonField
+	Name      string      `json:"name"`
+	Extends   string      `json:"extends,omitempty"`
+	Functions []*Function `json:"functions"`
+	Options   []Option    `json:"options,omitempty"`
+}
+
+// Function returns void if ReturnType is named void.
+type Function struct {
+	NodeCommonField
+	Name       string   `json:"name"`
+	Oneway     bool     `json:"oneway,omitempty"`
+	ReturnType *Type    `json:"returnType"`
+	Args       []*Field `json:"args"`
+	Throws     []*Field `json:"throws,omitempty"`
+	Options    []Option `json:"options,omitempty"`
+}
+
+// Field is a field of struct, or an argument or exception of function.
+type Field struct {
+	NodeCommonField
+	ID           int         `json:"id"`
+	Requiredness string      `json:"requiredness,omitempty"` // required, optional, or empty for default
+	Type         *Type       `json:"type"`
+	Name         string      `json:"name"`
+	Default      *ConstValue `json:"default,omitempty"`
+	Options      []Option    `json:"options,omitempty"`
+}
+
+// Type is named by a base type, e.g. i64, an identifier, e.g. shared.User, or map, list and set.
+type Type struct {
+	Name    string   `json:"name"`
+	Key     *Type    `json:"key,omitempty"`   // for map
+	Value   *Type    `json:"value,omitempty"` // for map
+	Elem    *Type    `json:"elem,omitempty"`  // for list and set
+	CppType string   `json:"cppType,omitempty"`
+	Options []Option `json:"options,omitempty"`
+}
+
+// Const value kinds
+const (
+	ConstInt    = "int"
+	ConstFloat  = "float"
+	ConstIdent  = "ident"
+	ConstString = "string"
+	ConstMap    = "map"
+	ConstList   = "list"
+)
+
+// ConstValue is a scalar whose text is Value, or a map or list.
+type ConstValue struct {
+	Kind    string        `json:"kind"`
+	Value   string        `json:"value,omitempty"` // unquoted for strings
+	Entries []MapEntry    `json:"entries,omitempty"`
+	Elems   []*ConstValue `json:"elems,omitempty"`
+}
+
+type MapEntry struct {
+	Key   *ConstValue `json:"key"`
+	Value *ConstValue `json:"value"`
+}
+
+// Option is an annotation, e.g. go.tag = "json". Value is nil if the option has no value, which is printed as the name only, and "" for an empty string.
+type Option struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value,omitempty"`
+}
+
+// Marshal serializes file into indented JSON.
+func Marshal(file *thrifter.Thrift) ([]byte, error) {
+	return json.MarshalIndent(FromThrift(file), "", "  ")
+}
+
+// MarshalYAML serializes file into YAML, which is the same document as Marshal.
+func MarshalYAML(file *thrifter.Thrift) ([]byte, error) {
+	data, err := json.Marshal(FromThrift(file))
+	if err != nil {
+		return nil, err
+	}
+	return yaml.FromJSON(data)
+}
+
+// Unmarshal loads a file serialized by Marshal.
+func Unmarshal(data []byte) (*thrifter.Thrift, error) {
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return file.Thrift()
+}
+
+// UnmarshalJSON decodes nodes by their kinds.
+func (f *File) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Version  int               `json:"version"`
+		FileName string            `json:"fileName"`
+		Nodes    []json.RawMessage `json:"nodes"`
+		Comments []Comment         `json:"comments"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Version > Version {
+		return fmt.Errorf("unsupported version %d", aux.Version)
+	}
+	f.Version, f.FileName, f.Comments, f.Nodes = aux.Version, aux.FileName, aux.Comments, nil
+	for i, raw := range aux.Nodes {
+		var common NodeCommonField
+		if err := json.Unmarshal(raw, &common); err != nil {
+			return err
+		}
+		var decl Decl
+		switch common.Kind {
+		case "Namespace":
+			decl = &Namespace{}
+		case "Include":
+			decl = &Include{}
+		case "TypeDef":
+			decl = &TypeDef{}
+		case "Const":
+			decl = &Const{}
+		case "Enum":
+			decl = &Enum{}
+		case "Struct", "Union", "Exception":
+			decl = &Struct{}
+		case "Service":
+			decl = &Service{}
+		default:
+			return fmt.Errorf("node %d: unknown kind %q", i, common.Kind)
+		}
+		if err := json.Unmarshal(raw, decl); err != nil {
+			return fmt.Errorf("node %d: %v", i, err)
+		}
+		f.Nodes = append(f.Nodes, decl)
+	}
+	return nil
+}
+
+// Thrift prints source of the file and parses it.
+func (f *File) Thrift() (*thrifter.Thrift, error) {
+	src, err := f.Source()
+	if err != nil {
+		return nil, err
+	}
+	return thrifter.NewParserBytes([]byte(src), false).Parse(f.FileName)
+}
@@ -0,0 +1,236 @@
+package ast
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+const src = `// header of file
+
+include "shared.thrift"
+namespace go user
+
+// ID of user
+typedef i64 ID (go.type = "int64")
+
+const map<string, list<i32>> LIMITS = {'a': [1, 2], "b": []}
+
+enum Status {
+  ACTIVE = 1, // active user
+  BLOCKED
+}
+
+/** a user */
+struct User {
+  1: required ID id
+  2: optional Status status = Status.ACTIVE (go.tag = 'json:"status"')
+  3: set cpp_type "std::set" <string> tags
+  // not attached
+}
+
+service UserService extends shared.Base {
+  User get(1: i64 id) throws (1: shared.NotFound e)
+  oneway void touch(1: i64 id, 2: list<string> keys)
+}
+`
+
+func parse(t *testing.T, src string) *thrifter.Thrift {
+	t.Helper()
+	res, err := thrifter.NewParserBytes([]byte(src), false).Parse("user.thrift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestMarshal(t *testing.T) {
+	data, err := Marshal(parse(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Nodes) != 7 || len(file.Comments) != 2 || file.Comments[0].Text != "// header of file" {
+		t.Fatalf("got %d nodes and comments %+v", len(file.Nodes), file.Comments)
+	}
+	user, ok := file.Nodes[5].(*Struct)
+	if !ok {
+		t.Fatalf("got %T", file.Nodes[5])
+	}
+	if user.Kind != "Struct" || user.NodeID != "Struct(User)" || user.Doc != "a user" || user.Span.Start != (Position{Line: 17, Column: 1, Offset: 244}) {
+		t.Errorf("got %+v", user.NodeCommonField)
+	}
+	status := user.Fields[1]
+	if status.Requiredness != "optional" || status.Type.Name != "Status" || status.Default.Value != "Status.ACTIVE" || status.Options[0].Name != "go.tag" || *status.Options[0].Value != `json:"status"` {
+		t.Errorf("got %+v", status)
+	}
+	if tags := user.Fields[2].Type; tags.Name != "set" || tags.CppType != "std::set" || tags.Elem.Name != "string" {
+		t.Errorf("got %+v", tags)
+	}
+	elems := file.Nodes[4].(*Enum).Elements
+	if elems[0].TrailingComment != "// active user" || !elems[0].Explicit || elems[1].Value != 2 || elems[1].Explicit {
+		t.Errorf("got %+v %+v", elems[0], elems[1])
+	}
+	fns := file.Nodes[6].(*Service).Functions
+	if fns[0].Throws[0].NodeID != "Service(UserService).Function(get).Throws(1)" || fns[1].ReturnType.Name != "void" || !fns[1].Oneway {
+		t.Errorf("got %+v %+v", fns[0], fns[1])
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	data, err := Marshal(parse(t, src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `// header of file
+
+include "shared.thrift"
+
+namespace go user
+
+// ID of user
+typedef i64 ID (go.type = "int64")
+
+const map<string, list<i32>> LIMITS = {"a": [1, 2], "b": []}
+
+enum Status {
+  ACTIVE = 1 // active user
+  BLOCKED
+}
+
+/** a user */
+struct User {
+  1: required ID id
+  2: optional Status status = Status.ACTIVE (go.tag = 'json:"status"')
+  3: set cpp_type "std::set"<string> tags
+}
+
+// not attached
+
+service UserService extends shared.Base {
+  User get(1: i64 id) throws (1: shared.NotFound e)
+  oneway void touch(1: i64 id, 2: list<string> keys)
+}
+`
+	if got := file.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// serializing a loaded file gives the same document except for positions
+func TestUnmarshal_roundTrip(t *testing.T) {
+	paths, err := filepath.Glob("../examples/*.thrift")
+	if err != nil || len(paths) == 0 {
+		t.Fatal(paths, err)
+	}
+	for _, path := range append(paths, "") {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			text := src
+			if path != "" {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				text = string(data)
+			}
+			parsed := parse(t, text)
+			want := FromThrift(parsed)
+			data, err := json.Marshal(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := Unmarshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := FromThrift(loaded)
+			wantJSON, gotJSON := withoutSpans(t, want), withoutSpans(t, got)
+			if gotJSON != wantJSON {
+				for i, line := range strings.Split(gotJSON, "\n") {
+					if wantLine := strings.Split(wantJSON, "\n")[i]; line != wantLine {
+						t.Fatalf("line %d: got %s, want %s", i+1, line, wantLine)
+					}
+				}
+			}
+			// options keep their values, including empty ones
+			if got, want := optionsText(loaded), optionsText(parsed); got != want {
+				t.Errorf("got [%v] want [%v]", got, want)
+			}
+		})
+	}
+}
+
+func optionsText(file *thrifter.Thrift) string {
+	var res []string
+	thrifter.Inspect(file, func(node thrifter.Node) bool {
+		if opt, ok := node.(*thrifter.Option); ok {
+			if opt.Value == "" {
+				res = append(res, opt.Name)
+			} else {
+				res = append(res, opt.Name+" = "+unquote(opt.Value))
+			}
+		}
+		return true
+	})
+	return strings.Join(res, ", ")
+}
+
+func withoutSpans(t *testing.T, file *File) string {
+	t.Helper()
+	data, err := json.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	var strip func(v interface{})
+	strip = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			delete(v, "span")
+			for _, elem := range v {
+				strip(elem)
+			}
+		case []interface{}:
+			for _, elem := range v {
+				strip(elem)
+			}
+		}
+	}
+	strip(doc)
+	// unattached comments may be moved
+	delete(doc.(map[string]interface{}), "comments")
+	data, err = json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestUnmarshal_errors(t *testing.T) {
+	cases := map[string]string{
+		`{"version": 2, "nodes": []}`:                                 "unsupported version",
+		`{"version": 1, "nodes": [{"kind": "Table"}]}`:                "unknown kind",
+		`{"version": 1, "nodes": [{"kind": "TypeDef", "name": "A"}]}`: "missing type",
+		`{"version": 1, "nodes": [{"kind": "Struct", "name": "A", "fields": [{"id": 1, "name": "a", "type": {"name": "map"}}]}]}`: "missing type",
+	}
+	for data, want := range cases {
+		_, err := Unmarshal([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got [%v] want [%v]", data, err, want)
+		}
+	}
+}
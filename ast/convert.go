@@ -0,0 +1,195 @@
+package ast
+
+import (
+	"github.com/YYCoder/thrifter"
+)
+
+// FromThrift converts a parsed file into its serialized form.
+func FromThrift(file *thrifter.Thrift) *File {
+	c := &converter{attached: map[*thrifter.Token]bool{}}
+	res := &File{Version: Version, FileName: file.FileName, Nodes: []Decl{}}
+	for _, node := range file.Nodes {
+		if decl := c.decl(node); decl != nil {
+			res.Nodes = append(res.Nodes, decl)
+		}
+	}
+	for tok := file.StartToken; tok != nil; tok = tok.Next {
+		if tok.Type == thrifter.T_COMMENT && !c.attached[tok] {
+			res.Comments = append(res.Comments, Comment{Text: tok.Raw, Span: tokenSpan(tok, tok)})
+		}
+	}
+	return res
+}
+
+type converter struct {
+	attached map[*thrifter.Token]bool // comments attached to nodes
+}
+
+func (c *converter) decl(node thrifter.Node) Decl {
+	switch n := node.(type) {
+	case *thrifter.Namespace:
+		return &Namespace{NodeCommonField: c.common(n), Name: n.Name, Value: n.Value, Options: options(n.Options)}
+	case *thrifter.Include:
+		return &Include{NodeCommonField: c.common(n), Path: n.FilePath, Cpp: n.IsCpp()}
+	case *thrifter.TypeDef:
+		return &TypeDef{NodeCommonField: c.common(n), Type: fieldType(n.Type), Name: n.Ident, Options: options(n.Options)}
+	case *thrifter.Const:
+		return &Const{NodeCommonField: c.common(n), Type: fieldType(n.Type), Name: n.Ident, Value: constValue(n.Value)}
+	case *thrifter.Enum:
+		res := &Enum{NodeCommonField: c.common(n), Name: n.Ident, Elements: []*EnumElement{}, Options: options(n.Options)}
+		for _, elem := range n.Elems {
+			res.Elements = append(res.Elements, &EnumElement{
+				NodeCommonField: c.common(elem),
+				Name:            elem.Ident,
+				Value:           elem.Value(),
+				Explicit:        elem.HasValue(),
+				Options:         options(elem.Options),
+			})
+		}
+		return res
+	case *thrifter.Struct:
+		return &Struct{NodeCommonField: c.common(n), Name: n.Ident, Fields: c.fields(n.Elems), Options: options(n.Options)}
+	case *thrifter.Service:
+		res := &Service{NodeCommonField: c.common(n), Name: n.Ident, Extends: n.Extends, Functions: []*Function{}, Options: options(n.Options)}
+		for _, fn := range n.Elems {
+			f := &Function{
+				NodeCommonField: c.common(fn),
+				Name:            fn.Ident,
+				Oneway:          fn.Oneway,
+				ReturnType:      &Type{Name: "void"},
+				Args:            c.fields(fn.Args),
+				Options:         options(fn.Options),
+			}
+			if !fn.Void {
+				f.ReturnType = fieldType(fn.FunctionType)
+			}
+			if len(fn.Throws) > 0 {
+				f.Throws = c.fields(fn.Throws)
+			}
+			res.Functions = append(res.Functions, f)
+		}
+		return res
+	}
+	return nil
+}
+
+func (c *converter) fields(fields []*thrifter.Field) []*Field {
+	res := []*Field{}
+	for _, field := range fields {
+		res = append(res, &Field{
+			NodeCommonField: c.common(field),
+			ID:              field.ID,
+			Requiredness:    field.Requiredness,
+			Type:            fieldType(field.FieldType),
+			Name:            field.Ident,
+			Default:         constValue(field.DefaultValue),
+			Options:         options(field.Options),
+		})
+	}
+	return res
+}
+
+func (c *converter) common(node thrifter.Node) NodeCommonField {
+	common := node.CommonField()
+	res := NodeCommonField{Kind: node.NodeType(), NodeID: common.NodeID, Doc: common.Doc()}
+	if common.StartToken != nil && common.EndToken != nil {
+		res.Span = tokenSpan(common.StartToken, common.EndToken)
+	}
+	for _, tok := range common.LeadingComments() {
+		res.LeadingComments = append(res.LeadingComments, tok.Raw)
+		c.attached[tok] = true
+	}
+	if tok := common.TrailingComment(); tok != nil {
+		res.TrailingComment = tok.Raw
+		c.attached[tok] = true
+	}
+	return res
+}
+
+func tokenSpan(start *thrifter.Token, end *thrifter.Token) *Span {
+	res := &Span{
+		Start: Position{Line: start.Pos.Line, Column: start.Pos.Column, Offset: start.Start},
+		End:   Position{Line: end.Pos.Line, Column: end.Pos.Column, Offset: end.End},
+	}
+	for _, ru := range end.Raw {
+		if ru == '\n' {
+			res.End.Line++
+			res.End.Column = 1
+		} else {
+			res.End.Column++
+		}
+	}
+	return res
+}
+
+func fieldType(ft *thrifter.FieldType) *Type {
+	if ft == nil {
+		return nil
+	}
+	res := &Type{Options: options(ft.Options)}
+	switch ft.Type {
+	case thrifter.FIELD_TYPE_BASE:
+		res.Name = ft.BaseType
+	case thrifter.FIELD_TYPE_IDENT:
+		res.Name = ft.Ident
+	case thrifter.FIELD_TYPE_MAP:
+		res.Name, res.Key, res.Value, res.CppType = "map", fieldType(ft.Map.Key), fieldType(ft.Map.Value), ft.Map.CppType
+	case thrifter.FIELD_TYPE_LIST:
+		res.Name, res.Elem, res.CppType = "list", fieldType(ft.List.Elem), ft.List.CppType
+	case thrifter.FIELD_TYPE_SET:
+		res.Name, res.Elem, res.CppType = "set", fieldType(ft.Set.Elem), ft.Set.CppType
+	}
+	return res
+}
+
+func constValue(value *thrifter.ConstValue) *ConstValue {
+	if value == nil {
+		return nil
+	}
+	switch value.Type {
+	case thrifter.CONST_VALUE_INT:
+		return &ConstValue{Kind: ConstInt, Value: value.Value}
+	case thrifter.CONST_VALUE_FLOAT:
+		return &ConstValue{Kind: ConstFloat, Value: value.Value}
+	case thrifter.CONST_VALUE_IDENT:
+		return &ConstValue{Kind: ConstIdent, Value: value.Value}
+	case thrifter.CONST_VALUE_LITERAL:
+		return &ConstValue{Kind: ConstString, Value: unquote(value.Value)}
+	case thrifter.CONST_VALUE_MAP:
+		res := &ConstValue{Kind: ConstMap, Entries: []MapEntry{}}
+		if value.Map != nil {
+			for i := range value.Map.MapKeyList {
+				res.Entries = append(res.Entries, MapEntry{Key: constValue(&value.Map.MapKeyList[i]), Value: constValue(&value.Map.MapValueList[i])})
+			}
+		}
+		return res
+	case thrifter.CONST_VALUE_LIST:
+		res := &ConstValue{Kind: ConstList, Elems: []*ConstValue{}}
+		if value.List != nil {
+			for _, elem := range value.List.Elems {
+				res.Elems = append(res.Elems, constValue(elem))
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+func options(opts []*thrifter.Option) (res []Option) {
+	for _, opt := range opts {
+		option := Option{Name: opt.Name}
+		if opt.Value != "" {
+			value := unquote(opt.Value)
+			option.Value = &value
+		}
+		res = append(res, option)
+	}
+	return
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,335 @@
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const indent = "  "
+
+// Source prints the file as thrift source, see the package doc for what is kept.
+func (f *File) Source() (string, error) {
+	p := &printer{}
+	comments := f.Comments
+	var prev Decl
+	for _, decl := range f.Nodes {
+		common := decl.CommonField()
+		// unattached comments before the declaration, they are kept in a separated block
+		var before []Comment
+		for len(comments) > 0 && (comments[0].Span == nil || common.Span == nil || comments[0].Span.Start.Offset < common.Span.Start.Offset) {
+			before, comments = append(before, comments[0]), comments[1:]
+		}
+		if prev != nil && (len(before) > 0 || !grouped(prev, decl)) {
+			p.line(0, "")
+		}
+		if len(before) > 0 {
+			for _, comment := range before {
+				p.line(0, comment.Text)
+			}
+			p.line(0, "")
+		}
+		if err := p.decl(decl); err != nil {
+			return "", err
+		}
+		prev = decl
+	}
+	if len(comments) > 0 && prev != nil {
+		p.line(0, "")
+	}
+	for _, comment := range comments {
+		p.line(0, comment.Text)
+	}
+	return p.buf.String(), nil
+}
+
+// consecutive namespaces or includes are not separated by blank lines
+func grouped(prev Decl, decl Decl) bool {
+	switch prev.(type) {
+	case *Namespace:
+		_, ok := decl.(*Namespace)
+		return ok
+	case *Include:
+		_, ok := decl.(*Include)
+		return ok
+	}
+	return false
+}
+
+type printer struct {
+	buf strings.Builder
+}
+
+func (p *printer) line(depth int, text string) {
+	if text != "" {
+		p.buf.WriteString(strings.Repeat(indent, depth))
+		p.buf.WriteString(text)
+	}
+	p.buf.WriteString("\n")
+}
+
+// print a node with its comments, text is the first line of node, and the trailing comment follows the last line
+func (p *printer) node(depth int, common *NodeCommonField, text string) {
+	for _, comment := range common.LeadingComments {
+		p.line(depth, comment)
+	}
+	if common.TrailingComment != "" {
+		text += " " + common.TrailingComment
+	}
+	p.line(depth, text)
+}
+
+func (p *printer) decl(decl Decl) error {
+	switch n := decl.(type) {
+	case *Namespace:
+		p.node(0, &n.NodeCommonField, "namespace "+n.Name+" "+n.Value+optionsString(n.Options))
+	case *Include:
+		keyword := "include"
+		if n.Cpp {
+			keyword = "cpp_include"
+		}
+		p.node(0, &n.NodeCommonField, keyword+" "+quote(n.Path))
+	case *TypeDef:
+		typ, err := typeString(n.Type)
+		if err != nil {
+			return fmt.Errorf("typedef %s: %v", n.Name, err)
+		}
+		p.node(0, &n.NodeCommonField, "typedef "+typ+" "+n.Name+optionsString(n.Options))
+	case *Const:
+		typ, err := typeString(n.Type)
+		if err != nil {
+			return fmt.Errorf("const %s: %v", n.Name, err)
+		}
+		value, err := constString(n.Value)
+		if err != nil {
+			return fmt.Errorf("const %s: %v", n.Name, err)
+		}
+		p.node(0, &n.NodeCommonField, "const "+typ+" "+n.Name+" = "+value)
+	case *Enum:
+		p.block(&n.NodeCommonField, "enum "+n.Name, n.Options, func() error {
+			for _, elem := range n.Elements {
+				text := elem.Name
+				if elem.Explicit {
+					text += " = " + strconv.Itoa(elem.Value)
+				}
+				p.node(1, &elem.NodeCommonField, text+optionsString(elem.Options))
+			}
+			return nil
+		})
+	case *Struct:
+		keyword := strings.ToLower(n.Kind)
+		if keyword != "union" && keyword != "exception" {
+			keyword = "struct"
+		}
+		return p.block(&n.NodeCommonField, keyword+" "+n.Name, n.Options, func() error {
+			for _, field := range n.Fields {
+				text, err := fieldString(field)
+				if err != nil {
+					return fmt.Errorf("%s %s: %v", keyword, n.Name, err)
+				}
+				p.node(1, &field.NodeCommonField, text)
+			}
+			return nil
+		})
+	case *Service:
+		header := "service " + n.Name
+		if n.Extends != "" {
+			header += " extends " + n.Extends
+		}
+		return p.block(&n.NodeCommonField, header, n.Options, func() error {
+			for _, fn := range n.Functions {
+				text, err := functionString(fn)
+				if err != nil {
+					return fmt.Errorf("service %s: function %s: %v", n.Name, fn.Name, err)
+				}
+				p.node(1, &fn.NodeCommonField, text)
+			}
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown declaration %T", decl)
+	}
+	return nil
+}
+
+// print a declaration with elements in curly braces, the trailing comment and options follow the right curly brace
+func (p *printer) block(common *NodeCommonField, header string, options []Option, elems func() error) error {
+	for _, comment := range common.LeadingComments {
+		p.line(0, comment)
+	}
+	p.line(0, header+" {")
+	if err := elems(); err != nil {
+		return err
+	}
+	last := "}" + optionsString(options)
+	if common.TrailingComment != "" {
+		last += " " + common.TrailingComment
+	}
+	p.line(0, last)
+	return nil
+}
+
+func functionString(fn *Function) (string, error) {
+	var res strings.Builder
+	if fn.Oneway {
+		res.WriteString("oneway ")
+	}
+	ret, err := typeString(fn.ReturnType)
+	if err != nil {
+		return "", err
+	}
+	res.WriteString(ret + " " + fn.Name + "(")
+	if err := fieldsString(&res, fn.Args); err != nil {
+		return "", err
+	}
+	res.WriteString(")")
+	if len(fn.Throws) > 0 {
+		res.WriteString(" throws (")
+		if err := fieldsString(&res, fn.Throws); err != nil {
+			return "", err
+		}
+		res.WriteString(")")
+	}
+	res.WriteString(optionsString(fn.Options))
+	return res.String(), nil
+}
+
+func fieldsString(res *strings.Builder, fields []*Field) error {
+	for i, field := range fields {
+		if i > 0 {
+			res.WriteString(", ")
+		}
+		text, err := fieldString(field)
+		if err != nil {
+			return err
+		}
+		res.WriteString(text)
+	}
+	return nil
+}
+
+func fieldString(field *Field) (string, error) {
+	typ, err := typeString(field.Type)
+	if err != nil {
+		return "", fmt.Errorf("field %s: %v", field.Name, err)
+	}
+	res := strconv.Itoa(field.ID) + ": "
+	if field.Requiredness != "" {
+		res += field.Requiredness + " "
+	}
+	res += typ + " " + field.Name
+	if field.Default != nil {
+		value, err := constString(field.Default)
+		if err != nil {
+			return "", fmt.Errorf("field %s: %v", field.Name, err)
+		}
+		res += " = " + value
+	}
+	return res + optionsString(field.Options), nil
+}
+
+func typeString(typ *Type) (string, error) {
+	if typ == nil {
+		return "", fmt.Errorf("missing type")
+	}
+	var res string
+	switch typ.Name {
+	case "map":
+		key, err := typeString(typ.Key)
+		if err != nil {
+			return "", err
+		}
+		value, err := typeString(typ.Value)
+		if err != nil {
+			return "", err
+		}
+		res = "map" + cppType(typ.CppType) + "<" + key + ", " + value + ">"
+	case "set":
+		elem, err := typeString(typ.Elem)
+		if err != nil {
+			return "", err
+		}
+		res = "set" + cppType(typ.CppType) + "<" + elem + ">"
+	case "list":
+		elem, err := typeString(typ.Elem)
+		if err != nil {
+			return "", err
+		}
+		res = "list<" + elem + ">" + cppType(typ.CppType)
+	case "":
+		return "", fmt.Errorf("missing type name")
+	default:
+		res = typ.Name
+	}
+	return res + optionsString(typ.Options), nil
+}
+
+func cppType(name string) string {
+	if name == "" {
+		return ""
+	}
+	return " cpp_type " + quote(name)
+}
+
+func constString(value *ConstValue) (string, error) {
+	if value == nil {
+		return "", fmt.Errorf("missing value")
+	}
+	switch value.Kind {
+	case ConstInt, ConstFloat, ConstIdent:
+		if value.Value == "" {
+			return "", fmt.Errorf("missing %s value", value.Kind)
+		}
+		return value.Value, nil
+	case ConstString:
+		return quote(value.Value), nil
+	case ConstMap:
+		entries := make([]string, 0, len(value.Entries))
+		for _, entry := range value.Entries {
+			key, err := constString(entry.Key)
+			if err != nil {
+				return "", err
+			}
+			val, err := constString(entry.Value)
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, key+": "+val)
+		}
+		return "{" + strings.Join(entries, ", ") + "}", nil
+	case ConstList:
+		elems := make([]string, 0, len(value.Elems))
+		for _, elem := range value.Elems {
+			text, err := constString(elem)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, text)
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	}
+	return "", fmt.Errorf("unknown const kind %q", value.Kind)
+}
+
+func optionsString(options []Option) string {
+	if len(options) == 0 {
+		return ""
+	}
+	res := make([]string, 0, len(options))
+	for _, opt := range options {
+		if opt.Value == nil {
+			res = append(res, opt.Name)
+		} else {
+			res = append(res, opt.Name+" = "+quote(*opt.Value))
+		}
+	}
+	return " (" + strings.Join(res, ", ") + ")"
+}
+
+// thrift strings have no escapes, so quote by single quotes if s contains double quotes
+func quote(s string) string {
+	if strings.Contains(s, `"`) {
+		return "'" + s + "'"
+	}
+	return `"` + s + `"`
+}
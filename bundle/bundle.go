@@ -0,0 +1,261 @@
+// Package bundle flattens a thrift file and files it includes transitively into a single self-contained file.
+package bundle
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Options of Bundle.
+type Options struct {
+	// KeepUnused keeps declarations of included files which are not referred by the root file, they are removed by default.
+	KeepUnused bool
+}
+
+// Result of Bundle.
+type Result struct {
+	File    *thrifter.Thrift
+	Renamed map[thrifter.Node]string // declarations of included files whose names are taken, and their new names
+	Removed []thrifter.Node          // declarations of included files not referred by the root file
+}
+
+// Bundle inlines declarations of files included by root transitively, so the result has no includes.
+//
+// Declarations of included files come first, ordered by includes so that dependencies come before dependents, followed by declarations of root.
+// Namespaces and cpp_includes of root are kept, those of included files are dropped.
+// Declarations of root keep their names, others keep theirs unless the name is taken, in which case it's prefixed by the file name, e.g. shared_User.
+// References are rewritten to the new names, e.g. shared.User becomes User or shared_User.
+// Only tokens of identifiers are rewritten in the printed source, so formatting and comments of declarations survive, and files in program are not changed.
+func Bundle(program *thrifter.Program, root *thrifter.Thrift, opts Options) (*Result, error) {
+	b := &bundler{
+		program: program,
+		root:    root,
+		visited: map[*thrifter.Thrift]bool{},
+		needed:  map[thrifter.Node]bool{},
+		names:   map[thrifter.Node]string{},
+		subst:   map[*thrifter.Token]string{},
+		refs:    map[thrifter.Node][]thrifter.Reference{},
+	}
+	if err := b.visit(root); err != nil {
+		return nil, err
+	}
+	res := &Result{Renamed: map[thrifter.Node]string{}}
+
+	// tree shaking, starting from declarations of root
+	var queue []thrifter.Node
+	for _, file := range b.files {
+		for _, decl := range declarations(file) {
+			if file == root || opts.KeepUnused {
+				b.needed[decl] = true
+				queue = append(queue, decl)
+			}
+		}
+	}
+	for len(queue) > 0 {
+		decl := queue[0]
+		queue = queue[1:]
+		for _, ref := range b.refs[decl] {
+			target := thrifter.TopLevel(ref.Target)
+			if !b.needed[target] {
+				b.needed[target] = true
+				queue = append(queue, target)
+			}
+		}
+	}
+
+	// names of root are taken first, then included files in order
+	taken := map[string]bool{}
+	for _, decl := range declarations(root) {
+		b.names[decl] = declarationName(decl)
+		taken[b.names[decl]] = true
+	}
+	for _, file := range b.files {
+		if file == root {
+			continue
+		}
+		prefix := filePrefix(file)
+		for _, decl := range declarations(file) {
+			if !b.needed[decl] {
+				res.Removed = append(res.Removed, decl)
+				continue
+			}
+			name := declarationName(decl)
+			if taken[name] {
+				name = prefix + "_" + declarationName(decl)
+				for i := 2; taken[name]; i++ {
+					name = fmt.Sprintf("%s_%s_%d", prefix, declarationName(decl), i)
+				}
+				res.Renamed[decl] = name
+				b.subst[thrifter.IdentToken(decl)] = name
+			}
+			b.names[decl] = name
+			taken[name] = true
+		}
+	}
+	for _, refs := range b.refs {
+		for _, ref := range refs {
+			name := b.names[thrifter.TopLevel(ref.Target)]
+			if elem, ok := ref.Target.(*thrifter.EnumElement); ok {
+				name += "." + elem.Ident
+			}
+			if name != ref.Token.Raw {
+				b.subst[ref.Token] = name
+			}
+		}
+	}
+
+	src := b.print()
+	file, err := thrifter.NewParserBytes([]byte(src), false).Parse(root.FileName)
+	if err != nil {
+		return nil, fmt.Errorf("bundled source is invalid: %v", err)
+	}
+	res.File = file
+	return res, nil
+}
+
+type bundler struct {
+	program *thrifter.Program
+	root    *thrifter.Thrift
+	files   []*thrifter.Thrift // included files before files including them, root is the last
+	visited map[*thrifter.Thrift]bool
+	needed  map[thrifter.Node]bool
+	names   map[thrifter.Node]string               // final names of declarations
+	subst   map[*thrifter.Token]string             // tokens to be printed as other text
+	refs    map[thrifter.Node][]thrifter.Reference // references by declarations containing them
+}
+
+// visit files included by file in depth-first order, and collects their references
+func (b *bundler) visit(file *thrifter.Thrift) error {
+	if b.visited[file] {
+		return nil
+	}
+	b.visited[file] = true
+	for _, inc := range file.Includes() {
+		path, ok := b.program.IncludePath(file, inc)
+		included := b.program.Files[path]
+		if !ok || included == nil {
+			return fmt.Errorf("%v: included file %q not loaded", inc.StartToken.Pos, inc.FilePath)
+		}
+		if err := b.visit(included); err != nil {
+			return err
+		}
+	}
+	for _, ref := range b.program.FileReferences(file) {
+		if ref.Target == nil {
+			return fmt.Errorf("%v: unresolved reference %s", ref.Token.Pos, ref.Token.Raw)
+		}
+		decl := thrifter.TopLevel(ref.Node)
+		b.refs[decl] = append(b.refs[decl], ref)
+	}
+	b.files = append(b.files, file)
+	return nil
+}
+
+func (b *bundler) print() string {
+	var parts []string
+	// file header, i.e. comments before the first node which don't belong to it
+	if len(b.root.Nodes) > 0 {
+		first, _ := span(b.root.Nodes[0])
+		if header := strings.TrimSpace(b.text(b.root.StartToken, first.Prev)); header != "" {
+			parts = append(parts, header)
+		}
+	}
+	var lines []string
+	for _, node := range b.root.Nodes {
+		switch n := node.(type) {
+		case *thrifter.Namespace:
+			lines = append(lines, b.nodeText(n))
+		case *thrifter.Include:
+			if n.IsCpp() {
+				lines = append(lines, b.nodeText(n))
+			}
+		}
+	}
+	if len(lines) > 0 {
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+	for _, file := range b.files {
+		for _, decl := range declarations(file) {
+			if b.needed[decl] {
+				parts = append(parts, b.nodeText(decl))
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n") + "\n"
+}
+
+func (b *bundler) nodeText(node thrifter.Node) string {
+	first, last := span(node)
+	return b.text(first, last)
+}
+
+// source from token first to last, with substitutions
+func (b *bundler) text(first *thrifter.Token, last *thrifter.Token) string {
+	var res strings.Builder
+	if first == nil || last == nil {
+		return ""
+	}
+	for tok := first; tok != nil; tok = tok.Next {
+		if raw, ok := b.subst[tok]; ok {
+			res.WriteString(raw)
+		} else {
+			res.WriteString(tok.Raw)
+		}
+		if tok == last {
+			break
+		}
+	}
+	return res.String()
+}
+
+// first and last token of node, along with its leading comments and trailing comment
+func span(node thrifter.Node) (first *thrifter.Token, last *thrifter.Token) {
+	common := node.CommonField()
+	first, last = common.StartToken, common.EndToken
+	if comments := common.LeadingComments(); len(comments) > 0 {
+		first = comments[0]
+	}
+	if tok := common.TrailingComment(); tok != nil {
+		last = tok
+	}
+	return
+}
+
+func declarations(file *thrifter.Thrift) (res []thrifter.Node) {
+	for _, node := range file.Nodes {
+		if declarationName(node) != "" {
+			res = append(res, node)
+		}
+	}
+	return
+}
+
+func declarationName(node thrifter.Node) string {
+	switch n := node.(type) {
+	case *thrifter.Struct:
+		return n.Ident
+	case *thrifter.Enum:
+		return n.Ident
+	case *thrifter.Service:
+		return n.Ident
+	case *thrifter.TypeDef:
+		return n.Ident
+	case *thrifter.Const:
+		return n.Ident
+	}
+	return ""
+}
+
+// file name without extension, characters not allowed in identifiers are replaced by _
+func filePrefix(file *thrifter.Thrift) string {
+	base := strings.TrimSuffix(filepath.Base(file.FileName), filepath.Ext(file.FileName))
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, base)
+}
@@ -0,0 +1,179 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+func load(t *testing.T, files map[string]string, main string) (*thrifter.Program, *thrifter.Thrift) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	program := thrifter.NewProgram()
+	root, err := program.Load(filepath.Join(dir, main))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program, root
+}
+
+var files = map[string]string{
+	"main.thrift": `// main file
+
+include "shared.thrift"
+include "users.thrift"
+namespace go main
+
+// Status of order
+enum Status {
+  OK
+}
+
+struct Order {
+  1: users.User   buyer   // aligned
+  2: shared.Status status = shared.Status.ACTIVE
+  3: Status order_status
+}
+
+service OrderService extends users.UserService {
+  Order get(1: shared.ID id)
+}
+`,
+	"users.thrift": `include "shared.thrift"
+
+namespace go user
+
+struct User {
+  1: shared.ID id
+  2: optional shared.Status status
+}
+
+struct Unused {
+  1: User user
+}
+
+service UserService {
+  User find(1: shared.ID id)
+}
+`,
+	"shared.thrift": `typedef i64 ID
+
+/** status of user */
+enum Status {
+  ACTIVE = 1
+  BLOCKED
+}
+
+const list<Status> ALL = [Status.ACTIVE, Status.BLOCKED]
+`,
+}
+
+func TestBundle(t *testing.T) {
+	program, root := load(t, files, "main.thrift")
+	res, err := Bundle(program, root, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `// main file
+
+namespace go main
+
+typedef i64 ID
+
+/** status of user */
+enum shared_Status {
+  ACTIVE = 1
+  BLOCKED
+}
+
+struct User {
+  1: ID id
+  2: optional shared_Status status
+}
+
+service UserService {
+  User find(1: ID id)
+}
+
+// Status of order
+enum Status {
+  OK
+}
+
+struct Order {
+  1: User   buyer   // aligned
+  2: shared_Status status = shared_Status.ACTIVE
+  3: Status order_status
+}
+
+service OrderService extends UserService {
+  Order get(1: ID id)
+}
+`
+	if got := res.File.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if len(res.Renamed) != 1 {
+		t.Errorf("renamed %v", res.Renamed)
+	}
+	for node, name := range res.Renamed {
+		if node.CommonField().NodeID != "Enum(Status)" || name != "shared_Status" {
+			t.Errorf("renamed %s to %s", node.CommonField().NodeID, name)
+		}
+	}
+	var removed []string
+	for _, node := range res.Removed {
+		removed = append(removed, node.CommonField().NodeID)
+	}
+	if got := strings.Join(removed, ","); got != "Const(ALL),Struct(Unused)" {
+		t.Errorf("removed %s", got)
+	}
+	// files in program are not changed
+	if got := program.Files[filepath.Clean(root.FileName)].String(); got != files["main.thrift"] {
+		t.Errorf("root changed:\n%s", got)
+	}
+}
+
+func TestBundle_keepUnused(t *testing.T) {
+	program, root := load(t, files, "main.thrift")
+	res, err := Bundle(program, root, Options{KeepUnused: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Removed) != 0 {
+		t.Errorf("removed %v", res.Removed)
+	}
+	got := res.File.String()
+	for _, want := range []string{
+		"const list<shared_Status> ALL = [shared_Status.ACTIVE, shared_Status.BLOCKED]",
+		"struct Unused {\n  1: User user\n}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestBundle_errors(t *testing.T) {
+	program, root := load(t, map[string]string{
+		"main.thrift": `include "shared.thrift"
+
+struct Order {
+  1: shared.Missing m
+}
+`,
+		"shared.thrift": ``,
+	}, "main.thrift")
+	_, err := Bundle(program, root, Options{})
+	if err == nil || !strings.Contains(err.Error(), "unresolved reference shared.Missing") {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+// Command thrifter-compat reports changes between two versions of a thrift file and the files it includes, and fails if any change is at least as severe as -fail-on, so it can be used as a CI gate.
+//
+// Usage:
+//
+//	thrifter-compat [-I dir]... [-fail-on breaking|risky|safe] [-json] old.thrift new.thrift
+//
+// Exit code is 1 if a change fails the check, and 2 if files can't be loaded.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/compat"
+	"github.com/YYCoder/thrifter/internal/cli"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("thrifter-compat", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var includeDirs cli.StringList
+	failOn := compat.Breaking
+	flags.Var(&includeDirs, "I", "directory to search included files, can be repeated")
+	flags.TextVar(&failOn, "fail-on", compat.Breaking, "minimum severity of changes failing the check, one of breaking, risky and safe")
+	asJSON := flags.Bool("json", false, "print changes in json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(stderr, "usage: thrifter-compat [flags] old.thrift new.thrift")
+		flags.PrintDefaults()
+		return 2
+	}
+
+	oldPath, newPath := flags.Arg(0), flags.Arg(1)
+	oldProgram, newProgram := thrifter.NewProgram(includeDirs...), thrifter.NewProgram(includeDirs...)
+	if _, err := oldProgram.Load(oldPath); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if _, err := newProgram.Load(newPath); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	changes := compat.ComparePrograms(oldProgram, filepath.Dir(oldPath), newProgram, filepath.Dir(newPath))
+
+	if *asJSON {
+		if changes == nil {
+			changes = []compat.Change{}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(changes); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	} else {
+		for _, change := range changes {
+			fmt.Fprintln(stdout, change)
+		}
+	}
+	if len(changes) > 0 && compat.Max(changes) >= failOn {
+		return 1
+	}
+	return 0
+}
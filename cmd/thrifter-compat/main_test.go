@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter/compat"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"old/user.thrift": "struct User {\n 1: i64 id\n 2: string name\n}",
+		"new/user.thrift": "struct User {\n 1: i64 id\n 3: string email\n}",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldPath, newPath := filepath.Join(dir, "old/user.thrift"), filepath.Join(dir, "new/user.thrift")
+
+	cases := []struct {
+		args []string
+		code int
+	}{
+		{[]string{oldPath, newPath}, 0},
+		{[]string{"-fail-on", "risky", oldPath, newPath}, 1},
+		{[]string{"-fail-on", "unknown", oldPath, newPath}, 2},
+		{[]string{oldPath, filepath.Join(dir, "missing.thrift")}, 2},
+		{[]string{oldPath}, 2},
+	}
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		if got := run(c.args, &stdout, &stderr); got != c.code {
+			t.Errorf("%v: got [%v] want [%v], stderr: %s", c.args, got, c.code, stderr.String())
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	run([]string{oldPath, newPath}, &stdout, &stderr)
+	if got, want := strings.Count(stdout.String(), "\n"), 2; got != want {
+		t.Errorf("got [%v] want [%v]: %s", got, want, stdout.String())
+	}
+
+	stdout.Reset()
+	run([]string{"-json", oldPath, newPath}, &stdout, &stderr)
+	var changes []compat.Change
+	if err := json.Unmarshal(stdout.Bytes(), &changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Kind != compat.FieldRemoved || changes[0].Severity != compat.Risky {
+		t.Errorf("got [%+v]", changes)
+	}
+}
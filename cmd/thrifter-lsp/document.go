@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// document is a file opened by client, whose content is synchronized by didOpen and didChange notifications.
+type document struct {
+	uri     string
+	path    string
+	version int
+	text    string
+	lines   []int            // offset of each line start
+	tree    *thrifter.Thrift // nil if text can't be parsed
+	err     error            // parse error of text
+}
+
+func newDocument(uri string, version int, text string) *document {
+	res := &document{
+		uri:     uri,
+		path:    uriToPath(uri),
+		version: version,
+	}
+	res.setText(text)
+	return res
+}
+
+func (d *document) setText(text string) {
+	d.text = text
+	d.lines = d.lines[:0]
+	d.lines = append(d.lines, 0)
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+}
+
+// parse parses the whole text, tree is kept nil on error.
+func (d *document) parse() {
+	d.tree, d.err = thrifter.NewParser(strings.NewReader(d.text), false).Parse(d.path)
+	if d.err != nil {
+		d.tree = nil
+	}
+}
+
+// applyChange applies a content change to text, and reparses the touched declarations if the tree is available.
+// It returns whether the whole text needs to be parsed.
+func (d *document) applyChange(change TextDocumentContentChangeEvent) (needParse bool) {
+	if change.Range == nil {
+		d.setText(change.Text)
+		d.tree = nil
+		return true
+	}
+	start, end := d.offset(change.Range.Start), d.offset(change.Range.End)
+	if end < start {
+		start, end = end, start
+	}
+	d.setText(d.text[:start] + change.Text + d.text[end:])
+	if d.tree == nil {
+		return true
+	}
+	if err := d.tree.Reparse(thrifter.TextEdit{Start: start, End: end, NewText: change.Text}); err != nil {
+		d.tree, d.err = nil, err
+		return false
+	}
+	d.err = nil
+	return false
+}
+
+// offset converts LSP position into byte offset of text, positions out of range are clamped.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset := d.lines[pos.Line]
+	for units := 0; units < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		units += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+// position converts byte offset of text into LSP position.
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lines), func(i int) bool {
+		return d.lines[i] > offset
+	}) - 1
+	return Position{
+		Line:      line,
+		Character: utf16Count(d.text[d.lines[line]:offset]),
+	}
+}
+
+// tokenPosition computes LSP position of tok by tokens before it in the same line, so it works for files not opened by client.
+func tokenPosition(tok *thrifter.Token) Position {
+	character := 0
+	for prev := tok.Prev; prev != nil; prev = prev.Prev {
+		if idx := strings.LastIndexByte(prev.Raw, '\n'); idx >= 0 {
+			character += utf16Count(prev.Raw[idx+1:])
+			break
+		}
+		character += utf16Count(prev.Raw)
+	}
+	return Position{Line: tok.Pos.Line - 1, Character: character}
+}
+
+// tokenRange returns range from start of start token to end of end token.
+func tokenRange(start *thrifter.Token, end *thrifter.Token) Range {
+	endPos := tokenPosition(end)
+	if idx := strings.LastIndexByte(end.Raw, '\n'); idx >= 0 {
+		endPos.Line += strings.Count(end.Raw, "\n")
+		endPos.Character = utf16Count(end.Raw[idx+1:])
+	} else {
+		endPos.Character += utf16Count(end.Raw)
+	}
+	return Range{Start: tokenPosition(start), End: endPos}
+}
+
+func nodeRange(node thrifter.Node) Range {
+	common := node.CommonField()
+	return tokenRange(common.StartToken, common.EndToken)
+}
+
+// number of UTF-16 code units of r
+func utf16Len(r rune) int {
+	if r >= 0x10000 && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+func utf16Count(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16Len(r)
+	}
+	return n
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.Clean(filepath.FromSlash(u.Path))
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,34 @@
+// Command thrifter-lsp is a language server for thrift IDL, which speaks Language Server Protocol over stdio.
+//
+// It supports diagnostics of parse errors, go to definition, find references, hover, document symbols and formatting.
+//
+// Usage:
+//
+//	thrifter-lsp [-I dir]...
+//
+// Each -I adds a directory to search included files in, after the directory of the including file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/YYCoder/thrifter/internal/cli"
+)
+
+func main() {
+	var includeDirs cli.StringList
+	flag.Var(&includeDirs, "I", "directory to search included files, can be repeated")
+	flag.Parse()
+
+	server := NewServer(os.Stdin, os.Stdout, includeDirs...)
+	if err := server.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// exit code is 1 if client exits without shutdown request, as the spec requires
+	if !server.shutdown {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Server is a language server for thrift files, it handles messages sequentially, so no lock is needed for its states.
+type Server struct {
+	conn     *conn
+	docs     map[string]*document // uri => opened document
+	program  *thrifter.Program    // opened documents and files they include
+	shutdown bool
+}
+
+// NewServer creates a server which reads messages from r and writes messages to w, includeDirs are used to search included files.
+func NewServer(r io.Reader, w io.Writer, includeDirs ...string) *Server {
+	return &Server{
+		conn:    newConn(r, w),
+		docs:    map[string]*document{},
+		program: thrifter.NewProgram(includeDirs...),
+	}
+}
+
+// Run handles messages until exit notification is received or input is closed.
+func (s *Server) Run() error {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if respErr, ok := err.(*responseError); ok {
+			// id of a malformed message is unknown, so reply with null id
+			if err := s.conn.reply(json.RawMessage("null"), nil, respErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "" {
+			// responses are ignored since server sends no request
+			continue
+		}
+		result, err := s.handle(msg)
+		if msg.isRequest() {
+			if err := s.conn.reply(msg.ID, result, err); err != nil {
+				return err
+			}
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+	}
+}
+
+// handle handles a message, a panic fails only the message instead of killing the server.
+func (s *Server) handle(msg *message) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, &responseError{Code: codeInternalError, Message: fmt.Sprintf("%s: %v", msg.Method, r)}
+		}
+	}()
+	return s.dispatch(msg)
+}
+
+func (s *Server) dispatch(msg *message) (any, error) {
+	if s.shutdown && msg.Method != "exit" {
+		return nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"}
+	}
+	switch msg.Method {
+	case "initialize":
+		return s.initialize()
+	case "initialized", "exit":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		params := DidOpenTextDocumentParams{}
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didOpen(params)
+	case "textDocument/didChange":
+		params := DidChangeTextDocumentParams{}
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didChange(params)
+	case "textDocument/didClose":
+		params := DidCloseTextDocumentParams{}
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.didClose(params)
+	case "textDocument/definition":
+		params := TextDocumentPositionParams{}
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params)
+	case "textDocument/references":
+		params := ReferenceParams{}
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.references(params)
+	case "textDocument/hover":
+		params := TextDocumentPositionParams{}
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params)
+	case "textDocument/documentSymbol":
+		params := DocumentSymbolParams{}
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.documentSymbol(params)
+	case "textDocument/formatting":
+		params := DocumentFormattingParams{}
+		if err := decodeParams(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.formatting(params)
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+func decodeParams(raw json.RawMessage, v any) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize() (any, error) {
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync: TextDocumentSyncOptions{
+				OpenClose: true,
+				Change:    SyncIncremental,
+			},
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			HoverProvider:              true,
+			DocumentSymbolProvider:     true,
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: &ServerInfo{Name: "thrifter-lsp"},
+	}, nil
+}
+
+func (s *Server) didOpen(params DidOpenTextDocumentParams) error {
+	item := params.TextDocument
+	doc := newDocument(item.URI, item.Version, item.Text)
+	doc.parse()
+	s.docs[item.URI] = doc
+	s.updateProgram(doc)
+	return s.publishDiagnostics(doc)
+}
+
+func (s *Server) didChange(params DidChangeTextDocumentParams) error {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	needParse := false
+	for _, change := range params.ContentChanges {
+		if doc.applyChange(change) {
+			needParse = true
+		}
+	}
+	if needParse {
+		doc.parse()
+	}
+	doc.version = params.TextDocument.Version
+	s.updateProgram(doc)
+	return s.publishDiagnostics(doc)
+}
+
+func (s *Server) didClose(params DidCloseTextDocumentParams) error {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+	delete(s.docs, doc.uri)
+	// fall back to the content on disk, which may be included by other files
+	delete(s.program.Files, doc.path)
+	s.program.Load(doc.path)
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// add the latest tree of document into program, errors of included files are ignored since they are not diagnostics of the document
+func (s *Server) updateProgram(doc *document) {
+	if doc.tree != nil {
+		s.program.Add(doc.tree)
+	}
+}
+
+func (s *Server) publishDiagnostics(doc *document) error {
+	diagnostics := []Diagnostic{}
+	if doc.err != nil {
+		offset, msg := len(doc.text), doc.err.Error()
+		var parseErr *thrifter.ParseError
+		if errors.As(doc.err, &parseErr) {
+			offset, msg = parseErr.Pos.Offset, parseErr.Msg
+		}
+		end := offset
+		if end < len(doc.text) && doc.text[end] != '\n' {
+			end++
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{Start: doc.position(offset), End: doc.position(end)},
+			Severity: SeverityError,
+			Source:   "thrifter",
+			Message:  msg,
+		})
+	}
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Version:     doc.version,
+		Diagnostics: diagnostics,
+	})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "document not opened: " + uri}
+	}
+	return doc, nil
+}
+
+// uri of file path, uri sent by client is preferred for opened documents
+func (s *Server) uri(path string) string {
+	for _, doc := range s.docs {
+		if doc.path == path {
+			return doc.uri
+		}
+	}
+	return pathToURI(path)
+}
+
+// declarationAt returns the declaration referred or declared by identifier at pos, and the identifier token.
+func (s *Server) declarationAt(doc *document, pos Position) (thrifter.Node, *thrifter.Token) {
+	if doc.tree == nil {
+		return nil, nil
+	}
+	tok := identTokenAt(doc.tree, doc.offset(pos))
+	if tok == nil {
+		return nil, nil
+	}
+	for _, ref := range s.program.FileReferences(doc.tree) {
+		if ref.Token == tok {
+			return ref.Target, tok
+		}
+	}
+	var res thrifter.Node
+	thrifter.Inspect(doc.tree, func(node thrifter.Node) bool {
+		if res == nil && thrifter.IdentToken(node) == tok {
+			res = node
+		}
+		return res == nil
+	})
+	return res, tok
+}
+
+// identifier token containing offset, an offset right after identifier is counted too, as cursor is usually there
+func identTokenAt(file *thrifter.Thrift, offset int) (res *thrifter.Token) {
+	for tok := file.StartToken; tok != nil && tok.Start <= offset; tok = tok.Next {
+		if tok.Type != thrifter.T_IDENT {
+			continue
+		}
+		if offset < tok.End {
+			return tok
+		}
+		if offset == tok.End {
+			res = tok
+		}
+	}
+	return
+}
+
+func (s *Server) location(decl thrifter.Node) *Location {
+	file, tok := thrifter.Root(decl), thrifter.IdentToken(decl)
+	if file == nil || tok == nil {
+		return nil
+	}
+	return &Location{URI: s.uri(file.FileName), Range: tokenRange(tok, tok)}
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) (any, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	decl, _ := s.declarationAt(doc, params.Position)
+	if decl == nil {
+		return nil, nil
+	}
+	return s.location(decl), nil
+}
+
+func (s *Server) references(params ReferenceParams) (any, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	decl, _ := s.declarationAt(doc, params.Position)
+	if decl == nil {
+		return nil, nil
+	}
+	res := []Location{}
+	if params.Context.IncludeDeclaration {
+		if loc := s.location(decl); loc != nil {
+			res = append(res, *loc)
+		}
+	}
+	for _, ref := range s.program.References(decl) {
+		res = append(res, Location{URI: s.uri(ref.File.FileName), Range: tokenRange(ref.Token, ref.Token)})
+	}
+	return res, nil
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) (any, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	decl, tok := s.declarationAt(doc, params.Position)
+	if decl == nil {
+		return nil, nil
+	}
+	value := "```thrift\n" + signature(decl) + "\n```"
+	if text := decl.CommonField().Doc(); text != "" {
+		value += "\n\n" + text
+	}
+	rng := tokenRange(tok, tok)
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: value},
+		Range:    &rng,
+	}, nil
+}
+
+// source of declaration in a single line, bodies of struct, enum and service are omitted
+func signature(node thrifter.Node) string {
+	src := node.String()
+	switch node.(type) {
+	case *thrifter.Struct, *thrifter.Enum, *thrifter.Service:
+		if idx := strings.IndexByte(src, '{'); idx >= 0 {
+			src = src[:idx]
+		}
+	}
+	src = strings.Join(strings.Fields(src), " ")
+	return strings.TrimRight(src, ",;")
+}
+
+func (s *Server) documentSymbol(params DocumentSymbolParams) (any, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	res := []DocumentSymbol{}
+	if doc.tree == nil {
+		return res, nil
+	}
+	for _, node := range doc.tree.Nodes {
+		switch n := node.(type) {
+		case *thrifter.Struct:
+			symbol := newSymbol(n, n.Ident, n.StartToken.Raw, SymbolStruct)
+			for _, field := range n.Elems {
+				symbol.Children = append(symbol.Children, newSymbol(field, field.Ident, signature(field.FieldType), SymbolField))
+			}
+			res = append(res, symbol)
+		case *thrifter.Enum:
+			symbol := newSymbol(n, n.Ident, "enum", SymbolEnum)
+			for _, elem := range n.Elems {
+				symbol.Children = append(symbol.Children, newSymbol(elem, elem.Ident, "", SymbolEnumMember))
+			}
+			res = append(res, symbol)
+		case *thrifter.Service:
+			symbol := newSymbol(n, n.Ident, "service", SymbolInterface)
+			for _, function := range n.Elems {
+				symbol.Children = append(symbol.Children, newSymbol(function, function.Ident, signature(function), SymbolMethod))
+			}
+			res = append(res, symbol)
+		}
+	}
+	return res, nil
+}
+
+func newSymbol(node thrifter.Node, name string, detail string, kind int) DocumentSymbol {
+	rng := nodeRange(node)
+	selection := rng
+	if tok := thrifter.IdentToken(node); tok != nil {
+		selection = tokenRange(tok, tok)
+	}
+	return DocumentSymbol{
+		Name:           name,
+		Detail:         detail,
+		Kind:           kind,
+		Range:          rng,
+		SelectionRange: selection,
+	}
+}
+
+func (s *Server) formatting(params DocumentFormattingParams) (any, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if doc.tree == nil {
+		// source with syntax errors is left as it is
+		return nil, nil
+	}
+	indent := "\t"
+	if params.Options.InsertSpaces {
+		size := params.Options.TabSize
+		if size <= 0 {
+			size = 4
+		}
+		indent = strings.Repeat(" ", size)
+	}
+	res := []TextEdit{}
+	for _, edit := range doc.tree.Format(indent) {
+		res = append(res, TextEdit{
+			Range:   Range{Start: doc.position(edit.Start), End: doc.position(edit.End)},
+			NewText: edit.NewText,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClient talks to an in-process server through pipes.
+type testClient struct {
+	t             *testing.T
+	conn          *conn
+	id            int
+	messages      chan *message
+	notifications []*message
+	done          chan error
+}
+
+func newTestClient(t *testing.T) *testClient {
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	res := &testClient{
+		t:        t,
+		conn:     newConn(clientReader, clientWriter),
+		messages: make(chan *message, 100),
+		done:     make(chan error, 1),
+	}
+	server := NewServer(serverReader, serverWriter)
+	go func() {
+		res.done <- server.Run()
+		serverWriter.Close()
+	}()
+	// keep reading, otherwise server is blocked on writing notifications
+	go func() {
+		defer close(res.messages)
+		for {
+			msg, err := res.conn.read()
+			if err != nil {
+				return
+			}
+			res.messages <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		clientWriter.Close()
+	})
+	return res
+}
+
+func (c *testClient) receive() *message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("connection closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timeout waiting for message")
+	}
+	return nil
+}
+
+func (c *testClient) call(method string, params any, result any) error {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.t, c.id))))
+	if err := c.conn.write(&message{ID: id, Method: method, Params: mustMarshal(c.t, params)}); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		msg := c.receive()
+		if msg.Method != "" {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(msg.ID) != string(id) {
+			c.t.Fatalf("got response of [%s] want [%s]", msg.ID, id)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *testClient) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// waits for the next notification of method
+func (c *testClient) notification(method string, params any) {
+	c.t.Helper()
+	for {
+		var msg *message
+		if len(c.notifications) > 0 {
+			msg, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			msg = c.receive()
+		}
+		if msg.Method != method {
+			continue
+		}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			c.t.Fatal(err)
+		}
+		return
+	}
+}
+
+func (c *testClient) diagnostics() []Diagnostic {
+	c.t.Helper()
+	params := PublishDiagnosticsParams{}
+	c.notification("textDocument/publishDiagnostics", &params)
+	return params.Diagnostics
+}
+
+func mustMarshal(t *testing.T, v any) json.RawMessage {
+	t.Helper()
+	res, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+const testShared = `/**
+ * Color of things.
+ */
+enum Color {
+  RED
+  GREEN
+}
+`
+
+const testMain = `include "shared.thrift"
+
+// A user.
+struct User {
+  1: shared.Color color = shared.Color.RED
+    2: Name name
+}
+
+typedef string Name
+
+service UserService {
+  User get(1: Name name)
+}
+`
+
+func setup(t *testing.T) (client *testClient, mainURI string, sharedURI string) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "shared.thrift"), []byte(testShared), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mainURI, sharedURI = pathToURI(filepath.Join(dir, "main.thrift")), pathToURI(filepath.Join(dir, "shared.thrift"))
+
+	client = newTestClient(t)
+	res := InitializeResult{}
+	if err := client.call("initialize", InitializeParams{}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Capabilities.DefinitionProvider || res.Capabilities.TextDocumentSync.Change != SyncIncremental {
+		t.Errorf("unexpected capabilities %+v", res.Capabilities)
+	}
+	client.notify("initialized", struct{}{})
+	client.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: mainURI, LanguageID: "thrift", Version: 1, Text: testMain},
+	})
+	if got, want := len(client.diagnostics()), 0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	return
+}
+
+func position(params TextDocumentIdentifier, line int, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: params, Position: Position{Line: line, Character: character}}
+}
+
+func lineRange(line int, start int, end int) Range {
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+func TestServer_definition(t *testing.T) {
+	client, mainURI, sharedURI := setup(t)
+	doc := TextDocumentIdentifier{URI: mainURI}
+
+	cases := []struct {
+		pos  TextDocumentPositionParams
+		want *Location
+	}{
+		{position(doc, 4, 8), &Location{URI: sharedURI, Range: lineRange(3, 5, 10)}},
+		{position(doc, 4, 40), &Location{URI: sharedURI, Range: lineRange(4, 2, 5)}},
+		{position(doc, 11, 2), &Location{URI: mainURI, Range: lineRange(3, 7, 11)}},
+		// cursor right after identifier
+		{position(doc, 5, 11), &Location{URI: mainURI, Range: lineRange(8, 15, 19)}},
+		// declaration itself
+		{position(doc, 8, 16), &Location{URI: mainURI, Range: lineRange(8, 15, 19)}},
+		{position(doc, 1, 0), nil},
+	}
+	for _, c := range cases {
+		var got *Location
+		if err := client.call("textDocument/definition", c.pos, &got); err != nil {
+			t.Fatal(err)
+		}
+		if c.want == nil {
+			if got != nil {
+				t.Errorf("%v: got [%v] want [nil]", c.pos.Position, got)
+			}
+			continue
+		}
+		if got == nil || *got != *c.want {
+			t.Errorf("%v: got [%v] want [%v]", c.pos.Position, got, c.want)
+		}
+	}
+}
+
+func TestServer_references(t *testing.T) {
+	client, mainURI, _ := setup(t)
+
+	var got []Location
+	params := ReferenceParams{
+		TextDocumentPositionParams: position(TextDocumentIdentifier{URI: mainURI}, 8, 16),
+		Context:                    ReferenceContext{IncludeDeclaration: true},
+	}
+	if err := client.call("textDocument/references", params, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []Range{lineRange(8, 15, 19), lineRange(5, 7, 11), lineRange(11, 14, 18)}
+	if len(got) != len(want) {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	for i := range want {
+		if got[i].URI != mainURI || got[i].Range != want[i] {
+			t.Errorf("got [%v] want [%v]", got[i], want[i])
+		}
+	}
+}
+
+func TestServer_hover(t *testing.T) {
+	client, mainURI, _ := setup(t)
+	doc := TextDocumentIdentifier{URI: mainURI}
+
+	cases := []struct {
+		pos  TextDocumentPositionParams
+		want string
+	}{
+		{position(doc, 4, 12), "```thrift\nenum Color\n```\n\nColor of things."},
+		{position(doc, 3, 8), "```thrift\nstruct User\n```\n\nA user."},
+		{position(doc, 11, 8), "```thrift\nUser get(1: Name name)\n```"},
+	}
+	for _, c := range cases {
+		got := Hover{}
+		if err := client.call("textDocument/hover", c.pos, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Contents.Value != c.want {
+			t.Errorf("got [%q] want [%q]", got.Contents.Value, c.want)
+		}
+	}
+}
+
+func TestServer_documentSymbol(t *testing.T) {
+	client, mainURI, _ := setup(t)
+
+	var got []DocumentSymbol
+	if err := client.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: mainURI}}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got [%v] want 2 symbols", got)
+	}
+	user, service := got[0], got[1]
+	if user.Name != "User" || user.Kind != SymbolStruct || user.Range != (Range{Start: Position{3, 0}, End: Position{6, 1}}) || user.SelectionRange != lineRange(3, 7, 11) {
+		t.Errorf("got [%+v]", user)
+	}
+	if len(user.Children) != 2 || user.Children[0].Name != "color" || user.Children[0].Detail != "shared.Color" {
+		t.Errorf("got [%+v]", user.Children)
+	}
+	if service.Name != "UserService" || service.Kind != SymbolInterface || len(service.Children) != 1 || service.Children[0].Name != "get" {
+		t.Errorf("got [%+v]", service)
+	}
+}
+
+func TestServer_formatting(t *testing.T) {
+	client, mainURI, _ := setup(t)
+
+	var got []TextEdit
+	params := DocumentFormattingParams{
+		TextDocument: TextDocumentIdentifier{URI: mainURI},
+		Options:      FormattingOptions{TabSize: 2, InsertSpaces: true},
+	}
+	if err := client.call("textDocument/formatting", params, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := TextEdit{Range: lineRange(5, 0, 4), NewText: "  "}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestServer_didChange(t *testing.T) {
+	client, mainURI, _ := setup(t)
+	doc := TextDocumentIdentifier{URI: mainURI}
+	change := func(version int, rng Range, text string) []Diagnostic {
+		client.notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   VersionedTextDocumentIdentifier{URI: mainURI, Version: version},
+			ContentChanges: []TextDocumentContentChangeEvent{{Range: &rng, Text: text}},
+		})
+		return client.diagnostics()
+	}
+
+	// rename a field, which is reparsed incrementally
+	if got := change(2, lineRange(5, 12, 16), "nick"); len(got) != 0 {
+		t.Errorf("got [%v] want no diagnostics", got)
+	}
+	var symbols []DocumentSymbol
+	if err := client.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: doc}, &symbols); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := symbols[0].Children[1].Name, "nick"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	// syntax error
+	got := change(3, lineRange(5, 4, 4), "!")
+	if len(got) != 1 {
+		t.Fatalf("got [%v] want 1 diagnostic", got)
+	}
+	if got[0].Range.Start != (Position{Line: 5, Character: 4}) || got[0].Severity != SeverityError {
+		t.Errorf("got [%+v]", got[0])
+	}
+
+	// fix it
+	if got := change(4, lineRange(5, 4, 5), ""); len(got) != 0 {
+		t.Errorf("got [%v] want no diagnostics", got)
+	}
+	var loc *Location
+	if err := client.call("textDocument/definition", position(doc, 11, 2), &loc); err != nil {
+		t.Fatal(err)
+	}
+	if loc == nil || loc.Range != lineRange(3, 7, 11) {
+		t.Errorf("got [%v]", loc)
+	}
+}
+
+func TestServer_incompleteInput(t *testing.T) {
+	client, mainURI, _ := setup(t)
+	for i, text := range []string{"struct {", "struct A {} (", "enum E { A (", "struct A { 1: i32 a (x", "const i32 A = ["} {
+		client.notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   VersionedTextDocumentIdentifier{URI: mainURI, Version: i + 2},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: text}},
+		})
+		if got := client.diagnostics(); len(got) != 1 {
+			t.Errorf("%q: got [%v] want 1 diagnostic", text, got)
+		}
+	}
+}
+
+func TestServer_panic(t *testing.T) {
+	server := NewServer(strings.NewReader(""), io.Discard)
+	server.docs = nil // didOpen panics on writing the nil map
+	params := mustMarshal(t, DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: "file:///a.thrift", Text: "struct A {}"}})
+	_, err := server.handle(&message{Method: "textDocument/didOpen", Params: params})
+	if respErr, ok := err.(*responseError); !ok || respErr.Code != codeInternalError {
+		t.Errorf("got [%v] want internal error", err)
+	}
+}
+
+func TestServer_shutdown(t *testing.T) {
+	client, _, _ := setup(t)
+
+	if err := client.call("textDocument/unknown", struct{}{}, nil); err == nil || err.(*responseError).Code != codeMethodNotFound {
+		t.Errorf("got [%v] want method not found", err)
+	}
+	if err := client.call("shutdown", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.call("textDocument/hover", struct{}{}, nil); err == nil {
+		t.Errorf("expect error after shutdown")
+	}
+	client.notify("exit", nil)
+	select {
+	case err := <-client.done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("timeout waiting for server to exit")
+	}
+}
@@ -0,0 +1,92 @@
+// Command thrifter-merge merges thrift files in three ways on their ASTs, it can be used as a git merge driver.
+//
+// Usage:
+//
+//	thrifter-merge [-o output] [-L ours-label] [-L theirs-label] base ours theirs
+//
+// The result is written to ours unless -o is given, "-o -" writes it to stdout. Files which can't be parsed are merged line by line instead,
+// like git merge-file. Exit code is 0 for a clean merge, 1 if there are conflicts, and 2 if files can't be read, in which case nothing is written.
+//
+// To use it as a git merge driver, add to .gitattributes:
+//
+//	*.thrift merge=thrifter
+//
+// and to git config:
+//
+//	[merge "thrifter"]
+//		name = thrift AST merge
+//		driver = thrifter-merge -L ours -L theirs %O %A %B
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/YYCoder/thrifter/internal/cli"
+	"github.com/YYCoder/thrifter/merge"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("thrifter-merge", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "output file, ours by default, - for stdout")
+	var labels cli.StringList
+	flags.Var(&labels, "L", "label of conflict markers, the first one is for ours and the second one is for theirs")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 3 {
+		fmt.Fprintln(stderr, "usage: thrifter-merge [flags] base ours theirs")
+		flags.PrintDefaults()
+		return 2
+	}
+
+	var srcs [3][]byte
+	for i, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		srcs[i] = src
+	}
+	opts := merge.Options{}
+	if len(labels) > 0 {
+		opts.OursLabel = labels[0]
+	}
+	if len(labels) > 1 {
+		opts.TheirsLabel = labels[1]
+	}
+	res, err := merge.MergeBytes(srcs[0], srcs[1], srcs[2], opts)
+	if err != nil {
+		// keep changes of both sides with conflict markers, rather than leaving ours as the result
+		fmt.Fprintf(stderr, "%v, merging line by line\n", err)
+		res = merge.MergeLines(string(srcs[0]), string(srcs[1]), string(srcs[2]), opts)
+	}
+
+	switch *output {
+	case "-":
+		_, err = io.WriteString(stdout, res.Text)
+	case "":
+		err = os.WriteFile(flags.Arg(1), []byte(res.Text), 0o644)
+	default:
+		err = os.WriteFile(*output, []byte(res.Text), 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	for _, conflict := range res.Conflicts {
+		fmt.Fprintf(stderr, "conflict: %s\n", conflict.Key)
+	}
+	if !res.Clean() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base.thrift", "struct A {\n  1: i32 a\n}\n")
+	ours := write("ours.thrift", "struct A {\n  1: i32 a\n  2: i32 b\n}\n")
+	theirs := write("theirs.thrift", "struct A {\n  1: i32 a\n}\n\nstruct B {}\n")
+
+	var stdout, stderr bytes.Buffer
+	if got := run([]string{"-o", "-", base, ours, theirs}, &stdout, &stderr); got != 0 {
+		t.Errorf("got [%v] want [0], stderr: %s", got, stderr.String())
+	}
+	if got, want := stdout.String(), "struct A {\n  1: i32 a\n  2: i32 b\n}\n\nstruct B {}\n"; got != want {
+		t.Errorf("got [%q] want [%q]", got, want)
+	}
+
+	// as a merge driver, result is written to ours
+	conflicting := write("conflicting.thrift", "struct A {\n  1: i64 a\n}\n")
+	other := write("other.thrift", "struct A {\n  1: string a\n}\n")
+	if got := run([]string{"-L", "HEAD", "-L", "feature", base, conflicting, other}, &stdout, &stderr); got != 1 {
+		t.Errorf("got [%v] want [1], stderr: %s", got, stderr.String())
+	}
+	res, err := os.ReadFile(conflicting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(res, []byte("<<<<<<< HEAD\n  1: i64 a\n=======\n  1: string a\n>>>>>>> feature\n")) {
+		t.Errorf("got [%s]", res)
+	}
+
+	// unparsable sides are merged line by line
+	stdout.Reset()
+	invalid := write("invalid.thrift", "struct A {{\n  1: i32 a\n}\n")
+	if got := run([]string{"-o", "-", base, invalid, theirs}, &stdout, &stderr); got != 0 {
+		t.Errorf("got [%v] want [0], stderr: %s", got, stderr.String())
+	}
+	if got, want := stdout.String(), "struct A {{\n  1: i32 a\n}\n\nstruct B {}\n"; got != want {
+		t.Errorf("got [%q] want [%q]", got, want)
+	}
+	broken := write("broken.thrift", "struct A {\n  1: i32 a\n}\n\nstruct C {\n")
+	if got := run([]string{base, broken, theirs}, &stdout, &stderr); got != 1 {
+		t.Errorf("got [%v] want [1], stderr: %s", got, stderr.String())
+	}
+	res, err = os.ReadFile(broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "struct A {\n  1: i32 a\n}\n\n<<<<<<< ours\nstruct C {\n=======\nstruct B {}\n>>>>>>> theirs\n"; string(res) != want {
+		t.Errorf("got [%q] want [%q]", res, want)
+	}
+
+	if got := run([]string{base, filepath.Join(dir, "missing.thrift"), theirs}, &stdout, &stderr); got != 2 {
+		t.Errorf("got [%v] want [2]", got)
+	}
+	if got := run([]string{base, ours}, &stdout, &stderr); got != 2 {
+		t.Errorf("got [%v] want [2]", got)
+	}
+}
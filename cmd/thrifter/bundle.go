@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/bundle"
+	"github.com/YYCoder/thrifter/internal/cli"
+)
+
+// runBundle prints a file with declarations of files it includes inlined, or writes it to -o.
+func runBundle(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("bundle", stderr)
+	var includeDirs cli.StringList
+	flags.Var(&includeDirs, "I", "directory to search included files in, can be repeated")
+	output := flags.String("o", "", "write the bundled file to this path instead of stdout")
+	keepUnused := flags.Bool("keep-unused", false, "keep declarations of included files which are not used")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	program := thrifter.NewProgram(includeDirs...)
+	root, err := program.Load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	res, err := bundle.Bundle(program, root, bundle.Options{KeepUnused: *keepUnused})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *output == "" {
+		io.WriteString(stdout, res.File.String())
+		return 0
+	}
+	if err := os.WriteFile(*output, []byte(res.File.String()), 0o644); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	return 0
+}
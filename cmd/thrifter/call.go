@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/dynamic"
+	"github.com/YYCoder/thrifter/internal/cli"
+)
+
+var protocols = map[string]dynamic.Protocol{
+	"binary":  dynamic.BinaryProtocol{},
+	"compact": dynamic.CompactProtocol{},
+	"json":    dynamic.JSONProtocol{},
+}
+
+// runCall calls a function of service at an address with args in JSON, and prints its result or exception in JSON.
+// It exits with 1 if the function throws, or the server fails with an application exception.
+func runCall(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("call", stderr)
+	var includeDirs cli.StringList
+	flags.Var(&includeDirs, "I", "directory to search included files in, can be repeated")
+	idl := flags.String("idl", "", "file declaring the service, required")
+	protocolName := flags.String("protocol", "binary", "protocol, one of binary, compact and json")
+	framed := flags.Bool("framed", false, "use the framed transport")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of the call including connecting")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	protocol, ok := protocols[*protocolName]
+	if *idl == "" || !ok || flags.NArg() < 2 || flags.NArg() > 3 {
+		flags.Usage()
+		return 2
+	}
+	addr, method := flags.Arg(0), flags.Arg(1)
+	input := []byte("{}")
+	switch flags.Arg(2) {
+	case "":
+	case "-":
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		input = data
+	default:
+		input = []byte(flags.Arg(2))
+	}
+
+	program := thrifter.NewProgram(includeDirs...)
+	file, err := program.Load(*idl)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	codec := dynamic.NewCodec(program)
+	fn, err := lookupFunction(program, codec, file, method)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	callArgs, err := codec.DecodeSimpleJSON(dynamic.ArgsStruct(fn), input)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid args: %v\n", err)
+		return 2
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, *timeout)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(*timeout))
+	result, err := codec.NewClient(conn, protocol, *framed).Call(fn, callArgs)
+	var exception *dynamic.Exception
+	var appErr *dynamic.ApplicationError
+	switch {
+	case errors.As(err, &appErr):
+		fmt.Fprintln(stderr, err)
+		return 1
+	case errors.As(err, &exception):
+		// printed like the result struct of generated code, keyed by the name in throws
+		data, err := codec.EncodeSimpleJSON(dynamic.ResultStruct(fn), map[string]any{exception.Field: exception.Value})
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		printJSON(stdout, data)
+		fmt.Fprintf(stderr, "%s threw %s\n", fn.Ident, exception.Type)
+		return 1
+	case err != nil:
+		fmt.Fprintln(stderr, err)
+		return 2
+	case fn.Oneway:
+		return 0
+	case fn.Void:
+		fmt.Fprintln(stdout, "null")
+		return 0
+	}
+	data, err := codec.EncodeSimpleJSON(dynamic.ResultStruct(fn), map[string]any{"success": result})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	printJSON(stdout, fields["success"])
+	return 0
+}
+
+// lookupFunction finds a function by Service.method, the service is resolved in file, so it may be prefixed by an include.
+func lookupFunction(program *thrifter.Program, codec *dynamic.Codec, file *thrifter.Thrift, method string) (*thrifter.Function, error) {
+	dot := strings.LastIndexByte(method, '.')
+	if dot < 0 {
+		return nil, fmt.Errorf("expected Service.method, got %s", method)
+	}
+	decl, _ := program.Resolve(file, method[:dot])
+	service, ok := decl.(*thrifter.Service)
+	if !ok {
+		return nil, fmt.Errorf("service %s not found", method[:dot])
+	}
+	fn, err := codec.Function(service, method[dot+1:])
+	if err == nil && fn == nil {
+		err = fmt.Errorf("service %s has no function %s", service.Ident, method[dot+1:])
+	}
+	return fn, err
+}
+
+// printJSON prints data indented
+func printJSON(w io.Writer, data []byte) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		buf.Reset()
+		buf.Write(data)
+	}
+	buf.WriteByte('\n')
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/ast"
+	"github.com/YYCoder/thrifter/internal/yaml"
+)
+
+// formats of convert by file extensions
+var convertFormats = map[string]string{
+	".thrift": "thrift",
+	".json":   "json",
+	".yaml":   "yaml",
+	".yml":    "yaml",
+}
+
+// runConvert converts a file or stdin between thrift source and the json or yaml form of package ast.
+func runConvert(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("convert", stderr)
+	from := flags.String("from", "auto", "format of input, one of auto, thrift, json and yaml, auto detects it by extension or content")
+	to := flags.String("to", "json", "format of output, one of thrift, json and yaml")
+	output := flags.String("o", "", "write the result to this path instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 || *from != "auto" && !isConvertFormat(*from) || !isConvertFormat(*to) {
+		flags.Usage()
+		return 2
+	}
+	sources, err := readSources(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if len(sources) != 1 {
+		flags.Usage()
+		return 2
+	}
+	s := sources[0]
+	if *from == "auto" {
+		*from = detectFormat(s)
+	}
+
+	var file *thrifter.Thrift
+	switch *from {
+	case "thrift":
+		file, err = s.parse()
+	case "json":
+		file, err = ast.Unmarshal(s.src)
+	case "yaml":
+		var data []byte
+		if data, err = yaml.ToJSON(s.src); err == nil {
+			file, err = ast.Unmarshal(data)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", s.name, err)
+		return 1
+	}
+
+	var data []byte
+	switch *to {
+	case "thrift":
+		data = []byte(file.String())
+	case "json":
+		if data, err = ast.Marshal(file); err == nil {
+			data = append(data, '\n')
+		}
+	case "yaml":
+		data, err = ast.MarshalYAML(file)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if *output == "" {
+		stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	return 0
+}
+
+func isConvertFormat(format string) bool {
+	for _, f := range convertFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// detectFormat detects format of s by its extension, or its content for stdin and unknown extensions:
+// json objects start with {, and yaml documents of package ast with a version key.
+func detectFormat(s source) string {
+	if format, ok := convertFormats[filepath.Ext(s.name)]; ok {
+		return format
+	}
+	content := bytes.TrimSpace(s.src)
+	switch {
+	case bytes.HasPrefix(content, []byte("{")):
+		return "json"
+	case bytes.HasPrefix(content, []byte("version:")):
+		return "yaml"
+	}
+	return "thrift"
+}
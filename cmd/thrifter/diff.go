@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/YYCoder/thrifter/diff"
+)
+
+// runDiff prints semantic differences between two files, either of which may be - for stdin, exit code is 1 if they differ.
+func runDiff(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("diff", stderr)
+	asJSON := flags.Bool("json", false, "print differences in json")
+	comments := flags.Bool("comments", false, "report changes of comments")
+	whitespace := flags.Bool("whitespace", false, "report changes of white spaces")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 || flags.Arg(0) == "-" && flags.Arg(1) == "-" {
+		flags.Usage()
+		return 2
+	}
+	var sources []source
+	for _, path := range flags.Args() {
+		s, err := readSources([]string{path})
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		if len(s) != 1 {
+			fmt.Fprintf(stderr, "%s is not a single file\n", path)
+			return 2
+		}
+		sources = append(sources, s[0])
+	}
+	old, err := sources[0].parse()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	new, err := sources[1].parse()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	entries := diff.Compare(old, new, diff.Options{Comments: *comments, Whitespace: *whitespace})
+	if *asJSON {
+		err = diff.WriteJSON(stdout, entries)
+	} else {
+		err = diff.WriteText(stdout, entries)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if len(entries) > 0 {
+		return 1
+	}
+	return 0
+}
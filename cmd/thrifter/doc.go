@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/doc"
+	"github.com/YYCoder/thrifter/internal/cli"
+)
+
+// runDoc writes documentation of files and files they include into a directory, as a static HTML site or Markdown.
+func runDoc(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("doc", stderr)
+	var includeDirs cli.StringList
+	flags.Var(&includeDirs, "I", "directory to search included files in, can be repeated")
+	output := flags.String("o", "", "directory to write pages into, required")
+	format := flags.String("format", "html", "format of pages, one of html and markdown")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	paths, err := thriftFiles(flags.Args())
+	if err != nil || len(paths) == 0 || *output == "" || *format != "html" && *format != "markdown" {
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+		flags.Usage()
+		return 2
+	}
+	program := thrifter.NewProgram(includeDirs...)
+	for _, path := range paths {
+		if _, err := program.Load(path); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	f := doc.HTML
+	if *format == "markdown" {
+		f = doc.Markdown
+	}
+	pages := doc.Generate(program, f)
+	names := make([]string, 0, len(pages))
+	for name := range pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(*output, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		if err := os.WriteFile(path, pages[name], 0o644); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		fmt.Fprintln(stdout, path)
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/ast"
+)
+
+// runDump prints the syntax tree of a file or stdin, as an indented tree by default.
+func runDump(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("dump", stderr)
+	asJSON := flags.Bool("json", false, "print the tree in json, see package ast for the format")
+	asYAML := flags.Bool("yaml", false, "print the tree in yaml, which is the same document as json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 || *asJSON && *asYAML {
+		flags.Usage()
+		return 2
+	}
+	sources, err := readSources(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if len(sources) != 1 {
+		flags.Usage()
+		return 2
+	}
+	file, err := sources[0].parse()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	var data []byte
+	switch {
+	case *asJSON:
+		data, err = ast.Marshal(file)
+		data = append(data, '\n')
+	case *asYAML:
+		data, err = ast.MarshalYAML(file)
+	default:
+		data = []byte(tree(file))
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	stdout.Write(data)
+	return 0
+}
+
+// tree prints each node on a line indented by its depth, with its NodeID or source, and position.
+func tree(file *thrifter.Thrift) string {
+	var res strings.Builder
+	thrifter.Inspect(file, func(node thrifter.Node) bool {
+		depth := 0
+		for parent := node.CommonField().Parent; parent != nil; parent = parent.CommonField().Parent {
+			depth++
+		}
+		res.WriteString(strings.Repeat("  ", depth))
+		res.WriteString(node.NodeType())
+		common := node.CommonField()
+		switch {
+		case node == file:
+			res.WriteString(" " + file.FileName)
+		case common.NodeID != "":
+			res.WriteString(" " + common.NodeID)
+		case common.StartToken != nil && common.EndToken != nil:
+			res.WriteString(" " + excerpt(node.String()))
+		}
+		if node != file && common.StartToken != nil {
+			fmt.Fprintf(&res, " %d:%d", common.StartToken.Pos.Line, common.StartToken.Pos.Column)
+		}
+		res.WriteString("\n")
+		return true
+	})
+	return res.String()
+}
+
+// quoted source of node with white spaces collapsed, long source is truncated
+func excerpt(src string) string {
+	src = strings.Join(strings.Fields(src), " ")
+	if len(src) > 40 {
+		src = src[:37] + "..."
+	}
+	return strconv.Quote(src)
+}
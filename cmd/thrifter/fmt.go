@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// runFmt prints formatted files or stdin, lists files which are not formatted with -l, or writes them in place with -w.
+func runFmt(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("fmt", stderr)
+	list := flags.Bool("l", false, "list files which are not formatted instead of printing them, exit code is 1 if any")
+	write := flags.Bool("w", false, "write formatted files in place instead of printing them")
+	indent := flags.String("indent", "  ", "indentation of a nesting level")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	sources, err := readSources(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if strings.Trim(*indent, " \t") != "" {
+		fmt.Fprintf(stderr, "indent %q is not white space\n", *indent)
+		flags.Usage()
+		return 2
+	}
+	for _, s := range sources {
+		if *write && s.name == stdinName {
+			fmt.Fprintln(stderr, "cannot write stdin in place")
+			flags.Usage()
+			return 2
+		}
+	}
+
+	code := 0
+	for _, s := range sources {
+		file, err := s.parse()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+			continue
+		}
+		edits := file.Format(*indent)
+		formatted := thrifter.ApplyEdits(string(s.src), edits)
+		switch {
+		case *list:
+			if len(edits) > 0 {
+				fmt.Fprintln(stdout, s.name)
+				code = 1
+			}
+		case *write:
+			if len(edits) == 0 {
+				continue
+			}
+			if err := os.WriteFile(s.name, []byte(formatted), 0o644); err != nil {
+				fmt.Fprintln(stderr, err)
+				return 2
+			}
+		default:
+			io.WriteString(stdout, formatted)
+		}
+	}
+	return code
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/graph"
+	"github.com/YYCoder/thrifter/internal/cli"
+)
+
+// runGraph prints the include graph of files, or the reference graph of types with -types or -service.
+func runGraph(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("graph", stderr)
+	var includeDirs cli.StringList
+	flags.Var(&includeDirs, "I", "directory to search included files in, can be repeated")
+	format := flags.String("format", "dot", "output format, one of dot, mermaid and json")
+	types := flags.Bool("types", false, "print the reference graph of types instead of the include graph of files")
+	service := flags.String("service", "", "print only types reachable from the service, implies -types")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	paths, err := thriftFiles(flags.Args())
+	if err != nil || len(paths) == 0 || *format != "dot" && *format != "mermaid" && *format != "json" {
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+		flags.Usage()
+		return 2
+	}
+	program := thrifter.NewProgram(includeDirs...)
+	for _, path := range paths {
+		if _, err := program.Load(path); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	g := graph.Includes(program)
+	if *types || *service != "" {
+		g = graph.Types(program)
+	}
+	if *service != "" {
+		var ids []string
+		for _, file := range program.Files {
+			if decl, ok := file.Declaration(*service).(*thrifter.Service); ok {
+				ids = append(ids, graph.ID(decl))
+			}
+		}
+		if len(ids) == 0 {
+			fmt.Fprintf(stderr, "service %s not found\n", *service)
+			return 2
+		}
+		g = g.Closure(ids...)
+	}
+
+	switch *format {
+	case "mermaid":
+		io.WriteString(stdout, g.Mermaid())
+	case "json":
+		data, err := g.JSON()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		stdout.Write(append(data, '\n'))
+	default:
+		io.WriteString(stdout, g.DOT())
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/internal/cli"
+	"github.com/YYCoder/thrifter/lint"
+)
+
+// runLint reports problems of files or stdin, exit code is 1 if any file is invalid or has problems.
+func runLint(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("lint", stderr)
+	var includeDirs, disabled cli.StringList
+	flags.Var(&includeDirs, "I", "directory to search included files in, can be repeated")
+	flags.Var(&disabled, "disable", "name of a rule not to run, can be repeated or separated by commas")
+	asJSON := flags.Bool("json", false, "print problems in json")
+	rules := flags.Bool("rules", false, "list rules and exit")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *rules {
+		for _, rule := range lint.Rules {
+			fmt.Fprintf(stdout, "%-16s %-8s %s\n", rule.Name, rule.Severity, rule.Doc)
+		}
+		return 0
+	}
+	opts := lint.Options{}
+	for _, names := range disabled {
+		for _, name := range strings.Split(names, ",") {
+			if !isRule(name) {
+				fmt.Fprintf(stderr, "unknown rule %q\n", name)
+				flags.Usage()
+				return 2
+			}
+			opts.Disabled = append(opts.Disabled, name)
+		}
+	}
+	sources, err := readSources(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	code := 0
+	program := thrifter.NewProgram(includeDirs...)
+	var files []*thrifter.Thrift
+	for _, s := range sources {
+		file, err := s.parse()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+			continue
+		}
+		// missing includes are reported as problems, but errors of included files are not
+		for _, inc := range file.Includes() {
+			if path, ok := program.IncludePath(file, inc); ok {
+				if _, err := program.Load(path); err != nil {
+					fmt.Fprintln(stderr, err)
+					code = 1
+				}
+			}
+		}
+		program.Add(file)
+		files = append(files, file)
+	}
+	problems := []lint.Problem{}
+	for _, file := range files {
+		problems = append(problems, lint.Lint(program, file, opts)...)
+	}
+	if len(problems) > 0 {
+		code = 1
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(problems); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		return code
+	}
+	for _, p := range problems {
+		fmt.Fprintln(stdout, p)
+	}
+	return code
+}
+
+func isRule(name string) bool {
+	for _, rule := range lint.Rules {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+// Command thrifter is a toolbox for thrift definitions.
+//
+// Usage:
+//
+//	thrifter <command> [flags] [arguments]
+//
+// Run thrifter help to list commands, and thrifter <command> -h for flags of a command.
+//
+// Commands exit with 0 on success, 1 if they found something, e.g. invalid files, lint problems, differences or unformatted files, and 2 on usage or IO errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+type command struct {
+	name    string
+	usage   string // arguments after flags
+	summary string
+	run     func(args []string, stdout io.Writer, stderr io.Writer) int
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"parse", "[path|-]...", "check that files parse, and report syntax errors", runParse},
+		{"fmt", "[path|-]...", "normalize indentation and blank lines of files", runFmt},
+		{"lint", "[path|-]...", "report problems of files which parse, e.g. duplicate field ids or unresolved types", runLint},
+		{"tokens", "[path|-]...", "print the token chain of files with positions, for debugging", runTokens},
+		{"diff", "old new", "print semantic differences of declarations between two files", runDiff},
+		{"convert", "[path|-]", "convert a file between thrift source and its json or yaml form", runConvert},
+		{"bundle", "path", "inline declarations of included files into a single file", runBundle},
+		{"dump", "[path|-]", "print the syntax tree of a file, or its json or yaml form", runDump},
+		{"unused", "path...", "report declarations no service reaches and unused includes, or remove them", runUnused},
+		{"graph", "path...", "print the include graph of files or the reference graph of types, in dot, mermaid or json", runGraph},
+		{"doc", "path...", "generate documentation of files as a static html site or markdown", runDoc},
+		{"call", "host:port Service.method [args|-]", "call a function with args in json over tcp, and print its result in json", runCall},
+		{"mock", "", "serve all functions of a service with canned or random results, and log calls", runMock},
+		{"sample", "path Struct", "print random values of a struct in json, e.g. as fixtures of tests", runSample},
+		{"query", "selector path...", "print nodes matching a selector, e.g. 'struct > field[requiredness=optional]'", runQuery},
+	}
+}
+
+// stdin is read by commands given no paths or -, replaced in tests
+var stdin io.Reader = os.Stdin
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(stdout)
+		return 0
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdout, stderr)
+		}
+	}
+	fmt.Fprintf(stderr, "thrifter: unknown command %q\n", args[0])
+	printUsage(stderr)
+	return 2
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: thrifter <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nexit status is 0 on success, 1 if a command found something, e.g. invalid files, lint problems,")
+	fmt.Fprintln(w, "differences or unformatted files, and 2 on usage or IO errors")
+}
+
+// thriftFiles expands glob patterns in paths, and directories to .thrift files under them recursively, files are kept as is.
+func thriftFiles(paths []string) (res []string, err error) {
+	var expanded []string
+	for _, path := range paths {
+		if !strings.ContainsAny(path, "*?[") {
+			expanded = append(expanded, path)
+			continue
+		}
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", path)
+		}
+		expanded = append(expanded, matches...)
+	}
+	for _, path := range expanded {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			res = append(res, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && filepath.Ext(p) == ".thrift" {
+				res = append(res, p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// stdinName names the source read from stdin in positions
+const stdinName = "<stdin>"
+
+type source struct {
+	name string
+	src  []byte
+}
+
+// readSources reads files of paths as thriftFiles expands them, and stdin for - or no paths at all.
+func readSources(paths []string) (res []source, err error) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	for _, path := range paths {
+		if path == "-" {
+			src, err := io.ReadAll(stdin)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, source{stdinName, src})
+			continue
+		}
+		files, err := thriftFiles([]string{path})
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, source{file, src})
+		}
+	}
+	return
+}
+
+func (s source) parse() (*thrifter.Thrift, error) {
+	return thrifter.NewParserBytes(s.src, false).Parse(s.name)
+}
+
+func parseFile(path string) (*thrifter.Thrift, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return source{path, src}.parse()
+}
+
+// newFlagSet creates flags of command name, whose usage is printed on errors.
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	var cmd *command
+	for _, c := range commands {
+		if c.name == name {
+			cmd = c
+		}
+	}
+	flags := flag.NewFlagSet("thrifter "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: thrifter %s [flags] %s\n\n%s\n\nflags:\n", cmd.name, cmd.usage, cmd.summary)
+		flags.PrintDefaults()
+	}
+	return flags
+}
@@ -0,0 +1,749 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/ast"
+	"github.com/YYCoder/thrifter/dynamic"
+	"github.com/YYCoder/thrifter/graph"
+)
+
+// writes files into a temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// setStdin makes commands read content as stdin until the test ends
+func setStdin(t *testing.T, content string) {
+	t.Helper()
+	orig := stdin
+	stdin = strings.NewReader(content)
+	t.Cleanup(func() { stdin = orig })
+}
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		args []string
+		code int
+	}{
+		{nil, 2},
+		{[]string{"help"}, 0},
+		{[]string{"unknown"}, 2},
+	}
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		if got := run(c.args, &stdout, &stderr); got != c.code {
+			t.Errorf("%v: got [%v] want [%v], stderr: %s", c.args, got, c.code, stderr.String())
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"user.thrift":        "struct User {\n  1: optional i64 user_id\n  2: string name\n}\n",
+		"nested/item.thrift": "struct Item {\n  1: optional i64 item_id\n}\n",
+		"broken.txt":         "struct {",
+	})
+	cases := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{"field[name$=_id]", filepath.Join(dir, "user.thrift")}, 0, filepath.Join(dir, "user.thrift") + ":2:3: Struct(User).Field(1): 1: optional i64 user_id\n"},
+		{[]string{"field[name=email]", filepath.Join(dir, "user.thrift")}, 1, ""},
+		{[]string{"field[", filepath.Join(dir, "user.thrift")}, 2, ""},
+		{[]string{"field", filepath.Join(dir, "broken.txt")}, 2, ""},
+		{[]string{"field"}, 2, ""},
+	}
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		if got := run(append([]string{"query"}, c.args...), &stdout, &stderr); got != c.code {
+			t.Errorf("%v: got [%v] want [%v], stderr: %s", c.args, got, c.code, stderr.String())
+		}
+		if got := stdout.String(); got != c.want {
+			t.Errorf("%v: got [%v] want [%v]", c.args, got, c.want)
+		}
+	}
+
+	// directories are searched recursively
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"query", "-json", "field[requiredness=optional]", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	var matches []match
+	if err := json.Unmarshal(stdout.Bytes(), &matches); err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[0].NodeID != "Struct(Item).Field(1)" || matches[0].Line != 2 || matches[1].File != filepath.Join(dir, "user.thrift") {
+		t.Errorf("got %+v", matches)
+	}
+}
+
+func TestDump(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"user.thrift": "struct User {\n  1: i64 id\n}\n"})
+	path := filepath.Join(dir, "user.thrift")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"dump", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	want := "Thrift " + path + "\n  Struct Struct(User) 1:1\n    Field Struct(User).Field(1) 2:3\n      FieldType \"i64\" 2:6\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"dump", "-json", path}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	file, err := ast.Unmarshal(stdout.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := file.String(), "struct User {\n  1: i64 id\n}\n"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	stdout.Reset()
+	setStdin(t, "enum Color {\n  RED\n}\n")
+	if code := run([]string{"dump"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), "Thrift <stdin>\n  Enum Enum(Color) 1:1\n    EnumElement Enum(Color).EnumElement(RED) 2:3\n"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	for _, args := range [][]string{{"dump", path, path}, {"dump", "-json", "-yaml", path}, {"dump", filepath.Join(dir, "missing.thrift")}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: got [%v] want [2]", args, code)
+		}
+	}
+}
+
+func TestBundle(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.thrift":            "include \"shared/user.thrift\"\n\nstruct Order {\n  1: user.User buyer\n}\n",
+		"inc/shared/user.thrift": "struct User {\n  1: i64 id\n}\n\nstruct Unused {\n}\n",
+	})
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"bundle", "-I", filepath.Join(dir, "inc"), filepath.Join(dir, "main.thrift")}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	want := "struct User {\n  1: i64 id\n}\n\nstruct Order {\n  1: User buyer\n}\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	// included file is not found without -I
+	if code := run([]string{"bundle", filepath.Join(dir, "main.thrift")}, &stdout, &stderr); code != 2 {
+		t.Errorf("got [%v] want [2]", code)
+	}
+}
+
+func TestUnused(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.thrift":   "include \"shared.thrift\"\n\nstruct Old {\n  1: shared.User user\n}\n\nservice S {\n  void ping()\n}\n\nservice T {\n  void ping()\n}\n",
+		"shared.thrift": "struct User {\n  1: i64 id\n}\n",
+	})
+	main, shared := filepath.Join(dir, "main.thrift"), filepath.Join(dir, "shared.thrift")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"unused", dir}, &stdout, &stderr); code != 1 {
+		t.Fatalf("got [%v] want [1], stderr: %s", code, stderr.String())
+	}
+	want := main + ":1:1: unused Include(shared.thrift)\n" + main + ":3:1: unused Struct(Old)\n" + shared + ":1:1: unused Struct(User)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"unused", "-services", "-root", "S", dir}, &stdout, &stderr); code != 1 {
+		t.Fatalf("got [%v] want [1], stderr: %s", code, stderr.String())
+	}
+	want = main + ":1:1: unused Include(shared.thrift)\n" + main + ":3:1: unused Struct(Old)\n" + main + ":11:1: unused Service(T)\n" + shared + ":1:1: unused Struct(User)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	if code := run([]string{"unused", "-services", "-root", "Missing", dir}, &stdout, &stderr); code != 2 {
+		t.Errorf("got [%v] want [2]", code)
+	}
+	// S is declared in both files
+	other := filepath.Join(dir, "other.thrift")
+	if err := os.WriteFile(other, []byte("service S {\n  void ping()\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stderr.Reset()
+	if code := run([]string{"unused", "-services", "-root", "S", dir}, &stdout, &stderr); code != 2 {
+		t.Errorf("got [%v] want [2]", code)
+	}
+	if got, want := stderr.String(), "service S is declared in "+main+", "+other+", use file#S to choose one\n"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	stdout.Reset()
+	if code := run([]string{"unused", "-services", "-root", "main.thrift#S", dir}, &stdout, &stderr); code != 1 {
+		t.Fatalf("got [%v] want [1], stderr: %s", code, stderr.String())
+	}
+	want = main + ":1:1: unused Include(shared.thrift)\n" + main + ":3:1: unused Struct(Old)\n" + main + ":11:1: unused Service(T)\n" + other + ":1:1: unused Service(S)\n" + shared + ":1:1: unused Struct(User)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if err := os.Remove(other); err != nil {
+		t.Fatal(err)
+	}
+
+	// other services are kept without -services
+	stdout.Reset()
+	if code := run([]string{"unused", "-root", "S", dir}, &stdout, &stderr); code != 1 {
+		t.Fatalf("got [%v] want [1], stderr: %s", code, stderr.String())
+	}
+	want = main + ":1:1: unused Include(shared.thrift)\n" + main + ":3:1: unused Struct(Old)\n" + shared + ":1:1: unused Struct(User)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	if code := run([]string{"unused", "-w", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	data, err := os.ReadFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "service S {\n  void ping()\n}\n\nservice T {\n  void ping()\n}\n"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	stdout.Reset()
+	if code := run([]string{"unused", dir}, &stdout, &stderr); code != 0 {
+		t.Errorf("got [%v] want [0], stdout: %s", code, stdout.String())
+	}
+}
+
+func TestGraph(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.thrift":   "include \"shared.thrift\"\n\nservice S {\n  shared.User get()\n}\n\nservice T {\n  void ping()\n}\n",
+		"shared.thrift": "struct User {\n  1: i64 id\n}\n",
+	})
+	main := filepath.Join(dir, "main.thrift")
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"graph", "-format", "mermaid", main}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	want := "flowchart LR\n  n0[\"main.thrift\"]\n  n1[\"shared.thrift\"]\n  n0 --> n1\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"graph", "-format", "json", "-service", "S", main}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	var g graph.Graph
+	if err := json.Unmarshal(stdout.Bytes(), &g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Nodes) != 3 || len(g.Edges) != 2 || g.Edges[0].Label != "returns" {
+		t.Errorf("got %s", stdout.String())
+	}
+
+	for _, args := range [][]string{{"graph"}, {"graph", "-format", "svg", main}, {"graph", "-service", "Missing", main}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: got [%v] want [2]", args, code)
+		}
+	}
+}
+
+// loopback serves calls of Greeter in unframed binary protocol on a local address until the test ends
+func loopback(t *testing.T, path string, handler dynamic.Handler) string {
+	t.Helper()
+	program := thrifter.NewProgram()
+	file, err := program.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := mockService(program, file, "Greeter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := dynamic.NewCodec(program).NewServer(service, dynamic.BinaryProtocol{}, false, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go server.Serve(listener)
+	return listener.Addr().String()
+}
+
+func TestCall(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"greeter.thrift": "include \"base.thrift\"\n\nexception Rejected {\n  1: string reason\n}\n\n" +
+			"service Greeter extends base.Base {\n  map<string, i32> greet(1: string name, 2: list<string> tags) throws (1: Rejected rejected)\n}\n",
+		"base.thrift": "service Base {\n  i64 ping()\n}\n",
+	})
+	path := filepath.Join(dir, "greeter.thrift")
+	addr := loopback(t, path, func(fn *thrifter.Function, args map[string]any) (any, error) {
+		switch {
+		case fn.Ident == "ping":
+			return int64(1), nil
+		case args["name"] == "":
+			return nil, &dynamic.Exception{Field: "rejected", Value: map[string]any{"reason": "empty"}}
+		}
+		return map[any]any{args["name"]: int32(len(args["tags"].([]any)))}, nil
+	})
+
+	cases := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{addr, "Greeter.greet", `{"name":"a","tags":["x","y"]}`}, 0, "{\n  \"a\": 2\n}\n"},
+		{[]string{addr, "Greeter.ping"}, 0, "1\n"},
+		{[]string{addr, "base.Base.ping"}, 0, "1\n"},
+		{[]string{addr, "Greeter.greet", `{"name":""}`}, 1, "{\n  \"rejected\": {\n    \"reason\": \"empty\"\n  }\n}\n"},
+		{[]string{addr, "Greeter.greet", `{"name":1}`}, 2, ""},
+		{[]string{addr, "Greeter.hello"}, 2, ""},
+		{[]string{addr, "Greeter"}, 2, ""},
+		{[]string{addr}, 2, ""},
+	}
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		if got := run(append([]string{"call", "-idl", path}, c.args...), &stdout, &stderr); got != c.code {
+			t.Errorf("%v: got [%v] want [%v], stderr: %s", c.args, got, c.code, stderr.String())
+		}
+		if got := stdout.String(); got != c.want {
+			t.Errorf("%v: got [%v] want [%v]", c.args, got, c.want)
+		}
+	}
+}
+
+// chanWriter sends each write to the channel
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestMock(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"greeter.thrift": "include \"base.thrift\"\n\nexception Rejected {\n  1: string reason\n}\n\n" +
+			"service Greeter extends base.Base {\n  map<string, i32> greet(1: string name)\n" +
+			"  void reject() throws (1: Rejected rejected)\n  list<string> names()\n}\n",
+		"base.thrift":          "service Base {\n  i64 ping()\n}\n",
+		"responses/ping.json":  `{"success": 7}`,
+		"responses/greet.yaml": "# canned\nsuccess:\n  a: 1\n",
+		"responses/reject.yml": "rejected: {reason: canned}\n",
+	})
+	path := filepath.Join(dir, "greeter.thrift")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer func(original func() (context.Context, context.CancelFunc)) { mockContext = original }(mockContext)
+	mockContext = func() (context.Context, context.CancelFunc) { return ctx, cancel }
+
+	var stdout bytes.Buffer
+	stderr := make(chanWriter, 10)
+	done := make(chan int)
+	go func() {
+		done <- run([]string{"mock", "-idl", path, "-addr", "127.0.0.1:0", "-responses", filepath.Join(dir, "responses")}, &stdout, stderr)
+	}()
+	line := <-stderr
+	addr := strings.TrimSpace(strings.TrimPrefix(line, "serving Greeter on "))
+	if addr == line {
+		t.Fatalf("got %s", line)
+	}
+
+	cases := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{addr, "Greeter.ping"}, 0, "7\n"},
+		{[]string{addr, "Greeter.greet", `{"name":"x"}`}, 0, "{\n  \"a\": 1\n}\n"},
+		{[]string{addr, "Greeter.reject"}, 1, "{\n  \"rejected\": {\n    \"reason\": \"canned\"\n  }\n}\n"},
+	}
+	for _, c := range cases {
+		var out, errOut bytes.Buffer
+		if got := run(append([]string{"call", "-idl", path}, c.args...), &out, &errOut); got != c.code {
+			t.Errorf("%v: got [%v] want [%v], stderr: %s", c.args, got, c.code, errOut.String())
+		}
+		if got := out.String(); got != c.want {
+			t.Errorf("%v: got [%v] want [%v]", c.args, got, c.want)
+		}
+	}
+	// without a response, the result is random
+	var out, errOut bytes.Buffer
+	if code := run([]string{"call", "-idl", path, addr, "Greeter.names"}, &out, &errOut); code != 0 {
+		t.Fatalf("got [%v], stderr: %s", code, errOut.String())
+	}
+	var names []string
+	if err := json.Unmarshal(out.Bytes(), &names); err != nil {
+		t.Errorf("got %s: %v", out.String(), err)
+	}
+
+	cancel()
+	if code := <-done; code != 0 {
+		t.Errorf("got [%v] want [0]", code)
+	}
+	want := "ping {}\ngreet {\"name\":\"x\"}\nreject {}\nnames {}\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	dir = writeFiles(t, map[string]string{
+		"a.thrift":           "service A {\n  i32 get()\n}\n\nservice B {\n}\n",
+		"responses/get.json": `{"success": "x"}`,
+	})
+	path = filepath.Join(dir, "a.thrift")
+	for _, args := range [][]string{
+		{"mock"},
+		{"mock", "-idl", path},
+		{"mock", "-idl", path, "-service", "C"},
+		{"mock", "-idl", path, "-service", "A", "-responses", filepath.Join(dir, "responses")},
+	} {
+		var out, errOut bytes.Buffer
+		if code := run(args, &out, &errOut); code != 2 {
+			t.Errorf("%v: got [%v] want [2]", args, code)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.thrift": "include \"b.thrift\"\n\nenum Color {\n  RED\n}\n\n" +
+			"struct User {\n  1: required i64 id\n  2: optional string name\n  3: list<Color> colors\n  4: b.Meta meta\n}\n",
+		"b.thrift": "union Meta {\n  1: i32 version\n  2: string tag\n}\n",
+	})
+	path := filepath.Join(dir, "a.thrift")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"sample", "-n", "3", "-seed", "7", path, "User"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v], stderr: %s", code, stderr.String())
+	}
+	first := stdout.String()
+	decoder := json.NewDecoder(&stdout)
+	for i := 0; i < 3; i++ {
+		var user struct {
+			ID     *int64
+			Colors []string
+			Meta   map[string]any
+		}
+		if err := decoder.Decode(&user); err != nil {
+			t.Fatal(err)
+		}
+		if user.ID == nil || len(user.Meta) != 1 {
+			t.Errorf("got %+v", user)
+		}
+		for _, color := range user.Colors {
+			if color != "RED" {
+				t.Errorf("got %+v", user)
+			}
+		}
+	}
+	if decoder.More() {
+		t.Error("got more than 3 values")
+	}
+
+	stdout.Reset()
+	run([]string{"sample", "-n", "3", "-seed", "7", path, "User"}, &stdout, &stderr)
+	if stdout.String() != first {
+		t.Errorf("got [%v] want [%v]", stdout.String(), first)
+	}
+	if code := run([]string{"sample", "-fuzz", path, "b.Meta"}, &stdout, &stderr); code != 0 {
+		t.Errorf("got [%v], stderr: %s", code, stderr.String())
+	}
+
+	for _, args := range [][]string{{"sample", path}, {"sample", path, "Color"}, {"sample", path, "Missing"}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: got [%v] want [2]", args, code)
+		}
+	}
+}
+
+func TestDoc(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"idl/a.thrift": "include \"b.thrift\"\n\n// A user.\nstruct User {\n  1: b.Id id\n}\n",
+		"idl/b.thrift": "typedef i64 Id\n",
+	})
+	out := filepath.Join(dir, "out")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"doc", "-o", out, "-format", "markdown", filepath.Join(dir, "idl")}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v], stderr: %s", code, stderr.String())
+	}
+	if got := strings.Count(stdout.String(), "\n"); got != 3 {
+		t.Errorf("got [%v]", stdout.String())
+	}
+	data, err := os.ReadFile(filepath.Join(out, "a.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "| 1 | id | default | [b.Id](b.md#Id) |") {
+		t.Errorf("got %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(out, "index.md")); err != nil {
+		t.Error(err)
+	}
+
+	for _, args := range [][]string{{"doc", filepath.Join(dir, "idl")}, {"doc", "-o", out}, {"doc", "-o", out, "-format", "pdf", filepath.Join(dir, "idl")}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: got [%v] want [2]", args, code)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"user.thrift":        "struct User {\n  1: i64 id\n}\n",
+		"nested/item.thrift": "struct Item {}\n",
+		"broken.thrift":      "struct {",
+	})
+	cases := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"-v", filepath.Join(dir, "user.thrift")}, "", 0, filepath.Join(dir, "user.thrift") + ": ok\n", ""},
+		{[]string{"-v", filepath.Join(dir, "nested")}, "", 0, filepath.Join(dir, "nested", "item.thrift") + ": ok\n", ""},
+		{[]string{dir}, "", 1, "", filepath.Join(dir, "broken.thrift") + ":1:8: "},
+		{[]string{filepath.Join(dir, "u*.thrift")}, "", 0, "", ""},
+		{nil, "struct A {}", 0, "", ""},
+		{[]string{"-"}, "struct {", 1, "", "<stdin>:1:8: "},
+		{[]string{filepath.Join(dir, "x*.thrift")}, "", 2, "", "no files match"},
+		{[]string{filepath.Join(dir, "missing.thrift")}, "", 2, "", "no such file"},
+	}
+	for _, c := range cases {
+		setStdin(t, c.stdin)
+		var stdout, stderr bytes.Buffer
+		if got := run(append([]string{"parse"}, c.args...), &stdout, &stderr); got != c.code {
+			t.Errorf("%v: got [%v] want [%v], stderr: %s", c.args, got, c.code, stderr.String())
+		}
+		if got := stdout.String(); got != c.stdout {
+			t.Errorf("%v: got [%v] want [%v]", c.args, got, c.stdout)
+		}
+		if got := stderr.String(); !strings.Contains(got, c.stderr) {
+			t.Errorf("%v: got [%v] want [%v] in it", c.args, got, c.stderr)
+		}
+	}
+}
+
+func TestFmt(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"messy.thrift": "\nstruct User {\n1: i64 id   \n\n\n    2: string name\n}",
+		"clean.thrift": "struct Item {\n  1: i64 id\n}\n",
+	})
+	messy, clean := filepath.Join(dir, "messy.thrift"), filepath.Join(dir, "clean.thrift")
+	formatted := "struct User {\n  1: i64 id\n\n  2: string name\n}\n"
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"fmt", messy}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	if got := stdout.String(); got != formatted {
+		t.Errorf("got [%v] want [%v]", got, formatted)
+	}
+
+	stdout.Reset()
+	setStdin(t, "enum E {\n\tX\n}")
+	if code := run([]string{"fmt", "-indent", "    "}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), "enum E {\n    X\n}\n"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"fmt", "-l", dir}, &stdout, &stderr); code != 1 {
+		t.Errorf("got [%v] want [1], stderr: %s", code, stderr.String())
+	}
+	if got, want := stdout.String(), messy+"\n"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"fmt", "-w", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	if data, _ := os.ReadFile(messy); string(data) != formatted {
+		t.Errorf("got [%s] want [%v]", data, formatted)
+	}
+	if code := run([]string{"fmt", "-l", messy, clean}, &stdout, &stderr); code != 0 || stdout.Len() != 0 {
+		t.Errorf("got [%v %s] want [0]", code, stdout.String())
+	}
+
+	for _, args := range [][]string{{"fmt", "-w", "-"}, {"fmt", "-indent", "x", clean}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: got [%v] want [2]", args, code)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	setStdin(t, "const i32 A = 1 // one\n")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"tokens", "-no-space"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	want := "<stdin>:1:1\tCONST\t\"const\"\n" +
+		"<stdin>:1:7\tIDENT\t\"i32\"\n" +
+		"<stdin>:1:11\tIDENT\t\"A\"\n" +
+		"<stdin>:1:13\tEQUALS\t\"=\"\n" +
+		"<stdin>:1:15\tNUMBER\t\"1\"\n" +
+		"<stdin>:1:17\tCOMMENT\t\"// one\"\n" +
+		"<stdin>:2:1\tEOF\t\"\"\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	stdout.Reset()
+	setStdin(t, "struct A {}")
+	if code := run([]string{"tokens"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	if got := strings.Count(stdout.String(), "SPACE"); got != 2 {
+		t.Errorf("got [%v] want [2]", got)
+	}
+}
+
+func TestLint(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"shared.thrift": "struct Point {}\n",
+		"main.thrift":   "include \"shared.thrift\"\nstruct A {\n  1: required shared.Point p\n  1: Missing m\n}\n",
+		"ok.thrift":     "struct B {\n  1: i32 b = 1\n}\n",
+	})
+	main := filepath.Join(dir, "main.thrift")
+	cases := []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{filepath.Join(dir, "ok.thrift")}, 0, ""},
+		{[]string{main}, 1, main + ":3:28: warning: field p of A is required (required-field)\n" +
+			main + ":4:6: error: unknown type Missing (unresolved)\n" +
+			main + ":4:14: error: field id 1 of A is used more than once (duplicate-id)\n"},
+		{[]string{"-disable", "required-field,duplicate-id", "-disable", "unresolved", main}, 0, ""},
+		{[]string{"-disable", "nope", main}, 2, ""},
+		{[]string{filepath.Join(dir, "missing.thrift")}, 2, ""},
+	}
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		if got := run(append([]string{"lint"}, c.args...), &stdout, &stderr); got != c.code {
+			t.Errorf("%v: got [%v] want [%v], stderr: %s", c.args, got, c.code, stderr.String())
+		}
+		if got := stdout.String(); got != c.want {
+			t.Errorf("%v: got [%v] want [%v]", c.args, got, c.want)
+		}
+	}
+
+	setStdin(t, "enum E {\n  X = 1\n  Y = 1\n}\n")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"lint", "-json"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("got [%v] want [1], stderr: %s", code, stderr.String())
+	}
+	var problems []struct {
+		Pos      struct{ Filename string }
+		Rule     string
+		Severity string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &problems); err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Pos.Filename != "<stdin>" || problems[0].Rule != "duplicate-value" || problems[0].Severity != "warning" {
+		t.Errorf("got [%+v]", problems)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"old.thrift": "struct User {\n  1: i32 id\n}\n",
+		"new.thrift": "// users\nstruct User {\n  1: i64 id\n}\n",
+	})
+	old, new := filepath.Join(dir, "old.thrift"), filepath.Join(dir, "new.thrift")
+	cases := []struct {
+		args  []string
+		stdin string
+		code  int
+		want  string
+	}{
+		{[]string{old, new}, "", 1, "~ Struct(User).Field(1) type: i32 -> i64\n"},
+		{[]string{old, "-"}, "struct User {\n 1: i32 id\n}", 0, ""},
+		{[]string{"-json", old, old}, "", 0, "[]\n"},
+		{[]string{old}, "", 2, ""},
+		{[]string{"-", "-"}, "", 2, ""},
+		{[]string{old, filepath.Join(dir, "missing.thrift")}, "", 2, ""},
+	}
+	for _, c := range cases {
+		setStdin(t, c.stdin)
+		var stdout, stderr bytes.Buffer
+		if got := run(append([]string{"diff"}, c.args...), &stdout, &stderr); got != c.code {
+			t.Errorf("%v: got [%v] want [%v], stderr: %s", c.args, got, c.code, stderr.String())
+		}
+		if got := stdout.String(); got != c.want {
+			t.Errorf("%v: got [%v] want [%v]", c.args, got, c.want)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"diff", "-comments", old, new}, &stdout, &stderr); code != 1 || !strings.Contains(stdout.String(), "users") {
+		t.Errorf("got [%v %s] want comments", code, stdout.String())
+	}
+}
+
+func TestConvert(t *testing.T) {
+	src := "struct User {\n  1: i64 id\n}\n"
+	dir := writeFiles(t, map[string]string{"user.thrift": src})
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"convert", "-to", "yaml", filepath.Join(dir, "user.thrift")}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	yaml := stdout.String()
+
+	// yaml and json are detected by content on stdin
+	stdout.Reset()
+	setStdin(t, yaml)
+	if code := run([]string{"convert"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	out := filepath.Join(dir, "user.thrift.out")
+	setStdin(t, stdout.String())
+	if code := run([]string{"convert", "-to", "thrift", "-o", out}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got [%v] want [0], stderr: %s", code, stderr.String())
+	}
+	if data, _ := os.ReadFile(out); string(data) != src {
+		t.Errorf("got [%s] want [%v]", data, src)
+	}
+
+	for _, args := range [][]string{{"convert", "-to", "xml", "-"}, {"convert", "-from", "xml", "-"}, {"convert", dir, dir}} {
+		if code := run(args, &stdout, &stderr); code != 2 {
+			t.Errorf("%v: got [%v] want [2]", args, code)
+		}
+	}
+	setStdin(t, "{\"version\": ")
+	if code := run([]string{"convert", "-to", "thrift"}, &stdout, &stderr); code != 1 {
+		t.Errorf("got [%v] want [1]", code)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/dynamic"
+	"github.com/YYCoder/thrifter/internal/cli"
+	"github.com/YYCoder/thrifter/internal/yaml"
+)
+
+// mockContext is done when the mock server should stop, it's replaced in tests
+var mockContext = func() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// runMock serves all functions of a service including inherited ones until interrupted, and logs calls with args in JSON.
+// Results come from files of functions under -responses, or are random.
+func runMock(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("mock", stderr)
+	var includeDirs cli.StringList
+	flags.Var(&includeDirs, "I", "directory to search included files in, can be repeated")
+	idl := flags.String("idl", "", "file declaring the service, required")
+	serviceName := flags.String("service", "", "service to serve, required if the file declares more than one")
+	addr := flags.String("addr", "127.0.0.1:9090", "address to listen on")
+	protocolName := flags.String("protocol", "binary", "protocol, one of binary, compact and json")
+	framed := flags.Bool("framed", false, "use the framed transport")
+	responses := flags.String("responses", "", "directory of responses in files named after functions, e.g. get.json or get.yaml,\n"+
+		"containing the result struct like {\"success\": value} or {\"name in throws\": exception}")
+	seed := flags.Int64("seed", 1, "seed of random results")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	protocol, ok := protocols[*protocolName]
+	if *idl == "" || !ok || flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	program := thrifter.NewProgram(includeDirs...)
+	file, err := program.Load(*idl)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	service, err := mockService(program, file, *serviceName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	codec := dynamic.NewCodec(program)
+	fns, err := codec.Functions(service)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	canned := map[string]map[string]any{}
+	if *responses != "" {
+		for _, fn := range fns {
+			if canned[fn.Ident], err = loadResponse(codec, fn, *responses); err != nil {
+				fmt.Fprintln(stderr, err)
+				return 2
+			}
+		}
+	}
+
+	// calls are handled concurrently, while the generator and the log are shared
+	var mu sync.Mutex
+	generator := codec.NewGenerator(*seed, dynamic.GeneratorOptions{})
+	handler := func(fn *thrifter.Function, args map[string]any) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		data, err := codec.EncodeSimpleJSON(dynamic.ArgsStruct(fn), args)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(stdout, "%s %s\n", fn.Ident, data)
+		if fn.Oneway {
+			return nil, nil
+		}
+		if result := canned[fn.Ident]; result != nil {
+			for _, field := range fn.Throws {
+				if v, ok := result[field.Ident]; ok {
+					return nil, &dynamic.Exception{Field: field.Ident, Type: field.FieldType.Ident, Value: v.(map[string]any)}
+				}
+			}
+			if v, ok := result["success"]; ok || fn.Void {
+				return v, nil
+			}
+		}
+		if fn.Void {
+			return nil, nil
+		}
+		return generator.Value(fn.FunctionType)
+	}
+	server, err := codec.NewServer(service, protocol, *framed, handler)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	ctx, cancel := mockContext()
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	fmt.Fprintf(stderr, "serving %s on %s\n", service.Ident, listener.Addr())
+	if err := server.Serve(listener); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	return 0
+}
+
+// mockService finds the service named name in file, or the only service of file if name is empty
+func mockService(program *thrifter.Program, file *thrifter.Thrift, name string) (*thrifter.Service, error) {
+	if name != "" {
+		decl, _ := program.Resolve(file, name)
+		if service, ok := decl.(*thrifter.Service); ok {
+			return service, nil
+		}
+		return nil, fmt.Errorf("service %s not found", name)
+	}
+	var res []*thrifter.Service
+	for _, node := range file.Nodes {
+		if service, ok := node.(*thrifter.Service); ok {
+			res = append(res, service)
+		}
+	}
+	if len(res) != 1 {
+		return nil, fmt.Errorf("%s declares %d services, choose one by -service", file.FileName, len(res))
+	}
+	return res[0], nil
+}
+
+// loadResponse reads the result struct of fn from a JSON or YAML file named after fn in dir, or returns nil if there is no such file
+func loadResponse(codec *dynamic.Codec, fn *thrifter.Function, dir string) (map[string]any, error) {
+	for _, ext := range []string{".json", ".yaml", ".yml"} {
+		path := filepath.Join(dir, fn.Ident+ext)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ext != ".json" {
+			if data, err = yaml.ToJSON(data); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+		}
+		res, err := codec.DecodeSimpleJSON(dynamic.ResultStruct(fn), data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return res, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// runParse parses files or stdin, and prints syntax errors, exit code is 1 if any file is invalid.
+func runParse(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("parse", stderr)
+	verbose := flags.Bool("v", false, "print names of valid files as well")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	sources, err := readSources(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	code := 0
+	for _, s := range sources {
+		if _, err := s.parse(); err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+		} else if *verbose {
+			fmt.Fprintf(stdout, "%s: ok\n", s.name)
+		}
+	}
+	return code
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/query"
+)
+
+// a node matching the query
+type match struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Kind   string `json:"kind"`
+	NodeID string `json:"nodeId,omitempty"`
+	Text   string `json:"text"` // first line of the node
+}
+
+// runQuery prints matches of files or .thrift files under directories, exit code is 1 if nothing matches, and 2 on errors.
+func runQuery(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("query", stderr)
+	asJSON := flags.Bool("json", false, "print matches in json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return 2
+	}
+	sel, err := query.Compile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	paths, err := thriftFiles(flags.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	code := 0
+	matches := []match{}
+	for _, path := range paths {
+		file, err := parseFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 2
+			continue
+		}
+		for _, node := range sel.Select(file) {
+			matches = append(matches, newMatch(path, node))
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(matches); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	} else {
+		for _, m := range matches {
+			id := m.NodeID
+			if id == "" {
+				id = m.Kind
+			}
+			fmt.Fprintf(stdout, "%s:%d:%d: %s: %s\n", m.File, m.Line, m.Column, id, m.Text)
+		}
+	}
+	if code == 0 && len(matches) == 0 {
+		code = 1
+	}
+	return code
+}
+
+func newMatch(path string, node thrifter.Node) match {
+	res := match{File: path, Line: 1, Column: 1, Kind: query.Kind(node), NodeID: node.CommonField().NodeID}
+	common := node.CommonField()
+	if _, ok := node.(*thrifter.Thrift); ok || common.StartToken == nil {
+		return res
+	}
+	res.Line, res.Column = common.StartToken.Pos.Line, common.StartToken.Pos.Column
+	var text strings.Builder
+	for tok := common.StartToken; tok != nil && tok.Type != thrifter.T_LINEBREAK && tok.Type != thrifter.T_EOF; tok = tok.Next {
+		text.WriteString(tok.Raw)
+		if tok == common.EndToken {
+			break
+		}
+	}
+	res.Text = strings.TrimSpace(text.String())
+	return res
+}
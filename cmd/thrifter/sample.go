@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/dynamic"
+	"github.com/YYCoder/thrifter/internal/cli"
+)
+
+// runSample prints random values of a struct in JSON, e.g. as fixtures of tests.
+func runSample(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("sample", stderr)
+	var includeDirs cli.StringList
+	flags.Var(&includeDirs, "I", "directory to search included files in, can be repeated")
+	count := flags.Int("n", 1, "number of values")
+	seed := flags.Int64("seed", 1, "seed of values, the same seed prints the same values")
+	depth := flags.Int("depth", 3, "depth beyond which containers are empty and fields which are not required are omitted")
+	size := flags.Int("size", 3, "max number of elements of containers")
+	optional := flags.Float64("optional", 0.5, "probability that an optional field is set")
+	fuzz := flags.Bool("fuzz", false, "prefer edge cases, e.g. max integers, NaN and long strings")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 || *count < 0 {
+		flags.Usage()
+		return 2
+	}
+	program := thrifter.NewProgram(includeDirs...)
+	file, err := program.Load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	decl, _ := program.Resolve(file, flags.Arg(1))
+	st, ok := decl.(*thrifter.Struct)
+	if !ok {
+		fmt.Fprintf(stderr, "struct %s not found\n", flags.Arg(1))
+		return 2
+	}
+	if *optional == 0 {
+		*optional = -1
+	}
+	codec := dynamic.NewCodec(program)
+	generator := codec.NewGenerator(*seed, dynamic.GeneratorOptions{Depth: *depth, MaxSize: *size, Optional: *optional, Fuzz: *fuzz})
+	for i := 0; i < *count; i++ {
+		value, err := generator.Struct(st)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		data, err := codec.EncodeSimpleJSON(st, value)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		printJSON(stdout, data)
+	}
+	return 0
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// runTokens prints the token chain of files or stdin, a token per line with its position, type and raw text.
+func runTokens(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("tokens", stderr)
+	skipSpace := flags.Bool("no-space", false, "skip white space and line break tokens")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	sources, err := readSources(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	code := 0
+	for _, s := range sources {
+		file, err := s.parse()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+			continue
+		}
+		for tok := file.StartToken; tok != nil; tok = tok.Next {
+			if *skipSpace && isSpace(tok) {
+				continue
+			}
+			fmt.Fprintf(stdout, "%v\t%v\t%s\n", tok.Pos, tok.Type, strconv.Quote(tok.Raw))
+		}
+	}
+	return code
+}
+
+func isSpace(tok *thrifter.Token) bool {
+	switch tok.Type {
+	case thrifter.T_SPACE, thrifter.T_TAB, thrifter.T_LINEBREAK, thrifter.T_RETURN:
+		return true
+	}
+	return false
+}
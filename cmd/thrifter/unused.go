@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+	"github.com/YYCoder/thrifter/internal/cli"
+	"github.com/YYCoder/thrifter/refactor"
+)
+
+// runUnused reports declarations not reachable from root services and unused includes, or removes them with -w. Services are only reported with -services.
+func runUnused(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlagSet("unused", stderr)
+	var includeDirs, rootNames cli.StringList
+	flags.Var(&includeDirs, "I", "directory to search included files in, can be repeated")
+	flags.Var(&rootNames, "root", "name of a root service, or file#Service if several files declare it, can be repeated, all services are roots by default")
+	services := flags.Bool("services", false, "report services not reachable from root services too, -w removes them")
+	write := flags.Bool("w", false, "remove unused declarations and includes, and write files in place")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	paths, err := thriftFiles(flags.Args())
+	if err != nil || len(paths) == 0 {
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+		flags.Usage()
+		return 2
+	}
+	program := thrifter.NewProgram(includeDirs...)
+	for _, path := range paths {
+		if _, err := program.Load(path); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	var roots []*thrifter.Service
+	for _, name := range rootNames {
+		root, err := findService(program, name)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		roots = append(roots, root)
+	}
+
+	unused := refactor.FindUnused(program, refactor.UnusedOptions{Roots: roots, Services: *services})
+	if *write {
+		for _, file := range refactor.RemoveUnused(unused) {
+			if err := os.WriteFile(file.FileName, []byte(file.String()), 0o644); err != nil {
+				fmt.Fprintln(stderr, err)
+				return 2
+			}
+		}
+		return 0
+	}
+	for _, u := range unused {
+		pos := u.Node.CommonField().StartToken.Pos
+		fmt.Fprintf(stdout, "%s:%d:%d: unused %s\n", u.File.FileName, pos.Line, pos.Column, u.Node.CommonField().NodeID)
+	}
+	if len(unused) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// findService finds the service called name, which is either Service or file#Service, where file is the path of the file declaring it
+// or a suffix of that path, e.g. shared.thrift#BaseService. It's an error if several files declare the service.
+func findService(program *thrifter.Program, name string) (*thrifter.Service, error) {
+	file, ident := "", name
+	if idx := strings.LastIndex(name, "#"); idx >= 0 {
+		file, ident = filepath.Clean(name[:idx]), name[idx+1:]
+	}
+	paths := make([]string, 0, len(program.Files))
+	for path := range program.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var res *thrifter.Service
+	var found []string
+	for _, path := range paths {
+		if file != "" && path != file && !strings.HasSuffix(path, string(filepath.Separator)+file) {
+			continue
+		}
+		if service, ok := program.Files[path].Declaration(ident).(*thrifter.Service); ok {
+			res = service
+			found = append(found, path)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("service %s not found", name)
+	case 1:
+		return res, nil
+	}
+	return nil, fmt.Errorf("service %s is declared in %s, use file#%s to choose one", name, strings.Join(found, ", "), ident)
+}
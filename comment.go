@@ -0,0 +1,89 @@
+package thrifter
+
+import "strings"
+
+// LeadingComments returns comment tokens right above the node, e.g. doc comments of a declaration.
+// Comments must start their own lines, and a blank line ends the search, so comments belong to previous node or file header are excluded.
+func (r *NodeCommonField) LeadingComments() (res []*Token) {
+	if r.StartToken == nil {
+		return
+	}
+	lineBreaks := 0
+	for tok := r.StartToken.Prev; tok != nil; tok = tok.Prev {
+		switch {
+		case tok.Type == T_LINEBREAK:
+			lineBreaks++
+			if lineBreaks > 1 {
+				return
+			}
+		case IsWhitespace(tok.Type):
+		case tok.Type == T_COMMENT:
+			if !startsLine(tok) {
+				return
+			}
+			// multi-line comment may be on the same line with node, e.g. /* doc */ 1: i32 a
+			lineBreaks = 0
+			res = append([]*Token{tok}, res...)
+		default:
+			return
+		}
+	}
+	return
+}
+
+// TrailingComment returns the comment token following the node on the same line, or nil if there isn't one.
+func (r *NodeCommonField) TrailingComment() *Token {
+	if r.EndToken == nil {
+		return nil
+	}
+	for tok := r.EndToken.Next; tok != nil; tok = tok.Next {
+		switch {
+		case tok.Type == T_COMMENT:
+			return tok
+		case tok.Type == T_SPACE || tok.Type == T_TAB:
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// Doc returns the documentation text of node, which comes from its leading comments, or its trailing comment if there are no leading comments.
+// Comment markers, such as // or the leading * of each line in /** */, are trimmed.
+func (r *NodeCommonField) Doc() string {
+	comments := r.LeadingComments()
+	if len(comments) == 0 {
+		if tok := r.TrailingComment(); tok != nil {
+			comments = []*Token{tok}
+		}
+	}
+	var lines []string
+	for _, tok := range comments {
+		for _, line := range strings.Split(tok.Value, "\n") {
+			line = strings.TrimSpace(line)
+			line = strings.TrimSpace(strings.TrimLeft(line, "*"))
+			lines = append(lines, line)
+		}
+	}
+	// drop blank lines around, e.g. the first line of /**
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// whether there are only white spaces between tok and the line break before it
+func startsLine(tok *Token) bool {
+	for prev := tok.Prev; prev != nil; prev = prev.Prev {
+		if prev.Type == T_LINEBREAK {
+			return true
+		}
+		if prev.Type != T_SPACE && prev.Type != T_TAB && prev.Type != T_RETURN {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package thrifter
+
+import "testing"
+
+func TestComment_doc(t *testing.T) {
+	parser := newParserOn(`// file header
+
+/**
+ * User of the system.
+ * Second line.
+ */
+struct User {
+	// unique id
+	1: i64 id, // trailing of id
+	2: string name // name of user
+	# bash style
+	3: string email
+} // trailing of struct`)
+	res, err := parser.Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	st := res.Nodes[0].(*Struct)
+
+	if got, want := st.Doc(), "User of the system.\nSecond line."; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := st.TrailingComment().Raw, "// trailing of struct"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := st.Elems[0].Doc(), "unique id"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := st.Elems[1].Doc(), "name of user"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(st.Elems[1].LeadingComments()), 0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := st.Elems[2].Doc(), "bash style"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
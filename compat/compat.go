@@ -0,0 +1,605 @@
+// Package compat detects changes between two versions of thrift definitions, and rates whether they break wire compatibility.
+//
+// Declarations are matched by name, fields, arguments and exceptions are matched by field id, and enum elements are matched by name.
+// Field types are compared after typedefs are resolved, so changing a field from i64 to a typedef of i64 is not a change.
+package compat
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/scanner"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Severity rates how a change affects compatibility between old and new peers.
+type Severity int
+
+const (
+	// Safe changes keep old and new peers working together.
+	Safe Severity = iota
+	// Risky changes are wire compatible, but may break generated code, name based protocols, or application logic.
+	Risky
+	// Breaking changes make old and new peers fail to communicate, or silently lose data.
+	Breaking
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Safe:
+		return "safe"
+	case Risky:
+		return "risky"
+	case Breaking:
+		return "breaking"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "safe":
+		*s = Safe
+	case "risky":
+		*s = Risky
+	case "breaking":
+		*s = Breaking
+	default:
+		return fmt.Errorf("unknown severity %q", text)
+	}
+	return nil
+}
+
+// Kind is the kind of a change.
+type Kind string
+
+const (
+	DeclarationAdded       Kind = "DeclarationAdded"
+	DeclarationRemoved     Kind = "DeclarationRemoved"
+	DeclarationKindChanged Kind = "DeclarationKindChanged" // e.g. struct to union, or struct to enum
+
+	FieldAdded          Kind = "FieldAdded"
+	FieldRemoved        Kind = "FieldRemoved"
+	FieldRenamed        Kind = "FieldRenamed"
+	FieldRenumbered     Kind = "FieldRenumbered"
+	FieldTypeChanged    Kind = "FieldTypeChanged"
+	RequirednessChanged Kind = "RequirednessChanged"
+	DefaultValueChanged Kind = "DefaultValueChanged"
+
+	EnumValueAdded   Kind = "EnumValueAdded"
+	EnumValueRemoved Kind = "EnumValueRemoved"
+	EnumValueChanged Kind = "EnumValueChanged"
+
+	ExtendsChanged      Kind = "ExtendsChanged"
+	FunctionAdded       Kind = "FunctionAdded"
+	FunctionRemoved     Kind = "FunctionRemoved"
+	ReturnTypeChanged   Kind = "ReturnTypeChanged"
+	OnewayChanged       Kind = "OnewayChanged"
+	ArgumentAdded       Kind = "ArgumentAdded"
+	ArgumentRemoved     Kind = "ArgumentRemoved"
+	ArgumentRenamed     Kind = "ArgumentRenamed"
+	ArgumentTypeChanged Kind = "ArgumentTypeChanged"
+	ExceptionAdded      Kind = "ExceptionAdded"
+	ExceptionRemoved    Kind = "ExceptionRemoved"
+	ExceptionChanged    Kind = "ExceptionChanged"
+
+	TypeDefChanged Kind = "TypeDefChanged"
+	ConstChanged   Kind = "ConstChanged"
+)
+
+// Change is a difference between old and new definitions.
+type Change struct {
+	Severity Severity
+	Kind     Kind
+	NodeID   string // id of the changed node, from new version unless it's removed
+	Message  string
+	Old      thrifter.Node    `json:"-"` // nil if node is added
+	New      thrifter.Node    `json:"-"` // nil if node is removed
+	OldPos   scanner.Position // invalid if node is added
+	NewPos   scanner.Position // invalid if node is removed
+}
+
+func (c Change) String() string {
+	var pos []string
+	if c.OldPos.IsValid() {
+		pos = append(pos, c.OldPos.String())
+	}
+	if c.NewPos.IsValid() {
+		pos = append(pos, c.NewPos.String())
+	}
+	return fmt.Sprintf("%s: %s: %s", strings.Join(pos, " -> "), c.Severity, c.Message)
+}
+
+// Max returns the highest severity of changes, it's Safe if there are no changes.
+func Max(changes []Change) (res Severity) {
+	for _, change := range changes {
+		if change.Severity > res {
+			res = change.Severity
+		}
+	}
+	return
+}
+
+// Filter returns changes whose severity is at least min.
+func Filter(changes []Change, min Severity) (res []Change) {
+	for _, change := range changes {
+		if change.Severity >= min {
+			res = append(res, change)
+		}
+	}
+	return
+}
+
+// Compare compares two versions of a single file, identifiers are resolved within each file.
+func Compare(old, new *thrifter.Thrift) []Change {
+	c := &comparer{oldProgram: thrifter.NewProgram(), newProgram: thrifter.NewProgram()}
+	c.compareFiles(old, new)
+	return c.changes
+}
+
+// ComparePrograms compares two versions of include graphs, whose files are matched by their paths relative to oldRoot and newRoot.
+// Identifiers are resolved across files of each program, declarations of a file missing in new program are reported as removed.
+func ComparePrograms(old *thrifter.Program, oldRoot string, new *thrifter.Program, newRoot string) []Change {
+	c := &comparer{oldProgram: old, newProgram: new}
+	newFiles := map[string]*thrifter.Thrift{}
+	for path, file := range new.Files {
+		newFiles[relPath(newRoot, path)] = file
+	}
+	oldFiles := map[string]*thrifter.Thrift{}
+	for path, file := range old.Files {
+		oldFiles[relPath(oldRoot, path)] = file
+	}
+	for _, rel := range sortedKeys(oldFiles) {
+		c.compareFiles(oldFiles[rel], newFiles[rel])
+	}
+	for _, rel := range sortedKeys(newFiles) {
+		if oldFiles[rel] == nil {
+			c.compareFiles(nil, newFiles[rel])
+		}
+	}
+	return c.changes
+}
+
+func relPath(root string, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+func sortedKeys(files map[string]*thrifter.Thrift) []string {
+	res := make([]string, 0, len(files))
+	for key := range files {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+type comparer struct {
+	oldProgram *thrifter.Program
+	newProgram *thrifter.Program
+	oldFile    *thrifter.Thrift
+	newFile    *thrifter.Thrift
+	changes    []Change
+}
+
+func (c *comparer) report(severity Severity, kind Kind, old, new thrifter.Node, format string, args ...interface{}) {
+	change := Change{
+		Severity: severity,
+		Kind:     kind,
+		Message:  fmt.Sprintf(format, args...),
+		Old:      old,
+		New:      new,
+	}
+	if old != nil {
+		change.NodeID = old.CommonField().NodeID
+		change.OldPos = old.CommonField().StartToken.Pos
+	}
+	if new != nil {
+		change.NodeID = new.CommonField().NodeID
+		change.NewPos = new.CommonField().StartToken.Pos
+	}
+	c.changes = append(c.changes, change)
+}
+
+// compare declarations of two files, either of them can be nil
+func (c *comparer) compareFiles(old, new *thrifter.Thrift) {
+	c.oldFile, c.newFile = old, new
+	oldDecls, newDecls := declarations(old), declarations(new)
+	for _, oldDecl := range oldDecls {
+		newDecl := lookup(newDecls, name(oldDecl))
+		if newDecl == nil {
+			c.removed(oldDecl)
+			continue
+		}
+		if kind(oldDecl) != kind(newDecl) {
+			severity := Breaking
+			if isStruct(oldDecl) && isStruct(newDecl) {
+				// struct, union and exception are the same on wire
+				severity = Risky
+			}
+			c.report(severity, DeclarationKindChanged, oldDecl, newDecl, "%s %s changed to %s", kind(oldDecl), name(oldDecl), kind(newDecl))
+			if severity == Breaking {
+				continue
+			}
+		}
+		switch o := oldDecl.(type) {
+		case *thrifter.Struct:
+			c.compareFields(o.Elems, newDecl.(*thrifter.Struct).Elems, newDecl.(*thrifter.Struct).Options, fieldKinds, "field", kind(o)+" "+o.Ident)
+		case *thrifter.Enum:
+			c.compareEnums(o, newDecl.(*thrifter.Enum))
+		case *thrifter.Service:
+			c.compareServices(o, newDecl.(*thrifter.Service))
+		case *thrifter.TypeDef:
+			n := newDecl.(*thrifter.TypeDef)
+			if severity, oldType, newType, changed := c.compareTypes(o.Type, n.Type); changed {
+				c.report(severity, TypeDefChanged, o, n, "typedef %s changed from %s to %s", o.Ident, oldType, newType)
+			}
+		case *thrifter.Const:
+			n := newDecl.(*thrifter.Const)
+			if _, oldType, newType, changed := c.compareTypes(o.Type, n.Type); changed {
+				c.report(Risky, ConstChanged, o, n, "type of const %s changed from %s to %s", o.Ident, oldType, newType)
+			} else if oldValue, newValue := normalize(o.Value), normalize(n.Value); oldValue != newValue {
+				c.report(Risky, ConstChanged, o, n, "value of const %s changed from %s to %s", o.Ident, oldValue, newValue)
+			}
+		}
+	}
+	for _, newDecl := range newDecls {
+		if lookup(oldDecls, name(newDecl)) == nil {
+			c.report(Safe, DeclarationAdded, nil, newDecl, "%s %s added", kind(newDecl), name(newDecl))
+		}
+	}
+}
+
+func (c *comparer) removed(decl thrifter.Node) {
+	severity := Risky
+	if _, ok := decl.(*thrifter.Service); ok {
+		// clients calling the service fail
+		severity = Breaking
+	}
+	c.report(severity, DeclarationRemoved, decl, nil, "%s %s removed", kind(decl), name(decl))
+}
+
+// kinds of changes of fields, which are different for arguments and exceptions
+type fieldChangeKinds struct {
+	added, removed, renamed, typeChanged Kind
+}
+
+var (
+	fieldKinds     = fieldChangeKinds{FieldAdded, FieldRemoved, FieldRenamed, FieldTypeChanged}
+	argumentKinds  = fieldChangeKinds{ArgumentAdded, ArgumentRemoved, ArgumentRenamed, ArgumentTypeChanged}
+	exceptionKinds = fieldChangeKinds{ExceptionAdded, ExceptionRemoved, ExceptionChanged, ExceptionChanged}
+)
+
+// compare fields by id, reserved is options of the new container, whose reserved option lists ids or names which are removed on purpose, e.g. (reserved = "3, 4, email")
+func (c *comparer) compareFields(oldFields, newFields []*thrifter.Field, reserved []*thrifter.Option, kinds fieldChangeKinds, noun string, owner string) {
+	reservedSet := reservedFields(reserved)
+	for _, oldField := range oldFields {
+		newField := fieldByID(newFields, oldField.ID)
+		if newField == nil {
+			if renumbered := fieldByName(newFields, oldField.Ident); renumbered != nil && fieldByID(oldFields, renumbered.ID) == nil {
+				c.report(Breaking, FieldRenumbered, oldField, renumbered, "%s %q of %s renumbered from %d to %d", noun, oldField.Ident, owner, oldField.ID, renumbered.ID)
+				continue
+			}
+			c.fieldRemoved(oldField, reservedSet, kinds, noun, owner)
+			continue
+		}
+
+		if severity, oldType, newType, changed := c.compareTypes(oldField.FieldType, newField.FieldType); changed {
+			c.report(severity, kinds.typeChanged, oldField, newField, "type of %s %d %q of %s changed from %s to %s", noun, oldField.ID, oldField.Ident, owner, oldType, newType)
+		}
+		if oldField.Ident != newField.Ident {
+			// binary and compact protocols identify fields by id, but json protocols may use names
+			c.report(Risky, kinds.renamed, oldField, newField, "%s %d of %s renamed from %q to %q", noun, oldField.ID, owner, oldField.Ident, newField.Ident)
+		}
+		if kinds == fieldKinds {
+			c.compareRequiredness(oldField, newField, owner)
+		}
+		if oldValue, newValue := normalize(oldField.DefaultValue), normalize(newField.DefaultValue); oldValue != newValue {
+			c.report(Risky, DefaultValueChanged, oldField, newField, "default value of %s %d %q of %s changed from %s to %s", noun, oldField.ID, oldField.Ident, owner, orNone(oldValue), orNone(newValue))
+		}
+	}
+	for _, newField := range newFields {
+		if fieldByID(oldFields, newField.ID) != nil {
+			continue
+		}
+		if renumbered := fieldByName(oldFields, newField.Ident); renumbered != nil && fieldByID(newFields, renumbered.ID) == nil {
+			// reported as renumbered already
+			continue
+		}
+		severity := Safe
+		switch {
+		case kinds == exceptionKinds:
+			// old clients don't know the exception, they get an unknown result error instead
+			severity = Risky
+		case newField.Requiredness == "required":
+			// old peers don't send it
+			severity = Breaking
+		}
+		c.report(severity, kinds.added, nil, newField, "%s %d %q added to %s", noun, newField.ID, newField.Ident, owner)
+	}
+}
+
+func (c *comparer) fieldRemoved(field *thrifter.Field, reserved map[string]bool, kinds fieldChangeKinds, noun string, owner string) {
+	switch {
+	case kinds == exceptionKinds:
+		c.report(Breaking, kinds.removed, field, nil, "%s %d %q removed from %s", noun, field.ID, field.Ident, owner)
+	case field.Requiredness == "required":
+		c.report(Breaking, kinds.removed, field, nil, "required %s %d %q removed from %s", noun, field.ID, field.Ident, owner)
+	case reserved[fmt.Sprint(field.ID)] || reserved[field.Ident]:
+		c.report(Safe, kinds.removed, field, nil, "reserved %s %d %q removed from %s", noun, field.ID, field.Ident, owner)
+	default:
+		c.report(Risky, kinds.removed, field, nil, "%s %d %q removed from %s without reservation, its id may be reused", noun, field.ID, field.Ident, owner)
+	}
+}
+
+func (c *comparer) compareRequiredness(oldField, newField *thrifter.Field, owner string) {
+	oldReq, newReq := requiredness(oldField), requiredness(newField)
+	if oldReq == newReq {
+		return
+	}
+	severity := Safe
+	if newReq == "required" {
+		// old peers may not send it
+		severity = Breaking
+	} else if oldReq == "required" {
+		// old peers may fail to read messages without it
+		severity = Risky
+	}
+	c.report(severity, RequirednessChanged, oldField, newField, "field %d %q of %s changed from %s to %s", oldField.ID, oldField.Ident, owner, oldReq, newReq)
+}
+
+func (c *comparer) compareEnums(old, new *thrifter.Enum) {
+	for _, oldElem := range old.Elems {
+		newElem := new.ElementByName(oldElem.Ident)
+		if newElem == nil {
+			c.report(Breaking, EnumValueRemoved, oldElem, nil, "value %s (%d) removed from enum %s", oldElem.Ident, oldElem.Value(), old.Ident)
+			continue
+		}
+		if oldValue, newValue := oldElem.Value(), newElem.Value(); oldValue != newValue {
+			c.report(Breaking, EnumValueChanged, oldElem, newElem, "value %s of enum %s renumbered from %d to %d", oldElem.Ident, old.Ident, oldValue, newValue)
+		}
+	}
+	for _, newElem := range new.Elems {
+		if old.ElementByName(newElem.Ident) == nil {
+			c.report(Safe, EnumValueAdded, nil, newElem, "value %s (%d) added to enum %s", newElem.Ident, newElem.Value(), new.Ident)
+		}
+	}
+}
+
+func (c *comparer) compareServices(old, new *thrifter.Service) {
+	if old.Extends != new.Extends {
+		// methods of the old parent service may be gone
+		c.report(Risky, ExtendsChanged, old, new, "service %s changed to extend %s from %s", old.Ident, orNone(new.Extends), orNone(old.Extends))
+	}
+	for _, oldFunc := range old.Elems {
+		newFunc := new.FunctionByName(oldFunc.Ident)
+		if newFunc == nil {
+			c.report(Breaking, FunctionRemoved, oldFunc, nil, "method %s removed from service %s", oldFunc.Ident, old.Ident)
+			continue
+		}
+		owner := "method " + old.Ident + "." + oldFunc.Ident
+		if oldFunc.Oneway != newFunc.Oneway {
+			c.report(Breaking, OnewayChanged, oldFunc, newFunc, "oneway of %s changed from %t to %t", owner, oldFunc.Oneway, newFunc.Oneway)
+		}
+		if oldFunc.Void != newFunc.Void {
+			c.report(Breaking, ReturnTypeChanged, oldFunc, newFunc, "return type of %s changed from %s to %s", owner, returnType(oldFunc), returnType(newFunc))
+		} else if !oldFunc.Void {
+			if severity, oldType, newType, changed := c.compareTypes(oldFunc.FunctionType, newFunc.FunctionType); changed {
+				c.report(severity, ReturnTypeChanged, oldFunc, newFunc, "return type of %s changed from %s to %s", owner, oldType, newType)
+			}
+		}
+		c.compareFields(oldFunc.Args, newFunc.Args, newFunc.Options, argumentKinds, "argument", owner)
+		c.compareFields(oldFunc.Throws, newFunc.Throws, nil, exceptionKinds, "exception", owner)
+	}
+	for _, newFunc := range new.Elems {
+		if old.FunctionByName(newFunc.Ident) == nil {
+			c.report(Safe, FunctionAdded, nil, newFunc, "method %s added to service %s", newFunc.Ident, new.Ident)
+		}
+	}
+}
+
+// compareTypes compares field types of old and new, changed is true if they are different after resolving typedefs.
+// Changes keeping wire type, e.g. string to binary, i32 to enum, or a struct to another struct, are risky, others are breaking.
+func (c *comparer) compareTypes(old, new *thrifter.FieldType) (severity Severity, oldType string, newType string, changed bool) {
+	oldResolver := resolver{program: c.oldProgram, file: c.oldFile}
+	newResolver := resolver{program: c.newProgram, file: c.newFile}
+	oldType, newType = oldResolver.typeString(old, false, 0), newResolver.typeString(new, false, 0)
+	if oldType == newType {
+		return Safe, oldType, newType, false
+	}
+	if oldResolver.typeString(old, true, 0) == newResolver.typeString(new, true, 0) {
+		return Risky, oldType, newType, true
+	}
+	return Breaking, oldType, newType, true
+}
+
+// maximum depth of typedef chain, deeper typedefs are considered as recursive definitions
+const maxTypeDepth = 32
+
+type resolver struct {
+	program *thrifter.Program
+	file    *thrifter.Thrift
+}
+
+// typeString returns canonical form of field type with typedefs resolved, if wire is true, it returns type on wire instead, e.g. i32 for enums.
+func (r resolver) typeString(ft *thrifter.FieldType, wire bool, depth int) string {
+	if ft == nil {
+		return "void"
+	}
+	switch ft.Type {
+	case thrifter.FIELD_TYPE_BASE:
+		if wire {
+			return wireBaseType(ft.BaseType)
+		}
+		return ft.BaseType
+	case thrifter.FIELD_TYPE_MAP:
+		return "map<" + r.typeString(ft.Map.Key, wire, depth) + "," + r.typeString(ft.Map.Value, wire, depth) + ">"
+	case thrifter.FIELD_TYPE_LIST:
+		return "list<" + r.typeString(ft.List.Elem, wire, depth) + ">"
+	case thrifter.FIELD_TYPE_SET:
+		return "set<" + r.typeString(ft.Set.Elem, wire, depth) + ">"
+	case thrifter.FIELD_TYPE_IDENT:
+		if r.file == nil || depth > maxTypeDepth {
+			return ft.Ident
+		}
+		decl, file := r.program.Resolve(r.file, ft.Ident)
+		switch d := decl.(type) {
+		case *thrifter.TypeDef:
+			return resolver{program: r.program, file: file}.typeString(d.Type, wire, depth+1)
+		case *thrifter.Enum:
+			if wire {
+				return "i32"
+			}
+			return d.Ident
+		case *thrifter.Struct:
+			if wire {
+				return "struct"
+			}
+			return d.Ident
+		}
+		return ft.Ident
+	}
+	return ft.String()
+}
+
+func wireBaseType(t string) string {
+	switch t {
+	case "i8":
+		return "byte"
+	case "binary":
+		return "string"
+	}
+	return t
+}
+
+func returnType(function *thrifter.Function) string {
+	if function.Void {
+		return "void"
+	}
+	return strings.Join(strings.Fields(function.FunctionType.String()), " ")
+}
+
+func requiredness(field *thrifter.Field) string {
+	if field.Requiredness == "" {
+		return "default"
+	}
+	return field.Requiredness
+}
+
+// reserved field ids and names from option reserved, e.g. (reserved = "3, 4, email")
+func reservedFields(options []*thrifter.Option) map[string]bool {
+	res := map[string]bool{}
+	for _, option := range options {
+		if option.Name != "reserved" {
+			continue
+		}
+		// option value is quoted
+		for _, item := range strings.Split(strings.Trim(option.Value, `"'`), ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				res[item] = true
+			}
+		}
+	}
+	return res
+}
+
+// source of node without white spaces and comments, empty for nil
+func normalize(node *thrifter.ConstValue) string {
+	if node == nil {
+		return ""
+	}
+	var res strings.Builder
+	for tok := node.StartToken; tok != nil; tok = tok.Next {
+		if !thrifter.IsWhitespace(tok.Type) && tok.Type != thrifter.T_COMMENT {
+			res.WriteString(tok.Raw)
+		}
+		if tok == node.EndToken {
+			break
+		}
+	}
+	return res.String()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func fieldByID(fields []*thrifter.Field, id int) *thrifter.Field {
+	for _, field := range fields {
+		if field.ID == id {
+			return field
+		}
+	}
+	return nil
+}
+
+func fieldByName(fields []*thrifter.Field, name string) *thrifter.Field {
+	for _, field := range fields {
+		if field.Ident == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// declarations with names, i.e. everything except includes and namespaces
+func declarations(file *thrifter.Thrift) (res []thrifter.Node) {
+	if file == nil {
+		return
+	}
+	for _, node := range file.Nodes {
+		if name(node) != "" {
+			res = append(res, node)
+		}
+	}
+	return
+}
+
+func lookup(decls []thrifter.Node, ident string) thrifter.Node {
+	for _, decl := range decls {
+		if name(decl) == ident {
+			return decl
+		}
+	}
+	return nil
+}
+
+func name(node thrifter.Node) string {
+	switch n := node.(type) {
+	case *thrifter.Struct:
+		return n.Ident
+	case *thrifter.Enum:
+		return n.Ident
+	case *thrifter.Service:
+		return n.Ident
+	case *thrifter.TypeDef:
+		return n.Ident
+	case *thrifter.Const:
+		return n.Ident
+	}
+	return ""
+}
+
+// keyword of declaration, e.g. struct, union or exception for Struct
+func kind(node thrifter.Node) string {
+	if n, ok := node.(*thrifter.Struct); ok {
+		return n.StartToken.Raw
+	}
+	return strings.ToLower(node.NodeType())
+}
+
+func isStruct(node thrifter.Node) bool {
+	_, ok := node.(*thrifter.Struct)
+	return ok
+}
@@ -0,0 +1,222 @@
+package compat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+func parse(t *testing.T, name string, src string) *thrifter.Thrift {
+	t.Helper()
+	res, err := thrifter.NewParser(strings.NewReader(src), false).Parse(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return res
+}
+
+type expected struct {
+	severity Severity
+	kind     Kind
+}
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		name string
+		old  string
+		new  string
+		want []expected
+	}{
+		{
+			name: "no change",
+			old:  "struct A {\n 1: i32 a // comment\n}",
+			new:  "struct A {\n\t1: i32   a\n}",
+		},
+		{
+			name: "field id reused with different type",
+			old:  "struct A {\n 1: i32 a\n}",
+			new:  "struct A {\n 1: string a\n}",
+			want: []expected{{Breaking, FieldTypeChanged}},
+		},
+		{
+			name: "wire compatible type change",
+			old:  "struct A {\n 1: string a\n 2: i32 b\n 3: list<B> c\n}\nstruct B {}\nstruct C {}\nenum E {\n X\n}",
+			new:  "struct A {\n 1: binary a\n 2: E b\n 3: list<C> c\n}\nstruct B {}\nstruct C {}\nenum E {\n X\n}",
+			want: []expected{{Risky, FieldTypeChanged}, {Risky, FieldTypeChanged}, {Risky, FieldTypeChanged}},
+		},
+		{
+			name: "typedefs are resolved",
+			old:  "typedef i64 Id\nstruct A {\n 1: i64 a\n 2: map<Id, string> b\n}",
+			new:  "typedef i64 Id\ntypedef i64 UserId\nstruct A {\n 1: UserId a\n 2: map<i64, string> b\n}",
+			want: []expected{{Safe, DeclarationAdded}},
+		},
+		{
+			name: "field removed",
+			old:  "struct A {\n 1: i32 a\n 2: required i32 b\n 3: i32 c\n}",
+			new:  "struct A {\n}",
+			want: []expected{{Risky, FieldRemoved}, {Breaking, FieldRemoved}, {Risky, FieldRemoved}},
+		},
+		{
+			name: "field removed with reservation",
+			old:  "struct A {\n 1: i32 a\n 2: i32 b\n}",
+			new:  "struct A {\n} (reserved = \"1, b\")",
+			want: []expected{{Safe, FieldRemoved}, {Safe, FieldRemoved}},
+		},
+		{
+			name: "field added",
+			old:  "struct A {\n 1: i32 a\n}",
+			new:  "struct A {\n 1: i32 a\n 2: optional i32 b\n 3: required i32 c\n}",
+			want: []expected{{Safe, FieldAdded}, {Breaking, FieldAdded}},
+		},
+		{
+			name: "field renamed and renumbered",
+			old:  "struct A {\n 1: i32 a\n 2: i32 b\n}",
+			new:  "struct A {\n 1: i32 aa\n 3: i32 b\n}",
+			want: []expected{{Risky, FieldRenamed}, {Breaking, FieldRenumbered}},
+		},
+		{
+			name: "requiredness",
+			old:  "struct A {\n 1: optional i32 a\n 2: required i32 b\n 3: i32 c\n}",
+			new:  "struct A {\n 1: required i32 a\n 2: optional i32 b\n 3: optional i32 c\n}",
+			want: []expected{{Breaking, RequirednessChanged}, {Risky, RequirednessChanged}, {Safe, RequirednessChanged}},
+		},
+		{
+			name: "default value",
+			old:  "struct A {\n 1: i32 a = 1\n 2: list<i32> b = [1, 2]\n}",
+			new:  "struct A {\n 1: i32 a = 2\n 2: list<i32> b = [ 1,2 ]\n}",
+			want: []expected{{Risky, DefaultValueChanged}},
+		},
+		{
+			name: "struct kind",
+			old:  "struct A {}\nstruct B {}",
+			new:  "union A {}\nenum B {}",
+			want: []expected{{Risky, DeclarationKindChanged}, {Breaking, DeclarationKindChanged}},
+		},
+		{
+			name: "enum",
+			old:  "enum E {\n A\n B\n C = 5\n D\n}",
+			new:  "enum E {\n A\n C = 5\n D = 7\n F\n}",
+			want: []expected{{Breaking, EnumValueRemoved}, {Breaking, EnumValueChanged}, {Safe, EnumValueAdded}},
+		},
+		{
+			name: "service",
+			old: `exception E1 {}
+			exception E2 {}
+			service S {
+				void a(1: i32 x, 2: string y) throws (1: E1 e1, 2: E2 e2)
+				i32 b()
+				void c()
+				oneway void d()
+			}`,
+			new: `exception E1 {}
+			exception E2 {}
+			service S {
+				void a(1: i64 x, 3: string z) throws (1: E1 e1)
+				i64 b()
+				i32 c()
+				void d()
+				void e()
+			}`,
+			want: []expected{
+				{Breaking, ArgumentTypeChanged},
+				{Risky, ArgumentRemoved},
+				{Safe, ArgumentAdded},
+				{Breaking, ExceptionRemoved},
+				{Breaking, ReturnTypeChanged},
+				{Breaking, ReturnTypeChanged},
+				{Breaking, OnewayChanged},
+				{Safe, FunctionAdded},
+			},
+		},
+		{
+			name: "service method removed",
+			old:  "service S extends B {\n void a()\n void b()\n}\nservice T {}",
+			new:  "service S {\n void a()\n}",
+			want: []expected{{Risky, ExtendsChanged}, {Breaking, FunctionRemoved}, {Breaking, DeclarationRemoved}},
+		},
+		{
+			name: "typedef and const",
+			old:  "typedef i32 T\nconst i32 C = 1\nconst i32 D = 1",
+			new:  "typedef i64 T\nconst i32 C = 2\nconst string D = \"1\"",
+			want: []expected{{Breaking, TypeDefChanged}, {Risky, ConstChanged}, {Risky, ConstChanged}},
+		},
+	}
+	for _, c := range cases {
+		changes := Compare(parse(t, "old.thrift", c.old), parse(t, "new.thrift", c.new))
+		if len(changes) != len(c.want) {
+			t.Errorf("%s: got [%v] want [%v]", c.name, changes, c.want)
+			continue
+		}
+		for i, change := range changes {
+			if got, want := (expected{change.Severity, change.Kind}), c.want[i]; got != want {
+				t.Errorf("%s: got [%v] want [%v], %s", c.name, got, want, change)
+			}
+		}
+	}
+}
+
+func TestCompare_positions(t *testing.T) {
+	old := parse(t, "old.thrift", "struct A {\n 1: i32 a\n}")
+	new := parse(t, "new.thrift", "\n\nstruct A {\n    1: string a\n}")
+	changes := Compare(old, new)
+	if len(changes) != 1 {
+		t.Fatalf("got [%v] want 1 change", changes)
+	}
+	change := changes[0]
+	if got, want := change.String(), `old.thrift:2:2 -> new.thrift:4:5: breaking: type of field 1 "a" of struct A changed from i32 to string`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := change.NodeID, "Struct(A).Field(1)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := Max(changes), Breaking; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(Filter(changes, Breaking)), 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestComparePrograms(t *testing.T) {
+	write := func(files map[string]string) string {
+		dir := t.TempDir()
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	oldDir := write(map[string]string{
+		"main.thrift":   "include \"shared.thrift\"\nstruct A {\n 1: shared.Id id\n}",
+		"shared.thrift": "typedef i64 Id",
+		"gone.thrift":   "struct Gone {}",
+	})
+	newDir := write(map[string]string{
+		"main.thrift":   "include \"shared.thrift\"\nstruct A {\n 1: shared.Id id\n}",
+		"shared.thrift": "typedef string Id",
+	})
+	load := func(dir string, names ...string) *thrifter.Program {
+		res := thrifter.NewProgram()
+		for _, name := range names {
+			if _, err := res.Load(filepath.Join(dir, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return res
+	}
+	changes := ComparePrograms(load(oldDir, "main.thrift", "gone.thrift"), oldDir, load(newDir, "main.thrift"), newDir)
+
+	want := []expected{{Risky, DeclarationRemoved}, {Breaking, FieldTypeChanged}, {Breaking, TypeDefChanged}}
+	if len(changes) != len(want) {
+		t.Fatalf("got [%v] want [%v]", changes, want)
+	}
+	for i, change := range changes {
+		if got := (expected{change.Severity, change.Kind}); got != want[i] {
+			t.Errorf("got [%v] want [%v], %s", got, want[i], change)
+		}
+	}
+}
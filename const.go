@@ -44,10 +44,14 @@ func (r *Const) parse(p *Parser) (err error) {
 		return
 	}
 	p.peekNonWhitespace()
-	identTok := p.nextIdent(false)
+	identTok, err := p.expectIdent(false)
+	if err != nil {
+		return err
+	}
 	r.Ident = identTok.Raw
+	r.NodeID = genNodeID("", r.NodeType(), r.Ident)
 	ru := p.peekNonWhitespace()
-	if toToken(string(ru)) != T_EQUALS {
+	if runeToken(ru) != T_EQUALS {
 		return p.unexpected(string(ru), "=")
 	}
 	p.next() // consume T_EQUALS
@@ -56,7 +60,7 @@ func (r *Const) parse(p *Parser) (err error) {
 		return
 	}
 	ru = p.peekNonWhitespace()
-	if toToken(string(ru)) == T_COMMA || toToken(string(ru)) == T_SEMICOLON {
+	if runeToken(ru) == T_COMMA || runeToken(ru) == T_SEMICOLON {
 		r.EndToken = p.next() // consume comma or semicolon
 	} else {
 		r.EndToken = r.Value.EndToken
@@ -95,7 +99,7 @@ func (r *ConstValue) String() string {
 
 func (r *ConstValue) parse(p *Parser) (err error) {
 	ru := p.peekNonWhitespace()
-	tok := toToken(string(ru))
+	tok := runeToken(ru)
 
 	// if it's minus symbol or a digit
 	if tok == T_MINUS || IsDigit(ru) {
@@ -141,7 +145,10 @@ func (r *ConstValue) parse(p *Parser) (err error) {
 		}
 		r.EndToken = r.Map.EndToken
 	} else {
-		identTok := p.nextIdent(false)
+		identTok, err := p.expectIdent(false)
+		if err != nil {
+			return err
+		}
 		if identTok.Type != T_IDENT {
 			return p.unexpected("identifier", identTok.Raw)
 		}
@@ -188,11 +195,14 @@ func (r *ConstMap) parse(p *Parser) (err error) {
 	p.peekNonWhitespace()
 	for {
 		ru := p.peekNonWhitespace() // consume white spaces
-		ruTok := toToken(string(ru))
+		ruTok := runeToken(ru)
 		if ruTok == T_RIGHTCURLY {
 			r.EndToken = p.next() // consume right curly
 			break
 		}
+		if ru == eof {
+			return p.unexpected("EOF", "}")
+		}
 
 		keyNode := NewConstValue(r)
 		err = keyNode.parse(p)
@@ -200,7 +210,7 @@ func (r *ConstMap) parse(p *Parser) (err error) {
 			return err
 		}
 		ru = p.peekNonWhitespace() // consume white spaces
-		if toToken(string(ru)) != T_COLON {
+		if runeToken(ru) != T_COLON {
 			return p.unexpected(":", string(ru))
 		}
 		p.next()              // consume T_COLON
@@ -215,7 +225,7 @@ func (r *ConstMap) parse(p *Parser) (err error) {
 		r.MapValueList = append(r.MapValueList, *valNode)
 
 		ru = p.peekNonWhitespace() // consume white spaces
-		ruTok = toToken(string(ru))
+		ruTok = runeToken(ru)
 		if ruTok == T_COMMA || ruTok == T_SEMICOLON {
 			p.next() // consume separator
 		}
@@ -254,12 +264,15 @@ func (r *ConstList) parse(p *Parser) (err error) {
 	p.peekNonWhitespace()
 	for {
 		ru := p.peekNonWhitespace()
-		nextTok := toToken(string(ru))
+		nextTok := runeToken(ru)
 		// const list end
 		if nextTok == T_RIGHTSQUARE {
 			r.EndToken = p.next() // consume right square
 			break
 		}
+		if ru == eof {
+			return p.unexpected("EOF", "]")
+		}
 		valNode := NewConstValue(r)
 		err = valNode.parse(p)
 		if err != nil {
@@ -267,7 +280,7 @@ func (r *ConstList) parse(p *Parser) (err error) {
 		}
 		r.Elems = append(r.Elems, valNode)
 		ru = p.peekNonWhitespace()
-		nextTok = toToken(string(ru))
+		nextTok = runeToken(ru)
 		if nextTok == T_COMMA || nextTok == T_SEMICOLON {
 			p.next() // consume list separator
 		}
@@ -0,0 +1,372 @@
+// Package diff computes semantic differences between two versions of a thrift file.
+//
+// Unlike a text diff, declarations are matched by name, struct fields, arguments and exceptions by field id, enum elements, functions and options by name,
+// so reordering declarations or reformatting the source produces no entries. Changes of white spaces and comments are ignored unless requested by Options.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Op is the operation of an entry.
+type Op string
+
+const (
+	Added    Op = "added"
+	Removed  Op = "removed"
+	Modified Op = "modified"
+)
+
+// Entry is a single difference between two trees.
+// For added and removed nodes, Old or New is the canonical source of the node, for modified nodes, Attr names the changed attribute, e.g. type or default.
+type Entry struct {
+	Op       Op                `json:"op"`
+	NodeType string            `json:"nodeType"`
+	Path     string            `json:"path"` // node id, nodes without id, e.g. options, are appended to id of their parent
+	Attr     string            `json:"attr,omitempty"`
+	Old      string            `json:"old,omitempty"`
+	New      string            `json:"new,omitempty"`
+	OldPos   *scanner.Position `json:"oldPos,omitempty"`
+	NewPos   *scanner.Position `json:"newPos,omitempty"`
+	OldNode  thrifter.Node     `json:"-"`
+	NewNode  thrifter.Node     `json:"-"`
+}
+
+func (e Entry) String() string {
+	switch e.Op {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", e.Path, e.New)
+	case Removed:
+		return fmt.Sprintf("- %s: %s", e.Path, e.Old)
+	}
+	return fmt.Sprintf("~ %s %s: %s -> %s", e.Path, e.Attr, orNone(e.Old), orNone(e.New))
+}
+
+// Options controls what differences are reported.
+type Options struct {
+	// Comments reports changes of doc comments, which are leading comments and trailing comment of a node.
+	Comments bool
+	// Whitespace reports changes of white spaces within declarations, fields, enum elements and functions.
+	Whitespace bool
+}
+
+// Compare returns differences from old to new, in order of old declarations, followed by added declarations.
+func Compare(old, new *thrifter.Thrift, opts Options) []Entry {
+	d := &differ{opts: opts}
+	d.compareNodes("", topLevel(old), topLevel(new))
+	return d.entries
+}
+
+// WriteText writes entries in a human readable form, one entry per line.
+func WriteText(w io.Writer, entries []Entry) error {
+	for _, entry := range entries {
+		if _, err := fmt.Fprintln(w, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes entries as a json array.
+func WriteJSON(w io.Writer, entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+type differ struct {
+	opts    Options
+	entries []Entry
+}
+
+// keyed node, key is used to match nodes of old and new
+type keyed struct {
+	key  string
+	node thrifter.Node
+}
+
+func (d *differ) add(op Op, path string, attr string, old, new thrifter.Node, oldText, newText string) {
+	entry := Entry{Op: op, Path: path, Attr: attr, Old: oldText, New: newText, OldNode: old, NewNode: new}
+	if new != nil {
+		entry.NodeType = new.NodeType()
+		entry.NewPos = position(new)
+	}
+	if old != nil {
+		entry.NodeType = old.NodeType()
+		entry.OldPos = position(old)
+	}
+	d.entries = append(d.entries, entry)
+}
+
+func (d *differ) modified(old, new thrifter.Node, attr string, oldText, newText string) {
+	if oldText != newText {
+		d.add(Modified, nodePath(new), attr, old, new, oldText, newText)
+	}
+}
+
+// compare nodes matched by key, parentPath is used for nodes without id
+func (d *differ) compareNodes(parentPath string, olds, news []keyed) {
+	newByKey := map[string]thrifter.Node{}
+	for _, n := range news {
+		newByKey[n.key] = n.node
+	}
+	oldByKey := map[string]thrifter.Node{}
+	for _, o := range olds {
+		oldByKey[o.key] = o.node
+		if n, ok := newByKey[o.key]; ok {
+			d.compareNode(parentPath, o.node, n)
+		} else {
+			d.add(Removed, pathOf(parentPath, o.node), "", o.node, nil, canonical(o.node), "")
+		}
+	}
+	for _, n := range news {
+		if _, ok := oldByKey[n.key]; !ok {
+			d.add(Added, pathOf(parentPath, n.node), "", nil, n.node, "", canonical(n.node))
+		}
+	}
+}
+
+func (d *differ) compareNode(parentPath string, old, new thrifter.Node) {
+	if d.opts.Comments {
+		d.modified(old, new, "doc", comments(old), comments(new))
+	}
+	if old.NodeType() != new.NodeType() {
+		// e.g. a struct changed to an enum, compare them as opaque nodes
+		d.modified(old, new, "kind", strings.ToLower(old.NodeType()), strings.ToLower(new.NodeType()))
+		d.modified(old, new, "definition", canonical(old), canonical(new))
+		return
+	}
+	switch o := old.(type) {
+	case *thrifter.Include:
+	case *thrifter.Namespace:
+		n := new.(*thrifter.Namespace)
+		d.modified(o, n, "value", o.Value, n.Value)
+		d.compareOptions(o, o.Options, n, n.Options)
+	case *thrifter.Struct:
+		n := new.(*thrifter.Struct)
+		d.compareNodes("", fields(o.Elems), fields(n.Elems))
+		d.compareOptions(o, o.Options, n, n.Options)
+	case *thrifter.Field:
+		n := new.(*thrifter.Field)
+		d.modified(o, n, "name", o.Ident, n.Ident)
+		d.modified(o, n, "requiredness", o.Requiredness, n.Requiredness)
+		d.modified(o, n, "type", canonical(o.FieldType), canonical(n.FieldType))
+		d.modified(o, n, "default", defaultValue(o), defaultValue(n))
+		d.compareOptions(o, o.Options, n, n.Options)
+	case *thrifter.Enum:
+		n := new.(*thrifter.Enum)
+		var olds, news []keyed
+		for _, elem := range o.Elems {
+			olds = append(olds, keyed{elem.Ident, elem})
+		}
+		for _, elem := range n.Elems {
+			news = append(news, keyed{elem.Ident, elem})
+		}
+		d.compareNodes("", olds, news)
+		d.compareOptions(o, o.Options, n, n.Options)
+	case *thrifter.EnumElement:
+		n := new.(*thrifter.EnumElement)
+		d.modified(o, n, "value", strconv.Itoa(o.Value()), strconv.Itoa(n.Value()))
+		d.compareOptions(o, o.Options, n, n.Options)
+	case *thrifter.Service:
+		n := new.(*thrifter.Service)
+		d.modified(o, n, "extends", o.Extends, n.Extends)
+		var olds, news []keyed
+		for _, function := range o.Elems {
+			olds = append(olds, keyed{function.Ident, function})
+		}
+		for _, function := range n.Elems {
+			news = append(news, keyed{function.Ident, function})
+		}
+		d.compareNodes("", olds, news)
+		d.compareOptions(o, o.Options, n, n.Options)
+	case *thrifter.Function:
+		n := new.(*thrifter.Function)
+		d.modified(o, n, "oneway", strconv.FormatBool(o.Oneway), strconv.FormatBool(n.Oneway))
+		d.modified(o, n, "returnType", returnType(o), returnType(n))
+		d.compareNodes("", fields(o.Args), fields(n.Args))
+		d.compareNodes("", fields(o.Throws), fields(n.Throws))
+		d.compareOptions(o, o.Options, n, n.Options)
+	case *thrifter.TypeDef:
+		n := new.(*thrifter.TypeDef)
+		d.modified(o, n, "type", canonical(o.Type), canonical(n.Type))
+		d.compareOptions(o, o.Options, n, n.Options)
+	case *thrifter.Const:
+		n := new.(*thrifter.Const)
+		d.modified(o, n, "type", canonical(o.Type), canonical(n.Type))
+		d.modified(o, n, "value", canonical(o.Value), canonical(n.Value))
+	case *thrifter.Option:
+		n := new.(*thrifter.Option)
+		if !sameOptionValue(o.Value, n.Value) {
+			d.add(Modified, pathOf(parentPath, n), "value", o, n, o.Value, n.Value)
+		}
+		return
+	}
+	if d.opts.Whitespace && isLeaf(old) {
+		d.modified(old, new, "source", old.String(), new.String())
+	}
+}
+
+// options are matched by name, and only their values are compared
+func (d *differ) compareOptions(oldOwner thrifter.Node, olds []*thrifter.Option, newOwner thrifter.Node, news []*thrifter.Option) {
+	var oldKeyed, newKeyed []keyed
+	for _, option := range olds {
+		oldKeyed = append(oldKeyed, keyed{option.Name, option})
+	}
+	for _, option := range news {
+		newKeyed = append(newKeyed, keyed{option.Name, option})
+	}
+	d.compareNodes(nodePath(newOwner), oldKeyed, newKeyed)
+}
+
+// quotes of option values are not compared, but an empty value differs from no value
+func sameOptionValue(old, new string) bool {
+	if old == "" || new == "" {
+		return old == new
+	}
+	return old[1:len(old)-1] == new[1:len(new)-1]
+}
+
+// nodes with keys of a file, declarations are matched by name, includes by path, and namespaces by scope
+func topLevel(file *thrifter.Thrift) (res []keyed) {
+	if file == nil {
+		return
+	}
+	for _, node := range file.Nodes {
+		switch n := node.(type) {
+		case *thrifter.Include:
+			res = append(res, keyed{"include:" + n.FilePath, n})
+		case *thrifter.Namespace:
+			res = append(res, keyed{"namespace:" + n.Name, n})
+		case *thrifter.Struct:
+			res = append(res, keyed{n.Ident, n})
+		case *thrifter.Enum:
+			res = append(res, keyed{n.Ident, n})
+		case *thrifter.Service:
+			res = append(res, keyed{n.Ident, n})
+		case *thrifter.TypeDef:
+			res = append(res, keyed{n.Ident, n})
+		case *thrifter.Const:
+			res = append(res, keyed{n.Ident, n})
+		}
+	}
+	return
+}
+
+func fields(elems []*thrifter.Field) (res []keyed) {
+	for _, field := range elems {
+		res = append(res, keyed{strconv.Itoa(field.ID), field})
+	}
+	return
+}
+
+// nodes whose source is compared in Whitespace mode, containers are not since their source contains children
+func isLeaf(node thrifter.Node) bool {
+	switch node.(type) {
+	case *thrifter.Include, *thrifter.Namespace, *thrifter.Field, *thrifter.EnumElement, *thrifter.TypeDef, *thrifter.Const:
+		return true
+	}
+	return false
+}
+
+func defaultValue(field *thrifter.Field) string {
+	if field.DefaultValue == nil {
+		return ""
+	}
+	return canonical(field.DefaultValue)
+}
+
+func returnType(function *thrifter.Function) string {
+	if function.Void {
+		return "void"
+	}
+	return canonical(function.FunctionType)
+}
+
+// path of node, which is its id, or id of parent with its name for options
+func pathOf(parentPath string, node thrifter.Node) string {
+	if option, ok := node.(*thrifter.Option); ok {
+		return parentPath + ".Option(" + option.Name + ")"
+	}
+	return nodePath(node)
+}
+
+func nodePath(node thrifter.Node) string {
+	return node.CommonField().NodeID
+}
+
+func position(node thrifter.Node) *scanner.Position {
+	if tok := node.CommonField().StartToken; tok != nil {
+		pos := tok.Pos
+		return &pos
+	}
+	return nil
+}
+
+// leading and trailing comments of node
+func comments(node thrifter.Node) string {
+	common := node.CommonField()
+	var res []string
+	for _, tok := range common.LeadingComments() {
+		res = append(res, tok.Raw)
+	}
+	if tok := common.TrailingComment(); tok != nil {
+		res = append(res, tok.Raw)
+	}
+	return strings.Join(res, "\n")
+}
+
+// canonical returns source of node with comments removed and white spaces normalized, so that formatting changes don't matter.
+func canonical(node thrifter.Node) string {
+	common := node.CommonField()
+	if common.StartToken == nil {
+		return ""
+	}
+	var res strings.Builder
+	var prev *thrifter.Token
+	for tok := common.StartToken; tok != nil; tok = tok.Next {
+		if !thrifter.IsWhitespace(tok.Type) && tok.Type != thrifter.T_COMMENT {
+			if prev != nil && needSpace(prev, tok) {
+				res.WriteByte(' ')
+			}
+			res.WriteString(tok.Raw)
+			prev = tok
+		}
+		if tok == common.EndToken {
+			break
+		}
+	}
+	return strings.TrimRight(res.String(), ",;")
+}
+
+// whether a space is needed between two adjacent tokens in canonical form
+func needSpace(prev, next *thrifter.Token) bool {
+	switch {
+	case isWord(prev) && isWord(next):
+		return true
+	case prev.Type == thrifter.T_COMMA || prev.Type == thrifter.T_COLON || prev.Type == thrifter.T_EQUALS:
+		return true
+	}
+	return next.Type == thrifter.T_EQUALS
+}
+
+func isWord(tok *thrifter.Token) bool {
+	return tok.Type == thrifter.T_IDENT || tok.Type == thrifter.T_NUMBER || tok.Type == thrifter.T_STRING || thrifter.IsKeyword(tok.Type)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,165 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+func parse(t *testing.T, src string) *thrifter.Thrift {
+	t.Helper()
+	res, err := thrifter.NewParser(strings.NewReader(src), false).Parse("test.thrift")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return res
+}
+
+const oldSrc = `namespace go user
+include "shared.thrift"
+
+// user of system
+struct User {
+	1: i64 id
+	2: string name = "anonymous" (go.tag = 'json:"name"')
+	3: optional list<string> tags
+} (deprecated = "false")
+
+enum Status {
+	ACTIVE
+	BANNED
+}
+
+service UserService {
+	User get(1: i64 id) throws (1: shared.NotFound e)
+	void ping()
+}
+
+const i32 LIMIT = 10
+`
+
+const newSrc = `namespace go user.v2
+
+/* reordered */
+enum Status {
+	ACTIVE = 1,
+	BANNED
+	DELETED
+}
+
+// user of the system
+struct User {
+	1:    i64   id,
+	2: string nickname = "anonymous" (go.tag = 'json:"nickname"', x = "y")
+	3: required list<  string > tags
+	4: i32 age
+}
+
+service UserService extends shared.Base {
+	User get(1: i64 id, 2: bool cached)
+	oneway void ping()
+}
+
+typedef i64 Timestamp
+const i64 LIMIT = 10
+`
+
+func TestCompare(t *testing.T) {
+	entries := Compare(parse(t, oldSrc), parse(t, newSrc), Options{})
+
+	want := []string{
+		`~ Namespace(go) value: user -> user.v2`,
+		`- Include(shared.thrift): include "shared.thrift"`,
+		`~ Struct(User).Field(2) name: name -> nickname`,
+		`~ Struct(User).Field(2).Option(go.tag) value: 'json:"name"' -> 'json:"nickname"'`,
+		`+ Struct(User).Field(2).Option(x): x = "y"`,
+		`~ Struct(User).Field(3) requiredness: optional -> required`,
+		`+ Struct(User).Field(4): 4: i32 age`,
+		`- Struct(User).Option(deprecated): deprecated = "false"`,
+		`~ Enum(Status).EnumElement(ACTIVE) value: 0 -> 1`,
+		`~ Enum(Status).EnumElement(BANNED) value: 1 -> 2`,
+		`+ Enum(Status).EnumElement(DELETED): DELETED`,
+		`~ Service(UserService) extends: none -> shared.Base`,
+		`+ Service(UserService).Function(get).Arg(2): 2: bool cached`,
+		`- Service(UserService).Function(get).Throws(1): 1: shared.NotFound e`,
+		`~ Service(UserService).Function(ping) oneway: false -> true`,
+		`~ Const(LIMIT) type: i32 -> i64`,
+		`+ TypeDef(Timestamp): typedef i64 Timestamp`,
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.String())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got [%v] want [%v]", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got [%v] want [%v]", got[i], want[i])
+		}
+	}
+}
+
+func TestCompare_options(t *testing.T) {
+	old := parse(t, "// a\nstruct A {\n\t1: i32 a // b\n}")
+	new := parse(t, "// c\nstruct A {\n\t1:  i32 a\n}")
+
+	if got := Compare(old, new, Options{}); len(got) != 0 {
+		t.Errorf("got [%v] want no entries", got)
+	}
+	got := Compare(old, new, Options{Comments: true})
+	if len(got) != 2 || got[0].Attr != "doc" || got[0].Path != "Struct(A)" || got[1].Attr != "doc" || got[1].Path != "Struct(A).Field(1)" {
+		t.Errorf("got [%v]", got)
+	}
+	got = Compare(old, new, Options{Whitespace: true})
+	if len(got) != 1 || got[0].Attr != "source" || got[0].Old != "1: i32 a" || got[0].New != "1:  i32 a" {
+		t.Errorf("got [%v]", got)
+	}
+}
+
+func TestCompare_optionValues(t *testing.T) {
+	old := parse(t, `struct A {} (a = 'x', b = "", c)`)
+	if got := Compare(old, parse(t, `struct A {} (a = "x", b = '', c)`), Options{}); len(got) != 0 {
+		t.Errorf("got [%v] want no entries", got)
+	}
+	got := Compare(old, parse(t, `struct A {} (a = "x", b, c = "")`), Options{})
+	if len(got) != 2 || got[0].Path != "Struct(A).Option(b)" || got[1].Path != "Struct(A).Option(c)" {
+		t.Errorf("got [%v]", got)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	entries := Compare(parse(t, "struct A {\n1: i32 a\n}"), parse(t, "struct A {\n1: i64 a\n}"), Options{})
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got [%v] want 1 entry", got)
+	}
+	want := map[string]interface{}{"op": "modified", "nodeType": "Field", "path": "Struct(A).Field(1)", "attr": "type", "old": "i32", "new": "i64"}
+	for key, value := range want {
+		if got[0][key] != value {
+			t.Errorf("%s: got [%v] want [%v]", key, got[0][key], value)
+		}
+	}
+	if pos, ok := got[0]["newPos"].(map[string]interface{}); !ok || pos["Line"] != float64(2) {
+		t.Errorf("got [%v]", got[0]["newPos"])
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "[]\n"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -0,0 +1,472 @@
+// Package doc generates documentation of thrift files as a static HTML site or Markdown, with a page per file and an index page.
+package doc
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Format of pages.
+type Format int
+
+const (
+	HTML Format = iota
+	Markdown
+)
+
+// Ext returns the extension of pages in format, i.e. .html or .md.
+func (f Format) Ext() string {
+	if f == Markdown {
+		return ".md"
+	}
+	return ".html"
+}
+
+// Generate generates pages of all files in program, keyed by slash separated paths relative to the root of site.
+//
+// A file is documented at its path relative to the common directory of files, e.g. shared/user.thrift at shared/user.html, and index.html links all files and declarations.
+// Structs, enums, services, functions, consts and typedefs are anchored by their names, e.g. user.html#User, and functions by Service.function.
+// Type references are linked to their declarations, and doc text comes from comments of nodes, see thrifter.NodeCommonField.Doc.
+func Generate(program *thrifter.Program, format Format) map[string][]byte {
+	g := &generator{program: program, format: format, pages: map[*thrifter.Thrift]string{}, extendedBy: map[*thrifter.Service][]*thrifter.Service{}}
+	files := make([]*thrifter.Thrift, 0, len(program.Files))
+	for _, file := range program.Files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].FileName < files[j].FileName
+	})
+	root := commonDir(files)
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file.FileName)
+		if err != nil {
+			rel = filepath.Base(file.FileName)
+		}
+		g.pages[file] = filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))) + format.Ext()
+		for _, node := range file.Nodes {
+			if service, ok := node.(*thrifter.Service); ok {
+				if base := g.base(service); base != nil {
+					g.extendedBy[base] = append(g.extendedBy[base], service)
+				}
+			}
+		}
+	}
+
+	res := map[string][]byte{"index" + format.Ext(): g.index(files)}
+	for _, file := range files {
+		res[g.pages[file]] = g.file(file)
+	}
+	return res
+}
+
+type generator struct {
+	program    *thrifter.Program
+	format     Format
+	pages      map[*thrifter.Thrift]string // file => path of its page
+	extendedBy map[*thrifter.Service][]*thrifter.Service
+	page       string // path of the page being generated
+	w          writer
+}
+
+func (g *generator) newWriter(page string) writer {
+	g.page = page
+	if g.format == Markdown {
+		g.w = &markdownWriter{}
+	} else {
+		g.w = &htmlWriter{}
+	}
+	return g.w
+}
+
+func (g *generator) index(files []*thrifter.Thrift) []byte {
+	w := g.newWriter("index" + g.format.Ext())
+	w.heading(1, "", w.text("Thrift Documentation"))
+	w.heading(2, "", w.text("Files"))
+	var items []string
+	for _, file := range files {
+		items = append(items, w.link(w.text(g.pages[file]), g.href(g.pages[file], "")))
+	}
+	w.list(items)
+
+	w.heading(2, "", w.text("Declarations"))
+	var decls []thrifter.Node
+	for _, file := range files {
+		for _, node := range file.Nodes {
+			if anchor(node) != "" {
+				decls = append(decls, node)
+			}
+		}
+	}
+	sort.SliceStable(decls, func(i, j int) bool {
+		return anchor(decls[i]) < anchor(decls[j])
+	})
+	var rows [][]string
+	for _, node := range decls {
+		page := g.pages[thrifter.Root(node)]
+		rows = append(rows, []string{g.linkTo(node, anchor(node)), w.text(kind(node)), w.link(w.text(page), g.href(page, "")), w.text(summary(node))})
+	}
+	w.table([]string{"Name", "Kind", "File", "Description"}, rows)
+	return w.bytes("Thrift Documentation", "")
+}
+
+func (g *generator) file(file *thrifter.Thrift) []byte {
+	page := g.pages[file]
+	w := g.newWriter(page)
+	title := strings.TrimSuffix(page, g.format.Ext()) + filepath.Ext(file.FileName)
+	w.heading(1, "", w.text(title))
+
+	var includes []string
+	for _, inc := range file.Includes() {
+		if included := g.program.Included(file, inc.Prefix()); included != nil {
+			includes = append(includes, w.link(w.text(inc.FilePath), g.href(g.pages[included], "")))
+		} else {
+			includes = append(includes, w.text(inc.FilePath))
+		}
+	}
+	if len(includes) > 0 {
+		w.heading(2, "", w.text("Includes"))
+		w.list(includes)
+	}
+	var namespaces [][]string
+	for _, node := range file.Nodes {
+		if ns, ok := node.(*thrifter.Namespace); ok {
+			namespaces = append(namespaces, []string{w.text(ns.Name), w.text(ns.Value)})
+		}
+	}
+	if len(namespaces) > 0 {
+		w.heading(2, "", w.text("Namespaces"))
+		w.table([]string{"Scope", "Namespace"}, namespaces)
+	}
+
+	sections := []struct {
+		title string
+		kinds []string
+	}{
+		{"Services", []string{"service"}},
+		{"Structs", []string{"struct", "union", "exception"}},
+		{"Enums", []string{"enum"}},
+		{"Typedefs", []string{"typedef"}},
+		{"Consts", []string{"const"}},
+	}
+	groups := make([][]thrifter.Node, len(sections))
+	var contents []string
+	for i, section := range sections {
+		for _, node := range file.Nodes {
+			for _, k := range section.kinds {
+				if kind(node) == k {
+					groups[i] = append(groups[i], node)
+					contents = append(contents, w.text(k+" ")+g.linkTo(node, anchor(node)))
+				}
+			}
+		}
+	}
+	if len(contents) > 0 {
+		w.heading(2, "", w.text("Contents"))
+		w.list(contents)
+	}
+	for i, section := range sections {
+		if len(groups[i]) == 0 {
+			continue
+		}
+		w.heading(2, "", w.text(section.title))
+		for _, node := range groups[i] {
+			g.declaration(node)
+		}
+	}
+	return w.bytes(title, g.href("index"+g.format.Ext(), ""))
+}
+
+func (g *generator) declaration(node thrifter.Node) {
+	w := g.w
+	name := anchor(node)
+	w.heading(3, name, w.text(kind(node)+" "+name))
+	w.doc(node.CommonField().Doc())
+	switch n := node.(type) {
+	case *thrifter.Struct:
+		g.annotations(n.Options)
+		g.fields(n.Elems)
+	case *thrifter.Enum:
+		g.annotations(n.Options)
+		var rows [][]string
+		for _, elem := range n.Elems {
+			rows = append(rows, []string{w.text(elem.Ident), w.text(strconv.Itoa(elem.Value())), g.options(elem.Options), w.text(elem.Doc())})
+		}
+		w.table([]string{"Name", "Value", "Annotations", "Description"}, rows)
+	case *thrifter.TypeDef:
+		w.line(w.text("Type: ") + g.typeText(n.Type))
+		g.annotations(n.Options)
+	case *thrifter.Const:
+		w.line(w.text("Type: ") + g.typeText(n.Type))
+		w.line(w.text("Value: ") + g.value(n.Value))
+	case *thrifter.Service:
+		g.service(n)
+	}
+}
+
+func (g *generator) service(service *thrifter.Service) {
+	w := g.w
+	g.annotations(service.Options)
+	var chain []string
+	for base, seen := g.base(service), map[*thrifter.Service]bool{service: true}; base != nil && !seen[base]; base = g.base(base) {
+		seen[base] = true
+		chain = append(chain, g.linkTo(base, base.Ident))
+	}
+	if len(chain) > 0 {
+		w.line(w.text("Extends: ") + strings.Join(chain, w.text(" → ")))
+	}
+	if extendedBy := g.extendedBy[service]; len(extendedBy) > 0 {
+		var links []string
+		for _, s := range extendedBy {
+			links = append(links, g.linkTo(s, s.Ident))
+		}
+		w.line(w.text("Extended by: ") + strings.Join(links, w.text(", ")))
+	}
+
+	for _, fn := range service.Elems {
+		name := service.Ident + "." + fn.Ident
+		w.heading(4, name, w.text(name))
+		w.line(g.signature(fn))
+		w.doc(fn.Doc())
+		g.annotations(fn.Options)
+		if len(fn.Args) > 0 {
+			w.line(w.text("Arguments:"))
+			g.fields(fn.Args)
+		}
+		if len(fn.Throws) > 0 {
+			w.line(w.text("Throws:"))
+			g.fields(fn.Throws)
+		}
+	}
+
+	var inherited []string
+	for base, seen := g.base(service), map[*thrifter.Service]bool{service: true}; base != nil && !seen[base]; base = g.base(base) {
+		seen[base] = true
+		for _, fn := range base.Elems {
+			inherited = append(inherited, g.linkTo(fn, base.Ident+"."+fn.Ident))
+		}
+	}
+	if len(inherited) > 0 {
+		w.line(w.text("Inherited functions:"))
+		w.list(inherited)
+	}
+}
+
+// signature of fn, e.g. oneway void log(1: string line) throws (1: Error e)
+func (g *generator) signature(fn *thrifter.Function) string {
+	w := g.w
+	var b strings.Builder
+	if fn.Oneway {
+		b.WriteString(w.text("oneway "))
+	}
+	if fn.Void {
+		b.WriteString(w.text("void"))
+	} else {
+		b.WriteString(g.typeText(fn.FunctionType))
+	}
+	b.WriteString(w.text(" " + fn.Ident + "("))
+	g.signatureFields(&b, fn.Args)
+	b.WriteString(w.text(")"))
+	if len(fn.Throws) > 0 {
+		b.WriteString(w.text(" throws ("))
+		g.signatureFields(&b, fn.Throws)
+		b.WriteString(w.text(")"))
+	}
+	return b.String()
+}
+
+func (g *generator) signatureFields(b *strings.Builder, fields []*thrifter.Field) {
+	for i, field := range fields {
+		if i > 0 {
+			b.WriteString(g.w.text(", "))
+		}
+		b.WriteString(g.w.text(fmt.Sprintf("%d: ", field.ID)))
+		if field.Requiredness != "" {
+			b.WriteString(g.w.text(field.Requiredness + " "))
+		}
+		b.WriteString(g.typeText(field.FieldType))
+		b.WriteString(g.w.text(" " + field.Ident))
+	}
+}
+
+func (g *generator) fields(fields []*thrifter.Field) {
+	w := g.w
+	var rows [][]string
+	for _, field := range fields {
+		requiredness := field.Requiredness
+		if requiredness == "" {
+			requiredness = "default"
+		}
+		var value string
+		if field.DefaultValue != nil {
+			value = g.value(field.DefaultValue)
+		}
+		rows = append(rows, []string{w.text(strconv.Itoa(field.ID)), w.text(field.Ident), w.text(requiredness), g.typeText(field.FieldType), value, g.options(field.Options), w.text(field.Doc())})
+	}
+	w.table([]string{"ID", "Name", "Requiredness", "Type", "Default", "Annotations", "Description"}, rows)
+}
+
+func (g *generator) annotations(options []*thrifter.Option) {
+	if len(options) > 0 {
+		g.w.line(g.w.text("Annotations: ") + g.options(options))
+	}
+}
+
+func (g *generator) options(options []*thrifter.Option) string {
+	var res []string
+	for _, opt := range options {
+		res = append(res, g.w.code(opt.String()))
+	}
+	return strings.Join(res, g.w.text(", "))
+}
+
+// typeText is ft with identifiers linked to their declarations
+func (g *generator) typeText(ft *thrifter.FieldType) string {
+	w := g.w
+	switch ft.Type {
+	case thrifter.FIELD_TYPE_BASE:
+		return w.text(ft.BaseType)
+	case thrifter.FIELD_TYPE_MAP:
+		return w.text("map<") + g.typeText(ft.Map.Key) + w.text(", ") + g.typeText(ft.Map.Value) + w.text(">")
+	case thrifter.FIELD_TYPE_LIST:
+		return w.text("list<") + g.typeText(ft.List.Elem) + w.text(">")
+	case thrifter.FIELD_TYPE_SET:
+		return w.text("set<") + g.typeText(ft.Set.Elem) + w.text(">")
+	}
+	return g.reference(ft, ft.Ident)
+}
+
+// value is the source of a const value, which is linked if it's a reference
+func (g *generator) value(cv *thrifter.ConstValue) string {
+	if cv.Type == thrifter.CONST_VALUE_IDENT {
+		return g.reference(cv, cv.Value)
+	}
+	return g.w.code(cv.String())
+}
+
+// reference links name referred in node to its declaration, enum elements are linked to their enums
+func (g *generator) reference(node thrifter.Node, name string) string {
+	var decl thrifter.Node
+	if file := thrifter.Root(node); file != nil {
+		decl, _ = g.program.Resolve(file, name)
+	}
+	if elem, ok := decl.(*thrifter.EnumElement); ok {
+		decl = elem.Parent
+	}
+	if decl == nil || anchor(decl) == "" {
+		return g.w.text(name)
+	}
+	return g.linkTo(decl, name)
+}
+
+// linkTo links text to the anchor of node
+func (g *generator) linkTo(node thrifter.Node, text string) string {
+	page := g.pages[thrifter.Root(node)]
+	if page == "" {
+		return g.w.text(text)
+	}
+	return g.w.link(g.w.text(text), g.href(page, anchor(node)))
+}
+
+// href of the anchor in page, relative to the page being generated
+func (g *generator) href(page, anchor string) string {
+	res := ""
+	if page != g.page {
+		rel, err := filepath.Rel(path.Dir(g.page), page)
+		if err != nil {
+			rel = page
+		}
+		res = filepath.ToSlash(rel)
+	}
+	if anchor != "" {
+		res += "#" + anchor
+	}
+	return res
+}
+
+// base returns the service which service extends, or nil
+func (g *generator) base(service *thrifter.Service) *thrifter.Service {
+	if service.Extends == "" {
+		return nil
+	}
+	file := thrifter.Root(service)
+	if file == nil {
+		return nil
+	}
+	base, _ := g.program.Resolve(file, service.Extends)
+	res, _ := base.(*thrifter.Service)
+	return res
+}
+
+// anchor of a declaration or function, or empty for other nodes
+func anchor(node thrifter.Node) string {
+	switch n := node.(type) {
+	case *thrifter.Struct:
+		return n.Ident
+	case *thrifter.Enum:
+		return n.Ident
+	case *thrifter.Service:
+		return n.Ident
+	case *thrifter.TypeDef:
+		return n.Ident
+	case *thrifter.Const:
+		return n.Ident
+	case *thrifter.Function:
+		if service, ok := n.Parent.(*thrifter.Service); ok {
+			return service.Ident + "." + n.Ident
+		}
+	}
+	return ""
+}
+
+// kind of a declaration as the keyword declaring it
+func kind(node thrifter.Node) string {
+	switch n := node.(type) {
+	case *thrifter.Struct:
+		switch n.Type {
+		case thrifter.UNION:
+			return "union"
+		case thrifter.EXCEPTION:
+			return "exception"
+		}
+		return "struct"
+	case *thrifter.Enum:
+		return "enum"
+	case *thrifter.Service:
+		return "service"
+	case *thrifter.TypeDef:
+		return "typedef"
+	case *thrifter.Const:
+		return "const"
+	}
+	return ""
+}
+
+// summary is the first line of doc of node
+func summary(node thrifter.Node) string {
+	res, _, _ := strings.Cut(node.CommonField().Doc(), "\n")
+	return res
+}
+
+func commonDir(files []*thrifter.Thrift) string {
+	if len(files) == 0 {
+		return ""
+	}
+	res := filepath.Dir(files[0].FileName)
+	for _, file := range files[1:] {
+		dir := filepath.Dir(file.FileName)
+		for res != dir && !strings.HasPrefix(dir, res+string(filepath.Separator)) {
+			parent := filepath.Dir(res)
+			if parent == res {
+				break
+			}
+			res = parent
+		}
+	}
+	return res
+}
@@ -0,0 +1,162 @@
+package doc
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+var files = map[string]string{
+	"api/user.thrift": `include "../shared.thrift"
+
+namespace go api
+
+typedef list<User> Users
+
+/**
+ * A user.
+ *
+ * Rendered as <html>.
+ */
+struct User {
+  1: required i64 id // unique id
+  2: optional shared.Color color = shared.Color.RED (go.tag = "color")
+  3: map<string, Users> friends
+}
+
+exception NotFound {
+  1: string message
+}
+
+const list<i32> IDS = [1, 2]
+
+service UserService extends shared.Base {
+  /** Gets a user. */
+  User get(1: i64 id) throws (1: NotFound missing)
+  oneway void log(1: string line)
+}
+`,
+	"shared.thrift": `// Color of things.
+enum Color {
+  RED = 1
+  GREEN (deprecated = "true")
+}
+
+service Root {
+  void reset()
+}
+
+service Base extends Root {
+  i32 ping()
+}
+`,
+}
+
+func load(t *testing.T) *thrifter.Program {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	program := thrifter.NewProgram()
+	if _, err := program.Load(filepath.Join(dir, "api/user.thrift")); err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func TestGenerate_html(t *testing.T) {
+	pages := Generate(load(t), HTML)
+	var names []string
+	for name := range pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if got, want := strings.Join(names, ","), "api/user.html,index.html,shared.html"; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+
+	user := string(pages["api/user.html"])
+	for _, want := range []string{
+		`<h1>api/user.thrift</h1>`,
+		`<a href="../index.html">Index</a>`,
+		`<a href="../shared.html">../shared.thrift</a>`,
+		`<td>go</td><td>api</td>`,
+		`<h3 id="User">struct User</h3>`,
+		`<h3 id="NotFound">exception NotFound</h3>`,
+		`<h3 id="Users">typedef Users</h3>`,
+		`<h3 id="IDS">const IDS</h3>`,
+		`<h4 id="UserService.get">UserService.get</h4>`,
+		// doc text in paragraphs, escaped
+		"<p>A user.</p>\n<p>Rendered as &lt;html&gt;.</p>",
+		"<p>Gets a user.</p>",
+		// field tables
+		`<tr><td>1</td><td>id</td><td>required</td><td>i64</td><td></td><td></td><td>unique id</td></tr>`,
+		`<tr><td>2</td><td>color</td><td>optional</td><td><a href="../shared.html#Color">shared.Color</a></td><td><a href="../shared.html#Color">shared.Color.RED</a></td><td><code>go.tag = &#34;color&#34;</code></td><td></td></tr>`,
+		`<td>map&lt;string, <a href="#Users">Users</a>&gt;</td>`,
+		`<p>Type: list&lt;<a href="#User">User</a>&gt;</p>`,
+		`<p>Value: <code>[1, 2]</code></p>`,
+		`<p><a href="#User">User</a> get(1: i64 id) throws (1: <a href="#NotFound">NotFound</a> missing)</p>`,
+		`<p>oneway void log(1: string line)</p>`,
+		// inheritance
+		`<p>Extends: <a href="../shared.html#Base">Base</a> → <a href="../shared.html#Root">Root</a></p>`,
+		`<li><a href="../shared.html#Base.ping">Base.ping</a></li>` + "\n" + `<li><a href="../shared.html#Root.reset">Root.reset</a></li>`,
+	} {
+		if !strings.Contains(user, want) {
+			t.Errorf("want [%v] in\n%s", want, user)
+		}
+	}
+
+	shared := string(pages["shared.html"])
+	for _, want := range []string{
+		`<p>Extended by: <a href="#Base">Base</a></p>`,
+		`<p>Extended by: <a href="api/user.html#UserService">UserService</a></p>`,
+		`<tr><td>GREEN</td><td>2</td><td><code>deprecated = &#34;true&#34;</code></td><td></td></tr>`,
+	} {
+		if !strings.Contains(shared, want) {
+			t.Errorf("want [%v] in\n%s", want, shared)
+		}
+	}
+
+	index := string(pages["index.html"])
+	for _, want := range []string{
+		`<li><a href="api/user.html">api/user.html</a></li>`,
+		`<tr><td><a href="shared.html#Color">Color</a></td><td>enum</td><td><a href="shared.html">shared.html</a></td><td>Color of things.</td></tr>`,
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("want [%v] in\n%s", want, index)
+		}
+	}
+}
+
+func TestGenerate_markdown(t *testing.T) {
+	pages := Generate(load(t), Markdown)
+	user := string(pages["api/user.md"])
+	for _, want := range []string{
+		"[Index](../index.md)\n\n# api/user.thrift\n",
+		"<a id=\"User\"></a>\n\n### struct User\n\nA user.\n\nRendered as <html>.\n",
+		"| ID | Name | Requiredness | Type | Default | Annotations | Description |\n| --- | --- | --- | --- | --- | --- | --- |\n| 1 | id | required | i64 |  |  | unique id |\n",
+		"| 2 | color | optional | [shared.Color](../shared.md#Color) | [shared.Color.RED](../shared.md#Color) | ` go.tag = \"color\" ` |  |",
+		"| 3 | friends | default | map\\<string, [Users](#Users)\\> |  |  |  |",
+		"[User](#User) get(1: i64 id) throws (1: [NotFound](#NotFound) missing)",
+		"Extends: [Base](../shared.md#Base) → [Root](../shared.md#Root)",
+		"- [Base.ping](../shared.md#Base.ping)\n- [Root.reset](../shared.md#Root.reset)\n",
+	} {
+		if !strings.Contains(user, want) {
+			t.Errorf("want [%v] in\n%s", want, user)
+		}
+	}
+	if index := string(pages["index.md"]); !strings.Contains(index, "| [UserService](api/user.md#UserService) | service | [api/user.md](api/user.md) |  |") {
+		t.Errorf("got %s", index)
+	}
+}
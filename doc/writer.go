@@ -0,0 +1,184 @@
+package doc
+
+import (
+	"html"
+	"strings"
+)
+
+// writer writes a page in a format, content arguments are already escaped by text, code or link
+type writer interface {
+	text(s string) string
+	code(s string) string
+	link(content, href string) string
+	heading(level int, anchor string, content string)
+	doc(text string)
+	line(content string)
+	list(items []string)
+	table(header []string, rows [][]string)
+	// bytes returns the page titled title, which links to the index page at home unless it's empty
+	bytes(title string, home string) []byte
+}
+
+const style = `body { font-family: sans-serif; max-width: 960px; margin: 0 auto; padding: 0 16px; line-height: 1.5 }
+table { border-collapse: collapse; margin: 8px 0 }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top }
+code { background: #f4f4f4; padding: 0 4px }
+h3, h4 { border-top: 1px solid #eee; padding-top: 8px }
+`
+
+type htmlWriter struct {
+	strings.Builder
+}
+
+func (w *htmlWriter) text(s string) string {
+	return html.EscapeString(s)
+}
+
+func (w *htmlWriter) code(s string) string {
+	return "<code>" + html.EscapeString(s) + "</code>"
+}
+
+func (w *htmlWriter) link(content, href string) string {
+	return `<a href="` + html.EscapeString(href) + `">` + content + "</a>"
+}
+
+func (w *htmlWriter) heading(level int, anchor string, content string) {
+	tag := "h" + string(rune('0'+level))
+	if anchor != "" {
+		w.WriteString("<" + tag + ` id="` + html.EscapeString(anchor) + `">` + content + "</" + tag + ">\n")
+	} else {
+		w.WriteString("<" + tag + ">" + content + "</" + tag + ">\n")
+	}
+}
+
+func (w *htmlWriter) doc(text string) {
+	for _, p := range paragraphs(text) {
+		w.WriteString("<p>" + html.EscapeString(p) + "</p>\n")
+	}
+}
+
+func (w *htmlWriter) line(content string) {
+	w.WriteString("<p>" + content + "</p>\n")
+}
+
+func (w *htmlWriter) list(items []string) {
+	w.WriteString("<ul>\n")
+	for _, item := range items {
+		w.WriteString("<li>" + item + "</li>\n")
+	}
+	w.WriteString("</ul>\n")
+}
+
+func (w *htmlWriter) table(header []string, rows [][]string) {
+	w.WriteString("<table>\n<tr>")
+	for _, cell := range header {
+		w.WriteString("<th>" + html.EscapeString(cell) + "</th>")
+	}
+	w.WriteString("</tr>\n")
+	for _, row := range rows {
+		w.WriteString("<tr>")
+		for _, cell := range row {
+			w.WriteString("<td>" + cell + "</td>")
+		}
+		w.WriteString("</tr>\n")
+	}
+	w.WriteString("</table>\n")
+}
+
+func (w *htmlWriter) bytes(title string, home string) []byte {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>" + html.EscapeString(title) + "</title>\n<style>\n" + style + "</style>\n</head>\n<body>\n")
+	if home != "" {
+		b.WriteString(`<nav><a href="` + html.EscapeString(home) + "\">Index</a></nav>\n")
+	}
+	b.WriteString(w.String())
+	b.WriteString("</body>\n</html>\n")
+	return []byte(b.String())
+}
+
+type markdownWriter struct {
+	strings.Builder
+}
+
+// characters escaped by backslashes, which are special in inline Markdown or tables
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "|", `\|`, "#", `\#`, "\n", " ")
+
+func (w *markdownWriter) text(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+func (w *markdownWriter) code(s string) string {
+	// a code span is delimited by more backticks than it contains, and pipes are still special in tables
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	return fence + " " + strings.ReplaceAll(s, "|", `\|`) + " " + fence
+}
+
+func (w *markdownWriter) link(content, href string) string {
+	return "[" + content + "](" + strings.ReplaceAll(href, " ", "%20") + ")"
+}
+
+func (w *markdownWriter) heading(level int, anchor string, content string) {
+	if anchor != "" {
+		w.WriteString(`<a id="` + html.EscapeString(anchor) + "\"></a>\n\n")
+	}
+	w.WriteString(strings.Repeat("#", level) + " " + content + "\n\n")
+}
+
+func (w *markdownWriter) doc(text string) {
+	for _, p := range paragraphs(text) {
+		w.WriteString(p + "\n\n")
+	}
+}
+
+func (w *markdownWriter) line(content string) {
+	w.WriteString(content + "\n\n")
+}
+
+func (w *markdownWriter) list(items []string) {
+	for _, item := range items {
+		w.WriteString("- " + item + "\n")
+	}
+	w.WriteString("\n")
+}
+
+func (w *markdownWriter) table(header []string, rows [][]string) {
+	w.WriteString("|")
+	for _, cell := range header {
+		w.WriteString(" " + w.text(cell) + " |")
+	}
+	w.WriteString("\n|")
+	for range header {
+		w.WriteString(" --- |")
+	}
+	w.WriteString("\n")
+	for _, row := range rows {
+		w.WriteString("|")
+		for _, cell := range row {
+			w.WriteString(" " + cell + " |")
+		}
+		w.WriteString("\n")
+	}
+	w.WriteString("\n")
+}
+
+func (w *markdownWriter) bytes(title string, home string) []byte {
+	var b strings.Builder
+	if home != "" {
+		b.WriteString("[Index](" + home + ")\n\n")
+	}
+	b.WriteString(w.String())
+	return []byte(strings.TrimRight(b.String(), "\n") + "\n")
+}
+
+// paragraphs of doc text separated by blank lines
+func paragraphs(text string) (res []string) {
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			res = append(res, p)
+		}
+	}
+	return
+}
@@ -0,0 +1,294 @@
+package dynamic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/YYCoder/thrifter"
+)
+
+const (
+	binaryVersion     = 0x80010000
+	binaryVersionMask = 0xffff0000
+)
+
+// BinaryWriter writes values in TBinaryProtocol, big-endian with fixed-size integers.
+type BinaryWriter struct {
+	w   *bufio.Writer
+	buf [8]byte
+}
+
+// NewBinaryWriter creates a BinaryWriter, which buffers writes until Flush.
+func NewBinaryWriter(w io.Writer) *BinaryWriter {
+	return &BinaryWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteMessageBegin writes the header of message in the strict form with the version
+func (b *BinaryWriter) WriteMessageBegin(name string, typ MessageType, seqid int32) error {
+	if err := b.WriteI32(int32(binaryVersion | uint32(typ))); err != nil {
+		return err
+	}
+	if err := b.WriteString(name); err != nil {
+		return err
+	}
+	return b.WriteI32(seqid)
+}
+
+func (b *BinaryWriter) WriteMessageEnd() error             { return nil }
+func (b *BinaryWriter) WriteStructBegin(name string) error { return nil }
+func (b *BinaryWriter) WriteStructEnd() error              { return nil }
+func (b *BinaryWriter) WriteFieldEnd() error               { return nil }
+func (b *BinaryWriter) WriteMapEnd() error                 { return nil }
+func (b *BinaryWriter) WriteListEnd() error                { return nil }
+func (b *BinaryWriter) WriteSetEnd() error                 { return nil }
+
+func (b *BinaryWriter) WriteFieldBegin(name string, typ Type, id int16) error {
+	if err := b.w.WriteByte(byte(typ)); err != nil {
+		return err
+	}
+	return b.WriteI16(id)
+}
+
+func (b *BinaryWriter) WriteFieldStop() error {
+	return b.w.WriteByte(byte(STOP))
+}
+
+func (b *BinaryWriter) WriteMapBegin(key Type, value Type, size int) error {
+	b.w.WriteByte(byte(key))
+	b.w.WriteByte(byte(value))
+	return b.writeSize(size)
+}
+
+func (b *BinaryWriter) WriteListBegin(elem Type, size int) error {
+	b.w.WriteByte(byte(elem))
+	return b.writeSize(size)
+}
+
+func (b *BinaryWriter) WriteSetBegin(elem Type, size int) error {
+	return b.WriteListBegin(elem, size)
+}
+
+func (b *BinaryWriter) WriteBool(v bool) error {
+	if v {
+		return b.w.WriteByte(1)
+	}
+	return b.w.WriteByte(0)
+}
+
+func (b *BinaryWriter) WriteI8(v int8) error {
+	return b.w.WriteByte(byte(v))
+}
+
+func (b *BinaryWriter) WriteI16(v int16) error {
+	binary.BigEndian.PutUint16(b.buf[:2], uint16(v))
+	_, err := b.w.Write(b.buf[:2])
+	return err
+}
+
+func (b *BinaryWriter) WriteI32(v int32) error {
+	binary.BigEndian.PutUint32(b.buf[:4], uint32(v))
+	_, err := b.w.Write(b.buf[:4])
+	return err
+}
+
+func (b *BinaryWriter) WriteI64(v int64) error {
+	binary.BigEndian.PutUint64(b.buf[:8], uint64(v))
+	_, err := b.w.Write(b.buf[:8])
+	return err
+}
+
+func (b *BinaryWriter) WriteDouble(v float64) error {
+	return b.WriteI64(int64(math.Float64bits(v)))
+}
+
+func (b *BinaryWriter) WriteString(v string) error {
+	if err := b.writeSize(len(v)); err != nil {
+		return err
+	}
+	_, err := b.w.WriteString(v)
+	return err
+}
+
+func (b *BinaryWriter) WriteBinary(v []byte) error {
+	if err := b.writeSize(len(v)); err != nil {
+		return err
+	}
+	_, err := b.w.Write(v)
+	return err
+}
+
+func (b *BinaryWriter) Flush() error {
+	return b.w.Flush()
+}
+
+func (b *BinaryWriter) writeSize(size int) error {
+	if size > math.MaxInt32 {
+		return fmt.Errorf("size %d is too large", size)
+	}
+	return b.WriteI32(int32(size))
+}
+
+// BinaryReader reads values in TBinaryProtocol.
+// It reads no more than values, so it can read successive messages from a connection, which should be buffered by the caller.
+type BinaryReader struct {
+	r   io.Reader
+	buf [8]byte
+}
+
+func NewBinaryReader(r io.Reader) *BinaryReader {
+	return &BinaryReader{r: r}
+}
+
+// ReadMessageBegin reads the header of message in the strict form, or in the old form starting with the name
+func (b *BinaryReader) ReadMessageBegin() (name string, typ MessageType, seqid int32, err error) {
+	size, err := b.ReadI32()
+	if err != nil {
+		return
+	}
+	if size < 0 {
+		if version := uint32(size) & binaryVersionMask; version != binaryVersion {
+			return "", 0, 0, fmt.Errorf("bad binary protocol version %#x", version)
+		}
+		typ = MessageType(size & 0xff)
+		if name, err = b.ReadString(); err != nil {
+			return
+		}
+	} else {
+		data, err := readBytes(b.r, int(size))
+		if err != nil {
+			return "", 0, 0, err
+		}
+		name = string(data)
+		t, err := b.ReadI8()
+		if err != nil {
+			return "", 0, 0, err
+		}
+		typ = MessageType(t)
+	}
+	seqid, err = b.ReadI32()
+	return
+}
+
+func (b *BinaryReader) ReadMessageEnd() error  { return nil }
+func (b *BinaryReader) ReadStructBegin() error { return nil }
+func (b *BinaryReader) ReadStructEnd() error   { return nil }
+func (b *BinaryReader) ReadFieldEnd() error    { return nil }
+func (b *BinaryReader) ReadMapEnd() error      { return nil }
+func (b *BinaryReader) ReadListEnd() error     { return nil }
+func (b *BinaryReader) ReadSetEnd() error      { return nil }
+
+func (b *BinaryReader) ReadFieldBegin() (name string, typ Type, id int16, err error) {
+	t, err := b.ReadI8()
+	if err != nil || Type(t) == STOP {
+		return "", Type(t), 0, err
+	}
+	id, err = b.ReadI16()
+	return "", Type(t), id, err
+}
+
+func (b *BinaryReader) ReadMapBegin() (key Type, value Type, size int, err error) {
+	if _, err = io.ReadFull(b.r, b.buf[:2]); err != nil {
+		return
+	}
+	key, value = Type(b.buf[0]), Type(b.buf[1])
+	size, err = b.readSize()
+	return
+}
+
+func (b *BinaryReader) ReadListBegin() (elem Type, size int, err error) {
+	t, err := b.ReadI8()
+	if err != nil {
+		return
+	}
+	size, err = b.readSize()
+	return Type(t), size, err
+}
+
+func (b *BinaryReader) ReadSetBegin() (elem Type, size int, err error) {
+	return b.ReadListBegin()
+}
+
+func (b *BinaryReader) ReadBool() (bool, error) {
+	v, err := b.ReadI8()
+	return v != 0, err
+}
+
+func (b *BinaryReader) ReadI8() (int8, error) {
+	_, err := io.ReadFull(b.r, b.buf[:1])
+	return int8(b.buf[0]), err
+}
+
+func (b *BinaryReader) ReadI16() (int16, error) {
+	_, err := io.ReadFull(b.r, b.buf[:2])
+	return int16(binary.BigEndian.Uint16(b.buf[:2])), err
+}
+
+func (b *BinaryReader) ReadI32() (int32, error) {
+	_, err := io.ReadFull(b.r, b.buf[:4])
+	return int32(binary.BigEndian.Uint32(b.buf[:4])), err
+}
+
+func (b *BinaryReader) ReadI64() (int64, error) {
+	_, err := io.ReadFull(b.r, b.buf[:8])
+	return int64(binary.BigEndian.Uint64(b.buf[:8])), err
+}
+
+func (b *BinaryReader) ReadDouble() (float64, error) {
+	v, err := b.ReadI64()
+	return math.Float64frombits(uint64(v)), err
+}
+
+func (b *BinaryReader) ReadString() (string, error) {
+	v, err := b.ReadBinary()
+	return string(v), err
+}
+
+func (b *BinaryReader) ReadBinary() ([]byte, error) {
+	size, err := b.readSize()
+	if err != nil {
+		return nil, err
+	}
+	return readBytes(b.r, size)
+}
+
+func (b *BinaryReader) readSize() (int, error) {
+	size, err := b.ReadI32()
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("negative size %d", size)
+	}
+	return int(size), nil
+}
+
+// readBytes reads n bytes, the buffer grows as data arrives, since n may be corrupted
+func readBytes(r io.Reader, n int) ([]byte, error) {
+	if n <= 4096 {
+		res := make([]byte, n)
+		_, err := io.ReadFull(r, res)
+		return res, err
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeBinary encodes value as struct st in TBinaryProtocol.
+func (c *Codec) EncodeBinary(st *thrifter.Struct, value map[string]any) ([]byte, error) {
+	return c.encode(st, value, func(w io.Writer) Writer { return NewBinaryWriter(w) })
+}
+
+// DecodeBinary decodes a value of struct st in TBinaryProtocol, data after the value is an error.
+func (c *Codec) DecodeBinary(st *thrifter.Struct, data []byte) (map[string]any, error) {
+	return c.decode(st, data, func(r io.Reader) Reader { return NewBinaryReader(r) })
+}
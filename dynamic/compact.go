@@ -0,0 +1,462 @@
+package dynamic
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// types of TCompactProtocol, bools of fields are encoded in their types
+const (
+	compactBooleanTrue  = 1
+	compactBooleanFalse = 2
+	compactByte         = 3
+	compactI16          = 4
+	compactI32          = 5
+	compactI64          = 6
+	compactDouble       = 7
+	compactBinary       = 8
+	compactList         = 9
+	compactSet          = 10
+	compactMap          = 11
+	compactStruct       = 12
+)
+
+const (
+	compactProtocolID = 0x82
+	compactVersion    = 1
+)
+
+func compactType(typ Type) byte {
+	switch typ {
+	case BOOL:
+		return compactBooleanTrue
+	case BYTE:
+		return compactByte
+	case I16:
+		return compactI16
+	case I32:
+		return compactI32
+	case I64:
+		return compactI64
+	case DOUBLE:
+		return compactDouble
+	case STRING:
+		return compactBinary
+	case LIST:
+		return compactList
+	case SET:
+		return compactSet
+	case MAP:
+		return compactMap
+	case STRUCT:
+		return compactStruct
+	}
+	return byte(STOP)
+}
+
+func typeOfCompact(t byte) (Type, error) {
+	switch t {
+	case 0:
+		return STOP, nil
+	case compactBooleanTrue, compactBooleanFalse:
+		return BOOL, nil
+	case compactByte:
+		return BYTE, nil
+	case compactI16:
+		return I16, nil
+	case compactI32:
+		return I32, nil
+	case compactI64:
+		return I64, nil
+	case compactDouble:
+		return DOUBLE, nil
+	case compactBinary:
+		return STRING, nil
+	case compactList:
+		return LIST, nil
+	case compactSet:
+		return SET, nil
+	case compactMap:
+		return MAP, nil
+	case compactStruct:
+		return STRUCT, nil
+	}
+	return STOP, fmt.Errorf("unknown compact type %d", t)
+}
+
+// CompactWriter writes values in TCompactProtocol, i.e. integers in zigzag varints, field ids in deltas, and bools of fields in their types.
+type CompactWriter struct {
+	w       *bufio.Writer
+	buf     [binary.MaxVarintLen64]byte
+	lastID  int16
+	lastIDs []int16 // of outer structs
+	boolID  int16   // id of the bool field whose header is written along with its value
+	inBool  bool
+}
+
+// NewCompactWriter creates a CompactWriter, which buffers writes until Flush.
+func NewCompactWriter(w io.Writer) *CompactWriter {
+	return &CompactWriter{w: bufio.NewWriter(w)}
+}
+
+func (c *CompactWriter) WriteMessageBegin(name string, typ MessageType, seqid int32) error {
+	c.w.WriteByte(compactProtocolID)
+	c.w.WriteByte(compactVersion | byte(typ)<<5)
+	// seqid is a varint without zigzag
+	n := binary.PutUvarint(c.buf[:], uint64(uint32(seqid)))
+	c.w.Write(c.buf[:n])
+	return c.WriteString(name)
+}
+
+func (c *CompactWriter) WriteMessageEnd() error { return nil }
+
+func (c *CompactWriter) WriteStructBegin(name string) error {
+	c.lastIDs = append(c.lastIDs, c.lastID)
+	c.lastID = 0
+	return nil
+}
+
+func (c *CompactWriter) WriteStructEnd() error {
+	c.lastID = c.lastIDs[len(c.lastIDs)-1]
+	c.lastIDs = c.lastIDs[:len(c.lastIDs)-1]
+	return nil
+}
+
+func (c *CompactWriter) WriteFieldBegin(name string, typ Type, id int16) error {
+	if typ == BOOL {
+		c.boolID, c.inBool = id, true
+		return nil
+	}
+	return c.writeFieldHeader(compactType(typ), id)
+}
+
+func (c *CompactWriter) writeFieldHeader(t byte, id int16) error {
+	if delta := int(id) - int(c.lastID); delta > 0 && delta <= 15 {
+		c.w.WriteByte(byte(delta)<<4 | t)
+	} else {
+		c.w.WriteByte(t)
+		c.writeVarint(int64(id))
+	}
+	c.lastID = id
+	return nil
+}
+
+func (c *CompactWriter) WriteFieldEnd() error { return nil }
+func (c *CompactWriter) WriteMapEnd() error   { return nil }
+func (c *CompactWriter) WriteListEnd() error  { return nil }
+func (c *CompactWriter) WriteSetEnd() error   { return nil }
+
+func (c *CompactWriter) WriteFieldStop() error {
+	return c.w.WriteByte(byte(STOP))
+}
+
+func (c *CompactWriter) WriteMapBegin(key Type, value Type, size int) error {
+	if size == 0 {
+		return c.w.WriteByte(0)
+	}
+	if err := c.writeSize(size); err != nil {
+		return err
+	}
+	return c.w.WriteByte(compactType(key)<<4 | compactType(value))
+}
+
+func (c *CompactWriter) WriteListBegin(elem Type, size int) error {
+	if size < 15 {
+		return c.w.WriteByte(byte(size)<<4 | compactType(elem))
+	}
+	c.w.WriteByte(0xf0 | compactType(elem))
+	return c.writeSize(size)
+}
+
+func (c *CompactWriter) WriteSetBegin(elem Type, size int) error {
+	return c.WriteListBegin(elem, size)
+}
+
+func (c *CompactWriter) WriteBool(v bool) error {
+	t := byte(compactBooleanFalse)
+	if v {
+		t = compactBooleanTrue
+	}
+	if c.inBool {
+		c.inBool = false
+		return c.writeFieldHeader(t, c.boolID)
+	}
+	return c.w.WriteByte(t)
+}
+
+func (c *CompactWriter) WriteI8(v int8) error {
+	return c.w.WriteByte(byte(v))
+}
+
+func (c *CompactWriter) WriteI16(v int16) error {
+	return c.writeVarint(int64(v))
+}
+
+func (c *CompactWriter) WriteI32(v int32) error {
+	return c.writeVarint(int64(v))
+}
+
+func (c *CompactWriter) WriteI64(v int64) error {
+	return c.writeVarint(v)
+}
+
+// WriteDouble writes v in little-endian, unlike the binary protocol
+func (c *CompactWriter) WriteDouble(v float64) error {
+	binary.LittleEndian.PutUint64(c.buf[:8], math.Float64bits(v))
+	_, err := c.w.Write(c.buf[:8])
+	return err
+}
+
+func (c *CompactWriter) WriteString(v string) error {
+	if err := c.writeSize(len(v)); err != nil {
+		return err
+	}
+	_, err := c.w.WriteString(v)
+	return err
+}
+
+func (c *CompactWriter) WriteBinary(v []byte) error {
+	if err := c.writeSize(len(v)); err != nil {
+		return err
+	}
+	_, err := c.w.Write(v)
+	return err
+}
+
+func (c *CompactWriter) Flush() error {
+	return c.w.Flush()
+}
+
+// writeVarint writes v in zigzag varint
+func (c *CompactWriter) writeVarint(v int64) error {
+	n := binary.PutVarint(c.buf[:], v)
+	_, err := c.w.Write(c.buf[:n])
+	return err
+}
+
+// writeSize writes size in varint without zigzag
+func (c *CompactWriter) writeSize(size int) error {
+	if size > math.MaxInt32 {
+		return fmt.Errorf("size %d is too large", size)
+	}
+	n := binary.PutUvarint(c.buf[:], uint64(size))
+	_, err := c.w.Write(c.buf[:n])
+	return err
+}
+
+// CompactReader reads values in TCompactProtocol.
+// It reads no more than values, so it can read successive messages from a connection, which should be buffered by the caller.
+type CompactReader struct {
+	r       io.Reader
+	buf     [8]byte
+	lastID  int16
+	lastIDs []int16
+	boolVal bool // value of the bool field whose header is read
+	inBool  bool
+}
+
+func NewCompactReader(r io.Reader) *CompactReader {
+	return &CompactReader{r: r}
+}
+
+func (c *CompactReader) ReadMessageBegin() (name string, typ MessageType, seqid int32, err error) {
+	id, err := c.ReadByte()
+	if err != nil {
+		return
+	}
+	if id != compactProtocolID {
+		return "", 0, 0, fmt.Errorf("bad compact protocol id %#x", id)
+	}
+	b, err := c.ReadByte()
+	if err != nil {
+		return
+	}
+	if version := b & 0x1f; version != compactVersion {
+		return "", 0, 0, fmt.Errorf("bad compact protocol version %d", version)
+	}
+	typ = MessageType(b >> 5)
+	v, err := binary.ReadUvarint(c)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return
+	}
+	if v > math.MaxUint32 {
+		return "", 0, 0, fmt.Errorf("seqid %d overflows i32", v)
+	}
+	name, err = c.ReadString()
+	return name, typ, int32(uint32(v)), err
+}
+
+func (c *CompactReader) ReadMessageEnd() error { return nil }
+
+func (c *CompactReader) ReadStructBegin() error {
+	c.lastIDs = append(c.lastIDs, c.lastID)
+	c.lastID = 0
+	return nil
+}
+
+func (c *CompactReader) ReadStructEnd() error {
+	c.lastID = c.lastIDs[len(c.lastIDs)-1]
+	c.lastIDs = c.lastIDs[:len(c.lastIDs)-1]
+	return nil
+}
+
+func (c *CompactReader) ReadFieldBegin() (name string, typ Type, id int16, err error) {
+	b, err := c.ReadByte()
+	if err != nil {
+		return
+	}
+	if typ, err = typeOfCompact(b & 0x0f); err != nil || typ == STOP {
+		return
+	}
+	if delta := b >> 4; delta != 0 {
+		id = c.lastID + int16(delta)
+	} else {
+		v, err := c.readVarint()
+		if err != nil {
+			return "", typ, 0, err
+		}
+		if v < math.MinInt16 || v > math.MaxInt16 {
+			return "", typ, 0, fmt.Errorf("field id %d overflows i16", v)
+		}
+		id = int16(v)
+	}
+	c.lastID = id
+	if typ == BOOL {
+		c.boolVal, c.inBool = b&0x0f == compactBooleanTrue, true
+	}
+	return
+}
+
+func (c *CompactReader) ReadFieldEnd() error { return nil }
+func (c *CompactReader) ReadMapEnd() error   { return nil }
+func (c *CompactReader) ReadListEnd() error  { return nil }
+func (c *CompactReader) ReadSetEnd() error   { return nil }
+
+func (c *CompactReader) ReadMapBegin() (key Type, value Type, size int, err error) {
+	if size, err = c.readSize(); err != nil || size == 0 {
+		return
+	}
+	b, err := c.ReadByte()
+	if err != nil {
+		return
+	}
+	if key, err = typeOfCompact(b >> 4); err != nil {
+		return
+	}
+	value, err = typeOfCompact(b & 0x0f)
+	return
+}
+
+func (c *CompactReader) ReadListBegin() (elem Type, size int, err error) {
+	b, err := c.ReadByte()
+	if err != nil {
+		return
+	}
+	if elem, err = typeOfCompact(b & 0x0f); err != nil {
+		return
+	}
+	size = int(b >> 4)
+	if size == 15 {
+		size, err = c.readSize()
+	}
+	return
+}
+
+func (c *CompactReader) ReadSetBegin() (elem Type, size int, err error) {
+	return c.ReadListBegin()
+}
+
+func (c *CompactReader) ReadBool() (bool, error) {
+	if c.inBool {
+		c.inBool = false
+		return c.boolVal, nil
+	}
+	b, err := c.ReadByte()
+	return b == compactBooleanTrue, err
+}
+
+func (c *CompactReader) ReadI8() (int8, error) {
+	b, err := c.ReadByte()
+	return int8(b), err
+}
+
+func (c *CompactReader) ReadI16() (int16, error) {
+	v, err := c.readVarint()
+	if err == nil && (v < math.MinInt16 || v > math.MaxInt16) {
+		err = fmt.Errorf("%d overflows i16", v)
+	}
+	return int16(v), err
+}
+
+func (c *CompactReader) ReadI32() (int32, error) {
+	v, err := c.readVarint()
+	if err == nil && (v < math.MinInt32 || v > math.MaxInt32) {
+		err = fmt.Errorf("%d overflows i32", v)
+	}
+	return int32(v), err
+}
+
+func (c *CompactReader) ReadI64() (int64, error) {
+	return c.readVarint()
+}
+
+func (c *CompactReader) ReadDouble() (float64, error) {
+	_, err := io.ReadFull(c.r, c.buf[:8])
+	return math.Float64frombits(binary.LittleEndian.Uint64(c.buf[:8])), err
+}
+
+func (c *CompactReader) ReadString() (string, error) {
+	v, err := c.ReadBinary()
+	return string(v), err
+}
+
+func (c *CompactReader) ReadBinary() ([]byte, error) {
+	size, err := c.readSize()
+	if err != nil {
+		return nil, err
+	}
+	return readBytes(c.r, size)
+}
+
+// ReadByte implements io.ByteReader for reading varints.
+func (c *CompactReader) ReadByte() (byte, error) {
+	_, err := io.ReadFull(c.r, c.buf[:1])
+	return c.buf[0], err
+}
+
+func (c *CompactReader) readVarint() (int64, error) {
+	v, err := binary.ReadVarint(c)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+func (c *CompactReader) readSize() (int, error) {
+	v, err := binary.ReadUvarint(c)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil && v > math.MaxInt32 {
+		err = fmt.Errorf("size %d is too large", v)
+	}
+	return int(v), err
+}
+
+// EncodeCompact encodes value as struct st in TCompactProtocol.
+func (c *Codec) EncodeCompact(st *thrifter.Struct, value map[string]any) ([]byte, error) {
+	return c.encode(st, value, func(w io.Writer) Writer { return NewCompactWriter(w) })
+}
+
+// DecodeCompact decodes a value of struct st in TCompactProtocol, data after the value is an error.
+func (c *Codec) DecodeCompact(st *thrifter.Struct, data []byte) (map[string]any, error) {
+	return c.decode(st, data, func(r io.Reader) Reader { return NewCompactReader(r) })
+}
@@ -0,0 +1,111 @@
+package dynamic
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func shapeCompact(t *testing.T) []byte {
+	return fixture(t,
+		"18 02 7371",          // name = "sq", delta 1
+		"15 04",               // color = GREEN, zigzag 2
+		"19 18 01 61",         // tags = ["a"], 1 string
+		"1b 01 8c 01 6f",      // points = {"o": ..., 1 string to struct
+		"14 02 14 01 00",      // ... {x: 1, y: -1}}
+		"1a 13 03",            // flags = [3], 1 byte
+		"11",                  // filled = true, in the type
+		"17 000000000000e03f", // ratio = 0.5, little-endian
+		"16 808080808040",     // id = 1 << 40, zigzag 1 << 41
+		"18 01 ff",            // data = 0xff
+		"1c 15 0e 00",         // meta = {version: 7}
+		"00",
+	)
+}
+
+func TestEncodeCompact(t *testing.T) {
+	codec, file := load(t)
+	got, err := codec.EncodeCompact(structOf(t, file, "Shape"), shapeValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := shapeCompact(t); !bytes.Equal(got, want) {
+		t.Errorf("got  %x\nwant %x", got, want)
+	}
+}
+
+func TestDecodeCompact(t *testing.T) {
+	codec, file := load(t)
+	got, err := codec.DecodeCompact(structOf(t, file, "Shape"), shapeCompact(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, shapeValue) {
+		t.Errorf("got  %#v\nwant %#v", got, shapeValue)
+	}
+}
+
+// field ids not in 1 to 15 after the last one are written in full, the last id is restored after nested structs
+func TestDecodeCompact_skip(t *testing.T) {
+	codec, file := load(t)
+	data := fixture(t,
+		"0c c601 11 1c 12 00 00", // unknown struct field 99 with a bool and a struct of a bool
+		"08 02 00",               // name = "", field 1 in full after 99
+		"12",                     // unknown bool field 2 of wrong type
+		"05 04 08",               // color = 4, field 2 in full, zigzag
+		"00",
+	)
+	got, err := codec.DecodeCompact(structOf(t, file, "Shape"), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"name": "", "color": int32(4)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v want %#v", got, want)
+	}
+}
+
+func TestCompact_roundTrip(t *testing.T) {
+	codec, file := load(t)
+	st := structOf(t, file, "Nested")
+	colors := make([]any, 20)
+	for i := range colors {
+		colors[i] = "RED"
+	}
+	value := map[string]any{
+		"matrix": []any{map[any]any{int32(-1): colors, int32(300): []any{}}, map[any]any{}},
+		"seen":   map[any]any{"k": true, "l": false},
+	}
+	data, err := codec.EncodeCompact(st, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 20 elements don't fit in the header of list
+	if !bytes.Contains(data, []byte{0xf5, 20}) {
+		t.Errorf("got %x", data)
+	}
+	got, err := codec.DecodeCompact(st, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("got %#v want %#v", got, value)
+	}
+}
+
+func TestDecodeCompact_errors(t *testing.T) {
+	codec, file := load(t)
+	cases := map[string]string{
+		"18 05 73":             "unexpected EOF",
+		"18 01 73 0d":          "unknown compact type 13",
+		"18 01 73 15 ff":       "unexpected EOF",
+		"18 01 73 00 00":       "1 bytes remain after Shape",
+		"18 01 73 29 15 02 00": "Shape.tags: expected elements of string, got i32",
+	}
+	for data, want := range cases {
+		_, err := codec.DecodeCompact(structOf(t, file, "Shape"), fixture(t, data))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got [%v] want [%v]", data, err, want)
+		}
+	}
+}
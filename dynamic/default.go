@@ -0,0 +1,119 @@
+package dynamic
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// setDefaults sets default values of fields missing in value, then checks all required fields are set.
+func (c *Codec) setDefaults(st *thrifter.Struct, value map[string]any, path string, depth int) error {
+	for _, field := range st.Elems {
+		if _, ok := value[field.Ident]; ok {
+			continue
+		}
+		if field.DefaultValue != nil {
+			s, err := c.resolve(field.FieldType)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", path, field.Ident, err)
+			}
+			v, err := c.program.EvalValue(field.FieldType, field.DefaultValue)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", path, field.Ident, err)
+			}
+			if value[field.Ident], err = c.decoded(s, v, path+"."+field.Ident, depth+1); err != nil {
+				return err
+			}
+		} else if field.Requiredness == "required" {
+			return fmt.Errorf("%s: required field %s is missing", path, field.Ident)
+		}
+	}
+	return nil
+}
+
+// decoded converts v evaluated by thrifter.Program.EvalValue to the decoded form of s, e.g. a default value of field.
+func (c *Codec) decoded(s *schema, v any, path string, depth int) (any, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	switch s.typ {
+	case STRUCT:
+		fields, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected %s, got %T", path, s.name, v)
+		}
+		res := make(map[string]any, len(fields))
+		for name, fv := range fields {
+			field := s.st.FieldByName(name)
+			if field == nil {
+				return nil, fmt.Errorf("%s: unknown field %s", path, name)
+			}
+			fs, err := c.resolve(field.FieldType)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", path, name, err)
+			}
+			if res[name], err = c.decoded(fs, fv, path+"."+name, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case LIST, SET:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("%s: expected %s, got %T", path, s.name, v)
+		}
+		res := make([]any, rv.Len())
+		for i := range res {
+			var err error
+			if res[i], err = c.decoded(s.elem, rv.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case MAP:
+		if entries, ok := v.([]MapEntry); ok {
+			res := make([]MapEntry, len(entries))
+			for i, entry := range entries {
+				elemPath := fmt.Sprintf("%s[%d]", path, i)
+				var err error
+				if res[i].Key, err = c.decoded(s.key, entry.Key, elemPath, depth+1); err != nil {
+					return nil, err
+				}
+				if res[i].Value, err = c.decoded(s.elem, entry.Value, elemPath, depth+1); err != nil {
+					return nil, err
+				}
+			}
+			return res, nil
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Map {
+			return nil, fmt.Errorf("%s: expected %s, got %T", path, s.name, v)
+		}
+		res := make(map[any]any, rv.Len())
+		for _, key := range rv.MapKeys() {
+			elemPath := fmt.Sprintf("%s[%v]", path, key.Interface())
+			// binary keys are strings in both forms
+			k := key.Interface()
+			var err error
+			if !s.key.binary {
+				if k, err = c.decoded(s.key, k, elemPath, depth+1); err != nil {
+					return nil, err
+				}
+			}
+			if res[k], err = c.decoded(s.elem, rv.MapIndex(key).Interface(), elemPath, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+
+	v, err := scalar(s, v, path)
+	if err != nil {
+		return nil, err
+	}
+	if s.enum != nil {
+		return enumName(s, v.(int32)), nil
+	}
+	return v, nil
+}
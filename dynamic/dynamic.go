@@ -0,0 +1,807 @@
+// Package dynamic encodes and decodes thrift payloads without generated code, using structs parsed by thrifter as schemas.
+//
+// Values are generic trees:
+//
+//	struct, union, exception  map[string]any keyed by field names
+//	bool                      bool
+//	byte, i8                  int8
+//	i16, i32, i64             int16, int32, int64
+//	double                    float64
+//	string                    string
+//	binary                    []byte
+//	enum                      string of element name, or int32 if the value has no element
+//	list, set                 []any
+//	map                       map[any]any, binary keys are decoded as strings
+//	                          []MapEntry in wire order if keys are structs or containers
+//
+// On encoding, values are accepted loosely, e.g. any integer or integral float64 for integer types, so values decoded from JSON can be encoded directly.
+// Typedefs are resolved to their types, and identifiers are resolved across includes by a thrifter.Program.
+// On decoding, default values of missing fields are set, as generated code does.
+package dynamic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Type is a type of values on the wire, whose values are the same as type ids of the binary protocol.
+type Type byte
+
+const (
+	STOP   Type = 0
+	VOID   Type = 1
+	BOOL   Type = 2
+	BYTE   Type = 3
+	DOUBLE Type = 4
+	I16    Type = 6
+	I32    Type = 8
+	I64    Type = 10
+	STRING Type = 11
+	STRUCT Type = 12
+	MAP    Type = 13
+	SET    Type = 14
+	LIST   Type = 15
+)
+
+func (t Type) String() string {
+	switch t {
+	case STOP:
+		return "stop"
+	case VOID:
+		return "void"
+	case BOOL:
+		return "bool"
+	case BYTE:
+		return "byte"
+	case DOUBLE:
+		return "double"
+	case I16:
+		return "i16"
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case STRING:
+		return "string"
+	case STRUCT:
+		return "struct"
+	case MAP:
+		return "map"
+	case SET:
+		return "set"
+	case LIST:
+		return "list"
+	}
+	return fmt.Sprintf("type(%d)", byte(t))
+}
+
+// Writer writes values in a wire protocol, names are informational for protocols which don't need them.
+type Writer interface {
+	WriteMessageBegin(name string, typ MessageType, seqid int32) error
+	WriteMessageEnd() error
+	WriteStructBegin(name string) error
+	WriteStructEnd() error
+	WriteFieldBegin(name string, typ Type, id int16) error
+	WriteFieldEnd() error
+	WriteFieldStop() error
+	WriteMapBegin(key Type, value Type, size int) error
+	WriteMapEnd() error
+	WriteListBegin(elem Type, size int) error
+	WriteListEnd() error
+	WriteSetBegin(elem Type, size int) error
+	WriteSetEnd() error
+	WriteBool(v bool) error
+	WriteI8(v int8) error
+	WriteI16(v int16) error
+	WriteI32(v int32) error
+	WriteI64(v int64) error
+	WriteDouble(v float64) error
+	WriteString(v string) error
+	WriteBinary(v []byte) error
+	Flush() error
+}
+
+// Reader reads values in a wire protocol, it's the counterpart of Writer.
+type Reader interface {
+	ReadMessageBegin() (name string, typ MessageType, seqid int32, err error)
+	ReadMessageEnd() error
+	ReadStructBegin() error
+	ReadStructEnd() error
+	ReadFieldBegin() (name string, typ Type, id int16, err error)
+	ReadFieldEnd() error
+	ReadMapBegin() (key Type, value Type, size int, err error)
+	ReadMapEnd() error
+	ReadListBegin() (elem Type, size int, err error)
+	ReadListEnd() error
+	ReadSetBegin() (elem Type, size int, err error)
+	ReadSetEnd() error
+	ReadBool() (bool, error)
+	ReadI8() (int8, error)
+	ReadI16() (int16, error)
+	ReadI32() (int32, error)
+	ReadI64() (int64, error)
+	ReadDouble() (float64, error)
+	ReadString() (string, error)
+	ReadBinary() ([]byte, error)
+}
+
+// MaxDepth is the max depth of nested structs and containers, deeper values are rejected.
+const MaxDepth = 64
+
+// Codec encodes and decodes values of types declared in files of a program.
+type Codec struct {
+	program *thrifter.Program
+}
+
+// NewCodec creates a codec resolving identifiers in program, or only within their own files if program is nil.
+func NewCodec(program *thrifter.Program) *Codec {
+	if program == nil {
+		program = thrifter.NewProgram()
+	}
+	return &Codec{program: program}
+}
+
+// Write writes value as struct st.
+func (c *Codec) Write(w Writer, st *thrifter.Struct, value map[string]any) error {
+	if err := c.writeStruct(w, st, value, st.Ident, 0); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Read reads a value of struct st.
+func (c *Codec) Read(r Reader, st *thrifter.Struct) (map[string]any, error) {
+	return c.readStruct(r, st, st.Ident, 0)
+}
+
+func (c *Codec) encode(st *thrifter.Struct, value map[string]any, newWriter func(io.Writer) Writer) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.Write(newWriter(&buf), st, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *Codec) decode(st *thrifter.Struct, data []byte, newReader func(io.Reader) Reader) (map[string]any, error) {
+	r := bytes.NewReader(data)
+	res, err := c.Read(newReader(r), st)
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("%d bytes remain after %s", r.Len(), st.Ident)
+	}
+	return res, nil
+}
+
+// schema is a resolved FieldType
+type schema struct {
+	typ       Type
+	name      string // base type or declaration name
+	binary    bool
+	enum      *thrifter.Enum
+	st        *thrifter.Struct
+	key, elem *schema // key and value of map, or elem of list and set
+}
+
+// resolve resolves identifiers and typedefs in ft
+func (c *Codec) resolve(ft *thrifter.FieldType) (*schema, error) {
+	return c.resolveVisiting(ft, map[*thrifter.TypeDef]bool{})
+}
+
+// resolveVisiting resolves ft, visiting are typedefs being resolved, to find cyclic typedefs
+func (c *Codec) resolveVisiting(ft *thrifter.FieldType, visiting map[*thrifter.TypeDef]bool) (*schema, error) {
+	switch ft.Type {
+	case thrifter.FIELD_TYPE_BASE:
+		return baseSchema(ft.BaseType)
+	case thrifter.FIELD_TYPE_MAP:
+		key, err := c.resolveVisiting(ft.Map.Key, visiting)
+		if err != nil {
+			return nil, err
+		}
+		value, err := c.resolveVisiting(ft.Map.Value, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{typ: MAP, name: "map", key: key, elem: value}, nil
+	case thrifter.FIELD_TYPE_LIST:
+		elem, err := c.resolveVisiting(ft.List.Elem, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{typ: LIST, name: "list", elem: elem}, nil
+	case thrifter.FIELD_TYPE_SET:
+		elem, err := c.resolveVisiting(ft.Set.Elem, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{typ: SET, name: "set", elem: elem}, nil
+	}
+
+	var decl thrifter.Node
+	if file := thrifter.Root(ft); file != nil {
+		decl, _ = c.program.Resolve(file, ft.Ident)
+	}
+	switch d := decl.(type) {
+	case *thrifter.Struct:
+		return &schema{typ: STRUCT, name: d.Ident, st: d}, nil
+	case *thrifter.Enum:
+		return &schema{typ: I32, name: d.Ident, enum: d}, nil
+	case *thrifter.TypeDef:
+		if visiting[d] {
+			return nil, fmt.Errorf("typedef %s refers to itself", d.Ident)
+		}
+		visiting[d] = true
+		defer delete(visiting, d)
+		return c.resolveVisiting(d.Type, visiting)
+	}
+	return nil, fmt.Errorf("unknown type %s", ft.Ident)
+}
+
+func baseSchema(name string) (*schema, error) {
+	res := &schema{name: name}
+	switch name {
+	case "bool":
+		res.typ = BOOL
+	case "byte", "i8":
+		res.typ = BYTE
+	case "i16":
+		res.typ = I16
+	case "i32":
+		res.typ = I32
+	case "i64":
+		res.typ = I64
+	case "double":
+		res.typ = DOUBLE
+	case "string", "slist":
+		res.typ = STRING
+	case "binary":
+		res.typ, res.binary = STRING, true
+	default:
+		return nil, fmt.Errorf("unknown type %s", name)
+	}
+	return res, nil
+}
+
+func (c *Codec) writeStruct(w Writer, st *thrifter.Struct, value map[string]any, path string, depth int) error {
+	if depth > MaxDepth {
+		return fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	fields, err := presentFields(st, value, path)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteStructBegin(st.Ident); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		s, err := c.resolve(field.FieldType)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", path, field.Ident, err)
+		}
+		if err := w.WriteFieldBegin(field.Ident, s.typ, int16(field.ID)); err != nil {
+			return err
+		}
+		if err := c.writeValue(w, s, value[field.Ident], path+"."+field.Ident, depth+1); err != nil {
+			return err
+		}
+		if err := w.WriteFieldEnd(); err != nil {
+			return err
+		}
+	}
+	if err := w.WriteFieldStop(); err != nil {
+		return err
+	}
+	return w.WriteStructEnd()
+}
+
+// presentFields returns fields of st set in value in order of declaration,
+// it checks that value has no unknown fields, all required fields are set, and a union has exactly one field set.
+func presentFields(st *thrifter.Struct, value map[string]any, path string) ([]*thrifter.Field, error) {
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if st.FieldByName(name) == nil {
+			return nil, fmt.Errorf("%s: unknown field %s", path, name)
+		}
+	}
+	var res []*thrifter.Field
+	for _, field := range st.Elems {
+		if v, ok := value[field.Ident]; ok && v != nil {
+			res = append(res, field)
+		} else if field.Requiredness == "required" {
+			return nil, fmt.Errorf("%s: required field %s is missing", path, field.Ident)
+		}
+	}
+	if st.Type == thrifter.UNION && len(res) != 1 {
+		return nil, fmt.Errorf("%s: union must have exactly one field set, got %d", path, len(res))
+	}
+	return res, nil
+}
+
+func (c *Codec) writeValue(w Writer, s *schema, v any, path string, depth int) error {
+	if depth > MaxDepth {
+		return fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	switch s.typ {
+	case STRUCT:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected %s, got %T", path, s.name, v)
+		}
+		return c.writeStruct(w, s.st, m, path, depth)
+	case LIST, SET:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fmt.Errorf("%s: expected %s, got %T", path, s.name, v)
+		}
+		var err error
+		if s.typ == LIST {
+			err = w.WriteListBegin(s.elem.typ, rv.Len())
+		} else {
+			err = w.WriteSetBegin(s.elem.typ, rv.Len())
+		}
+		if err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := c.writeValue(w, s.elem, rv.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return err
+			}
+		}
+		if s.typ == LIST {
+			return w.WriteListEnd()
+		}
+		return w.WriteSetEnd()
+	case MAP:
+		entries, err := mapEntries(s, v, path)
+		if err != nil {
+			return err
+		}
+		if err := w.WriteMapBegin(s.key.typ, s.elem.typ, len(entries)); err != nil {
+			return err
+		}
+		for i, entry := range entries {
+			elemPath := entryPath(s, path, i, entry.Key)
+			if err := c.writeValue(w, s.key, entry.Key, elemPath, depth+1); err != nil {
+				return err
+			}
+			if err := c.writeValue(w, s.elem, entry.Value, elemPath, depth+1); err != nil {
+				return err
+			}
+		}
+		return w.WriteMapEnd()
+	}
+
+	v, err := scalar(s, v, path)
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case bool:
+		return w.WriteBool(v)
+	case int8:
+		return w.WriteI8(v)
+	case int16:
+		return w.WriteI16(v)
+	case int32:
+		return w.WriteI32(v)
+	case int64:
+		return w.WriteI64(v)
+	case float64:
+		return w.WriteDouble(v)
+	case string:
+		return w.WriteString(v)
+	case []byte:
+		return w.WriteBinary(v)
+	}
+	return fmt.Errorf("%s: unsupported type %s", path, s.name)
+}
+
+// keys of map sorted so that encoding is deterministic
+func sortedKeys(rv reflect.Value) []reflect.Value {
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+// scalar converts v to the Go type of base type or enum s, i.e. bool, int8, int16, int32, int64, float64, string, or []byte for binary.
+// Enums are converted to int32 values.
+func scalar(s *schema, v any, path string) (any, error) {
+	mismatch := fmt.Errorf("%s: expected %s, got %T", path, s.name, v)
+	switch s.typ {
+	case BOOL:
+		if _, ok := v.(bool); ok {
+			return v, nil
+		}
+		return nil, mismatch
+	case BYTE, I16, I32, I64:
+		if s.enum != nil {
+			if name, ok := v.(string); ok {
+				elem := s.enum.ElementByName(name)
+				if elem == nil {
+					return nil, fmt.Errorf("%s: %s has no element %s", path, s.enum.Ident, name)
+				}
+				return int32(elem.Value()), nil
+			}
+		}
+		n, ok := toInt(v)
+		if !ok {
+			return nil, mismatch
+		}
+		switch {
+		case s.typ == BYTE && n >= math.MinInt8 && n <= math.MaxInt8:
+			return int8(n), nil
+		case s.typ == I16 && n >= math.MinInt16 && n <= math.MaxInt16:
+			return int16(n), nil
+		case s.typ == I32 && n >= math.MinInt32 && n <= math.MaxInt32:
+			return int32(n), nil
+		case s.typ == I64:
+			return n, nil
+		}
+		return nil, fmt.Errorf("%s: %d overflows %s", path, n, s.name)
+	case DOUBLE:
+		if f, ok := toFloat(v); ok {
+			return f, nil
+		}
+		return nil, mismatch
+	case STRING:
+		switch str := v.(type) {
+		case string:
+			if s.binary {
+				return []byte(str), nil
+			}
+			return str, nil
+		case []byte:
+			if s.binary {
+				return str, nil
+			}
+			return string(str), nil
+		}
+		return nil, mismatch
+	}
+	return nil, mismatch
+}
+
+// hashable reports whether values of s can be keys of map[any]any
+func hashable(s *schema) bool {
+	return s.typ != STRUCT && s.typ != MAP && s.typ != LIST && s.typ != SET
+}
+
+// MapEntry is an entry of map whose keys are structs or containers, which can't be keys of map[any]any.
+type MapEntry = thrifter.MapEntry
+
+// mapEntries returns entries of map value v of map type s, either a Go map, whose entries are sorted by keys, or a []MapEntry
+func mapEntries(s *schema, v any, path string) ([]MapEntry, error) {
+	if entries, ok := v.([]MapEntry); ok {
+		return entries, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return nil, fmt.Errorf("%s: expected %s, got %T", path, s.name, v)
+	}
+	keys := sortedKeys(rv)
+	entries := make([]MapEntry, len(keys))
+	for i, key := range keys {
+		entries[i] = MapEntry{Key: key.Interface(), Value: rv.MapIndex(key).Interface()}
+	}
+	return entries, nil
+}
+
+// entryPath is the path of i-th entry of map at path, by its key if the key is a scalar
+func entryPath(s *schema, path string, i int, key any) string {
+	if !hashable(s.key) {
+		return fmt.Sprintf("%s[%d]", path, i)
+	}
+	return fmt.Sprintf("%s[%v]", path, key)
+}
+
+// enumName returns the name of element of enum s whose value is n, or n if there is no such element
+func enumName(s *schema, n int32) any {
+	for _, elem := range s.enum.Elems {
+		if elem.Value() == int(n) {
+			return elem.Ident
+		}
+	}
+	return n
+}
+
+func (c *Codec) readStruct(r Reader, st *thrifter.Struct, path string, depth int) (map[string]any, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	if err := r.ReadStructBegin(); err != nil {
+		return nil, err
+	}
+	res := map[string]any{}
+	for {
+		_, typ, id, err := r.ReadFieldBegin()
+		if err != nil {
+			return nil, err
+		}
+		if typ == STOP {
+			break
+		}
+		field := st.FieldByID(int(id))
+		var s *schema
+		if field != nil {
+			if s, err = c.resolve(field.FieldType); err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", path, field.Ident, err)
+			}
+		}
+		// unknown fields and fields of other types are skipped, like generated code does
+		if s == nil || s.typ != typ {
+			if err := skip(r, typ, depth+1); err != nil {
+				return nil, err
+			}
+		} else if res[field.Ident], err = c.readValue(r, s, path+"."+field.Ident, depth+1); err != nil {
+			return nil, err
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		return nil, err
+	}
+	if err := c.setDefaults(st, res, path, depth); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Codec) readValue(r Reader, s *schema, path string, depth int) (any, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	switch s.typ {
+	case BOOL:
+		return r.ReadBool()
+	case BYTE:
+		return r.ReadI8()
+	case I16:
+		return r.ReadI16()
+	case I32:
+		n, err := r.ReadI32()
+		if err != nil || s.enum == nil {
+			return n, err
+		}
+		return enumName(s, n), nil
+	case I64:
+		return r.ReadI64()
+	case DOUBLE:
+		return r.ReadDouble()
+	case STRING:
+		if s.binary {
+			return r.ReadBinary()
+		}
+		return r.ReadString()
+	case STRUCT:
+		return c.readStruct(r, s.st, path, depth)
+	case LIST, SET:
+		var elem Type
+		var size int
+		var err error
+		if s.typ == LIST {
+			elem, size, err = r.ReadListBegin()
+		} else {
+			elem, size, err = r.ReadSetBegin()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if size > 0 && elem != s.elem.typ {
+			return nil, fmt.Errorf("%s: expected elements of %s, got %s", path, s.elem.typ, elem)
+		}
+		res := make([]any, 0, capacity(size))
+		for i := 0; i < size; i++ {
+			v, err := c.readValue(r, s.elem, fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+		if s.typ == LIST {
+			err = r.ReadListEnd()
+		} else {
+			err = r.ReadSetEnd()
+		}
+		return res, err
+	case MAP:
+		key, value, size, err := r.ReadMapBegin()
+		if err != nil {
+			return nil, err
+		}
+		if size > 0 && (key != s.key.typ || value != s.elem.typ) {
+			return nil, fmt.Errorf("%s: expected map<%s,%s>, got map<%s,%s>", path, s.key.typ, s.elem.typ, key, value)
+		}
+		if !hashable(s.key) {
+			entries := make([]MapEntry, 0, capacity(size))
+			for i := 0; i < size; i++ {
+				elemPath := fmt.Sprintf("%s[%d]", path, i)
+				k, err := c.readValue(r, s.key, elemPath, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				v, err := c.readValue(r, s.elem, elemPath, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, MapEntry{Key: k, Value: v})
+			}
+			return entries, r.ReadMapEnd()
+		}
+		res := make(map[any]any, capacity(size))
+		for i := 0; i < size; i++ {
+			k, err := c.readValue(r, s.key, fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if b, ok := k.([]byte); ok {
+				k = string(b)
+			}
+			v, err := c.readValue(r, s.elem, fmt.Sprintf("%s[%v]", path, k), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res[k] = v
+		}
+		return res, r.ReadMapEnd()
+	}
+	return nil, fmt.Errorf("%s: unsupported type %s", path, s.name)
+}
+
+// Skip reads and discards a value of typ.
+func Skip(r Reader, typ Type) error {
+	return skip(r, typ, 0)
+}
+
+func skip(r Reader, typ Type, depth int) (err error) {
+	if depth > MaxDepth {
+		return fmt.Errorf("exceeds max depth %d", MaxDepth)
+	}
+	switch typ {
+	case BOOL:
+		_, err = r.ReadBool()
+	case BYTE:
+		_, err = r.ReadI8()
+	case I16:
+		_, err = r.ReadI16()
+	case I32:
+		_, err = r.ReadI32()
+	case I64:
+		_, err = r.ReadI64()
+	case DOUBLE:
+		_, err = r.ReadDouble()
+	case STRING:
+		_, err = r.ReadBinary()
+	case STRUCT:
+		if err = r.ReadStructBegin(); err != nil {
+			return
+		}
+		for {
+			_, typ, _, err := r.ReadFieldBegin()
+			if err != nil {
+				return err
+			}
+			if typ == STOP {
+				break
+			}
+			if err := skip(r, typ, depth+1); err != nil {
+				return err
+			}
+			if err := r.ReadFieldEnd(); err != nil {
+				return err
+			}
+		}
+		err = r.ReadStructEnd()
+	case MAP:
+		key, value, size, err := r.ReadMapBegin()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := skip(r, key, depth+1); err != nil {
+				return err
+			}
+			if err := skip(r, value, depth+1); err != nil {
+				return err
+			}
+		}
+		return r.ReadMapEnd()
+	case LIST:
+		elem, size, err := r.ReadListBegin()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := skip(r, elem, depth+1); err != nil {
+				return err
+			}
+		}
+		return r.ReadListEnd()
+	case SET:
+		elem, size, err := r.ReadSetBegin()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			if err := skip(r, elem, depth+1); err != nil {
+				return err
+			}
+		}
+		return r.ReadSetEnd()
+	default:
+		err = fmt.Errorf("unknown type %s", typ)
+	}
+	return
+}
+
+// capacity to preallocate for a container of size read from the wire, which may be corrupted
+func capacity(size int) int {
+	if size > 1024 {
+		return 1024
+	}
+	return size
+}
+
+func toInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint:
+		return int64(n), n <= math.MaxInt64
+	case uint64:
+		return int64(n), n <= math.MaxInt64
+	case float32:
+		return int64(n), float32(int64(n)) == n
+	case float64:
+		return int64(n), n >= math.MinInt64 && n < math.MaxInt64 && float64(int64(n)) == n
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	if i, ok := toInt(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
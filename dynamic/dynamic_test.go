@@ -0,0 +1,350 @@
+package dynamic
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+var files = map[string]string{
+	"shape.thrift": `include "shared.thrift"
+
+typedef list<string> Tags
+
+enum Color {
+  RED = 1
+  GREEN
+}
+
+struct Point {
+  1: i16 x
+  2: i16 y
+}
+
+struct Shape {
+  1: required string name
+  2: Color color
+  3: Tags tags
+  4: map<string, Point> points
+  5: set<i8> flags
+  6: optional bool filled
+  7: double ratio
+  8: i64 id
+  9: binary data
+  10: shared.Meta meta
+}
+
+union Value {
+  1: i32 number
+  2: string text
+}
+
+struct Nested {
+  1: list<map<i32, list<Color>>> matrix
+  2: map<binary, bool> seen
+}
+
+struct Keyed {
+  1: map<Point, string> names
+  2: map<set<i32>, list<i32>> groups
+  3: map<Point, i32> origin = {{"x": 0, "y": 0}: 1}
+}
+
+struct Tree {
+  1: required string label
+  2: list<Tree> children
+  3: optional Tree parent
+  4: optional Value value
+}
+
+const i32 VERSION = 2
+
+struct Options {
+  1: i32 version = VERSION
+  2: Color color = Color.GREEN
+  3: list<string> names = ["a", "b"]
+  4: map<string, i16> sizes = {"s": 1}
+  5: shared.Meta meta = {"version": 3}
+  6: bool on = 1
+  7: double ratio = 1
+  8: optional string note
+  9: set<binary> seen = {}
+  10: map<binary, i32> counts = {"x": 1}
+}
+
+exception NotFound {
+  1: string name
+}
+
+service Base {
+  i32 ping()
+}
+
+service Shapes extends Base {
+  Shape get(1: string name, 2: optional Color color) throws (1: NotFound missing)
+  oneway void log(1: string line)
+  void clear()
+}
+`,
+	"shared.thrift": `struct Meta {
+  1: i32 version
+}
+`,
+}
+
+func load(t *testing.T) (*Codec, *thrifter.Thrift) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	program := thrifter.NewProgram()
+	file, err := program.Load(filepath.Join(dir, "shape.thrift"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCodec(program), file
+}
+
+func structOf(t *testing.T, file *thrifter.Thrift, name string) *thrifter.Struct {
+	t.Helper()
+	st, ok := file.Declaration(name).(*thrifter.Struct)
+	if !ok {
+		t.Fatalf("struct %s not found", name)
+	}
+	return st
+}
+
+// fixture joins hex strings, spaces are ignored
+func fixture(t *testing.T, parts ...string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(strings.ReplaceAll(strings.Join(parts, ""), " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func shapeBinary(t *testing.T) []byte {
+	return fixture(t,
+		"0b 0001 00000002 7371",              // name = "sq"
+		"08 0002 00000002",                   // color = GREEN
+		"0f 0003 0b 00000001 00000001 61",    // tags = ["a"]
+		"0d 0004 0b 0c 00000001 00000001 6f", // points = {"o": ...
+		"06 0001 0001 06 0002 ffff 00",       // ... {x: 1, y: -1}}
+		"0e 0005 03 00000001 03",             // flags = [3]
+		"02 0006 01",                         // filled = true
+		"04 0007 3fe0000000000000",           // ratio = 0.5
+		"0a 0008 0000010000000000",           // id = 1 << 40
+		"0b 0009 00000001 ff",                // data = 0xff
+		"0c 000a 08 0001 00000007 00",        // meta = {version: 7}
+		"00",
+	)
+}
+
+var shapeValue = map[string]any{
+	"name":   "sq",
+	"color":  "GREEN",
+	"tags":   []any{"a"},
+	"points": map[any]any{"o": map[string]any{"x": int16(1), "y": int16(-1)}},
+	"flags":  []any{int8(3)},
+	"filled": true,
+	"ratio":  0.5,
+	"id":     int64(1 << 40),
+	"data":   []byte{0xff},
+	"meta":   map[string]any{"version": int32(7)},
+}
+
+func TestEncodeBinary(t *testing.T) {
+	codec, file := load(t)
+	// values are accepted loosely, e.g. decoded from JSON
+	value := map[string]any{
+		"name":   "sq",
+		"color":  "GREEN",
+		"tags":   []string{"a"},
+		"points": map[string]any{"o": map[string]any{"x": 1, "y": -1.0}},
+		"flags":  []int{3},
+		"filled": true,
+		"ratio":  0.5,
+		"id":     float64(1 << 40),
+		"data":   "\xff",
+		"meta":   map[string]any{"version": int64(7)},
+	}
+	got, err := codec.EncodeBinary(structOf(t, file, "Shape"), value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := shapeBinary(t); !bytes.Equal(got, want) {
+		t.Errorf("got  %x\nwant %x", got, want)
+	}
+}
+
+func TestDecodeBinary(t *testing.T) {
+	codec, file := load(t)
+	got, err := codec.DecodeBinary(structOf(t, file, "Shape"), shapeBinary(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, shapeValue) {
+		t.Errorf("got  %#v\nwant %#v", got, shapeValue)
+	}
+}
+
+// unknown fields and fields of other types are skipped, enum values without elements are kept as numbers
+func TestDecodeBinary_skip(t *testing.T) {
+	codec, file := load(t)
+	data := fixture(t,
+		"0b 0001 00000001 78", // name = "x"
+		"0c 0063 0f 0001 08 00000002 00000001 00000002 0d 0002 0b 0b 00000000 00", // unknown struct field 99
+		"0b 0002 00000000", // color of wrong type
+		"08 0002 00000009", // color = 9
+		"00",
+	)
+	got, err := codec.DecodeBinary(structOf(t, file, "Shape"), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"name": "x", "color": int32(9)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v want %#v", got, want)
+	}
+}
+
+func TestBinary_roundTrip(t *testing.T) {
+	codec, file := load(t)
+	st := structOf(t, file, "Nested")
+	value := map[string]any{
+		"matrix": []any{map[any]any{int32(1): []any{"RED", "GREEN"}}, map[any]any{}},
+		"seen":   map[any]any{"k": true},
+	}
+	data, err := codec.EncodeBinary(st, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := codec.DecodeBinary(st, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("got %#v want %#v", got, value)
+	}
+}
+
+// maps keyed by structs or containers are decoded as entries in wire order
+func TestBinary_entries(t *testing.T) {
+	codec, file := load(t)
+	st := structOf(t, file, "Keyed")
+	value := map[string]any{
+		"names": []MapEntry{
+			{Key: map[string]any{"x": int16(2), "y": int16(0)}, Value: "b"},
+			{Key: map[string]any{"x": int16(1), "y": int16(0)}, Value: "a"},
+		},
+		"groups": []MapEntry{{Key: []any{int32(1), int32(2)}, Value: []any{int32(3)}}},
+		"origin": []MapEntry{{Key: map[string]any{"x": int16(0), "y": int16(0)}, Value: int32(1)}},
+	}
+	for _, p := range []struct {
+		name   string
+		encode func(*thrifter.Struct, map[string]any) ([]byte, error)
+		decode func(*thrifter.Struct, []byte) (map[string]any, error)
+	}{
+		{"binary", codec.EncodeBinary, codec.DecodeBinary},
+		{"compact", codec.EncodeCompact, codec.DecodeCompact},
+		{"simple json", codec.EncodeSimpleJSON, codec.DecodeSimpleJSON},
+	} {
+		data, err := p.encode(st, value)
+		if err != nil {
+			t.Fatalf("%s: %v", p.name, err)
+		}
+		got, err := p.decode(st, data)
+		if err != nil {
+			t.Fatalf("%s: %v", p.name, err)
+		}
+		if !reflect.DeepEqual(got, value) {
+			t.Errorf("%s: got %#v want %#v", p.name, got, value)
+		}
+	}
+	// defaults
+	got, err := codec.DecodeBinary(st, []byte{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got["origin"], value["origin"]) {
+		t.Errorf("got %#v want %#v", got["origin"], value["origin"])
+	}
+	want := "struct can't be a map key in json protocol"
+	if _, err := codec.EncodeJSON(st, value); err == nil || err.Error() != want {
+		t.Errorf("got [%v] want [%v]", err, want)
+	}
+}
+
+func TestEncodeBinary_errors(t *testing.T) {
+	codec, file := load(t)
+	cases := []struct {
+		st    string
+		value map[string]any
+		want  string
+	}{
+		{"Shape", map[string]any{}, "Shape: required field name is missing"},
+		{"Shape", map[string]any{"name": "a", "size": 1}, "Shape: unknown field size"},
+		{"Shape", map[string]any{"name": 1}, "Shape.name: expected string, got int"},
+		{"Shape", map[string]any{"name": "a", "color": "BLUE"}, "Shape.color: Color has no element BLUE"},
+		{"Shape", map[string]any{"name": "a", "flags": []any{128}}, "Shape.flags[0]: 128 overflows i8"},
+		{"Shape", map[string]any{"name": "a", "id": 1.5}, "Shape.id: expected i64, got float64"},
+		{"Shape", map[string]any{"name": "a", "points": map[string]any{"o": map[string]any{"z": 1}}}, "Shape.points[o]: unknown field z"},
+		{"Value", map[string]any{"number": 1, "text": "a"}, "Value: union must have exactly one field set, got 2"},
+		{"Value", map[string]any{}, "Value: union must have exactly one field set, got 0"},
+	}
+	for _, c := range cases {
+		_, err := codec.EncodeBinary(structOf(t, file, c.st), c.value)
+		if err == nil || err.Error() != c.want {
+			t.Errorf("%v: got [%v] want [%v]", c.value, err, c.want)
+		}
+	}
+}
+
+func TestEncodeBinary_cyclicTypedef(t *testing.T) {
+	file, err := thrifter.NewParserBytes([]byte("typedef list<L> L\n\nstruct S {\n  1: L l\n}\n"), false).Parse("cyclic.thrift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := thrifter.NewProgram()
+	if err := program.Add(file); err != nil {
+		t.Fatal(err)
+	}
+	codec := NewCodec(program)
+	st := structOf(t, file, "S")
+
+	_, err = codec.EncodeBinary(st, map[string]any{"l": []any{}})
+	if want := "S.l: typedef L refers to itself"; err == nil || err.Error() != want {
+		t.Errorf("got [%v] want [%v]", err, want)
+	}
+	_, err = codec.NewGenerator(0, GeneratorOptions{}).Value(st.Elems[0].FieldType)
+	if want := "typedef L refers to itself"; err == nil || err.Error() != want {
+		t.Errorf("got [%v] want [%v]", err, want)
+	}
+}
+
+func TestDecodeBinary_errors(t *testing.T) {
+	codec, file := load(t)
+	cases := map[string]string{
+		"0b 0001 00000002 73":                                 "unexpected EOF",
+		"0b 0001 00000001 73 00 00":                           "1 bytes remain after Shape",
+		"08 0002 00000001 00":                                 "Shape: required field name is missing",
+		"0b 0001 ffffffff":                                    "negative size -1",
+		"0b 0001 00000001 73 0f 0003 08 00000001 00000001 00": "Shape.tags: expected elements of string, got i32",
+		"0b 0001 00000001 73 0c 0063 10 0001 00":              "unknown type type(16)",
+	}
+	for data, want := range cases {
+		_, err := codec.DecodeBinary(structOf(t, file, "Shape"), fixture(t, data))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got [%v] want [%v]", data, err, want)
+		}
+	}
+}
@@ -0,0 +1,625 @@
+package dynamic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+const jsonVersion = 1
+
+// names of types in TJSONProtocol
+var jsonTypeNames = map[Type]string{
+	BOOL:   "tf",
+	BYTE:   "i8",
+	I16:    "i16",
+	I32:    "i32",
+	I64:    "i64",
+	DOUBLE: "dbl",
+	STRING: "str",
+	STRUCT: "rec",
+	MAP:    "map",
+	LIST:   "lst",
+	SET:    "set",
+}
+
+func typeOfJSONName(name string) (Type, error) {
+	for typ, n := range jsonTypeNames {
+		if n == name {
+			return typ, nil
+		}
+	}
+	return STOP, fmt.Errorf("unknown json type %q", name)
+}
+
+// contexts of JSONWriter
+const (
+	jsonStruct = iota
+	jsonList
+	jsonMap
+)
+
+type jsonContext struct {
+	kind  int
+	count int // fields of struct, or keys and values of map
+}
+
+// JSONWriter writes values in TJSONProtocol, i.e. structs are objects keyed by field ids with values tagged by their types:
+//
+//	{"1":{"str":"sq"},"3":{"lst":["str",1,"a"]},"4":{"map":["str","i32",1,{"o":1}]}}
+//
+// Bools are written as 1 or 0, binaries in base64, and map keys are always quoted.
+// Structs and containers can't be map keys, as TJSONProtocol has no form of them.
+type JSONWriter struct {
+	w       *bufio.Writer
+	context []jsonContext
+}
+
+// NewJSONWriter creates a JSONWriter, which buffers writes until Flush.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: bufio.NewWriter(w)}
+}
+
+// begin writes the separator before a value, it reports whether the value is a key of map, which should be quoted
+func (j *JSONWriter) begin() bool {
+	if len(j.context) == 0 {
+		return false
+	}
+	ctx := &j.context[len(j.context)-1]
+	switch ctx.kind {
+	case jsonList:
+		j.w.WriteByte(',')
+	case jsonMap:
+		key := ctx.count%2 == 0
+		if !key {
+			j.w.WriteByte(':')
+		} else if ctx.count > 0 {
+			j.w.WriteByte(',')
+		}
+		ctx.count++
+		return key
+	}
+	return false
+}
+
+// beginContainer begins a struct or container of typ, which can't be a key of map in TJSONProtocol
+func (j *JSONWriter) beginContainer(typ Type) error {
+	if j.begin() {
+		return fmt.Errorf("%s can't be a map key in json protocol", typ)
+	}
+	return nil
+}
+
+func (j *JSONWriter) push(kind int) {
+	j.context = append(j.context, jsonContext{kind: kind})
+}
+
+func (j *JSONWriter) pop() {
+	j.context = j.context[:len(j.context)-1]
+}
+
+// writeRaw writes a number or literal, quoted if it's a key of map
+func (j *JSONWriter) writeRaw(v string) error {
+	if j.begin() {
+		v = `"` + v + `"`
+	}
+	_, err := j.w.WriteString(v)
+	return err
+}
+
+// WriteMessageBegin writes the header of message as the first elements of an array, whose last element is the struct of message
+func (j *JSONWriter) WriteMessageBegin(name string, typ MessageType, seqid int32) error {
+	j.begin()
+	j.push(jsonList)
+	_, err := fmt.Fprintf(j.w, `[%d,%s,%d,%d`, jsonVersion, quote(name), typ, seqid)
+	return err
+}
+
+func (j *JSONWriter) WriteMessageEnd() error {
+	j.pop()
+	return j.w.WriteByte(']')
+}
+
+func (j *JSONWriter) WriteStructBegin(name string) error {
+	if err := j.beginContainer(STRUCT); err != nil {
+		return err
+	}
+	j.push(jsonStruct)
+	return j.w.WriteByte('{')
+}
+
+func (j *JSONWriter) WriteStructEnd() error {
+	j.pop()
+	return j.w.WriteByte('}')
+}
+
+func (j *JSONWriter) WriteFieldBegin(name string, typ Type, id int16) error {
+	ctx := &j.context[len(j.context)-1]
+	if ctx.count > 0 {
+		j.w.WriteByte(',')
+	}
+	ctx.count++
+	_, err := fmt.Fprintf(j.w, `"%d":{"%s":`, id, jsonTypeNames[typ])
+	return err
+}
+
+func (j *JSONWriter) WriteFieldEnd() error {
+	return j.w.WriteByte('}')
+}
+
+func (j *JSONWriter) WriteFieldStop() error { return nil }
+
+func (j *JSONWriter) WriteMapBegin(key Type, value Type, size int) error {
+	if err := j.beginContainer(MAP); err != nil {
+		return err
+	}
+	j.push(jsonMap)
+	_, err := fmt.Fprintf(j.w, `["%s","%s",%d,{`, jsonTypeNames[key], jsonTypeNames[value], size)
+	return err
+}
+
+func (j *JSONWriter) WriteMapEnd() error {
+	j.pop()
+	_, err := j.w.WriteString("}]")
+	return err
+}
+
+func (j *JSONWriter) WriteListBegin(elem Type, size int) error {
+	if err := j.beginContainer(LIST); err != nil {
+		return err
+	}
+	j.push(jsonList)
+	_, err := fmt.Fprintf(j.w, `["%s",%d`, jsonTypeNames[elem], size)
+	return err
+}
+
+func (j *JSONWriter) WriteListEnd() error {
+	j.pop()
+	return j.w.WriteByte(']')
+}
+
+func (j *JSONWriter) WriteSetBegin(elem Type, size int) error {
+	return j.WriteListBegin(elem, size)
+}
+
+func (j *JSONWriter) WriteSetEnd() error {
+	return j.WriteListEnd()
+}
+
+func (j *JSONWriter) WriteBool(v bool) error {
+	if v {
+		return j.writeRaw("1")
+	}
+	return j.writeRaw("0")
+}
+
+func (j *JSONWriter) WriteI8(v int8) error {
+	return j.writeRaw(strconv.FormatInt(int64(v), 10))
+}
+
+func (j *JSONWriter) WriteI16(v int16) error {
+	return j.writeRaw(strconv.FormatInt(int64(v), 10))
+}
+
+func (j *JSONWriter) WriteI32(v int32) error {
+	return j.writeRaw(strconv.FormatInt(int64(v), 10))
+}
+
+func (j *JSONWriter) WriteI64(v int64) error {
+	return j.writeRaw(strconv.FormatInt(v, 10))
+}
+
+// WriteDouble writes NaN and infinities as strings, which JSON numbers can't represent
+func (j *JSONWriter) WriteDouble(v float64) error {
+	if special := specialFloat(v); special != "" {
+		return j.WriteString(special)
+	}
+	return j.writeRaw(strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func (j *JSONWriter) WriteString(v string) error {
+	j.begin()
+	_, err := j.w.Write(quote(v))
+	return err
+}
+
+func (j *JSONWriter) WriteBinary(v []byte) error {
+	return j.WriteString(base64.StdEncoding.EncodeToString(v))
+}
+
+func (j *JSONWriter) Flush() error {
+	return j.w.Flush()
+}
+
+func specialFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	}
+	return ""
+}
+
+func quote(v string) []byte {
+	res, _ := json.Marshal(v)
+	return res
+}
+
+// jsonFrame is a struct, field, list or map being read
+type jsonFrame struct {
+	values []any          // values to read in order
+	object map[string]any // fields of struct keyed by ids
+	keys   []string       // ids of fields to read
+}
+
+// JSONReader reads values in TJSONProtocol.
+// Each top-level value is decoded by a json.Decoder, which may read ahead of the value.
+type JSONReader struct {
+	dec   *json.Decoder
+	stack []*jsonFrame
+}
+
+func NewJSONReader(r io.Reader) *JSONReader {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &JSONReader{dec: dec}
+}
+
+// next returns the next value to read in the current frame
+func (j *JSONReader) next() (any, error) {
+	v, err := j.nextValue()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+// nextValue is next, but returns io.EOF if there is no more top-level value
+func (j *JSONReader) nextValue() (any, error) {
+	if len(j.stack) == 0 {
+		var v any
+		err := j.dec.Decode(&v)
+		return v, err
+	}
+	frame := j.stack[len(j.stack)-1]
+	if len(frame.values) == 0 {
+		return nil, fmt.Errorf("no more values to read")
+	}
+	v := frame.values[0]
+	frame.values = frame.values[1:]
+	return v, nil
+}
+
+func (j *JSONReader) push(frame *jsonFrame) {
+	j.stack = append(j.stack, frame)
+}
+
+func (j *JSONReader) pop() error {
+	j.stack = j.stack[:len(j.stack)-1]
+	return nil
+}
+
+// ReadMessageBegin returns io.EOF if there is no more message
+func (j *JSONReader) ReadMessageBegin() (name string, typ MessageType, seqid int32, err error) {
+	v, err := j.nextValue()
+	if err != nil {
+		return
+	}
+	header, ok := v.([]any)
+	if !ok || len(header) != 5 {
+		return "", 0, 0, fmt.Errorf("expected array of message, got %s", jsonKind(v))
+	}
+	if version, err := jsonInt(header[0], 32); err != nil || version != jsonVersion {
+		return "", 0, 0, fmt.Errorf("bad json protocol version %v", header[0])
+	}
+	if name, ok = header[1].(string); !ok {
+		return "", 0, 0, fmt.Errorf("expected message name, got %s", jsonKind(header[1]))
+	}
+	t, err := jsonInt(header[2], 8)
+	if err != nil {
+		return
+	}
+	n, err := jsonInt(header[3], 32)
+	if err != nil {
+		return
+	}
+	j.push(&jsonFrame{values: header[4:]})
+	return name, MessageType(t), int32(n), nil
+}
+
+func (j *JSONReader) ReadMessageEnd() error { return j.pop() }
+
+func (j *JSONReader) ReadStructBegin() error {
+	v, err := j.next()
+	if err != nil {
+		return err
+	}
+	object, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("expected object of struct, got %s", jsonKind(v))
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	// in order of ids, so that fields are read as written by generated code
+	sort.Slice(keys, func(a, b int) bool {
+		x, _ := strconv.Atoi(keys[a])
+		y, _ := strconv.Atoi(keys[b])
+		return x < y
+	})
+	j.push(&jsonFrame{object: object, keys: keys})
+	return nil
+}
+
+func (j *JSONReader) ReadStructEnd() error { return j.pop() }
+
+func (j *JSONReader) ReadFieldBegin() (name string, typ Type, id int16, err error) {
+	frame := j.stack[len(j.stack)-1]
+	if len(frame.keys) == 0 {
+		return "", STOP, 0, nil
+	}
+	key := frame.keys[0]
+	frame.keys = frame.keys[1:]
+	n, err := strconv.ParseInt(key, 10, 16)
+	if err != nil {
+		return "", STOP, 0, fmt.Errorf("invalid field id %q", key)
+	}
+	tagged, ok := frame.object[key].(map[string]any)
+	if !ok || len(tagged) != 1 {
+		return "", STOP, 0, fmt.Errorf("field %s: expected object of a type and a value", key)
+	}
+	for tn, v := range tagged {
+		if typ, err = typeOfJSONName(tn); err != nil {
+			return "", STOP, 0, err
+		}
+		j.push(&jsonFrame{values: []any{v}})
+	}
+	return "", typ, int16(n), nil
+}
+
+func (j *JSONReader) ReadFieldEnd() error { return j.pop() }
+
+func (j *JSONReader) ReadMapBegin() (key Type, value Type, size int, err error) {
+	v, err := j.next()
+	if err != nil {
+		return
+	}
+	header, ok := v.([]any)
+	if !ok || len(header) != 4 {
+		return STOP, STOP, 0, fmt.Errorf("expected array of map, got %s", jsonKind(v))
+	}
+	if key, err = j.headerType(header[0]); err != nil {
+		return
+	}
+	if value, err = j.headerType(header[1]); err != nil {
+		return
+	}
+	object, ok := header[3].(map[string]any)
+	if !ok {
+		return STOP, STOP, 0, fmt.Errorf("expected object of map entries, got %s", jsonKind(header[3]))
+	}
+	if size, err = headerSize(header[2], len(object)); err != nil {
+		return
+	}
+	keys := make([]string, 0, len(object))
+	for k := range object {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	frame := &jsonFrame{values: make([]any, 0, 2*len(keys))}
+	for _, k := range keys {
+		frame.values = append(frame.values, k, object[k])
+	}
+	j.push(frame)
+	return
+}
+
+func (j *JSONReader) ReadMapEnd() error { return j.pop() }
+
+func (j *JSONReader) ReadListBegin() (elem Type, size int, err error) {
+	v, err := j.next()
+	if err != nil {
+		return
+	}
+	header, ok := v.([]any)
+	if !ok || len(header) < 2 {
+		return STOP, 0, fmt.Errorf("expected array of list, got %s", jsonKind(v))
+	}
+	if elem, err = j.headerType(header[0]); err != nil {
+		return
+	}
+	if size, err = headerSize(header[1], len(header)-2); err != nil {
+		return
+	}
+	j.push(&jsonFrame{values: header[2:]})
+	return
+}
+
+func (j *JSONReader) ReadListEnd() error { return j.pop() }
+
+func (j *JSONReader) ReadSetBegin() (elem Type, size int, err error) {
+	return j.ReadListBegin()
+}
+
+func (j *JSONReader) ReadSetEnd() error { return j.pop() }
+
+func (j *JSONReader) headerType(v any) (Type, error) {
+	name, ok := v.(string)
+	if !ok {
+		return STOP, fmt.Errorf("expected type name, got %s", jsonKind(v))
+	}
+	return typeOfJSONName(name)
+}
+
+// headerSize returns the size in header of container, which should be the number of its elements
+func headerSize(v any, elems int) (int, error) {
+	n, err := jsonInt(v, 32)
+	if err != nil {
+		return 0, err
+	}
+	if int(n) != elems {
+		return 0, fmt.Errorf("size %d doesn't match %d elements", n, elems)
+	}
+	return elems, nil
+}
+
+func (j *JSONReader) ReadBool() (bool, error) {
+	v, err := j.next()
+	if err != nil {
+		return false, err
+	}
+	n, err := jsonInt(v, 8)
+	return n != 0, err
+}
+
+func (j *JSONReader) ReadI8() (int8, error) {
+	n, err := j.readInt(8)
+	return int8(n), err
+}
+
+func (j *JSONReader) ReadI16() (int16, error) {
+	n, err := j.readInt(16)
+	return int16(n), err
+}
+
+func (j *JSONReader) ReadI32() (int32, error) {
+	n, err := j.readInt(32)
+	return int32(n), err
+}
+
+func (j *JSONReader) ReadI64() (int64, error) {
+	return j.readInt(64)
+}
+
+func (j *JSONReader) readInt(bits int) (int64, error) {
+	v, err := j.next()
+	if err != nil {
+		return 0, err
+	}
+	return jsonInt(v, bits)
+}
+
+func (j *JSONReader) ReadDouble() (float64, error) {
+	v, err := j.next()
+	if err != nil {
+		return 0, err
+	}
+	return jsonFloat(v)
+}
+
+func (j *JSONReader) ReadString() (string, error) {
+	v, err := j.next()
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("expected string, got %s", jsonKind(v))
+	}
+	return s, nil
+}
+
+func (j *JSONReader) ReadBinary() ([]byte, error) {
+	s, err := j.ReadString()
+	if err != nil {
+		return nil, err
+	}
+	return decodeBase64(s)
+}
+
+// rest reports whether there is data after the last top-level value
+func (j *JSONReader) rest() bool {
+	_, err := j.dec.Token()
+	return err != io.EOF
+}
+
+// jsonInt converts a number, or a quoted number of map key, to an integer of bits
+func jsonInt(v any, bits int) (int64, error) {
+	var s string
+	switch n := v.(type) {
+	case json.Number:
+		s = string(n)
+	case string:
+		s = n
+	default:
+		return 0, fmt.Errorf("expected number, got %s", jsonKind(v))
+	}
+	n, err := strconv.ParseInt(s, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid i%d %s", bits, s)
+	}
+	return n, nil
+}
+
+// jsonFloat converts a number, or a quoted number including NaN and infinities, to a float
+func jsonFloat(v any) (float64, error) {
+	var s string
+	switch n := v.(type) {
+	case json.Number:
+		s = string(n)
+	case string:
+		s = n
+	default:
+		return 0, fmt.Errorf("expected number, got %s", jsonKind(v))
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid double %s", s)
+	}
+	return f, nil
+}
+
+// decodeBase64 decodes s with or without padding, which is omitted by some implementations
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// jsonKind describes the kind of a decoded JSON value in errors
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// EncodeJSON encodes value as struct st in TJSONProtocol.
+func (c *Codec) EncodeJSON(st *thrifter.Struct, value map[string]any) ([]byte, error) {
+	return c.encode(st, value, func(w io.Writer) Writer { return NewJSONWriter(w) })
+}
+
+// DecodeJSON decodes a value of struct st in TJSONProtocol, data after the value is an error.
+func (c *Codec) DecodeJSON(st *thrifter.Struct, data []byte) (map[string]any, error) {
+	r := NewJSONReader(bytes.NewReader(data))
+	res, err := c.Read(r, st)
+	if err != nil {
+		return nil, err
+	}
+	if r.rest() {
+		return nil, fmt.Errorf("data remain after %s", st.Ident)
+	}
+	return res, nil
+}
@@ -0,0 +1,200 @@
+package dynamic
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const shapeJSON = `{"1":{"str":"sq"},"2":{"i32":2},"3":{"lst":["str",1,"a"]},` +
+	`"4":{"map":["str","rec",1,{"o":{"1":{"i16":1},"2":{"i16":-1}}}]},"5":{"set":["i8",1,3]},"6":{"tf":1},` +
+	`"7":{"dbl":0.5},"8":{"i64":1099511627776},"9":{"str":"/w=="},"10":{"rec":{"1":{"i32":7}}}}`
+
+const shapeSimpleJSON = `{"name":"sq","color":"GREEN","tags":["a"],"points":{"o":{"x":1,"y":-1}},"flags":[3],` +
+	`"filled":true,"ratio":0.5,"id":1099511627776,"data":"/w==","meta":{"version":7}}`
+
+func TestEncodeJSON(t *testing.T) {
+	codec, file := load(t)
+	got, err := codec.EncodeJSON(structOf(t, file, "Shape"), shapeValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != shapeJSON {
+		t.Errorf("got  %s\nwant %s", got, shapeJSON)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	codec, file := load(t)
+	got, err := codec.DecodeJSON(structOf(t, file, "Shape"), []byte(shapeJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, shapeValue) {
+		t.Errorf("got  %#v\nwant %#v", got, shapeValue)
+	}
+}
+
+// map keys of other types than string are quoted, binaries are in base64 with or without padding
+func TestJSON_roundTrip(t *testing.T) {
+	codec, file := load(t)
+	st := structOf(t, file, "Nested")
+	value := map[string]any{
+		"matrix": []any{map[any]any{int32(-1): []any{"RED", int32(7)}}, map[any]any{}},
+		"seen":   map[any]any{"k": true, "l": false},
+	}
+	data, err := codec.EncodeJSON(st, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"1":{"lst":["map",2,["i32","lst",1,{"-1":["i32",2,1,7]}],["i32","lst",0,{}]]},"2":{"map":["str","tf",2,{"aw==":1,"bA==":0}]}}`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+	got, err := codec.DecodeJSON(st, []byte(strings.ReplaceAll(string(data), "=", "")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("got %#v want %#v", got, value)
+	}
+}
+
+func TestJSON_specialFloat(t *testing.T) {
+	codec, file := load(t)
+	st := structOf(t, file, "Shape")
+	for _, f := range []float64{math.Inf(1), math.Inf(-1), math.NaN()} {
+		data, err := codec.EncodeJSON(st, map[string]any{"name": "", "ratio": f})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := codec.DecodeJSON(st, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := got["ratio"].(float64); r != f && !(math.IsNaN(r) && math.IsNaN(f)) {
+			t.Errorf("%s: got %v", data, r)
+		}
+	}
+}
+
+func TestDecodeJSON_errors(t *testing.T) {
+	codec, file := load(t)
+	cases := map[string]string{
+		``:                                  "unexpected EOF",
+		`{"1":{"str":"a"}} {}`:              "data remain after Shape",
+		`[]`:                                "expected object of struct, got array",
+		`{"a":{"str":"a"}}`:                 `invalid field id "a"`,
+		`{"1":{"str":"a","i32":1}}`:         "field 1: expected object of a type and a value",
+		`{"1":{"txt":"a"}}`:                 `unknown json type "txt"`,
+		`{"1":{"str":1}}`:                   "expected string, got number",
+		`{"1":{"str":"a"},"2":{"i32":"x"}}`: "invalid i32 x",
+		`{"1":{"str":"a"},"3":{"lst":["str",2,"a"]}}`:   "size 2 doesn't match 1 elements",
+		`{"1":{"str":"a"},"4":{"map":["str","rec",0]}}`: "expected array of map, got array",
+		`{"2":{"i32":1}}`: "Shape: required field name is missing",
+	}
+	for data, want := range cases {
+		_, err := codec.DecodeJSON(structOf(t, file, "Shape"), []byte(data))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got [%v] want [%v]", data, err, want)
+		}
+	}
+}
+
+func TestEncodeSimpleJSON(t *testing.T) {
+	codec, file := load(t)
+	got, err := codec.EncodeSimpleJSON(structOf(t, file, "Shape"), shapeValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != shapeSimpleJSON {
+		t.Errorf("got  %s\nwant %s", got, shapeSimpleJSON)
+	}
+}
+
+func TestDecodeSimpleJSON(t *testing.T) {
+	codec, file := load(t)
+	got, err := codec.DecodeSimpleJSON(structOf(t, file, "Shape"), []byte(shapeSimpleJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, shapeValue) {
+		t.Errorf("got  %#v\nwant %#v", got, shapeValue)
+	}
+}
+
+func TestSimpleJSON_roundTrip(t *testing.T) {
+	codec, file := load(t)
+	st := structOf(t, file, "Nested")
+	value := map[string]any{
+		"matrix": []any{map[any]any{int32(-1): []any{"RED", int32(7)}}, map[any]any{}},
+		"seen":   map[any]any{"k": true},
+	}
+	data, err := codec.EncodeSimpleJSON(st, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"matrix":[{"-1":["RED",7]},{}],"seen":{"aw==":true}}`; string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+	got, err := codec.DecodeSimpleJSON(st, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("got %#v want %#v", got, value)
+	}
+}
+
+func TestDecodeSimpleJSON_errors(t *testing.T) {
+	codec, file := load(t)
+	cases := map[string]string{
+		`{"name":"a"} 1`:                      "data remain after Shape",
+		`[]`:                                  "Shape: expected object, got array",
+		`{"name":"a","size":1}`:               "Shape: unknown field size",
+		`{"name":1}`:                          "Shape.name: expected string, got number",
+		`{"name":"a","color":"BLUE"}`:         "Shape.color: Color has no element BLUE",
+		`{"name":"a","flags":[128]}`:          "Shape.flags[0]: 128 overflows i8",
+		`{"name":"a","points":{"o":{"z":1}}}`: "Shape.points[o]: unknown field z",
+		`{"name":null}`:                       "Shape: required field name is missing",
+	}
+	for data, want := range cases {
+		_, err := codec.DecodeSimpleJSON(structOf(t, file, "Shape"), []byte(data))
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%s: got [%v] want [%v]", data, err, want)
+		}
+	}
+}
+
+// default values are set for missing fields by all protocols, following consts and enum elements
+func TestDecode_defaults(t *testing.T) {
+	codec, file := load(t)
+	st := structOf(t, file, "Options")
+	want := map[string]any{
+		"version": int32(5),
+		"color":   "GREEN",
+		"names":   []any{"a", "b"},
+		"sizes":   map[any]any{"s": int16(1)},
+		"meta":    map[string]any{"version": int32(3)},
+		"on":      true,
+		"ratio":   1.0,
+		"seen":    []any{},
+		"counts":  map[any]any{"x": int32(1)},
+	}
+	decoders := map[string]func() (map[string]any, error){
+		"binary":  func() (map[string]any, error) { return codec.DecodeBinary(st, fixture(t, "08 0001 00000005 00")) },
+		"compact": func() (map[string]any, error) { return codec.DecodeCompact(st, fixture(t, "15 0a 00")) },
+		"json":    func() (map[string]any, error) { return codec.DecodeJSON(st, []byte(`{"1":{"i32":5}}`)) },
+		"simple":  func() (map[string]any, error) { return codec.DecodeSimpleJSON(st, []byte(`{"version":5}`)) },
+	}
+	for name, decode := range decoders {
+		got, err := decode()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v want %#v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package dynamic
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// GeneratorOptions of NewGenerator, zero values mean defaults.
+type GeneratorOptions struct {
+	// Depth beyond which containers are empty, fields which are not required are omitted, and unions prefer fields which are not structs, so that recursive types end, 3 by default.
+	// Required fields of recursive types may still exceed MaxDepth, which is an error.
+	Depth int
+	// MaxSize is the max number of elements of containers, 3 by default.
+	MaxSize int
+	// Optional is the probability that an optional field is set, 0.5 by default, and negative for never.
+	Optional float64
+	// Fuzz prefers edge cases, e.g. min and max integers, NaN and infinities, empty, long and non-ASCII strings, and full or empty containers.
+	Fuzz bool
+}
+
+// Generator generates random values of types, which are valid for encoding by Codec, including EncodeSimpleJSON for JSON fixtures.
+// Required fields are always set, fields of default requiredness are set within depth, unions have exactly one field, and enums are names of their elements.
+// Values are the same for the same seed and options, it's not safe for concurrent use.
+type Generator struct {
+	codec *Codec
+	rand  *rand.Rand
+	opts  GeneratorOptions
+}
+
+// NewGenerator creates a generator of values seeded by seed, resolving identifiers like c.
+func (c *Codec) NewGenerator(seed int64, opts GeneratorOptions) *Generator {
+	if opts.Depth <= 0 {
+		opts.Depth = 3
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 3
+	}
+	if opts.Optional == 0 {
+		opts.Optional = 0.5
+	}
+	return &Generator{codec: c, rand: rand.New(rand.NewSource(seed)), opts: opts}
+}
+
+// Struct generates a value of struct st.
+func (g *Generator) Struct(st *thrifter.Struct) (map[string]any, error) {
+	return g.structValue(st, st.Ident, 0)
+}
+
+// Value generates a value of type ft.
+func (g *Generator) Value(ft *thrifter.FieldType) (any, error) {
+	s, err := g.codec.resolve(ft)
+	if err != nil {
+		return nil, err
+	}
+	return g.value(s, s.name, 0)
+}
+
+func (g *Generator) structValue(st *thrifter.Struct, path string, depth int) (map[string]any, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	schemas := make([]*schema, len(st.Elems))
+	for i, field := range st.Elems {
+		s, err := g.codec.resolve(field.FieldType)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", path, field.Ident, err)
+		}
+		schemas[i] = s
+	}
+	res := map[string]any{}
+	if st.Type == thrifter.UNION {
+		if len(st.Elems) == 0 {
+			return res, nil
+		}
+		candidates := make([]int, 0, len(st.Elems))
+		if depth >= g.opts.Depth {
+			for i, s := range schemas {
+				if s.typ != STRUCT {
+					candidates = append(candidates, i)
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			for i := range st.Elems {
+				candidates = append(candidates, i)
+			}
+		}
+		i := candidates[g.rand.Intn(len(candidates))]
+		v, err := g.value(schemas[i], path+"."+st.Elems[i].Ident, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		res[st.Elems[i].Ident] = v
+		return res, nil
+	}
+	for i, field := range st.Elems {
+		switch {
+		case field.Requiredness == "required":
+		case depth >= g.opts.Depth:
+			continue
+		case field.Requiredness == "optional" && g.rand.Float64() >= g.opts.Optional:
+			continue
+		}
+		v, err := g.value(schemas[i], path+"."+field.Ident, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		res[field.Ident] = v
+	}
+	return res, nil
+}
+
+func (g *Generator) value(s *schema, path string, depth int) (any, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	r := g.rand
+	switch s.typ {
+	case BOOL:
+		return r.Intn(2) == 1, nil
+	case BYTE:
+		return int8(g.integer(8)), nil
+	case I16:
+		return int16(g.integer(16)), nil
+	case I32:
+		if s.enum != nil {
+			if len(s.enum.Elems) == 0 {
+				return int32(0), nil
+			}
+			return s.enum.Elems[r.Intn(len(s.enum.Elems))].Ident, nil
+		}
+		return int32(g.integer(32)), nil
+	case I64:
+		return g.integer(64), nil
+	case DOUBLE:
+		if g.edge() {
+			edges := []float64{0, math.Copysign(0, -1), 1, -1, math.NaN(), math.Inf(1), math.Inf(-1), math.MaxFloat64, -math.MaxFloat64, math.SmallestNonzeroFloat64}
+			return edges[r.Intn(len(edges))], nil
+		}
+		return r.NormFloat64() * 1000, nil
+	case STRING:
+		if s.binary {
+			size := r.Intn(8)
+			if g.edge() {
+				size = []int{0, 256}[r.Intn(2)]
+			}
+			res := make([]byte, size)
+			r.Read(res)
+			return res, nil
+		}
+		if g.edge() {
+			edges := []string{"", " ", "\x00", "\"\\\n\t ", "ünïcødé", "日本語", "😀", strings.Repeat("x", 1024)}
+			return edges[r.Intn(len(edges))], nil
+		}
+		return g.word(), nil
+	case STRUCT:
+		return g.structValue(s.st, path, depth)
+	case LIST, SET:
+		size := g.size(depth)
+		res := make([]any, 0, size)
+		seen := map[string]bool{}
+		for i := 0; i < size; i++ {
+			v, err := g.value(s.elem, fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			// elements of sets are distinct, duplicates are dropped
+			if key := fmt.Sprint(v); s.typ == SET {
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			res = append(res, v)
+		}
+		return res, nil
+	case MAP:
+		size := g.size(depth)
+		if !hashable(s.key) {
+			entries := make([]MapEntry, 0, size)
+			seen := map[string]bool{}
+			for i := 0; i < size; i++ {
+				elemPath := fmt.Sprintf("%s[%d]", path, i)
+				k, err := g.value(s.key, elemPath, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				// keys of maps are distinct, duplicates are dropped
+				key := fmt.Sprint(k)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				v, err := g.value(s.elem, elemPath, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, MapEntry{Key: k, Value: v})
+			}
+			return entries, nil
+		}
+		res := make(map[any]any, size)
+		for i := 0; i < size; i++ {
+			k, err := g.value(s.key, fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if b, ok := k.([]byte); ok {
+				k = string(b)
+			}
+			// NaN keys can't be looked up, nor distinct from each other
+			if f, ok := k.(float64); ok && math.IsNaN(f) {
+				continue
+			}
+			if res[k], err = g.value(s.elem, fmt.Sprintf("%s[%v]", path, k), depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported type %s", path, s.name)
+}
+
+// edge reports whether to generate an edge case when fuzzing
+func (g *Generator) edge() bool {
+	return g.opts.Fuzz && g.rand.Intn(2) == 0
+}
+
+// integer returns an integer of bits
+func (g *Generator) integer(bits uint) int64 {
+	if g.edge() {
+		max := int64(1)<<(bits-1) - 1
+		edges := []int64{0, 1, -1, max, -max - 1}
+		return edges[g.rand.Intn(len(edges))]
+	}
+	return int64(g.rand.Uint64()) >> (64 - bits)
+}
+
+// size of a container at depth
+func (g *Generator) size(depth int) int {
+	if depth >= g.opts.Depth {
+		return 0
+	}
+	if g.edge() {
+		return []int{0, g.opts.MaxSize}[g.rand.Intn(2)]
+	}
+	return g.rand.Intn(g.opts.MaxSize + 1)
+}
+
+// word returns a string of lowercase letters
+func (g *Generator) word() string {
+	res := make([]byte, 1+g.rand.Intn(8))
+	for i := range res {
+		res[i] = byte('a' + g.rand.Intn(26))
+	}
+	return string(res)
+}
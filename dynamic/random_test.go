@@ -0,0 +1,167 @@
+package dynamic
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// generated values survive all protocols, and are the same for the same seed
+func TestGenerator(t *testing.T) {
+	codec, file := load(t)
+	protocols := []struct {
+		name   string
+		encode func(*thrifter.Struct, map[string]any) ([]byte, error)
+		decode func(*thrifter.Struct, []byte) (map[string]any, error)
+	}{
+		{"binary", codec.EncodeBinary, codec.DecodeBinary},
+		{"compact", codec.EncodeCompact, codec.DecodeCompact},
+		{"json", codec.EncodeJSON, codec.DecodeJSON},
+		{"simple json", codec.EncodeSimpleJSON, codec.DecodeSimpleJSON},
+	}
+	for _, opts := range []GeneratorOptions{{}, {Fuzz: true}, {Depth: 5, MaxSize: 5, Optional: 1}, {Optional: -1}} {
+		for _, name := range []string{"Shape", "Value", "Nested", "Options", "Tree"} {
+			st := structOf(t, file, name)
+			for seed := int64(0); seed < 20; seed++ {
+				value, err := codec.NewGenerator(seed, opts).Struct(st)
+				if err != nil {
+					t.Fatal(err)
+				}
+				again, _ := codec.NewGenerator(seed, opts).Struct(st)
+				if fmt.Sprint(value) != fmt.Sprint(again) {
+					t.Errorf("%+v %s %d: got %v and %v", opts, name, seed, value, again)
+				}
+				// decoding sets defaults, so values are compared after decoding once
+				data, err := codec.EncodeBinary(st, value)
+				if err != nil {
+					t.Fatalf("%+v %s %d: %v", opts, name, seed, err)
+				}
+				decoded, _ := codec.DecodeBinary(st, data)
+				want, _ := codec.EncodeBinary(st, decoded)
+				for _, p := range protocols {
+					data, err := p.encode(st, value)
+					if err != nil {
+						t.Fatalf("%+v %s %d %s: %v", opts, name, seed, p.name, err)
+					}
+					decoded, err := p.decode(st, data)
+					if err != nil {
+						t.Fatalf("%+v %s %d %s: %v", opts, name, seed, p.name, err)
+					}
+					if got, _ := codec.EncodeBinary(st, decoded); !bytes.Equal(got, want) {
+						t.Errorf("%+v %s %d %s: got %v want %v", opts, name, seed, p.name, decoded, value)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestGenerator_schema(t *testing.T) {
+	codec, file := load(t)
+	tree := structOf(t, file, "Tree")
+	shape := structOf(t, file, "Shape")
+	var depth func(v map[string]any) int
+	depth = func(v map[string]any) int {
+		res := 1
+		children, _ := v["children"].([]any)
+		for _, child := range children {
+			if d := 1 + depth(child.(map[string]any)); d > res {
+				res = d
+			}
+		}
+		if parent, ok := v["parent"].(map[string]any); ok {
+			if d := 1 + depth(parent); d > res {
+				res = d
+			}
+		}
+		return res
+	}
+	for seed := int64(0); seed < 50; seed++ {
+		v, err := codec.NewGenerator(seed, GeneratorOptions{Depth: 4, Optional: 1}).Struct(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := v["label"].(string); !ok {
+			t.Errorf("%d: required field missing in %v", seed, v)
+		}
+		if _, ok := v["parent"]; !ok {
+			t.Errorf("%d: optional field missing in %v", seed, v)
+		}
+		if value, ok := v["value"].(map[string]any); ok && len(value) != 1 {
+			t.Errorf("%d: union with %d fields %v", seed, len(value), value)
+		}
+		// each parent is one level deeper, so the last one is at depth 4 with only its label
+		if d := depth(v); d > 5 {
+			t.Errorf("%d: got depth %d in %v", seed, d, v)
+		}
+
+		v, err = codec.NewGenerator(seed, GeneratorOptions{Optional: -1, Fuzz: true}).Struct(shape)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := v["filled"]; ok {
+			t.Errorf("%d: optional field set in %v", seed, v)
+		}
+		if color := v["color"]; color != "RED" && color != "GREEN" {
+			t.Errorf("%d: got color %v", seed, color)
+		}
+		for _, tag := range v["tags"].([]any) {
+			if _, ok := tag.(string); !ok {
+				t.Errorf("%d: got tag %v", seed, tag)
+			}
+		}
+	}
+
+	if v, err := codec.NewGenerator(1, GeneratorOptions{}).Value(tree.Elems[3].FieldType); err != nil || len(v.(map[string]any)) != 1 {
+		t.Errorf("got %v, %v", v, err)
+	}
+}
+
+// maps keyed by structs or containers are generated as entries with distinct keys
+func TestGenerator_entries(t *testing.T) {
+	codec, file := load(t)
+	st := structOf(t, file, "Keyed")
+	for seed := int64(0); seed < 20; seed++ {
+		value, err := codec.NewGenerator(seed, GeneratorOptions{Fuzz: true}).Struct(st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"names", "groups", "origin"} {
+			entries, ok := value[name].([]MapEntry)
+			if !ok {
+				t.Fatalf("%d: got %s %T", seed, name, value[name])
+			}
+			seen := map[string]bool{}
+			for _, entry := range entries {
+				if key := fmt.Sprint(entry.Key); seen[key] {
+					t.Errorf("%d: duplicate key %s in %s", seed, key, name)
+				} else {
+					seen[key] = true
+				}
+			}
+		}
+		for _, p := range []struct {
+			name   string
+			encode func(*thrifter.Struct, map[string]any) ([]byte, error)
+			decode func(*thrifter.Struct, []byte) (map[string]any, error)
+		}{
+			{"binary", codec.EncodeBinary, codec.DecodeBinary},
+			{"compact", codec.EncodeCompact, codec.DecodeCompact},
+			{"simple json", codec.EncodeSimpleJSON, codec.DecodeSimpleJSON},
+		} {
+			data, err := p.encode(st, value)
+			if err != nil {
+				t.Fatalf("%d %s: %v", seed, p.name, err)
+			}
+			decoded, err := p.decode(st, data)
+			if err != nil {
+				t.Fatalf("%d %s: %v", seed, p.name, err)
+			}
+			if fmt.Sprint(decoded) != fmt.Sprint(value) {
+				t.Errorf("%d %s: got %v want %v", seed, p.name, decoded, value)
+			}
+		}
+	}
+}
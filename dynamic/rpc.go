@@ -0,0 +1,311 @@
+package dynamic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// MessageType is the type in header of message.
+type MessageType byte
+
+const (
+	CALL      MessageType = 1
+	REPLY     MessageType = 2
+	EXCEPTION MessageType = 3
+	ONEWAY    MessageType = 4
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case CALL:
+		return "call"
+	case REPLY:
+		return "reply"
+	case EXCEPTION:
+		return "exception"
+	case ONEWAY:
+		return "oneway"
+	}
+	return fmt.Sprintf("message(%d)", byte(t))
+}
+
+// Protocol creates writers and readers of a wire protocol.
+type Protocol interface {
+	NewWriter(w io.Writer) Writer
+	NewReader(r io.Reader) Reader
+}
+
+// BinaryProtocol is TBinaryProtocol.
+type BinaryProtocol struct{}
+
+func (BinaryProtocol) NewWriter(w io.Writer) Writer { return NewBinaryWriter(w) }
+func (BinaryProtocol) NewReader(r io.Reader) Reader { return NewBinaryReader(r) }
+
+// CompactProtocol is TCompactProtocol.
+type CompactProtocol struct{}
+
+func (CompactProtocol) NewWriter(w io.Writer) Writer { return NewCompactWriter(w) }
+func (CompactProtocol) NewReader(r io.Reader) Reader { return NewCompactReader(r) }
+
+// JSONProtocol is TJSONProtocol.
+type JSONProtocol struct{}
+
+func (JSONProtocol) NewWriter(w io.Writer) Writer { return NewJSONWriter(w) }
+func (JSONProtocol) NewReader(r io.Reader) Reader { return NewJSONReader(r) }
+
+// MaxFrameSize is the max size of frames read, larger frames are rejected.
+const MaxFrameSize = 16 << 20
+
+// WriteFrame writes data as a frame of TFramedTransport, i.e. prefixed with its size in 4 bytes big-endian.
+func WriteFrame(w io.Writer, data []byte) error {
+	if len(data) > MaxFrameSize {
+		return fmt.Errorf("frame size %d exceeds %d", len(data), MaxFrameSize)
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(data)))
+	if _, err := w.Write(append(size[:], data...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadFrame reads a frame of TFramedTransport.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > MaxFrameSize {
+		return nil, fmt.Errorf("frame size %d exceeds %d", n, MaxFrameSize)
+	}
+	return readBytes(r, int(n))
+}
+
+// ApplicationError is a TApplicationException replied instead of a result, e.g. for unknown functions.
+type ApplicationError struct {
+	Message string
+	Type    int32
+}
+
+func (e *ApplicationError) Error() string {
+	return fmt.Sprintf("application exception %d: %s", e.Type, e.Message)
+}
+
+// applicationException is the struct of TApplicationException
+var applicationException = func() *thrifter.Struct {
+	res := &thrifter.Struct{Type: thrifter.EXCEPTION, Ident: "TApplicationException"}
+	for i, name := range []string{"string", "i32"} {
+		field := thrifter.NewField(res)
+		field.ID, field.Ident = i+1, []string{"message", "type"}[i]
+		field.FieldType = &thrifter.FieldType{Type: thrifter.FIELD_TYPE_BASE, BaseType: name}
+		res.Elems = append(res.Elems, field)
+	}
+	res.Reindex()
+	return res
+}()
+
+// Exception is an exception in throws of function replied instead of a result.
+type Exception struct {
+	Field string // name in throws
+	Type  string // name of the exception
+	Value map[string]any
+}
+
+func (e *Exception) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Field, e.Type, e.Value)
+}
+
+// ArgsStruct returns the struct of arguments of fn, named fn_args like generated code.
+func ArgsStruct(fn *thrifter.Function) *thrifter.Struct {
+	return syntheticStruct(fn.Ident+"_args", fn.Args)
+}
+
+// ResultStruct returns the struct of results of fn, named fn_result like generated code.
+// Its field 0 named success is the return value unless fn is void, and other fields are exceptions in throws.
+func ResultStruct(fn *thrifter.Function) *thrifter.Struct {
+	var fields []*thrifter.Field
+	if !fn.Void && fn.FunctionType != nil {
+		success := thrifter.NewField(fn)
+		success.Ident, success.FieldType = "success", fn.FunctionType
+		fields = append(fields, success)
+	}
+	return syntheticStruct(fn.Ident+"_result", append(fields, fn.Throws...))
+}
+
+// syntheticStruct creates a struct of copies of fields, so that they keep node ids, and their types are still resolved in their files
+func syntheticStruct(name string, fields []*thrifter.Field) *thrifter.Struct {
+	res := &thrifter.Struct{Type: thrifter.STRUCT, Ident: name}
+	for _, field := range fields {
+		copied := *field
+		res.Elems = append(res.Elems, &copied)
+	}
+	res.Reindex()
+	return res
+}
+
+// Functions returns functions of service including inherited ones, functions of base services come first.
+func (c *Codec) Functions(service *thrifter.Service) ([]*thrifter.Function, error) {
+	var chain []*thrifter.Service
+	seen := map[*thrifter.Service]bool{}
+	for s := service; ; {
+		if seen[s] {
+			return nil, fmt.Errorf("service %s extends itself", s.Ident)
+		}
+		seen[s] = true
+		chain = append(chain, s)
+		if s.Extends == "" {
+			break
+		}
+		var decl thrifter.Node
+		if file := thrifter.Root(s); file != nil {
+			decl, _ = c.program.Resolve(file, s.Extends)
+		}
+		base, ok := decl.(*thrifter.Service)
+		if !ok {
+			return nil, fmt.Errorf("unknown service %s", s.Extends)
+		}
+		s = base
+	}
+	var res []*thrifter.Function
+	for i := len(chain) - 1; i >= 0; i-- {
+		res = append(res, chain[i].Elems...)
+	}
+	return res, nil
+}
+
+// Function returns the function of service named name, which may be inherited, or nil if there is no such function.
+func (c *Codec) Function(service *thrifter.Service, name string) (*thrifter.Function, error) {
+	fns, err := c.Functions(service)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(fns) - 1; i >= 0; i-- {
+		if fns[i].Ident == name {
+			return fns[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// WriteCall writes a message calling fn, whose args are keyed by names of arguments.
+func (c *Codec) WriteCall(w Writer, fn *thrifter.Function, seqid int32, args map[string]any) error {
+	typ := CALL
+	if fn.Oneway {
+		typ = ONEWAY
+	}
+	if err := w.WriteMessageBegin(fn.Ident, typ, seqid); err != nil {
+		return err
+	}
+	if err := c.writeStruct(w, ArgsStruct(fn), args, fn.Ident, 0); err != nil {
+		return err
+	}
+	if err := w.WriteMessageEnd(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// ReadReply reads a message replying the call of fn with seqid.
+// It returns the return value, which is nil for void functions, or an *Exception in throws of fn, or an *ApplicationError.
+func (c *Codec) ReadReply(r Reader, fn *thrifter.Function, seqid int32) (any, error) {
+	name, typ, id, err := r.ReadMessageBegin()
+	if err != nil {
+		return nil, err
+	}
+	if name != fn.Ident {
+		return nil, fmt.Errorf("reply of %s, expected %s", name, fn.Ident)
+	}
+	if id != seqid {
+		return nil, fmt.Errorf("reply of seqid %d, expected %d", id, seqid)
+	}
+	switch typ {
+	case EXCEPTION:
+		value, err := c.readStruct(r, applicationException, fn.Ident, 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.ReadMessageEnd(); err != nil {
+			return nil, err
+		}
+		res := &ApplicationError{}
+		res.Message, _ = value["message"].(string)
+		res.Type, _ = value["type"].(int32)
+		return nil, res
+	case REPLY:
+	default:
+		return nil, fmt.Errorf("unexpected %s message replying %s", typ, fn.Ident)
+	}
+
+	value, err := c.readStruct(r, ResultStruct(fn), fn.Ident, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ReadMessageEnd(); err != nil {
+		return nil, err
+	}
+	for _, field := range fn.Throws {
+		if v, ok := value[field.Ident].(map[string]any); ok {
+			return nil, &Exception{Field: field.Ident, Type: field.FieldType.Ident, Value: v}
+		}
+	}
+	if fn.Void {
+		return nil, nil
+	}
+	res, ok := value["success"]
+	if !ok {
+		return nil, fmt.Errorf("%s failed: unknown result", fn.Ident)
+	}
+	return res, nil
+}
+
+// Client calls functions over a connection, it's not safe for concurrent use.
+type Client struct {
+	codec    *Codec
+	conn     io.ReadWriter
+	protocol Protocol
+	framed   bool
+	r        *bufio.Reader
+	reader   Reader // of unframed messages
+	seqid    int32
+}
+
+// NewClient creates a client calling over conn in protocol, with TFramedTransport if framed or unframed otherwise.
+func (c *Codec) NewClient(conn io.ReadWriter, protocol Protocol, framed bool) *Client {
+	r := bufio.NewReader(conn)
+	return &Client{codec: c, conn: conn, protocol: protocol, framed: framed, r: r, reader: protocol.NewReader(r)}
+}
+
+// Call calls fn with args keyed by names of arguments, and returns like Codec.ReadReply.
+// Oneway calls return nil without waiting for replies.
+func (c *Client) Call(fn *thrifter.Function, args map[string]any) (any, error) {
+	c.seqid++
+	var buf bytes.Buffer
+	if err := c.codec.WriteCall(c.protocol.NewWriter(&buf), fn, c.seqid, args); err != nil {
+		return nil, err
+	}
+	var err error
+	if c.framed {
+		err = WriteFrame(c.conn, buf.Bytes())
+	} else {
+		_, err = c.conn.Write(buf.Bytes())
+	}
+	if err != nil || fn.Oneway {
+		return nil, err
+	}
+
+	r := c.reader
+	if c.framed {
+		data, err := ReadFrame(c.r)
+		if err != nil {
+			return nil, err
+		}
+		r = c.protocol.NewReader(bytes.NewReader(data))
+	}
+	return c.codec.ReadReply(r, fn, c.seqid)
+}
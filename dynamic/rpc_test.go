@@ -0,0 +1,195 @@
+package dynamic
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+func serviceOf(t *testing.T, file *thrifter.Thrift, name string) *thrifter.Service {
+	t.Helper()
+	service, ok := file.Declaration(name).(*thrifter.Service)
+	if !ok {
+		t.Fatalf("service %s not found", name)
+	}
+	return service
+}
+
+func TestWriteCall(t *testing.T) {
+	codec, file := load(t)
+	get := serviceOf(t, file, "Shapes").FunctionByName("get")
+	cases := []struct {
+		protocol Protocol
+		want     []byte
+	}{
+		{BinaryProtocol{}, fixture(t, "80010001 00000003 676574 00000007", "0b 0001 00000002 7371 08 0002 00000001 00")},
+		{CompactProtocol{}, fixture(t, "82 21 07 03 676574", "18 02 7371 15 02 00")},
+		{JSONProtocol{}, []byte(`[1,"get",1,7,{"1":{"str":"sq"},"2":{"i32":1}}]`)},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := codec.WriteCall(c.protocol.NewWriter(&buf), get, 7, map[string]any{"name": "sq", "color": "RED"}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), c.want) {
+			t.Errorf("%T: got %x want %x", c.protocol, buf.Bytes(), c.want)
+		}
+	}
+}
+
+func TestReadMessageBegin(t *testing.T) {
+	cases := []struct {
+		reader Reader
+		err    string
+	}{
+		// the old form of binary protocol starts with the name
+		{NewBinaryReader(bytes.NewReader(fixture(t, "00000003 676574 02 00000007"))), ""},
+		{NewBinaryReader(bytes.NewReader(fixture(t, "80020002 00000003 676574 00000007"))), "bad binary protocol version 0x80020000"},
+		{NewCompactReader(bytes.NewReader(fixture(t, "82 41 07 03 676574"))), ""},
+		{NewCompactReader(bytes.NewReader(fixture(t, "80 41 07 03 676574"))), "bad compact protocol id 0x80"},
+		{NewCompactReader(bytes.NewReader(fixture(t, "82 42 07 03 676574"))), "bad compact protocol version 2"},
+		{NewJSONReader(strings.NewReader(`[1,"get",2,7,{}]`)), ""},
+		{NewJSONReader(strings.NewReader(`[2,"get",2,7,{}]`)), "bad json protocol version 2"},
+	}
+	for i, c := range cases {
+		name, typ, seqid, err := c.reader.ReadMessageBegin()
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%d: got [%v] want [%v]", i, err, c.err)
+			}
+			continue
+		}
+		if err != nil || name != "get" || typ != REPLY || seqid != 7 {
+			t.Errorf("%d: got %s %s %d %v", i, name, typ, seqid, err)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	codec, file := load(t)
+	service := serviceOf(t, file, "Shapes")
+	protocols := []Protocol{BinaryProtocol{}, CompactProtocol{}, JSONProtocol{}}
+	for _, protocol := range protocols {
+		for _, framed := range []bool{false, true} {
+			client, server := net.Pipe()
+			var logged []any
+			s, err := codec.NewServer(service, protocol, framed, func(fn *thrifter.Function, args map[string]any) (any, error) {
+				switch fn.Ident {
+				case "get":
+					if args["name"] == "sq" {
+						return map[string]any{"name": "sq", "color": args["color"]}, nil
+					}
+					return nil, &Exception{Field: "missing", Value: map[string]any{"name": args["name"]}}
+				case "log":
+					logged = append(logged, args["line"])
+				case "ping":
+					return "one", nil
+				}
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			done := make(chan error, 1)
+			go func() { done <- s.ServeConn(server) }()
+
+			c := codec.NewClient(client, protocol, framed)
+			get := service.FunctionByName("get")
+			got, err := c.Call(get, map[string]any{"name": "sq", "color": "GREEN"})
+			if want := map[string]any{"name": "sq", "color": "GREEN"}; err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("%T %v: got %#v, %v want %#v", protocol, framed, got, err, want)
+			}
+			_, err = c.Call(get, map[string]any{"name": "circle"})
+			var exception *Exception
+			if !errors.As(err, &exception) || exception.Field != "missing" || exception.Type != "NotFound" || exception.Value["name"] != "circle" {
+				t.Errorf("%T %v: got %v", protocol, framed, err)
+			}
+			if got, err := c.Call(service.FunctionByName("log"), map[string]any{"line": "a"}); got != nil || err != nil {
+				t.Errorf("%T %v: got %v, %v", protocol, framed, got, err)
+			}
+			if got, err := c.Call(service.FunctionByName("clear"), nil); got != nil || err != nil {
+				t.Errorf("%T %v: got %v, %v", protocol, framed, got, err)
+			}
+			_, err = c.Call(&thrifter.Function{Ident: "missing", Void: true}, nil)
+			var appErr *ApplicationError
+			if !errors.As(err, &appErr) || appErr.Type != AppUnknownMethod || appErr.Message != "unknown method missing" {
+				t.Errorf("%T %v: got %v", protocol, framed, err)
+			}
+			// inherited functions are served, invalid results are replied as internal errors
+			ping, _ := codec.Function(service, "ping")
+			_, err = c.Call(ping, nil)
+			if !errors.As(err, &appErr) || appErr.Type != AppInternalError || appErr.Message != "invalid result: ping.success: expected i32, got string" {
+				t.Errorf("%T %v: got %v", protocol, framed, err)
+			}
+
+			client.Close()
+			if err := <-done; err != nil {
+				t.Errorf("%T %v: server got %v", protocol, framed, err)
+			}
+			if !reflect.DeepEqual(logged, []any{"a"}) {
+				t.Errorf("%T %v: logged %v", protocol, framed, logged)
+			}
+		}
+	}
+}
+
+func TestReadReply_errors(t *testing.T) {
+	codec, file := load(t)
+	get := serviceOf(t, file, "Shapes").FunctionByName("get")
+	cases := map[string]string{
+		`[1,"put",2,1,{}]`:      "reply of put, expected get",
+		`[1,"get",2,2,{}]`:      "reply of seqid 2, expected 1",
+		`[1,"get",1,1,{}]`:      "unexpected call message replying get",
+		`[1,"get",2,1,{}]`:      "get failed: unknown result",
+		`[1,"get",2,1,{"0":1}]`: "field 0: expected object of a type and a value",
+	}
+	for data, want := range cases {
+		_, err := codec.ReadReply(NewJSONReader(strings.NewReader(data)), get, 1)
+		if err == nil || err.Error() != want {
+			t.Errorf("%s: got [%v] want [%v]", data, err, want)
+		}
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, []byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+	if want := fixture(t, "00000002 6162"); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got %x want %x", buf.Bytes(), want)
+	}
+	if got, err := ReadFrame(&buf); err != nil || string(got) != "ab" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := ReadFrame(bytes.NewReader(fixture(t, "7fffffff"))); err == nil || err.Error() != "frame size 2147483647 exceeds 16777216" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	codec, file := load(t)
+	service := serviceOf(t, file, "Shapes")
+	fns, err := codec.Functions(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, fn := range fns {
+		names = append(names, fn.Ident)
+	}
+	if want := []string{"ping", "get", "log", "clear"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v want %v", names, want)
+	}
+	if fn, err := codec.Function(service, "ping"); err != nil || fn != serviceOf(t, file, "Base").Elems[0] {
+		t.Errorf("got %v, %v", fn, err)
+	}
+	if fn, err := codec.Function(service, "put"); err != nil || fn != nil {
+		t.Errorf("got %v, %v", fn, err)
+	}
+}
@@ -0,0 +1,186 @@
+package dynamic
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// types of TApplicationException
+const (
+	AppUnknown       int32 = 0
+	AppUnknownMethod int32 = 1
+	AppInternalError int32 = 6
+)
+
+// WriteReply writes a message replying the call of fn with seqid.
+// If err is nil, result is the return value, which is ignored for void functions.
+// Otherwise an *Exception in throws of fn is replied as a field of the result struct, an *ApplicationError as is, and other errors as internal errors.
+func (c *Codec) WriteReply(w Writer, fn *thrifter.Function, seqid int32, result any, err error) error {
+	value := map[string]any{}
+	var exception *Exception
+	var appErr *ApplicationError
+	switch {
+	case errors.As(err, &exception):
+		value[exception.Field] = exception.Value
+	case errors.As(err, &appErr):
+		return c.writeApplicationError(w, fn.Ident, seqid, appErr)
+	case err != nil:
+		return c.writeApplicationError(w, fn.Ident, seqid, &ApplicationError{Message: err.Error(), Type: AppInternalError})
+	case !fn.Void:
+		value["success"] = result
+	}
+	if err := w.WriteMessageBegin(fn.Ident, REPLY, seqid); err != nil {
+		return err
+	}
+	if err := c.writeStruct(w, ResultStruct(fn), value, fn.Ident, 0); err != nil {
+		return err
+	}
+	if err := w.WriteMessageEnd(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (c *Codec) writeApplicationError(w Writer, name string, seqid int32, appErr *ApplicationError) error {
+	if err := w.WriteMessageBegin(name, EXCEPTION, seqid); err != nil {
+		return err
+	}
+	value := map[string]any{"message": appErr.Message, "type": appErr.Type}
+	if err := c.writeStruct(w, applicationException, value, name, 0); err != nil {
+		return err
+	}
+	if err := w.WriteMessageEnd(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Handler handles a call of fn with args keyed by names of arguments, it returns the result like Codec.WriteReply.
+// Results of oneway functions are ignored.
+type Handler func(fn *thrifter.Function, args map[string]any) (any, error)
+
+// Server serves calls of functions of a service, including inherited ones.
+type Server struct {
+	codec     *Codec
+	functions map[string]*thrifter.Function
+	protocol  Protocol
+	framed    bool
+	handler   Handler
+}
+
+// NewServer creates a server of service in protocol, with TFramedTransport if framed or unframed otherwise.
+// Calls are handled concurrently by handler.
+func (c *Codec) NewServer(service *thrifter.Service, protocol Protocol, framed bool, handler Handler) (*Server, error) {
+	fns, err := c.Functions(service)
+	if err != nil {
+		return nil, err
+	}
+	functions := make(map[string]*thrifter.Function, len(fns))
+	for _, fn := range fns {
+		functions[fn.Ident] = fn
+	}
+	return &Server{codec: c, functions: functions, protocol: protocol, framed: framed, handler: handler}, nil
+}
+
+// Serve serves connections accepted from listener until it's closed.
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			s.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn serves calls on conn until it's closed, or a message can't be read.
+// Calls of unknown functions are replied with application exceptions.
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	br := bufio.NewReader(conn)
+	stream := s.protocol.NewReader(br)
+	for {
+		r := stream
+		if s.framed {
+			data, err := ReadFrame(br)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			r = s.protocol.NewReader(bytes.NewReader(data))
+		}
+		name, typ, seqid, err := r.ReadMessageBegin()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		reply, err := s.handle(r, name, typ, seqid)
+		if err != nil {
+			return err
+		}
+		if reply == nil {
+			continue
+		}
+		if s.framed {
+			err = WriteFrame(conn, reply)
+		} else {
+			_, err = conn.Write(reply)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handle reads args of a call after its header, and returns the reply, which is nil for oneway calls
+func (s *Server) handle(r Reader, name string, typ MessageType, seqid int32) ([]byte, error) {
+	var buf bytes.Buffer
+	w := s.protocol.NewWriter(&buf)
+	fn := s.functions[name]
+	if fn == nil {
+		if err := Skip(r, STRUCT); err != nil {
+			return nil, err
+		}
+		if err := r.ReadMessageEnd(); err != nil {
+			return nil, err
+		}
+		err := s.codec.writeApplicationError(w, name, seqid, &ApplicationError{Message: "unknown method " + name, Type: AppUnknownMethod})
+		return buf.Bytes(), err
+	}
+
+	args, err := s.codec.Read(r, ArgsStruct(fn))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ReadMessageEnd(); err != nil {
+		return nil, err
+	}
+	result, err := s.handler(fn, args)
+	if typ == ONEWAY || fn.Oneway {
+		return nil, nil
+	}
+	if err := s.codec.WriteReply(w, fn, seqid, result, err); err != nil {
+		// the result of handler is invalid
+		buf.Reset()
+		w = s.protocol.NewWriter(&buf)
+		err = s.codec.writeApplicationError(w, name, seqid, &ApplicationError{Message: fmt.Sprintf("invalid result: %v", err), Type: AppInternalError})
+		return buf.Bytes(), err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,361 @@
+package dynamic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// EncodeSimpleJSON encodes value as struct st in plain JSON like TSimpleJSONProtocol, i.e. structs are objects keyed by field names in order of declaration.
+// Unlike TSimpleJSONProtocol, enums are written by element names, and values are written in forms which DecodeSimpleJSON reads back:
+// map keys are quoted, binaries are in base64, and NaN and infinities are strings.
+// Maps whose keys are structs or containers are written as arrays of {"key":…,"value":…} objects.
+func (c *Codec) EncodeSimpleJSON(st *thrifter.Struct, value map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.writeSimpleStruct(&buf, st, value, st.Ident, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *Codec) writeSimpleStruct(buf *bytes.Buffer, st *thrifter.Struct, value map[string]any, path string, depth int) error {
+	if depth > MaxDepth {
+		return fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	fields, err := presentFields(st, value, path)
+	if err != nil {
+		return err
+	}
+	buf.WriteByte('{')
+	for i, field := range fields {
+		s, err := c.resolve(field.FieldType)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %v", path, field.Ident, err)
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(quote(field.Ident))
+		buf.WriteByte(':')
+		if err := c.writeSimpleValue(buf, s, value[field.Ident], path+"."+field.Ident, depth+1); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func (c *Codec) writeSimpleValue(buf *bytes.Buffer, s *schema, v any, path string, depth int) error {
+	if depth > MaxDepth {
+		return fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	switch s.typ {
+	case STRUCT:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected %s, got %T", path, s.name, v)
+		}
+		return c.writeSimpleStruct(buf, s.st, m, path, depth)
+	case LIST, SET:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fmt.Errorf("%s: expected %s, got %T", path, s.name, v)
+		}
+		buf.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := c.writeSimpleValue(buf, s.elem, rv.Index(i).Interface(), fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case MAP:
+		if !hashable(s.key) {
+			entries, err := mapEntries(s, v, path)
+			if err != nil {
+				return err
+			}
+			buf.WriteByte('[')
+			for i, entry := range entries {
+				elemPath := fmt.Sprintf("%s[%d]", path, i)
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(`{"key":`)
+				if err := c.writeSimpleValue(buf, s.key, entry.Key, elemPath, depth+1); err != nil {
+					return err
+				}
+				buf.WriteString(`,"value":`)
+				if err := c.writeSimpleValue(buf, s.elem, entry.Value, elemPath, depth+1); err != nil {
+					return err
+				}
+				buf.WriteByte('}')
+			}
+			buf.WriteByte(']')
+			return nil
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Map {
+			return fmt.Errorf("%s: expected %s, got %T", path, s.name, v)
+		}
+		buf.WriteByte('{')
+		for i, key := range sortedKeys(rv) {
+			elemPath := fmt.Sprintf("%s[%v]", path, key.Interface())
+			k, err := simpleScalar(s.key, key.Interface(), elemPath)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if k[0] == '"' {
+				buf.WriteString(k)
+			} else {
+				buf.Write(quote(k))
+			}
+			buf.WriteByte(':')
+			if err := c.writeSimpleValue(buf, s.elem, rv.MapIndex(key).Interface(), elemPath, depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+	res, err := simpleScalar(s, v, path)
+	if err != nil {
+		return err
+	}
+	buf.WriteString(res)
+	return nil
+}
+
+// simpleScalar formats v of base type or enum s in JSON
+func simpleScalar(s *schema, v any, path string) (string, error) {
+	v, err := scalar(s, v, path)
+	if err != nil {
+		return "", err
+	}
+	if s.enum != nil {
+		v = enumName(s, v.(int32))
+	}
+	switch v := v.(type) {
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int8, int16, int32, int64:
+		return fmt.Sprint(v), nil
+	case float64:
+		if special := specialFloat(v); special != "" {
+			return `"` + special + `"`, nil
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case string:
+		return string(quote(v)), nil
+	case []byte:
+		return `"` + base64.StdEncoding.EncodeToString(v) + `"`, nil
+	}
+	return "", fmt.Errorf("%s: unsupported type %s", path, s.name)
+}
+
+// DecodeSimpleJSON decodes a value of struct st in plain JSON written by EncodeSimpleJSON, data after the value is an error.
+// Unknown fields are errors, while null values are taken as missing.
+func (c *Codec) DecodeSimpleJSON(st *thrifter.Struct, data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("data remain after %s", st.Ident)
+	}
+	object, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected object, got %s", st.Ident, jsonKind(v))
+	}
+	return c.readSimpleStruct(st, object, st.Ident, 0)
+}
+
+func (c *Codec) readSimpleStruct(st *thrifter.Struct, object map[string]any, path string, depth int) (map[string]any, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res := map[string]any{}
+	for _, name := range names {
+		field := st.FieldByName(name)
+		if field == nil {
+			return nil, fmt.Errorf("%s: unknown field %s", path, name)
+		}
+		if object[name] == nil {
+			continue
+		}
+		s, err := c.resolve(field.FieldType)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", path, name, err)
+		}
+		if res[name], err = c.readSimpleValue(s, object[name], path+"."+name, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.setDefaults(st, res, path, depth); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Codec) readSimpleValue(s *schema, v any, path string, depth int) (any, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("%s: exceeds max depth %d", path, MaxDepth)
+	}
+	mismatch := fmt.Errorf("%s: expected %s, got %s", path, s.name, jsonKind(v))
+	switch s.typ {
+	case STRUCT:
+		object, ok := v.(map[string]any)
+		if !ok {
+			return nil, mismatch
+		}
+		return c.readSimpleStruct(s.st, object, path, depth)
+	case LIST, SET:
+		elems, ok := v.([]any)
+		if !ok {
+			return nil, mismatch
+		}
+		res := make([]any, 0, len(elems))
+		for i, elem := range elems {
+			v, err := c.readSimpleValue(s.elem, elem, fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+		return res, nil
+	case MAP:
+		if !hashable(s.key) {
+			array, ok := v.([]any)
+			if !ok {
+				return nil, mismatch
+			}
+			entries := make([]MapEntry, len(array))
+			for i, elem := range array {
+				elemPath := fmt.Sprintf("%s[%d]", path, i)
+				entry, ok := elem.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("%s: expected object of map entry, got %s", elemPath, jsonKind(elem))
+				}
+				var err error
+				if entries[i].Key, err = c.readSimpleValue(s.key, entry["key"], elemPath, depth+1); err != nil {
+					return nil, err
+				}
+				if entries[i].Value, err = c.readSimpleValue(s.elem, entry["value"], elemPath, depth+1); err != nil {
+					return nil, err
+				}
+			}
+			return entries, nil
+		}
+		object, ok := v.(map[string]any)
+		if !ok {
+			return nil, mismatch
+		}
+		res := make(map[any]any, len(object))
+		for key, elem := range object {
+			elemPath := fmt.Sprintf("%s[%s]", path, key)
+			k, err := simpleKey(s.key, key, elemPath)
+			if err != nil {
+				return nil, err
+			}
+			if res[k], err = c.readSimpleValue(s.elem, elem, elemPath, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case DOUBLE:
+		if _, ok := v.(string); !ok {
+			break
+		}
+		// NaN and infinities
+		f, err := jsonFloat(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return f, nil
+	case STRING:
+		str, ok := v.(string)
+		if !ok {
+			return nil, mismatch
+		}
+		if !s.binary {
+			return str, nil
+		}
+		b, err := decodeBase64(str)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return b, nil
+	}
+
+	v, err := scalar(s, v, path)
+	if err != nil {
+		return nil, err
+	}
+	if s.enum != nil {
+		return enumName(s, v.(int32)), nil
+	}
+	return v, nil
+}
+
+// simpleKey converts a map key of JSON to a key of type s, binary keys are kept as strings like other decoders do
+func simpleKey(s *schema, key string, path string) (any, error) {
+	var v any = key
+	switch {
+	case s.typ == STRING && s.binary:
+		b, err := decodeBase64(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return string(b), nil
+	case s.typ == STRING:
+		return key, nil
+	case s.typ == BOOL:
+		b, err := strconv.ParseBool(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: expected bool, got %q", path, key)
+		}
+		v = b
+	case s.typ == DOUBLE:
+		f, err := jsonFloat(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		v = f
+	default:
+		// enums may be keyed by names
+		if _, err := strconv.ParseInt(key, 10, 64); err == nil {
+			v = json.Number(key)
+		}
+	}
+	v, err := scalar(s, v, path)
+	if err != nil {
+		return nil, err
+	}
+	if s.enum != nil {
+		return enumName(s, v.(int32)), nil
+	}
+	return v, nil
+}
@@ -7,7 +7,7 @@ type Enum struct {
 	Ident    string
 	Elems    []*EnumElement
 	Options  []*Option
-	ElemsMap map[string]*EnumElement // startToken hash => EnumElement node
+	ElemsMap map[string]*EnumElement // node id => EnumElement node
 }
 
 func NewEnum(start *Token, parent Node) *Enum {
@@ -34,19 +34,26 @@ func (r *Enum) String() string {
 
 func (r *Enum) parse(p *Parser) (err error) {
 	p.peekNonWhitespace()
-	identTok := p.nextIdent(false)
+	identTok, err := p.expectIdent(false)
+	if err != nil {
+		return err
+	}
 	r.Ident = identTok.Raw
+	r.NodeID = genNodeID("", r.NodeType(), r.Ident)
 	ru := p.peekNonWhitespace()
-	if toToken(string(ru)) != T_LEFTCURLY {
+	if runeToken(ru) != T_LEFTCURLY {
 		return p.unexpected(string(ru), "{")
 	}
 	p.next() // consume {
 	for {
 		ru := p.peekNonWhitespace()
-		if toToken(string(ru)) == T_RIGHTCURLY {
+		if runeToken(ru) == T_RIGHTCURLY {
 			r.EndToken = p.next()
 			break
 		}
+		if ru == eof {
+			return p.unexpected("EOF", "}")
+		}
 		elem := NewEnumElement(r)
 		if err = elem.parse(p); err != nil {
 			return err
@@ -57,7 +64,7 @@ func (r *Enum) parse(p *Parser) (err error) {
 
 	// parse options
 	ru = p.peekNonWhitespace()
-	if toToken(string(ru)) != T_LEFTPAREN {
+	if runeToken(ru) != T_LEFTPAREN {
 		return
 	}
 	p.next() // consume (
@@ -68,6 +75,25 @@ func (r *Enum) parse(p *Parser) (err error) {
 	return
 }
 
+// Reindex regenerates node ids of the enum and its elements, and rebuilds ElemsMap.
+// Call it after changing Ident, element identifiers or Elems directly.
+func (r *Enum) Reindex() {
+	r.NodeID = genNodeID("", r.NodeType(), r.Ident)
+	r.ElemsMap = make(map[string]*EnumElement, len(r.Elems))
+	for _, elem := range r.Elems {
+		elem.patchToParentMap()
+	}
+}
+
+// ElementByName returns the enum element with the given identifier, or nil if it does not exist.
+// It looks up ElemsMap without modifying the enum, call Reindex first after changing element identifiers or Elems directly.
+func (r *Enum) ElementByName(name string) *EnumElement {
+	if elem, ok := r.ElemsMap[genNodeID(r.NodeID, "EnumElement", name)]; ok && elem.Ident == name {
+		return elem
+	}
+	return nil
+}
+
 type EnumElement struct {
 	NodeCommonField
 	ID      int
@@ -96,22 +122,25 @@ func (r *EnumElement) String() string {
 }
 
 func (r *EnumElement) patchToParentMap() {
-	hash := GenTokenHash(r.StartToken)
 	parent := r.Parent.(*Enum)
-	parent.ElemsMap[hash] = r
+	r.NodeID = genNodeID(parent.NodeID, "EnumElement", r.Ident)
+	parent.ElemsMap[r.NodeID] = r
 }
 
 func (r *EnumElement) parse(p *Parser) (err error) {
 	p.peekNonWhitespace()
-	identTok := p.nextIdent(false)
+	identTok, err := p.expectIdent(false)
+	if err != nil {
+		return err
+	}
 	r.StartToken = identTok
 	r.Ident = identTok.Raw
 	ru := p.peekNonWhitespace()
 	// if there is no = after enum field identifier, then directly parse EndToken
-	if toToken(string(ru)) != T_EQUALS {
+	if runeToken(ru) != T_EQUALS {
 		// parse options
 		ru = p.peekNonWhitespace()
-		if toToken(string(ru)) != T_LEFTPAREN {
+		if runeToken(ru) != T_LEFTPAREN {
 			r.EndToken = identTok
 			// parse separator
 			if err = r.parseSeparator(p); err != nil {
@@ -148,7 +177,7 @@ func (r *EnumElement) parse(p *Parser) (err error) {
 
 	// parse options
 	ru = p.peekNonWhitespace()
-	if toToken(string(ru)) != T_LEFTPAREN {
+	if runeToken(ru) != T_LEFTPAREN {
 		r.EndToken = tok
 		// parse separator
 		if err = r.parseSeparator(p); err != nil {
@@ -172,8 +201,40 @@ func (r *EnumElement) parse(p *Parser) (err error) {
 
 func (r *EnumElement) parseSeparator(p *Parser) (err error) {
 	ru := p.peekNonWhitespace()
-	if toToken(string(ru)) == T_COMMA || toToken(string(ru)) == T_SEMICOLON {
+	if runeToken(ru) == T_COMMA || runeToken(ru) == T_SEMICOLON {
 		r.EndToken = p.next()
 	}
 	return
 }
+
+// Value returns the value of the element, an element without explicit value is one greater than the previous element, and the first one is 0.
+func (r *EnumElement) Value() int {
+	parent, ok := r.Parent.(*Enum)
+	if !ok || r.HasValue() {
+		return r.ID
+	}
+	value := 0
+	for _, elem := range parent.Elems {
+		if elem.HasValue() {
+			value = elem.ID
+		}
+		if elem == r {
+			return value
+		}
+		value++
+	}
+	return r.ID
+}
+
+// HasValue reports whether the element has an explicit value, e.g. ONE = 1, otherwise its ID is 0 and Value counts it.
+func (r *EnumElement) HasValue() bool {
+	for tok := r.StartToken; tok != nil && tok.Type != T_LEFTPAREN; tok = tok.Next {
+		if tok.Type == T_EQUALS {
+			return true
+		}
+		if tok == r.EndToken {
+			break
+		}
+	}
+	return false
+}
@@ -63,8 +63,7 @@ func TestEnum_elemsMap(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 	for _, ele := range n.Elems {
-		hash := GenTokenHash(ele.StartToken)
-		if got, want := n.ElemsMap[hash], ele; got != want {
+		if got, want := n.ElemsMap[ele.NodeID], ele; got != want {
 			t.Errorf("got [%v] want [%v]", got, want)
 		}
 	}
@@ -169,3 +168,60 @@ func TestEnum_toStringWithOptions(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 }
+
+func TestEnum_elementByName(t *testing.T) {
+	parser := newParserOn(`enum Color {
+		RED = 1
+		GREEN = 2;
+	}`)
+	startTok := parser.next()
+	n := NewEnum(startTok, nil)
+	if err := n.parse(parser); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	if got, want := n.Elems[1].NodeID, "Enum(Color).EnumElement(GREEN)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := n.ElementByName("GREEN"), n.Elems[1]; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := n.ElementByName("BLUE"); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+
+	n.Elems[1].Ident = "BLUE"
+	if got := n.ElementByName("BLUE"); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+	n.Reindex()
+	if got, want := n.ElementByName("BLUE"), n.Elems[1]; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := n.ElementByName("GREEN"); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+}
+
+func TestEnumElement_value(t *testing.T) {
+	parser := newParserOn(`enum a {
+		A
+		B (x = "1")
+		C = 10
+		D,
+		E = 3
+	}`)
+	startTok := parser.next()
+	n := NewEnum(startTok, nil)
+	if err := n.parse(parser); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	for i, want := range []int{0, 1, 10, 11, 3} {
+		if got := n.Elems[i].Value(); got != want {
+			t.Errorf("%s: got [%v] want [%v]", n.Elems[i].Ident, got, want)
+		}
+	}
+}
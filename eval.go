@@ -0,0 +1,337 @@
+package thrifter
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// maxEvalDepth limits nesting of const values
+const maxEvalDepth = 64
+
+// Eval evaluates the value of c as a Go value of its declared type, see EvalValue.
+func (p *Program) Eval(c *Const) (any, error) {
+	e := &evaluator{program: p, visiting: map[*Const]bool{c: true}, typedefs: map[*TypeDef]bool{}}
+	t, err := e.resolve(c.Type)
+	if err != nil {
+		return nil, err
+	}
+	v, err := e.value(t, c.Value, 0)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// EvalValue evaluates cv as a Go value of type ft, e.g. the default value of a field.
+//
+// Base types become bool, int8, int16, int32, int64, float64, string and []byte, enums become int32, lists and sets become slices, maps become Go maps, and structs become map[string]any keyed by field names.
+// So map<string, list<i32>> becomes map[string][]int32. Binary map keys become strings, and maps keyed by structs or containers become []MapEntry in order of declaration.
+// Typedefs are resolved to their types, and {} is also an empty list or set.
+// Identifiers refer to consts or enum elements, which are resolved from the file of cv, and can be qualified by include prefix.
+// Values out of range of their types, values of other types, enum values without elements, duplicate set elements or map keys are errors.
+func (p *Program) EvalValue(ft *FieldType, cv *ConstValue) (any, error) {
+	e := &evaluator{program: p, visiting: map[*Const]bool{}, typedefs: map[*TypeDef]bool{}}
+	t, err := e.resolve(ft)
+	if err != nil {
+		return nil, err
+	}
+	v, err := e.value(t, cv, 0)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// MapEntry is an entry of map whose keys are structs or containers, which can't be keys of Go maps.
+type MapEntry struct {
+	Key   any
+	Value any
+}
+
+type evaluator struct {
+	program  *Program
+	visiting map[*Const]bool   // consts being evaluated, to find cyclic references
+	typedefs map[*TypeDef]bool // typedefs being resolved, to find cyclic typedefs
+}
+
+// constType is a resolved FieldType
+type constType struct {
+	name      string // base type, declaration name, or list, set and map
+	goType    reflect.Type
+	enum      *Enum
+	st        *Struct
+	key, elem *constType // key and value of map, or elem of list and set
+}
+
+var goBaseTypes = map[string]reflect.Type{
+	"bool":   reflect.TypeOf(false),
+	"byte":   reflect.TypeOf(int8(0)),
+	"i8":     reflect.TypeOf(int8(0)),
+	"i16":    reflect.TypeOf(int16(0)),
+	"i32":    reflect.TypeOf(int32(0)),
+	"i64":    reflect.TypeOf(int64(0)),
+	"double": reflect.TypeOf(float64(0)),
+	"string": reflect.TypeOf(""),
+	"slist":  reflect.TypeOf(""),
+	"binary": reflect.TypeOf([]byte(nil)),
+}
+
+func (e *evaluator) resolve(ft *FieldType) (*constType, error) {
+	switch ft.Type {
+	case FIELD_TYPE_BASE:
+		goType, ok := goBaseTypes[ft.BaseType]
+		if !ok {
+			return nil, fmt.Errorf("%v: unknown type %s", ft.StartToken.Pos, ft.BaseType)
+		}
+		return &constType{name: ft.BaseType, goType: goType}, nil
+	case FIELD_TYPE_MAP:
+		key, err := e.resolve(ft.Map.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := e.resolve(ft.Map.Value)
+		if err != nil {
+			return nil, err
+		}
+		goType := reflect.TypeOf([]MapEntry(nil))
+		if key.name == "binary" {
+			goType = reflect.MapOf(reflect.TypeOf(""), value.goType)
+		} else if key.goType.Comparable() {
+			goType = reflect.MapOf(key.goType, value.goType)
+		}
+		return &constType{name: "map", goType: goType, key: key, elem: value}, nil
+	case FIELD_TYPE_LIST:
+		elem, err := e.resolve(ft.List.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &constType{name: "list", goType: reflect.SliceOf(elem.goType), elem: elem}, nil
+	case FIELD_TYPE_SET:
+		elem, err := e.resolve(ft.Set.Elem)
+		if err != nil {
+			return nil, err
+		}
+		return &constType{name: "set", goType: reflect.SliceOf(elem.goType), elem: elem}, nil
+	}
+
+	var decl Node
+	if file := Root(ft); file != nil {
+		decl, _ = e.program.Resolve(file, ft.Ident)
+	}
+	switch d := decl.(type) {
+	case *Struct:
+		return &constType{name: d.Ident, goType: reflect.TypeOf(map[string]any(nil)), st: d}, nil
+	case *Enum:
+		return &constType{name: d.Ident, goType: reflect.TypeOf(int32(0)), enum: d}, nil
+	case *TypeDef:
+		if e.typedefs[d] {
+			return nil, fmt.Errorf("%v: typedef %s refers to itself", ft.StartToken.Pos, d.Ident)
+		}
+		e.typedefs[d] = true
+		defer delete(e.typedefs, d)
+		return e.resolve(d.Type)
+	}
+	return nil, fmt.Errorf("%v: unknown type %s", ft.StartToken.Pos, ft.Ident)
+}
+
+func (e *evaluator) value(t *constType, cv *ConstValue, depth int) (reflect.Value, error) {
+	if depth > maxEvalDepth {
+		return reflect.Value{}, fmt.Errorf("%v: value exceeds max depth %d", cv.StartToken.Pos, maxEvalDepth)
+	}
+	mismatch := func(got string) (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("%v: expected %s, got %s", cv.StartToken.Pos, t.name, got)
+	}
+	res := reflect.New(t.goType).Elem()
+	switch cv.Type {
+	case CONST_VALUE_INT:
+		n, err := strconv.ParseInt(cv.Value, 0, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%v: %s overflows i64", cv.StartToken.Pos, cv.Value)
+		}
+		return e.integer(t, n, cv)
+	case CONST_VALUE_FLOAT:
+		if t.name != "double" {
+			return mismatch("double " + cv.Value)
+		}
+		f, err := strconv.ParseFloat(cv.Value, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%v: %s overflows double", cv.StartToken.Pos, cv.Value)
+		}
+		res.SetFloat(f)
+	case CONST_VALUE_LITERAL:
+		// quotes are kept in Value, and there are no escapes in thrift strings
+		s := cv.Value[1 : len(cv.Value)-1]
+		switch t.name {
+		case "string", "slist":
+			res.SetString(s)
+		case "binary":
+			res.SetBytes([]byte(s))
+		default:
+			return mismatch("string " + cv.Value)
+		}
+	case CONST_VALUE_IDENT:
+		if cv.Value == "true" || cv.Value == "false" {
+			if t.name != "bool" {
+				return mismatch("bool " + cv.Value)
+			}
+			res.SetBool(cv.Value == "true")
+			break
+		}
+		var decl Node
+		if file := Root(cv); file != nil {
+			decl, _ = e.program.Resolve(file, cv.Value)
+		}
+		switch d := decl.(type) {
+		case *Const:
+			if e.visiting[d] {
+				return reflect.Value{}, fmt.Errorf("%v: const %s refers to itself", cv.StartToken.Pos, cv.Value)
+			}
+			e.visiting[d] = true
+			defer delete(e.visiting, d)
+			return e.value(t, d.Value, depth+1)
+		case *EnumElement:
+			if t.enum != nil && d.Parent != t.enum {
+				return mismatch(d.Parent.(*Enum).Ident + " element " + cv.Value)
+			}
+			return e.integer(t, int64(d.Value()), cv)
+		case nil:
+			return reflect.Value{}, fmt.Errorf("%v: unknown const %s", cv.StartToken.Pos, cv.Value)
+		default:
+			return reflect.Value{}, fmt.Errorf("%v: %s is not a const", cv.StartToken.Pos, cv.Value)
+		}
+	case CONST_VALUE_LIST:
+		if t.elem == nil || t.key != nil {
+			return mismatch("list")
+		}
+		seen := map[any]bool{}
+		for _, elem := range cv.List.Elems {
+			v, err := e.value(t.elem, elem, depth+1)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if t.name == "set" && v.Type().Comparable() {
+				if seen[v.Interface()] {
+					return reflect.Value{}, fmt.Errorf("%v: duplicate element %s in set", elem.StartToken.Pos, elem.Value)
+				}
+				seen[v.Interface()] = true
+			}
+			res = reflect.Append(res, v)
+		}
+		if res.IsNil() {
+			res = reflect.MakeSlice(t.goType, 0, 0)
+		}
+	case CONST_VALUE_MAP:
+		if t.elem != nil && t.key == nil && len(cv.Map.MapKeyList) == 0 {
+			return reflect.MakeSlice(t.goType, 0, 0), nil
+		}
+		return e.constMap(t, cv, depth)
+	}
+	return res, nil
+}
+
+// integer converts n to t, which is an integer, bool, double or enum
+func (e *evaluator) integer(t *constType, n int64, cv *ConstValue) (reflect.Value, error) {
+	res := reflect.New(t.goType).Elem()
+	switch {
+	case t.name == "bool":
+		// 0 and 1 are used as bool in thrift
+		if n != 0 && n != 1 {
+			return reflect.Value{}, fmt.Errorf("%v: %d overflows bool", cv.StartToken.Pos, n)
+		}
+		res.SetBool(n == 1)
+	case t.name == "double":
+		res.SetFloat(float64(n))
+	case t.enum != nil:
+		found := false
+		for _, elem := range t.enum.Elems {
+			found = found || int64(elem.Value()) == n
+		}
+		if !found {
+			return reflect.Value{}, fmt.Errorf("%v: %s has no element of value %d", cv.StartToken.Pos, t.name, n)
+		}
+		res.SetInt(n)
+	case res.CanInt():
+		if res.OverflowInt(n) {
+			return reflect.Value{}, fmt.Errorf("%v: %d overflows %s", cv.StartToken.Pos, n, t.name)
+		}
+		res.SetInt(n)
+	default:
+		return reflect.Value{}, fmt.Errorf("%v: expected %s, got integer %s", cv.StartToken.Pos, t.name, cv.Value)
+	}
+	return res, nil
+}
+
+// constMap evaluates a const map as a map, or as a struct whose fields are named by keys
+func (e *evaluator) constMap(t *constType, cv *ConstValue, depth int) (reflect.Value, error) {
+	m := cv.Map
+	switch {
+	case t.st != nil:
+		res := map[string]any{}
+		for i := range m.MapKeyList {
+			key := &m.MapKeyList[i]
+			if key.Type != CONST_VALUE_LITERAL {
+				return reflect.Value{}, fmt.Errorf("%v: expected field name, got %s", key.StartToken.Pos, key.Value)
+			}
+			name := key.Value[1 : len(key.Value)-1]
+			field := t.st.FieldByName(name)
+			if field == nil {
+				return reflect.Value{}, fmt.Errorf("%v: %s has no field %s", key.StartToken.Pos, t.name, name)
+			}
+			if _, ok := res[name]; ok {
+				return reflect.Value{}, fmt.Errorf("%v: duplicate field %s", key.StartToken.Pos, name)
+			}
+			ft, err := e.resolve(field.FieldType)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v, err := e.value(ft, &m.MapValueList[i], depth+1)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			res[name] = v.Interface()
+		}
+		return reflect.ValueOf(res), nil
+	case t.key != nil && t.goType.Kind() == reflect.Slice:
+		res := make([]MapEntry, 0, len(m.MapKeyList))
+		seen := map[string]bool{}
+		for i := range m.MapKeyList {
+			key, err := e.value(t.key, &m.MapKeyList[i], depth+1)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			// structs and containers are printed the same iff they are equal
+			k := fmt.Sprint(key.Interface())
+			if seen[k] {
+				return reflect.Value{}, fmt.Errorf("%v: duplicate key %s in map", m.MapKeyList[i].StartToken.Pos, m.MapKeyList[i].String())
+			}
+			seen[k] = true
+			v, err := e.value(t.elem, &m.MapValueList[i], depth+1)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			res = append(res, MapEntry{Key: key.Interface(), Value: v.Interface()})
+		}
+		return reflect.ValueOf(res), nil
+	case t.key != nil:
+		res := reflect.MakeMapWithSize(t.goType, len(m.MapKeyList))
+		for i := range m.MapKeyList {
+			key, err := e.value(t.key, &m.MapKeyList[i], depth+1)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			// binary keys are strings
+			key = key.Convert(t.goType.Key())
+			if res.MapIndex(key).IsValid() {
+				return reflect.Value{}, fmt.Errorf("%v: duplicate key %s in map", m.MapKeyList[i].StartToken.Pos, m.MapKeyList[i].Value)
+			}
+			v, err := e.value(t.elem, &m.MapValueList[i], depth+1)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			res.SetMapIndex(key, v)
+		}
+		return res, nil
+	}
+	return reflect.Value{}, fmt.Errorf("%v: expected %s, got map", cv.StartToken.Pos, t.name)
+}
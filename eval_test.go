@@ -0,0 +1,130 @@
+package thrifter
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProgram_eval(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.thrift": `include "shared.thrift"
+		typedef list<i32> Ids
+		struct User {
+			1: i64 id
+			2: shared.Color color
+			3: optional list<string> tags
+		}
+		const i8 SMALL = -128
+		const i64 BIG = 9223372036854775807
+		const bool ON = 1
+		const double RATIO = 2
+		const binary DATA = "ab"
+		const Ids IDS = [1, SMALL, shared.LIMIT]
+		const map<string, list<i32>> GROUPS = {"a": [1, 2], "b": IDS, "c": []}
+		const set<shared.Color> COLORS = [shared.Color.RED, 2]
+		const map<shared.Color, User> USERS = {shared.Color.RED: {"id": 1, "color": shared.Color.GREEN, "tags": ["x"]}}
+		const i32 ALIAS = shared.Color.GREEN
+		const string NAME = shared.NAME
+		const set<binary> BIN_SET = {}
+		const map<binary, i32> BIN_MAP = {"a": 1}
+		const map<User, list<i32>> BY_USER = {{"id": 2}: [1], {"id": 1}: {}}
+		const map<set<i32>, i32> BY_SET = {[1, 2]: 3}`,
+		"shared.thrift": `enum Color {
+			RED
+			GREEN = 2
+		}
+		const i16 LIMIT = 100
+		const string NAME = 'thrifter'`,
+	})
+	program := NewProgram()
+	main, err := program.Load(filepath.Join(dir, "main.thrift"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cases := []struct {
+		name string
+		want any
+	}{
+		{"SMALL", int8(-128)},
+		{"BIG", int64(1<<63 - 1)},
+		{"ON", true},
+		{"RATIO", float64(2)},
+		{"DATA", []byte("ab")},
+		{"IDS", []int32{1, -128, 100}},
+		{"GROUPS", map[string][]int32{"a": {1, 2}, "b": {1, -128, 100}, "c": {}}},
+		{"COLORS", []int32{0, 2}},
+		{"USERS", map[int32]map[string]any{0: {"id": int64(1), "color": int32(2), "tags": []string{"x"}}}},
+		{"ALIAS", int32(2)},
+		{"NAME", "thrifter"},
+		{"BIN_SET", [][]byte{}},
+		{"BIN_MAP", map[string]int32{"a": 1}},
+		{"BY_USER", []MapEntry{{Key: map[string]any{"id": int64(2)}, Value: []int32{1}}, {Key: map[string]any{"id": int64(1)}, Value: []int32{}}}},
+		{"BY_SET", []MapEntry{{Key: []int32{1, 2}, Value: int32(3)}}},
+	}
+	for _, c := range cases {
+		got, err := program.Eval(main.Declaration(c.name).(*Const))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got [%#v] want [%#v]", c.name, got, c.want)
+		}
+	}
+
+	// a value evaluated as a type of another file
+	color := main.Declaration("User").(*Struct).Elems[1].FieldType
+	red := main.Declaration("COLORS").(*Const).Value.List.Elems[0]
+	if got, err := program.EvalValue(color, red); err != nil || got != int32(0) {
+		t.Errorf("got [%v, %v] want [0, nil]", got, err)
+	}
+}
+
+func TestProgram_evalErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{`const i8 X = 128`, "128 overflows i8"},
+		{`const i16 X = -32769`, "-32769 overflows i16"},
+		{`const i64 X = 9223372036854775808`, "9223372036854775808 overflows i64"},
+		{`const bool X = 2`, "2 overflows bool"},
+		{`const i32 X = 1.5`, "expected i32, got double 1.5"},
+		{`const string X = 1`, "expected string, got integer 1"},
+		{`const i32 X = "a"`, `expected i32, got string "a"`},
+		{`const i32 X = true`, "expected i32, got bool true"},
+		{`const list<i32> X = {1: 1}`, "expected list, got map"},
+		{`const map<i32, i32> X = [1]`, "expected map, got list"},
+		{`const map<i32, i32> X = {1: 1, 1: 2}`, "duplicate key 1 in map"},
+		{`const set<string> X = ["a", "a"]`, `duplicate element "a" in set`},
+		{`const map<binary, i32> X = {"a": 1, "a": 2}`, `duplicate key "a" in map`},
+		{`struct S { 1: i32 a } const map<S, i32> X = {{"a": 1}: 1, {"a": 1}: 2}`, `duplicate key {"a": 1} in map`},
+		{`const set<i32> X = {1: 1}`, "expected set, got map"},
+		{`const i32 X = Y`, "unknown const Y"},
+		{`const i32 X = Y const i32 Y = X`, "const X refers to itself"},
+		{`struct S {} const i32 X = S`, "S is not a const"},
+		{`const T X = 1`, "unknown type T"},
+		{`typedef list<L> L const L X = []`, "typedef L refers to itself"},
+		{`typedef map<i32, M> M struct S { 1: M a } const S X = {"a": {}}`, "typedef M refers to itself"},
+		{`enum E { A } const E X = 1`, "E has no element of value 1"},
+		{`enum E { A } enum F { B } const E X = F.B`, "expected E, got F element F.B"},
+		{`struct S { 1: i32 a } const S X = {"b": 1}`, "S has no field b"},
+		{`struct S { 1: i32 a } const S X = {1: 1}`, "expected field name, got 1"},
+		{`struct S { 1: list<i8> a } const S X = {"a": [1, 200]}`, "200 overflows i8"},
+	}
+	for _, c := range cases {
+		file, err := NewParser(strings.NewReader(c.src), false).Parse("test.thrift")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.src, err)
+			continue
+		}
+		program := NewProgram()
+		program.Add(file)
+		_, err = program.Eval(file.Declaration("X").(*Const))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got [%v] want [%v]", c.src, err, c.want)
+		}
+	}
+}
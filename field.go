@@ -50,14 +50,17 @@ func (r *Field) parse(p *Parser) (err error) {
 	r.ID = int(id)
 	r.StartToken = idToken
 	ru := p.peekNonWhitespace()
-	if toToken(string(ru)) != T_COLON {
+	if runeToken(ru) != T_COLON {
 		return p.unexpected(string(ru), ":")
 	}
 	p.next() // consume :
 
 	// parse requiredness
 	p.peekNonWhitespace()
-	tok := p.nextIdent(true)
+	tok, err := p.expectIdent(true)
+	if err != nil {
+		return err
+	}
 	if tok.Value == "required" || tok.Value == "optional" {
 		r.Requiredness = tok.Value
 	} else {
@@ -72,12 +75,15 @@ func (r *Field) parse(p *Parser) (err error) {
 
 	// parse identifier
 	p.peekNonWhitespace()
-	identTok := p.nextIdent(false)
+	identTok, err := p.expectIdent(false)
+	if err != nil {
+		return err
+	}
 	r.Ident = identTok.Raw
 
 	// parse DefaultValue/Options
 	ru = p.peekNonWhitespace()
-	if toToken(string(ru)) == T_EQUALS {
+	if runeToken(ru) == T_EQUALS {
 		p.next() // consume =
 		cst, err := r.parseDefaultValue(p)
 		if err != nil {
@@ -86,7 +92,7 @@ func (r *Field) parse(p *Parser) (err error) {
 		r.DefaultValue = cst
 		// see if there are options
 		ru := p.peekNonWhitespace()
-		if toToken(string(ru)) == T_LEFTPAREN {
+		if runeToken(ru) == T_LEFTPAREN {
 			p.next() // consume (
 			var rightParenTok *Token
 			r.Options, rightParenTok, err = r.parseOptions(p)
@@ -101,7 +107,7 @@ func (r *Field) parse(p *Parser) (err error) {
 				return err
 			}
 		}
-	} else if toToken(string(ru)) == T_LEFTPAREN {
+	} else if runeToken(ru) == T_LEFTPAREN {
 		p.next() // consume (
 		var rightParenTok *Token
 		r.Options, rightParenTok, err = r.parseOptions(p)
@@ -122,7 +128,7 @@ func (r *Field) parse(p *Parser) (err error) {
 
 func (r *Field) parseEnd(defaultEnd *Token, p *Parser) (err error) {
 	ru := p.peekNonWhitespace()
-	if toToken(string(ru)) == T_COMMA || toToken(string(ru)) == T_SEMICOLON {
+	if runeToken(ru) == T_COMMA || runeToken(ru) == T_SEMICOLON {
 		r.EndToken = p.next()
 	} else {
 		r.EndToken = defaultEnd
@@ -143,10 +149,14 @@ func (r *Field) parseOptions(p *Parser) (options []*Option, rightParenTok *Token
 	var currOption *Option
 	for {
 		ru := p.peekNonWhitespace()
-		if toToken(string(ru)) == T_RIGHTPAREN {
+		if runeToken(ru) == T_RIGHTPAREN {
 			rightParenTok = p.next()
 			break
 		}
+		if ru == eof {
+			err = p.unexpected("EOF", ")")
+			return
+		}
 
 		currOption = NewOption(r)
 		err = currOption.parse(p)
@@ -156,7 +166,7 @@ func (r *Field) parseOptions(p *Parser) (options []*Option, rightParenTok *Token
 		options = append(options, currOption)
 
 		ru = p.peekNonWhitespace()
-		if toToken(string(ru)) == T_COMMA {
+		if runeToken(ru) == T_COMMA {
 			p.next() // consume comma
 		}
 	}
@@ -42,7 +42,10 @@ func (r *FieldType) String() string {
 
 func (r *FieldType) parse(p *Parser) (err error) {
 	p.peekNonWhitespace()
-	identTok := p.nextIdent(true)
+	identTok, err := p.expectIdent(true)
+	if err != nil {
+		return err
+	}
 	r.StartToken = identTok
 	if isBaseTypeToken(identTok.Raw) {
 		r.Type = FIELD_TYPE_BASE
@@ -88,7 +91,7 @@ func (r *FieldType) parse(p *Parser) (err error) {
 		}
 	} else {
 		ru := p.peekNonWhitespace()
-		if toToken(string(ru)) != T_LEFTPAREN {
+		if runeToken(ru) != T_LEFTPAREN {
 			return
 		}
 		p.next() // consume (
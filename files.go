@@ -0,0 +1,130 @@
+package thrifter
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// Cache stores parsed files by path and content hash, so that unchanged files are not parsed again. It's safe for concurrent use.
+//
+// An entry is replaced when the content of its file changes, and removed when the file can't be read or parsed, or by Forget.
+type Cache struct {
+	mu      sync.RWMutex
+	entries map[string]cacheEntry // file path => cache entry
+}
+
+type cacheEntry struct {
+	hash   [sha256.Size]byte
+	thrift *Thrift
+}
+
+func NewCache() *Cache {
+	return &Cache{
+		entries: map[string]cacheEntry{},
+	}
+}
+
+// DefaultCache is the Cache used by ParseFiles. It holds every file parsed by ParseFiles until the file can no longer be read or parsed,
+// call DefaultCache.Forget for files which are no longer needed, or use a Cache owned by the caller to drop them all at once.
+var DefaultCache = NewCache()
+
+// ParseFiles parses files concurrently with DefaultCache, see Cache.ParseFiles.
+func ParseFiles(ctx context.Context, paths []string, workers int) ([]*Thrift, error) {
+	return DefaultCache.ParseFiles(ctx, paths, workers)
+}
+
+// ParseFiles parses files with at most workers goroutines, workers <= 0 means runtime.GOMAXPROCS(0). Each file is parsed by its own Parser, since Parser is not safe for concurrent use.
+//
+// Results are in the same order as paths. A file which failed to parse or was skipped due to cancellation gets a nil result, and all errors are joined into the returned error.
+//
+// Note that results of unchanged files are served from cache and shared between calls, so copy them before mutating.
+func (c *Cache) ParseFiles(ctx context.Context, paths []string, workers int) ([]*Thrift, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	res := make([]*Thrift, len(paths))
+	errs := make([]error, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				res[idx], errs[idx] = c.parseFile(paths[idx])
+			}
+		}()
+	}
+
+feed:
+	for idx := range paths {
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return res, errors.Join(errs...)
+}
+
+func (c *Cache) parseFile(path string) (res *Thrift, err error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		c.Forget(path)
+		return nil, err
+	}
+	hash := sha256.Sum256(src)
+	if res = c.get(path, hash); res != nil {
+		return
+	}
+	res, err = NewParserBytes(src, false).Parse(path)
+	if err != nil {
+		c.Forget(path)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c.put(path, hash, res)
+	return
+}
+
+func (c *Cache) get(path string, hash [sha256.Size]byte) *Thrift {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if entry, ok := c.entries[path]; ok && entry.hash == hash {
+		return entry.thrift
+	}
+	return nil
+}
+
+func (c *Cache) put(path string, hash [sha256.Size]byte, res *Thrift) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[path] = cacheEntry{hash: hash, thrift: res}
+}
+
+// Forget removes the entry of path, so the file is parsed again by the next ParseFiles.
+func (c *Cache) Forget(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, path)
+}
+
+// Len returns the number of files in cache.
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
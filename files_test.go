@@ -0,0 +1,204 @@
+package thrifter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFiles_basic(t *testing.T) {
+	paths := []string{
+		"./examples/ThriftTest.thrift",
+		"./examples/fb303.thrift",
+		"./examples/cassandra.thrift",
+		"./examples/annotation_test.thrift",
+	}
+	res, err := NewCache().ParseFiles(context.Background(), paths, 2)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	for i, path := range paths {
+		src, err := readFile(path)
+		if err != nil {
+			t.Errorf("readFile error: %v", err)
+			return
+		}
+		if got, want := res[i].FileName, path; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+		if got, want := res[i].String(), src; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+}
+
+func TestParseFiles_cache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.thrift")
+	if err := os.WriteFile(path, []byte("struct A {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache()
+	first, err := cache.ParseFiles(context.Background(), []string{path}, 1)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	second, err := cache.ParseFiles(context.Background(), []string{path}, 1)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	if got, want := second[0], first[0]; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("struct B {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	third, err := cache.ParseFiles(context.Background(), []string{path}, 1)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if got, want := third[0].Nodes[0].(*Struct).Ident, "B"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseFiles_errors(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.thrift")
+	bad := filepath.Join(dir, "bad.thrift")
+	comment := filepath.Join(dir, "comment.thrift")
+	missing := filepath.Join(dir, "missing.thrift")
+	os.WriteFile(good, []byte("struct A {}"), 0644)
+	os.WriteFile(bad, []byte("struct A ["), 0644)
+	os.WriteFile(comment, []byte("struct A {} /* unterminated"), 0644)
+
+	res, err := NewCache().ParseFiles(context.Background(), []string{good, bad, comment, missing}, 4)
+	if err == nil {
+		t.Errorf("expected error")
+		return
+	}
+
+	if res[0] == nil {
+		t.Errorf("got [nil] want result for %s", good)
+	}
+	for _, path := range []string{bad, comment, missing} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("got [%v] want error of %s", err.Error(), path)
+		}
+	}
+	if !strings.Contains(err.Error(), "unterminated block comment") {
+		t.Errorf("got [%v] want [%v]", err.Error(), "unterminated block comment")
+	}
+}
+
+func TestParseFiles_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := NewCache().ParseFiles(ctx, []string{"./examples/ThriftTest.thrift"}, 1)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got [%v] want [%v]", err, context.Canceled)
+	}
+	if res[0] != nil {
+		t.Errorf("got [%v] want [nil]", res[0])
+	}
+}
+
+func TestParseFiles_concurrentCache(t *testing.T) {
+	paths := []string{
+		"./examples/ThriftTest.thrift",
+		"./examples/fb303.thrift",
+		"./examples/cassandra.thrift",
+		"./examples/annotation_test.thrift",
+	}
+	cache := NewCache()
+	done := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			_, err := cache.ParseFiles(context.Background(), paths, 4)
+			done <- err
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestParseFiles_defaultCache(t *testing.T) {
+	paths := []string{"./examples/fb303.thrift"}
+	first, err := ParseFiles(context.Background(), paths, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := ParseFiles(context.Background(), paths, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := second[0], first[0]; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	DefaultCache.Forget(paths[0])
+	third, err := ParseFiles(context.Background(), paths, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if third[0] == first[0] {
+		t.Errorf("got cached result after Forget")
+	}
+}
+
+func TestCache_evict(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.thrift")
+	if err := os.WriteFile(path, []byte("struct A {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache()
+	if _, err := cache.ParseFiles(context.Background(), []string{path}, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := cache.Len(), 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	// entries of files which fail to parse are removed
+	if err := os.WriteFile(path, []byte("struct A {"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.ParseFiles(context.Background(), []string{path}, 1); err == nil {
+		t.Errorf("expected error")
+	}
+	if got, want := cache.Len(), 0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+
+	// and so are entries of removed files
+	if err := os.WriteFile(path, []byte("struct A {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.ParseFiles(context.Background(), []string{path}, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.ParseFiles(context.Background(), []string{path}, 1); err == nil {
+		t.Errorf("expected error")
+	}
+	if got, want := cache.Len(), 0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -0,0 +1,200 @@
+package thrifter
+
+import (
+	"sort"
+	"strings"
+)
+
+// Format computes edits which normalize white spaces of the source, it:
+//  1. indents each line by its nesting depth of {}, () and [].
+//  2. removes trailing white spaces.
+//  3. collapses consecutive blank lines into one, and removes blank lines at the beginning and the end of file.
+//  4. ends the file with a single line break.
+//
+// Only white space tokens are touched, so comments and alignment inside a line are preserved. Edits are sorted and relative to current source, use ApplyEdits to get the formatted source.
+func (r *Thrift) Format(indent string) (res []TextEdit) {
+	if r.StartToken == nil || r.EndToken == nil {
+		return
+	}
+	lines := splitLines(r.StartToken)
+	lastContent := -1
+	for idx, line := range lines {
+		if !line.isBlank() {
+			lastContent = idx
+		}
+	}
+
+	depth := 0
+	prevBlank := true // so that blank lines at the beginning of file are removed
+	for idx, line := range lines {
+		if line.isBlank() {
+			if prevBlank || idx > lastContent {
+				// remove whole line
+				if start, end := line.start(), line.end(); start != end {
+					res = append(res, TextEdit{Start: start, End: end})
+				}
+			} else if len(line.leading) > 0 {
+				res = append(res, line.replaceLeading(""))
+			}
+			prevBlank = true
+			continue
+		}
+		prevBlank = false
+
+		lineDepth := depth
+		if isClosingToken(line.content[0].Type) {
+			lineDepth--
+		}
+		for _, tok := range line.content {
+			if isOpeningToken(tok.Type) {
+				depth++
+			} else if isClosingToken(tok.Type) {
+				depth--
+			}
+		}
+		if lineDepth < 0 {
+			lineDepth = 0
+		}
+		if want := strings.Repeat(indent, lineDepth); line.leadingString() != want {
+			res = append(res, line.replaceLeading(want))
+		}
+		if len(line.trailing) > 0 {
+			res = append(res, TextEdit{Start: line.trailing[0].Start, End: line.trailing[len(line.trailing)-1].End})
+		}
+		if line.lineBreak == nil {
+			// file should end with a line break
+			res = append(res, TextEdit{Start: r.EndToken.Start, End: r.EndToken.Start, NewText: "\n"})
+		}
+	}
+	return
+}
+
+// ApplyEdits applies non-overlapping edits, whose offsets are relative to src, and returns the result.
+// Insertions at the same offset are applied in order, and before a replacement starting at that offset.
+func ApplyEdits(src string, edits []TextEdit) string {
+	sorted := make([]TextEdit, len(edits))
+	copy(sorted, edits)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Start != sorted[j].Start {
+			return sorted[i].Start < sorted[j].Start
+		}
+		return sorted[i].End == sorted[i].Start && sorted[j].End != sorted[j].Start
+	})
+	var res strings.Builder
+	offset := 0
+	for _, edit := range sorted {
+		res.WriteString(src[offset:edit.Start])
+		res.WriteString(edit.NewText)
+		offset = edit.End
+	}
+	res.WriteString(src[offset:])
+	return res.String()
+}
+
+// tokens of a single line, which are split into leading white spaces, content, trailing white spaces and line break
+type formatLine struct {
+	leading   []*Token
+	content   []*Token
+	trailing  []*Token
+	carriage  *Token // \r before line break
+	lineBreak *Token
+}
+
+func splitLines(start *Token) (res []*formatLine) {
+	var tokens []*Token
+	flush := func(lineBreak *Token) {
+		line := &formatLine{lineBreak: lineBreak}
+		if n := len(tokens); n > 0 && tokens[n-1].Type == T_RETURN {
+			line.carriage = tokens[n-1]
+			tokens = tokens[:n-1]
+		}
+		i := 0
+		for i < len(tokens) && isIndentToken(tokens[i].Type) {
+			i++
+		}
+		j := len(tokens)
+		for j > i && isIndentToken(tokens[j-1].Type) {
+			j--
+		}
+		line.leading, line.content, line.trailing = tokens[:i], tokens[i:j], tokens[j:]
+		res = append(res, line)
+		tokens = nil
+	}
+	for tok := start; tok != nil; tok = tok.Next {
+		if tok.Type == T_EOF {
+			break
+		}
+		if tok.Type == T_LINEBREAK {
+			flush(tok)
+			continue
+		}
+		tokens = append(tokens, tok)
+	}
+	if len(tokens) > 0 {
+		flush(nil)
+	}
+	return
+}
+
+func (r *formatLine) isBlank() bool {
+	return len(r.content) == 0
+}
+
+func (r *formatLine) start() int {
+	for _, toks := range [][]*Token{r.leading, r.content, r.trailing} {
+		if len(toks) > 0 {
+			return toks[0].Start
+		}
+	}
+	if r.carriage != nil {
+		return r.carriage.Start
+	}
+	if r.lineBreak != nil {
+		return r.lineBreak.Start
+	}
+	return 0
+}
+
+func (r *formatLine) end() int {
+	if r.lineBreak != nil {
+		return r.lineBreak.End
+	}
+	if r.carriage != nil {
+		return r.carriage.End
+	}
+	for _, toks := range [][]*Token{r.trailing, r.content, r.leading} {
+		if len(toks) > 0 {
+			return toks[len(toks)-1].End
+		}
+	}
+	return 0
+}
+
+func (r *formatLine) leadingString() string {
+	var res strings.Builder
+	for _, tok := range r.leading {
+		res.WriteString(tok.Raw)
+	}
+	return res.String()
+}
+
+// replace leading white spaces, for blank lines, trailing white spaces are counted as leading
+func (r *formatLine) replaceLeading(text string) TextEdit {
+	if len(r.leading) == 0 {
+		offset := r.start()
+		return TextEdit{Start: offset, End: offset, NewText: text}
+	}
+	return TextEdit{Start: r.leading[0].Start, End: r.leading[len(r.leading)-1].End, NewText: text}
+}
+
+func isIndentToken(t token) bool {
+	return t == T_SPACE || t == T_TAB
+}
+
+func isOpeningToken(t token) bool {
+	return t == T_LEFTCURLY || t == T_LEFTPAREN || t == T_LEFTSQUARE
+}
+
+func isClosingToken(t token) bool {
+	return t == T_RIGHTCURLY || t == T_RIGHTPAREN || t == T_RIGHTSQUARE
+}
@@ -0,0 +1,57 @@
+package thrifter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormat_basic(t *testing.T) {
+	src := "\n\nstruct A {   \n1: i32 a,\n\t\t2: list<i32> b = [\n1,\n  2\n]\n\n\n  3: i32 c /* keep   */\n  }\n\n"
+	res, err := NewParser(strings.NewReader(src), false).Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	want := "struct A {\n  1: i32 a,\n  2: list<i32> b = [\n    1,\n    2\n  ]\n\n  3: i32 c /* keep   */\n}\n"
+	if got := ApplyEdits(src, res.Format("  ")); got != want {
+		t.Errorf("got [%q] want [%q]", got, want)
+	}
+}
+
+func TestFormat_examples(t *testing.T) {
+	for _, path := range []string{"./examples/ThriftTest.thrift", "./examples/cassandra.thrift", "./examples/fb303.thrift", "./examples/annotation_test.thrift"} {
+		src, err := readFile(path)
+		if err != nil {
+			t.Errorf("readFile error: %v", err)
+			return
+		}
+		res, err := NewParser(strings.NewReader(src), false).Parse(path)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		formatted := ApplyEdits(src, res.Format("  "))
+		res, err = NewParser(strings.NewReader(formatted), false).Parse(path)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", path, err)
+			return
+		}
+		// formatting is idempotent
+		if got, want := len(res.Format("  ")), 0; got != want {
+			t.Errorf("%s: got [%v] want [%v]", path, got, want)
+		}
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	got := ApplyEdits("abcdef", []TextEdit{
+		{Start: 4, End: 5, NewText: "E"},
+		{Start: 0, End: 0, NewText: ">"},
+		{Start: 1, End: 3},
+	})
+
+	if want := ">adEf"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -0,0 +1,221 @@
+// Package graph exports dependencies of thrift files as graphs, i.e. the include graph of files and the reference graph of types,
+// which are printed in Graphviz DOT, Mermaid or JSON.
+package graph
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Graph is a directed graph, nodes and edges are in a deterministic order.
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+}
+
+// Node is a file, a top-level declaration or a function, see ID.
+type Node struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Kind  string `json:"kind"`           // NodeType, e.g. Thrift, Struct or Function
+	File  string `json:"file,omitempty"` // file of declaration, empty for files
+}
+
+// Edge from a node to a node it depends on, e.g. a struct to the type of its field, whose label is the field name.
+type Edge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label,omitempty"`
+}
+
+// ID returns the id of a file, a top-level declaration or a function in graphs, e.g. idl/user.thrift, idl/user.thrift#User and idl/user.thrift#UserService.get.
+func ID(node thrifter.Node) string {
+	file := thrifter.Root(node)
+	if file == nil {
+		return ""
+	}
+	switch n := node.(type) {
+	case *thrifter.Thrift:
+		return n.FileName
+	case *thrifter.Function:
+		if service, ok := n.Parent.(*thrifter.Service); ok {
+			return file.FileName + "#" + service.Ident + "." + n.Ident
+		}
+	case *thrifter.Struct:
+		return file.FileName + "#" + n.Ident
+	case *thrifter.Enum:
+		return file.FileName + "#" + n.Ident
+	case *thrifter.Service:
+		return file.FileName + "#" + n.Ident
+	case *thrifter.TypeDef:
+		return file.FileName + "#" + n.Ident
+	case *thrifter.Const:
+		return file.FileName + "#" + n.Ident
+	}
+	return ""
+}
+
+// Includes returns the include graph of files in program, cpp_includes and files not loaded are skipped.
+func Includes(program *thrifter.Program) *Graph {
+	g := newBuilder()
+	for _, file := range sortedFiles(program) {
+		g.addNode(&Node{ID: ID(file), Label: filepath.Base(file.FileName), Kind: file.NodeType()})
+	}
+	for _, file := range sortedFiles(program) {
+		for _, inc := range file.Includes() {
+			path, ok := program.IncludePath(file, inc)
+			if included := program.Files[path]; ok && included != nil {
+				g.addEdge(ID(file), ID(included), "")
+			}
+		}
+	}
+	return g.Graph
+}
+
+// Types returns the reference graph of declarations in program.
+// Structs refer to types of fields and consts of defaults, typedefs refer to their types, consts refer to enums and consts in values,
+// services refer to services they extend and their functions, and functions refer to types of args, returns and throws.
+// Edges are labeled by fields, args, e.g. arg id, throws, e.g. throws e, or by returns, typedef, value, extends and function.
+// An enum element is represented by its enum.
+func Types(program *thrifter.Program) *Graph {
+	g := newBuilder()
+	for _, file := range sortedFiles(program) {
+		prefix := strings.TrimSuffix(filepath.Base(file.FileName), filepath.Ext(file.FileName))
+		for _, node := range file.Nodes {
+			id := ID(node)
+			if id == "" {
+				continue
+			}
+			g.addNode(&Node{ID: id, Label: prefix + "." + thrifter.IdentToken(node).Raw, Kind: node.NodeType(), File: file.FileName})
+			if service, ok := node.(*thrifter.Service); ok {
+				for _, fn := range service.Elems {
+					g.addNode(&Node{ID: ID(fn), Label: service.Ident + "." + fn.Ident, Kind: fn.NodeType(), File: file.FileName})
+				}
+			}
+		}
+	}
+	for _, file := range sortedFiles(program) {
+		for _, ref := range program.FileReferences(file) {
+			if ref.Target == nil {
+				continue
+			}
+			from, label := source(ref.Node)
+			g.addEdge(ID(from), ID(thrifter.TopLevel(ref.Target)), label)
+		}
+		for _, node := range file.Nodes {
+			if service, ok := node.(*thrifter.Service); ok {
+				for _, fn := range service.Elems {
+					g.addEdge(ID(service), ID(fn), "function")
+				}
+			}
+		}
+	}
+	return g.Graph
+}
+
+// Closure returns the subgraph of nodes reachable from nodes of ids, including themselves, e.g. the closure of a service.
+func (g *Graph) Closure(ids ...string) *Graph {
+	edges := map[string][]*Edge{}
+	for _, edge := range g.Edges {
+		edges[edge.From] = append(edges[edge.From], edge)
+	}
+	reachable := map[string]bool{}
+	queue := append([]string{}, ids...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if reachable[id] {
+			continue
+		}
+		reachable[id] = true
+		for _, edge := range edges[id] {
+			queue = append(queue, edge.To)
+		}
+	}
+	res := &Graph{Nodes: []*Node{}, Edges: []*Edge{}}
+	for _, node := range g.Nodes {
+		if reachable[node.ID] {
+			res.Nodes = append(res.Nodes, node)
+		}
+	}
+	for _, edge := range g.Edges {
+		if reachable[edge.From] {
+			res.Edges = append(res.Edges, edge)
+		}
+	}
+	return res
+}
+
+// JSON returns the graph in indented JSON.
+func (g *Graph) JSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
+
+// the node which a reference in node belongs to, and the label of the edge
+func source(node thrifter.Node) (thrifter.Node, string) {
+	for ; node != nil; node = node.CommonField().Parent {
+		switch n := node.(type) {
+		case *thrifter.Field:
+			fn, ok := n.Parent.(*thrifter.Function)
+			if !ok {
+				return thrifter.TopLevel(n), n.Ident
+			}
+			for _, throw := range fn.Throws {
+				if throw == n {
+					return fn, "throws " + n.Ident
+				}
+			}
+			return fn, "arg " + n.Ident
+		case *thrifter.Function:
+			return n, "returns"
+		case *thrifter.TypeDef:
+			return n, "typedef"
+		case *thrifter.Const:
+			return n, "value"
+		case *thrifter.Service:
+			return n, "extends"
+		}
+	}
+	return nil, ""
+}
+
+func sortedFiles(program *thrifter.Program) []*thrifter.Thrift {
+	res := make([]*thrifter.Thrift, 0, len(program.Files))
+	for _, file := range program.Files {
+		res = append(res, file)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].FileName < res[j].FileName
+	})
+	return res
+}
+
+// builder skips duplicated nodes and edges
+type builder struct {
+	*Graph
+	nodes map[string]bool
+	edges map[Edge]bool
+}
+
+func newBuilder() *builder {
+	return &builder{Graph: &Graph{Nodes: []*Node{}, Edges: []*Edge{}}, nodes: map[string]bool{}, edges: map[Edge]bool{}}
+}
+
+func (b *builder) addNode(node *Node) {
+	if !b.nodes[node.ID] {
+		b.nodes[node.ID] = true
+		b.Nodes = append(b.Nodes, node)
+	}
+}
+
+func (b *builder) addEdge(from string, to string, label string) {
+	edge := Edge{from, to, label}
+	if from != "" && to != "" && !b.edges[edge] {
+		b.edges[edge] = true
+		b.Edges = append(b.Edges, &edge)
+	}
+}
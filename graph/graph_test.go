@@ -0,0 +1,165 @@
+package graph
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+func load(t *testing.T, files map[string]string, main string) (*thrifter.Program, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	program := thrifter.NewProgram()
+	if _, err := program.Load(filepath.Join(dir, main)); err != nil {
+		t.Fatal(err)
+	}
+	return program, dir
+}
+
+var files = map[string]string{
+	"main.thrift": `include "shared.thrift"
+cpp_include "<vector>"
+
+struct Order {
+  1: list<shared.User> buyers
+  2: shared.Status status = shared.Status.ACTIVE
+}
+
+service OrderService extends shared.BaseService {
+  Order get(1: shared.ID id) throws (1: shared.NotFound e)
+}
+
+service AdminService {
+  void reset(1: Order order)
+}
+`,
+	"shared.thrift": `typedef i64 ID
+
+enum Status {
+  ACTIVE = 1
+}
+
+struct User {
+  1: ID id
+}
+
+exception NotFound {
+}
+
+service BaseService {
+}
+`,
+}
+
+// edges in form of from -label-> to, with ids relative to dir
+func edges(g *Graph, dir string) string {
+	var res []string
+	for _, edge := range g.Edges {
+		from, to := strings.TrimPrefix(edge.From, dir+"/"), strings.TrimPrefix(edge.To, dir+"/")
+		res = append(res, from+" -"+edge.Label+"-> "+to)
+	}
+	return strings.Join(res, "\n")
+}
+
+func TestIncludes(t *testing.T) {
+	program, dir := load(t, files, "main.thrift")
+	g := Includes(program)
+	if len(g.Nodes) != 2 || g.Nodes[0].Label != "main.thrift" || g.Nodes[0].Kind != "Thrift" {
+		t.Errorf("got nodes %+v", g.Nodes)
+	}
+	if got, want := edges(g, dir), "main.thrift --> shared.thrift"; got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTypes(t *testing.T) {
+	program, dir := load(t, files, "main.thrift")
+	g := Types(program)
+	if len(g.Nodes) != 10 {
+		t.Errorf("got %d nodes", len(g.Nodes))
+	}
+	want := `main.thrift#Order -buyers-> shared.thrift#User
+main.thrift#Order -status-> shared.thrift#Status
+main.thrift#OrderService -extends-> shared.thrift#BaseService
+main.thrift#OrderService.get -returns-> main.thrift#Order
+main.thrift#OrderService.get -arg id-> shared.thrift#ID
+main.thrift#OrderService.get -throws e-> shared.thrift#NotFound
+main.thrift#AdminService.reset -arg order-> main.thrift#Order
+main.thrift#OrderService -function-> main.thrift#OrderService.get
+main.thrift#AdminService -function-> main.thrift#AdminService.reset
+shared.thrift#User -id-> shared.thrift#ID`
+	if got := edges(g, dir); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	closure := g.Closure(ID(program.Files[filepath.Join(dir, "main.thrift")].Declaration("AdminService")))
+	var labels []string
+	for _, node := range closure.Nodes {
+		labels = append(labels, node.Label)
+	}
+	if got, want := strings.Join(labels, ","), "main.Order,main.AdminService,AdminService.reset,shared.ID,shared.Status,shared.User"; got != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+	if len(closure.Edges) != 5 {
+		t.Errorf("got:\n%s", edges(closure, dir))
+	}
+}
+
+func TestGraph_print(t *testing.T) {
+	g := &Graph{
+		Nodes: []*Node{
+			{ID: "a.thrift#A", Label: "a.A", Kind: "Struct", File: "a.thrift"},
+			{ID: "a.thrift#S", Label: "a.S", Kind: "Service", File: "a.thrift"},
+			{ID: "b.thrift", Label: `b "quoted"`, Kind: "Thrift"},
+		},
+		Edges: []*Edge{
+			{From: "a.thrift#S", To: "a.thrift#A", Label: "returns"},
+			{From: "a.thrift#A", To: "b.thrift"},
+		},
+	}
+	wantDOT := `digraph thrift {
+  rankdir=LR;
+  node [fontname="Helvetica"];
+  subgraph cluster_0 {
+    label="a.thrift";
+    "a.thrift#A" [label="a.A", shape=box];
+    "a.thrift#S" [label="a.S", shape=component];
+  }
+  "b.thrift" [label="b \"quoted\"", shape=note];
+  "a.thrift#S" -> "a.thrift#A" [label="returns"];
+  "a.thrift#A" -> "b.thrift";
+}
+`
+	if got := g.DOT(); got != wantDOT {
+		t.Errorf("got:\n%s\nwant:\n%s", got, wantDOT)
+	}
+	wantMermaid := `flowchart LR
+  subgraph f0["a.thrift"]
+    n0["a.A"]
+    n1["a.S"]
+  end
+  n2["b #quot;quoted#quot;"]
+  n1 -->|"returns"| n0
+  n0 --> n2
+`
+	if got := g.Mermaid(); got != wantMermaid {
+		t.Errorf("got:\n%s\nwant:\n%s", got, wantMermaid)
+	}
+	data, err := g.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Graph
+	if err := json.Unmarshal(data, &loaded); err != nil || len(loaded.Nodes) != 3 || *loaded.Edges[0] != *g.Edges[0] {
+		t.Errorf("got %+v %v", loaded, err)
+	}
+}
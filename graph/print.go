@@ -0,0 +1,113 @@
+package graph
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DOT returns the graph in Graphviz DOT, declarations are grouped into clusters by files.
+func (g *Graph) DOT() string {
+	var res strings.Builder
+	res.WriteString("digraph thrift {\n  rankdir=LR;\n  node [fontname=\"Helvetica\"];\n")
+	for i, group := range g.groups() {
+		indent := "  "
+		if group.file != "" {
+			fmt.Fprintf(&res, "  subgraph cluster_%d {\n    label=%s;\n", i, strconv.Quote(filepath.Base(group.file)))
+			indent = "    "
+		}
+		for _, node := range group.nodes {
+			fmt.Fprintf(&res, "%s%s [label=%s, shape=%s];\n", indent, strconv.Quote(node.ID), strconv.Quote(node.Label), shape(node.Kind))
+		}
+		if group.file != "" {
+			res.WriteString("  }\n")
+		}
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&res, "  %s -> %s", strconv.Quote(edge.From), strconv.Quote(edge.To))
+		if edge.Label != "" {
+			fmt.Fprintf(&res, " [label=%s]", strconv.Quote(edge.Label))
+		}
+		res.WriteString(";\n")
+	}
+	res.WriteString("}\n")
+	return res.String()
+}
+
+// Mermaid returns the graph as a Mermaid flowchart, declarations are grouped into subgraphs by files.
+// Nodes are named n0, n1, ... in order of Nodes, since Mermaid doesn't allow paths in ids.
+func (g *Graph) Mermaid() string {
+	names := map[string]string{}
+	for i, node := range g.Nodes {
+		names[node.ID] = fmt.Sprintf("n%d", i)
+	}
+	var res strings.Builder
+	res.WriteString("flowchart LR\n")
+	for i, group := range g.groups() {
+		indent := "  "
+		if group.file != "" {
+			fmt.Fprintf(&res, "  subgraph f%d[%s]\n", i, mermaidText(filepath.Base(group.file)))
+			indent = "    "
+		}
+		for _, node := range group.nodes {
+			fmt.Fprintf(&res, "%s%s[%s]\n", indent, names[node.ID], mermaidText(node.Label))
+		}
+		if group.file != "" {
+			res.WriteString("  end\n")
+		}
+	}
+	for _, edge := range g.Edges {
+		from, to := names[edge.From], names[edge.To]
+		if from == "" || to == "" {
+			continue
+		}
+		if edge.Label != "" {
+			fmt.Fprintf(&res, "  %s -->|%s| %s\n", from, mermaidText(edge.Label), to)
+		} else {
+			fmt.Fprintf(&res, "  %s --> %s\n", from, to)
+		}
+	}
+	return res.String()
+}
+
+type group struct {
+	file  string
+	nodes []*Node
+}
+
+// nodes grouped by files in order of their first nodes, nodes of files themselves are not grouped
+func (g *Graph) groups() (res []*group) {
+	index := map[string]*group{}
+	for _, node := range g.Nodes {
+		if node.File == "" {
+			res = append(res, &group{nodes: []*Node{node}})
+			continue
+		}
+		if index[node.File] == nil {
+			index[node.File] = &group{file: node.File}
+			res = append(res, index[node.File])
+		}
+		index[node.File].nodes = append(index[node.File].nodes, node)
+	}
+	return
+}
+
+func shape(kind string) string {
+	switch kind {
+	case "Thrift":
+		return "note"
+	case "Service":
+		return "component"
+	case "Function":
+		return "ellipse"
+	case "Enum", "TypeDef", "Const":
+		return "box, style=rounded"
+	}
+	return "box"
+}
+
+// quoted text of Mermaid, where quotes are written as entity codes
+func mermaidText(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,63 @@
+package thrifter
+
+// IdentToken returns the token holding identifier of node, e.g. the name token of a struct, or the type name token of a FieldType which refers to other declaration.
+// It returns nil if node has no identifier.
+func IdentToken(node Node) *Token {
+	switch n := node.(type) {
+	case *Struct:
+		return findIdentToken(n.StartToken.Next, n.EndToken, n.Ident)
+	case *Enum:
+		return findIdentToken(n.StartToken.Next, n.EndToken, n.Ident)
+	case *Service:
+		return findIdentToken(n.StartToken.Next, n.EndToken, n.Ident)
+	case *EnumElement:
+		return n.StartToken
+	case *TypeDef:
+		return findIdentToken(n.Type.EndToken.Next, n.EndToken, n.Ident)
+	case *Const:
+		return findIdentToken(n.Type.EndToken.Next, n.EndToken, n.Ident)
+	case *Field:
+		return findIdentToken(n.FieldType.EndToken.Next, n.EndToken, n.Ident)
+	case *Function:
+		start := n.StartToken.Next
+		if n.FunctionType != nil {
+			start = n.FunctionType.EndToken.Next
+		}
+		return findIdentToken(start, n.EndToken, n.Ident)
+	case *FieldType:
+		if n.Type == FIELD_TYPE_IDENT {
+			return n.StartToken
+		}
+	case *ConstValue:
+		if n.Type == CONST_VALUE_IDENT {
+			return n.StartToken
+		}
+	}
+	return nil
+}
+
+// ExtendsToken returns the token of the service name after extends keyword, or nil if service extends nothing.
+func ExtendsToken(node *Service) *Token {
+	if node.Extends == "" {
+		return nil
+	}
+	for tok := node.StartToken; tok != nil && tok != node.EndToken; tok = tok.Next {
+		if tok.Type == T_IDENT && tok.Raw == "extends" {
+			return findIdentToken(tok.Next, node.EndToken, node.Extends)
+		}
+	}
+	return nil
+}
+
+// find the first identifier token whose raw value is ident, from start to end inclusively
+func findIdentToken(start *Token, end *Token, ident string) *Token {
+	for tok := start; tok != nil; tok = tok.Next {
+		if tok.Type == T_IDENT && tok.Raw == ident {
+			return tok
+		}
+		if tok == end {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package thrifter
+
+import "testing"
+
+func TestIdentToken(t *testing.T) {
+	parser := newParserOn(`const Foo Foo = Bar.BAZ
+	typedef list<Foo> Foos
+	struct Foo {
+		1: Foo Foo
+	}
+	service S extends shared.Base {
+		oneway void Foo(1: Foo foo)
+		Foo get()
+	}`)
+	res, err := parser.Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	cst := res.Nodes[0].(*Const)
+	typedef := res.Nodes[1].(*TypeDef)
+	st := res.Nodes[2].(*Struct)
+	svc := res.Nodes[3].(*Service)
+
+	cases := []struct {
+		node   Node
+		offset int
+	}{
+		{cst, 10},
+		{cst.Type, 6},
+		{cst.Value, 16},
+		{typedef, 43},
+		{st, 56},
+		{st.Elems[0], 71},
+		{st.Elems[0].FieldType, 67},
+		{svc.Elems[0], 125},
+		{svc.Elems[1], 147},
+	}
+	for _, c := range cases {
+		tok := IdentToken(c.node)
+		if tok == nil {
+			t.Errorf("got [nil] want token of %s", c.node.NodeType())
+			continue
+		}
+		if got, want := tok.Start, c.offset; got != want {
+			t.Errorf("%s: got [%v] want [%v]", c.node.NodeType(), got, want)
+		}
+	}
+	if got := IdentToken(st.Elems[0].FieldType.Map); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+	if got, want := ExtendsToken(svc).Raw, "shared.Base"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
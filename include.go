@@ -1,5 +1,10 @@
 package thrifter
 
+import (
+	"path/filepath"
+	"strings"
+)
+
 type Include struct {
 	NodeCommonField
 	FilePath string
@@ -26,6 +31,16 @@ func (r *Include) String() string {
 	return toString(r.StartToken, r.EndToken)
 }
 
+// Prefix returns the name to qualify declarations of the included file, which is the file name without extension, e.g. shared for "../shared.thrift".
+func (r *Include) Prefix() string {
+	return strings.TrimSuffix(filepath.Base(r.FilePath), filepath.Ext(r.FilePath))
+}
+
+// IsCpp reports whether it's a cpp_include, which does not include thrift definitions.
+func (r *Include) IsCpp() bool {
+	return r.StartToken != nil && r.StartToken.Type == T_CPP_INCLUDE
+}
+
 func (r *Include) parse(p *Parser) (err error) {
 	p.peekNonWhitespace()
 	tok, err := p.nextString()
@@ -34,6 +49,7 @@ func (r *Include) parse(p *Parser) (err error) {
 	}
 	r.EndToken = tok
 	r.FilePath = tok.Value
+	r.NodeID = genNodeID("", r.NodeType(), r.FilePath)
 
 	return
 }
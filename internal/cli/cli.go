@@ -0,0 +1,16 @@
+// Package cli has helpers shared by the commands.
+package cli
+
+import "strings"
+
+// StringList is a repeatable string flag, e.g. -I a -I b.
+type StringList []string
+
+func (r *StringList) String() string {
+	return strings.Join(*r, ",")
+}
+
+func (r *StringList) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestStringList(t *testing.T) {
+	var dirs StringList
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Var(&dirs, "I", "")
+	if err := flags.Parse([]string{"-I", "a", "-I", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dirs.String(), "a,b"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -0,0 +1,490 @@
+// Package yaml converts between JSON and the common subset of YAML, keeping the order of keys, for commands reading and writing YAML without dependencies.
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FromJSON converts a JSON document into block style YAML, keeping the order of object keys.
+func FromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+	var res strings.Builder
+	writeYAML(&res, value, 0, "")
+	return []byte(res.String()), nil
+}
+
+// object with ordered keys, array, or scalar
+type yamlValue struct {
+	keys   []string
+	values []*yamlValue
+	array  bool
+	object bool
+	scalar string
+}
+
+func decodeValue(dec *json.Decoder) (*yamlValue, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		res := &yamlValue{object: t == '{', array: t == '['}
+		for dec.More() {
+			if res.object {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				res.keys = append(res.keys, key.(string))
+			}
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			res.values = append(res.values, value)
+		}
+		_, err := dec.Token() // consume closing delimiter
+		return res, err
+	case string:
+		return &yamlValue{scalar: yamlString(t)}, nil
+	case json.Number:
+		return &yamlValue{scalar: t.String()}, nil
+	case bool:
+		return &yamlValue{scalar: fmt.Sprint(t)}, nil
+	default:
+		return &yamlValue{scalar: "null"}, nil
+	}
+}
+
+// write value at indent, prefix replaces the indentation of its first line, e.g. "- " for elements of arrays
+func writeYAML(w *strings.Builder, value *yamlValue, indent int, prefix string) {
+	pad := strings.Repeat(" ", indent)
+	first := func() string {
+		if prefix != "" {
+			res := prefix
+			prefix = ""
+			return res
+		}
+		return pad
+	}
+	switch {
+	case value.object && len(value.values) > 0:
+		for i, key := range value.keys {
+			writeEntry(w, first()+key+":", value.values[i], indent)
+		}
+	case value.array && len(value.values) > 0:
+		for _, elem := range value.values {
+			writeEntry(w, first()+"-", elem, indent)
+		}
+	default:
+		w.WriteString(first() + inline(value) + "\n")
+	}
+}
+
+// write a key or an array element, head is the text before value
+func writeEntry(w *strings.Builder, head string, value *yamlValue, indent int) {
+	switch {
+	case value.object && len(value.values) > 0:
+		if strings.HasSuffix(head, "-") {
+			// the first key of object follows -
+			writeYAML(w, value, indent+2, head+" ")
+			return
+		}
+		w.WriteString(head + "\n")
+		writeYAML(w, value, indent+2, "")
+	case value.array && len(value.values) > 0:
+		w.WriteString(head + "\n")
+		writeYAML(w, value, indent+2, "")
+	default:
+		w.WriteString(head + " " + inline(value) + "\n")
+	}
+}
+
+func inline(value *yamlValue) string {
+	switch {
+	case value.object:
+		return "{}"
+	case value.array:
+		return "[]"
+	}
+	return value.scalar
+}
+
+var plainString = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.()/-]*$`)
+
+// strings are plain if they can't be read as other types, otherwise they are double-quoted, which is compatible with JSON strings
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+	default:
+		if plainString.MatchString(s) {
+			return s
+		}
+	}
+	return jsonString(s)
+}
+
+// ToJSON converts a YAML document into JSON, keeping the order of mapping keys.
+// It supports the common subset of YAML: block mappings and sequences, flow collections, plain, single-quoted and double-quoted scalars, and comments.
+// Anchors, tags, block scalars and multi-line scalars are not supported.
+func ToJSON(data []byte) ([]byte, error) {
+	p := &yamlParser{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		content := strings.TrimLeft(line, " ")
+		if content == "" || content == "---" || content == "..." {
+			continue
+		}
+		if strings.HasPrefix(content, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed in indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{no: i + 1, indent: len(line) - len(content), content: content})
+	}
+	value := &yamlValue{scalar: "null"}
+	if len(p.lines) > 0 {
+		var err error
+		if value, err = p.block(p.lines[0].indent); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.lines) {
+			return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].no)
+		}
+	}
+	var res strings.Builder
+	writeJSON(&res, value)
+	return []byte(res.String()), nil
+}
+
+type yamlLine struct {
+	no      int
+	indent  int
+	content string
+}
+
+// yamlParser parses lines into yamlValue, whose scalars are in JSON
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// stripComment removes a comment starting with # at the start of line or after a space, outside of quotes
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+// block parses a mapping, a sequence, or a scalar, whose lines start at indent
+func (p *yamlParser) block(indent int) (*yamlValue, error) {
+	line := p.lines[p.pos]
+	if isSequenceItem(line.content) {
+		return p.sequence(indent)
+	}
+	if _, _, ok := splitKey(line.content); ok {
+		return p.mapping(indent)
+	}
+	p.pos++
+	return yamlScalar(line.content, line.no)
+}
+
+func (p *yamlParser) sequence(indent int) (*yamlValue, error) {
+	res := &yamlValue{array: true}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].content) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(strings.TrimPrefix(line.content, "-"), " ")
+		var elem *yamlValue
+		var err error
+		if rest == "" {
+			elem, err = p.nested(indent)
+		} else {
+			// the rest of line is the first line of the element, e.g. the first key of a mapping
+			p.lines[p.pos] = yamlLine{no: line.no, indent: indent + len(line.content) - len(rest), content: rest}
+			elem, err = p.block(p.lines[p.pos].indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		res.values = append(res.values, elem)
+	}
+	return res, nil
+}
+
+func (p *yamlParser) mapping(indent int) (*yamlValue, error) {
+	res := &yamlValue{object: true}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && !isSequenceItem(p.lines[p.pos].content) {
+		line := p.lines[p.pos]
+		key, rest, ok := splitKey(line.content)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a key", line.no)
+		}
+		keyValue, err := yamlScalar(key, line.no)
+		if err != nil {
+			return nil, err
+		}
+		var name string
+		if err := json.Unmarshal([]byte(keyValue.scalar), &name); err != nil {
+			// keys of other types are converted to strings like JSON does
+			name = keyValue.scalar
+		}
+		p.pos++
+		var value *yamlValue
+		switch {
+		case rest != "":
+			value, err = yamlScalar(rest, line.no)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].content):
+			// a sequence may be at the same indentation as its key
+			value, err = p.sequence(indent)
+		default:
+			p.pos--
+			value, err = p.nested(indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		res.keys = append(res.keys, name)
+		res.values = append(res.values, value)
+	}
+	return res, nil
+}
+
+// nested parses the block indented more than the current line, which is consumed, or null if there is no such block
+func (p *yamlParser) nested(indent int) (*yamlValue, error) {
+	p.pos++
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return p.block(p.lines[p.pos].indent)
+	}
+	return &yamlValue{scalar: "null"}, nil
+}
+
+// splitKey splits "key: value" at the first colon followed by a space or the end, outside of quotes and flow collections
+func splitKey(content string) (key string, rest string, ok bool) {
+	if strings.HasPrefix(content, "[") || strings.HasPrefix(content, "{") {
+		return "", "", false
+	}
+	var quote byte
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i == len(content)-1 || content[i+1] == ' '):
+			return strings.TrimSpace(content[:i]), strings.TrimSpace(content[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+var yamlNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// yamlScalar converts a scalar or a flow collection on a line to JSON
+func yamlScalar(s string, line int) (*yamlValue, error) {
+	f := &flowParser{s: s, line: line}
+	value, err := f.value(false)
+	if err != nil {
+		return nil, err
+	}
+	if f.skipSpace(); f.pos < len(s) {
+		return nil, fmt.Errorf("line %d: unexpected %q", line, s[f.pos:])
+	}
+	return value, nil
+}
+
+// flowParser parses scalars and flow collections like [a, {b: 1}]
+type flowParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (f *flowParser) skipSpace() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *flowParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", f.line, fmt.Sprintf(format, args...))
+}
+
+// value parses a value, in a flow collection if inFlow, where plain scalars end at , ] } and :
+func (f *flowParser) value(inFlow bool) (*yamlValue, error) {
+	f.skipSpace()
+	if f.pos == len(f.s) {
+		return &yamlValue{scalar: "null"}, nil
+	}
+	switch f.s[f.pos] {
+	case '[', '{':
+		return f.collection()
+	case '"':
+		end := f.pos + 1
+		for ; end < len(f.s) && f.s[end] != '"'; end++ {
+			if f.s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(f.s) {
+			return nil, f.errorf("unterminated string")
+		}
+		var str string
+		if err := json.Unmarshal([]byte(f.s[f.pos:end+1]), &str); err != nil {
+			return nil, f.errorf("invalid string %s", f.s[f.pos:end+1])
+		}
+		f.pos = end + 1
+		return &yamlValue{scalar: jsonString(str)}, nil
+	case '\'':
+		var str strings.Builder
+		for end := f.pos + 1; end < len(f.s); end++ {
+			if f.s[end] != '\'' {
+				str.WriteByte(f.s[end])
+			} else if end+1 < len(f.s) && f.s[end+1] == '\'' {
+				str.WriteByte('\'')
+				end++
+			} else {
+				f.pos = end + 1
+				return &yamlValue{scalar: jsonString(str.String())}, nil
+			}
+		}
+		return nil, f.errorf("unterminated string")
+	case '&', '*', '!', '|', '>', '%', '@', '`':
+		return nil, f.errorf("unsupported %q", f.s[f.pos])
+	}
+
+	start := f.pos
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		if inFlow && (c == ',' || c == ']' || c == '}' || c == ':' && (f.pos+1 == len(f.s) || f.s[f.pos+1] == ' ')) {
+			break
+		}
+		f.pos++
+	}
+	plain := strings.TrimSpace(f.s[start:f.pos])
+	switch {
+	case plain == "" || plain == "~" || plain == "null" || plain == "Null" || plain == "NULL":
+		return &yamlValue{scalar: "null"}, nil
+	case plain == "true" || plain == "True" || plain == "TRUE":
+		return &yamlValue{scalar: "true"}, nil
+	case plain == "false" || plain == "False" || plain == "FALSE":
+		return &yamlValue{scalar: "false"}, nil
+	case yamlNumber.MatchString(plain):
+		return &yamlValue{scalar: plain}, nil
+	}
+	return &yamlValue{scalar: jsonString(plain)}, nil
+}
+
+func (f *flowParser) collection() (*yamlValue, error) {
+	object := f.s[f.pos] == '{'
+	end := byte(']')
+	if object {
+		end = '}'
+	}
+	res := &yamlValue{object: object, array: !object}
+	f.pos++
+	for {
+		f.skipSpace()
+		if f.pos < len(f.s) && f.s[f.pos] == end {
+			f.pos++
+			return res, nil
+		}
+		if len(res.values) > 0 {
+			if f.pos == len(f.s) || f.s[f.pos] != ',' {
+				return nil, f.errorf("expected , or %c", end)
+			}
+			f.pos++
+			if f.skipSpace(); f.pos < len(f.s) && f.s[f.pos] == end {
+				// trailing comma
+				continue
+			}
+		}
+		if object {
+			key, err := f.value(true)
+			if err != nil {
+				return nil, err
+			}
+			var name string
+			if err := json.Unmarshal([]byte(key.scalar), &name); err != nil {
+				name = key.scalar
+			}
+			if f.skipSpace(); f.pos == len(f.s) || f.s[f.pos] != ':' {
+				return nil, f.errorf("expected : after key %s", name)
+			}
+			f.pos++
+			res.keys = append(res.keys, name)
+		}
+		value, err := f.value(true)
+		if err != nil {
+			return nil, err
+		}
+		res.values = append(res.values, value)
+	}
+}
+
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func writeJSON(w *strings.Builder, value *yamlValue) {
+	switch {
+	case value.object:
+		w.WriteByte('{')
+		for i, key := range value.keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(jsonString(key) + ":")
+			writeJSON(w, value.values[i])
+		}
+		w.WriteByte('}')
+	case value.array:
+		w.WriteByte('[')
+		for i, elem := range value.values {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeJSON(w, elem)
+		}
+		w.WriteByte(']')
+	default:
+		w.WriteString(value.scalar)
+	}
+}
@@ -0,0 +1,79 @@
+package yaml
+
+import "testing"
+
+func TestFromJSON(t *testing.T) {
+	data := `{"version": 1, "name": "a b", "empty": [], "obj": {}, "nodes": [{"kind": "Struct", "fields": [{"id": 1, "ok": true}]}, [1, null], "true"]}`
+	want := `version: 1
+name: "a b"
+empty: []
+obj: {}
+nodes:
+  - kind: Struct
+    fields:
+      - id: 1
+        ok: true
+  -
+    - 1
+    - null
+  - "true"
+`
+	got, err := FromJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	// output of FromJSON is converted back
+	data := `{"version":1,"name":"a b","empty":[],"obj":{},"nodes":[{"kind":"Struct","fields":[{"id":1,"ok":true}]},[1,null],"true"]}`
+	yaml, err := FromJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ToJSON(yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data {
+		t.Errorf("got:\n%s\nwant:\n%s", got, data)
+	}
+
+	cases := map[string]string{
+		"":              "null",
+		"a # comment\n": `"a"`,
+		"# comment\nkey: 'it''s' # end\nurl: http://x#y\n":   `{"key":"it's","url":"http://x#y"}`,
+		"list:\n- 1.5\n- -2\n- ~\n- yes\n":                   `{"list":[1.5,-2,null,"yes"]}`,
+		"flow: [a, \"b, c\", {x: 1, y: [true]}, ]\nempty:\n": `{"flow":["a","b, c",{"x":1,"y":[true]}],"empty":null}`,
+		"---\n- a: 1\n  b:\n    - c\n-\n  - 2\n":             `[{"a":1,"b":["c"]},[2]]`,
+		"1: one\n\"a: b\": 2\n":                              `{"1":"one","a: b":2}`,
+		`{"json": {"ok": [1, 2]}}`:                           `{"json":{"ok":[1,2]}}`,
+	}
+	for data, want := range cases {
+		got, err := ToJSON([]byte(data))
+		if err != nil {
+			t.Errorf("%q: %v", data, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%q: got [%s] want [%s]", data, got, want)
+		}
+	}
+
+	errors := map[string]string{
+		"a: 1\n  b: 2\n": "line 2: unexpected indentation",
+		"a: [1, 2\n":     "line 1: expected , or ]",
+		"a: \"b\n":       "line 1: unterminated string",
+		"a: &anchor b\n": "line 1: unsupported '&'",
+		"a: |\n  text\n": "line 1: unsupported '|'",
+		"a:\n\tb: 1\n":   "line 2: tabs are not allowed in indentation",
+	}
+	for data, want := range errors {
+		if _, err := ToJSON([]byte(data)); err == nil || err.Error() != want {
+			t.Errorf("%q: got [%v] want [%v]", data, err, want)
+		}
+	}
+}
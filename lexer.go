@@ -0,0 +1,181 @@
+package thrifter
+
+import (
+	"text/scanner"
+	"unicode"
+	"unicode/utf8"
+)
+
+const eof rune = -1
+
+// lexer is a minimal scanner working on an immutable copy of the source, every token it produces is a sub-string of the source, so no copy is made during scanning.
+// Unlike text/scanner it never skips white spaces or comments, parser decides how to handle them, since we want to be non-destructive.
+type lexer struct {
+	src      string
+	filename string
+	offset   int // read offset
+	line     int // line of read offset, starting at 1
+	column   int // column of read offset, counted by characters, starting at 1
+	// position of most recently consumed character
+	lastOffset int
+	lastLine   int
+	lastColumn int
+	tokStart   int // start offset of most recently scanned token
+	tokEnd     int // end offset of most recently scanned token
+	tokPos     scanner.Position
+	// Error is called for each error encountered, e.g. unterminated raw string
+	Error func(l *lexer, msg string)
+}
+
+func newLexer(src []byte) *lexer {
+	return &lexer{
+		src:    string(src),
+		line:   1,
+		column: 1,
+	}
+}
+
+// position of current read offset
+func (l *lexer) pos() scanner.Position {
+	return scanner.Position{
+		Filename: l.filename,
+		Offset:   l.offset,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+// Return next Unicode character without consuming it, return eof at the end of source.
+func (l *lexer) peek() rune {
+	if l.offset >= len(l.src) {
+		return eof
+	}
+	if c := l.src[l.offset]; c < utf8.RuneSelf {
+		return rune(c)
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.offset:])
+	return r
+}
+
+// position of most recently consumed character
+func (l *lexer) lastPos() scanner.Position {
+	return scanner.Position{
+		Filename: l.filename,
+		Offset:   l.lastOffset,
+		Line:     l.lastLine,
+		Column:   l.lastColumn,
+	}
+}
+
+// Consume and return next Unicode character, return eof at the end of source.
+func (l *lexer) next() rune {
+	if l.offset >= len(l.src) {
+		return eof
+	}
+	l.lastOffset, l.lastLine, l.lastColumn = l.offset, l.line, l.column
+	r, w := rune(l.src[l.offset]), 1
+	if r >= utf8.RuneSelf {
+		r, w = utf8.DecodeRuneInString(l.src[l.offset:])
+	}
+	l.offset += w
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return r
+}
+
+// Scan next token and return its first character, identifiers/numbers/raw strings are scanned as a whole, everything else is a single character.
+// Use tokenText to get the scanned literal.
+func (l *lexer) scan() rune {
+	l.tokStart = l.offset
+	l.tokPos = l.pos()
+	ch := l.peek()
+	switch {
+	case ch == eof:
+	case isIdentRune(ch, 0):
+		l.next()
+		for i := 1; isIdentRune(l.peek(), i); i++ {
+			l.next()
+		}
+	case IsDigit(ch) || ch == '.' && l.isDigitAt(l.offset+1):
+		l.scanNumber()
+	case ch == '`':
+		l.next()
+		for {
+			r := l.next()
+			if r == '`' {
+				break
+			}
+			if r == eof {
+				if l.Error != nil {
+					l.Error(l, "literal not terminated")
+				}
+				break
+			}
+		}
+	default:
+		l.next()
+	}
+	l.tokEnd = l.offset
+	return ch
+}
+
+// same number format as text/scanner with ScanInts and ScanFloats mode, e.g. 123, 0x1F, 1.5, .5, 1e10
+func (l *lexer) scanNumber() {
+	if l.peek() == '0' {
+		l.next()
+		switch l.peek() {
+		case 'x', 'X', 'o', 'O', 'b', 'B':
+			l.next()
+			for r := l.peek(); IsDigit(r) || r == '_' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'; r = l.peek() {
+				l.next()
+			}
+			return
+		}
+	}
+	l.scanDigits()
+	if l.peek() == '.' {
+		l.next()
+		l.scanDigits()
+	}
+	if r := l.peek(); r == 'e' || r == 'E' {
+		l.next()
+		if r := l.peek(); r == '+' || r == '-' {
+			l.next()
+		}
+		l.scanDigits()
+	}
+}
+
+func (l *lexer) scanDigits() {
+	for r := l.peek(); IsDigit(r) || r == '_'; r = l.peek() {
+		l.next()
+	}
+}
+
+func (l *lexer) isDigitAt(offset int) bool {
+	return offset < len(l.src) && IsDigit(rune(l.src[offset]))
+}
+
+func (l *lexer) isByteAt(offset int, c byte) bool {
+	return offset < len(l.src) && l.src[offset] == c
+}
+
+// literal of most recently scanned token
+func (l *lexer) tokenText() string {
+	return l.src[l.tokStart:l.tokEnd]
+}
+
+func isIdentRune(ch rune, i int) bool {
+	if ch < utf8.RuneSelf {
+		return ch == '_' || 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || IsDigit(ch) && i > 0
+	}
+	return unicode.IsLetter(ch) || unicode.IsDigit(ch) && i > 0
+}
+
+func isWhitespaceRune(r rune) bool {
+	return r == ' ' || r == '\n' || r == '\r' || r == '\t'
+}
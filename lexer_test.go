@@ -0,0 +1,122 @@
+package thrifter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLexer_scan(t *testing.T) {
+	l := newLexer([]byte("abc_1 123 0.5 .5 0x1F `raw` é{"))
+	var lits []string
+	for l.scan() != eof {
+		lits = append(lits, l.tokenText())
+	}
+
+	if got, want := strings.Join(lits, "|"), "abc_1| |123| |0.5| |.5| |0x1F| |`raw`| |é|{"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestLexer_position(t *testing.T) {
+	l := newLexer([]byte("a\n  bé c"))
+	l.scan() // a
+	l.scan() // \n
+	l.scan() // space
+	l.scan() // space
+	l.scan() // bé
+	l.scan() // space
+	l.scan() // c
+
+	if got, want := l.tokenText(), "c"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := l.tokPos.Line, 2; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := l.tokPos.Column, 6; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := l.tokStart, 8; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestLexer_tokenOffsets(t *testing.T) {
+	src, err := readFile("./examples/ThriftTest.thrift")
+	if err != nil {
+		t.Errorf("readFile error: %v", err)
+		return
+	}
+	res, err := NewParser(strings.NewReader(src), false).Parse("ThriftTest.thrift")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	for tok := res.StartToken; tok != nil; tok = tok.Next {
+		if got, want := src[tok.Start:tok.End], tok.Raw; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+			return
+		}
+		if got, want := tok.Pos.Offset, tok.Start; tok.Type != T_EOF && got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+			return
+		}
+	}
+}
+
+func TestToToken_keyword(t *testing.T) {
+	if got, want := toToken("cpp_include"), T_CPP_INCLUDE; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := toToken("map"), T_MAP; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := toToken("maps"), T_IDENT; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := toToken("{"), T_LEFTCURLY; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := toToken("*"), T_IDENT; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func benchmarkParse(b *testing.B, filePath string) {
+	src, err := readFile(filePath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewParserBytes([]byte(src), false).Parse(filePath); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse_ThriftTest(b *testing.B) {
+	benchmarkParse(b, "./examples/ThriftTest.thrift")
+}
+
+func BenchmarkParse_Cassandra(b *testing.B) {
+	benchmarkParse(b, "./examples/cassandra.thrift")
+}
+
+func BenchmarkLexer_scan(b *testing.B) {
+	src, err := readFile("./examples/cassandra.thrift")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l := newLexer([]byte(src))
+		for l.scan() != eof {
+		}
+	}
+}
@@ -0,0 +1,285 @@
+// Package lint reports problems of thrift files which parse, but fail code generators, break at runtime, or are discouraged.
+//
+// Each problem is found by a rule, see Rules. References are resolved by a Program, so files included by the checked file should be loaded.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"text/scanner"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Severity of a problem.
+type Severity int
+
+const (
+	// Warning is discouraged usage, which works but is easy to get wrong.
+	Warning Severity = iota
+	// Error is a mistake which code generators reject, or which breaks at runtime.
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "warning":
+		*s = Warning
+	case "error":
+		*s = Error
+	default:
+		return fmt.Errorf("unknown severity %q", text)
+	}
+	return nil
+}
+
+// Rule finds a kind of problems.
+type Rule struct {
+	Name     string
+	Severity Severity
+	Doc      string
+}
+
+// Rules are all rules, sorted by name.
+var Rules = []Rule{
+	{"duplicate-id", Error, "field ids of a struct, arguments or exceptions of a function are unique"},
+	{"duplicate-name", Error, "names of declarations of a file, fields, enum elements and functions of a service are unique"},
+	{"duplicate-value", Warning, "values of enum elements are unique"},
+	{"invalid-value", Error, "consts and default values of fields are valid values of their types"},
+	{"required-field", Warning, "fields are not required, since required fields can never be removed"},
+	{"unresolved", Error, "included files and referred declarations exist"},
+	{"wrong-kind", Error, "references refer to declarations of the right kind, e.g. throws refer to exceptions, and extends to services"},
+}
+
+// Problem is found by a rule at a position.
+type Problem struct {
+	Pos      scanner.Position `json:"pos"`
+	Rule     string           `json:"rule"`
+	Severity Severity         `json:"severity"`
+	Message  string           `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", p.Pos, p.Severity, p.Message, p.Rule)
+}
+
+// Options of Lint.
+type Options struct {
+	Disabled []string // names of rules not to run
+}
+
+// Lint returns problems of file sorted by position, references are resolved by program.
+func Lint(program *thrifter.Program, file *thrifter.Thrift, opts Options) []Problem {
+	l := &linter{program: program, file: file, disabled: map[string]bool{}, unresolved: map[thrifter.Node]bool{}}
+	for _, name := range opts.Disabled {
+		l.disabled[name] = true
+	}
+	l.includes()
+	l.references()
+	l.declarations()
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		return l.problems[i].Pos.Offset < l.problems[j].Pos.Offset
+	})
+	return l.problems
+}
+
+type linter struct {
+	program    *thrifter.Program
+	file       *thrifter.Thrift
+	disabled   map[string]bool
+	unresolved map[thrifter.Node]bool // consts and fields with unresolved or wrong references, whose values are not evaluated
+	problems   []Problem
+}
+
+func (l *linter) report(rule string, node thrifter.Node, format string, args ...any) {
+	var pos scanner.Position
+	if tok := thrifter.IdentToken(node); tok != nil {
+		pos = tok.Pos
+	} else if start := node.CommonField().StartToken; start != nil {
+		pos = start.Pos
+	}
+	l.reportAt(rule, pos, format, args...)
+}
+
+func (l *linter) reportAt(rule string, pos scanner.Position, format string, args ...any) {
+	if l.disabled[rule] {
+		return
+	}
+	for _, r := range Rules {
+		if r.Name == rule {
+			l.problems = append(l.problems, Problem{Pos: pos, Rule: rule, Severity: r.Severity, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+}
+
+func (l *linter) includes() {
+	for _, inc := range l.file.Includes() {
+		if _, ok := l.program.IncludePath(l.file, inc); !ok {
+			l.report("unresolved", inc, "included file %q not found", inc.FilePath)
+		}
+	}
+}
+
+func (l *linter) references() {
+	for _, ref := range l.program.FileReferences(l.file) {
+		name := ref.Token.Raw
+		bad := func(rule string, format string) {
+			l.unresolved[owner(ref.Node)] = true
+			l.reportAt(rule, ref.Token.Pos, format, name)
+		}
+		switch node := ref.Node.(type) {
+		case *thrifter.FieldType:
+			switch target := ref.Target.(type) {
+			case nil:
+				bad("unresolved", "unknown type %s")
+			case *thrifter.Struct, *thrifter.Enum, *thrifter.TypeDef:
+				if field, ok := node.Parent.(*thrifter.Field); ok && isThrows(field) && !isException(target) {
+					bad("wrong-kind", "%s in throws is not an exception")
+				}
+			default:
+				bad("wrong-kind", "%s is not a type")
+			}
+		case *thrifter.ConstValue:
+			switch ref.Target.(type) {
+			case nil:
+				bad("unresolved", "unknown const %s")
+			case *thrifter.Const, *thrifter.EnumElement:
+			default:
+				bad("wrong-kind", "%s is not a const or an enum element")
+			}
+		case *thrifter.Service:
+			switch ref.Target.(type) {
+			case nil:
+				bad("unresolved", "unknown service %s")
+			case *thrifter.Service:
+			default:
+				bad("wrong-kind", "%s is not a service")
+			}
+		}
+	}
+}
+
+func (l *linter) declarations() {
+	names := map[string]bool{}
+	for _, node := range l.file.Nodes {
+		tok := thrifter.IdentToken(node)
+		switch node.(type) {
+		case *thrifter.Struct, *thrifter.Enum, *thrifter.Service, *thrifter.TypeDef, *thrifter.Const:
+			if tok != nil && names[tok.Raw] {
+				l.report("duplicate-name", node, "%s is declared more than once", tok.Raw)
+			}
+			if tok != nil {
+				names[tok.Raw] = true
+			}
+		}
+		switch n := node.(type) {
+		case *thrifter.Struct:
+			l.fields(n.Ident, n.Elems)
+		case *thrifter.Enum:
+			l.enum(n)
+		case *thrifter.Service:
+			fns := map[string]bool{}
+			for _, fn := range n.Elems {
+				if fns[fn.Ident] {
+					l.report("duplicate-name", fn, "function %s.%s is declared more than once", n.Ident, fn.Ident)
+				}
+				fns[fn.Ident] = true
+				l.fields(n.Ident+"."+fn.Ident, fn.Args)
+				l.fields(n.Ident+"."+fn.Ident+" throws", fn.Throws)
+			}
+		case *thrifter.Const:
+			if l.unresolved[n] {
+				break
+			}
+			if _, err := l.program.Eval(n); err != nil {
+				l.report("invalid-value", n, "invalid value of %s: %v", n.Ident, trimPos(err))
+			}
+		}
+	}
+}
+
+func (l *linter) fields(owner string, fields []*thrifter.Field) {
+	ids, names := map[int]bool{}, map[string]bool{}
+	for _, field := range fields {
+		if ids[field.ID] {
+			l.report("duplicate-id", field, "field id %d of %s is used more than once", field.ID, owner)
+		}
+		if names[field.Ident] {
+			l.report("duplicate-name", field, "field %s of %s is declared more than once", field.Ident, owner)
+		}
+		ids[field.ID], names[field.Ident] = true, true
+		if field.Requiredness == "required" {
+			l.report("required-field", field, "field %s of %s is required", field.Ident, owner)
+		}
+		if field.DefaultValue != nil && !l.unresolved[field] {
+			if _, err := l.program.EvalValue(field.FieldType, field.DefaultValue); err != nil {
+				l.report("invalid-value", field, "invalid default value of %s: %v", field.Ident, trimPos(err))
+			}
+		}
+	}
+}
+
+func (l *linter) enum(enum *thrifter.Enum) {
+	names, values := map[string]bool{}, map[int]string{}
+	for _, elem := range enum.Elems {
+		if names[elem.Ident] {
+			l.report("duplicate-name", elem, "element %s.%s is declared more than once", enum.Ident, elem.Ident)
+		}
+		names[elem.Ident] = true
+		if other, ok := values[elem.Value()]; ok {
+			l.report("duplicate-value", elem, "value %d of %s.%s is the same as %s", elem.Value(), enum.Ident, elem.Ident, other)
+		} else {
+			values[elem.Value()] = elem.Ident
+		}
+	}
+}
+
+// isThrows reports whether field is in throws of a function
+func isThrows(field *thrifter.Field) bool {
+	if fn, ok := field.Parent.(*thrifter.Function); ok {
+		for _, throw := range fn.Throws {
+			if throw == field {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isException(node thrifter.Node) bool {
+	st, ok := node.(*thrifter.Struct)
+	return ok && st.Type == thrifter.EXCEPTION
+}
+
+// owner is the const or field containing node, or nil
+func owner(node thrifter.Node) thrifter.Node {
+	for ; node != nil; node = node.CommonField().Parent {
+		switch node.(type) {
+		case *thrifter.Const, *thrifter.Field:
+			return node
+		}
+	}
+	return nil
+}
+
+var posPrefix = regexp.MustCompile(`^(.*:)?\d+:\d+: `)
+
+// trimPos removes the position prefix of errors of Program.Eval, since problems have their own
+func trimPos(err error) string {
+	return posPrefix.ReplaceAllString(err.Error(), "")
+}
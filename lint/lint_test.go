@@ -0,0 +1,134 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+const shared = `enum Color {
+  RED = 1
+}
+struct Point {}
+exception Missing {}
+service Base {}
+const i32 ONE = 1
+`
+
+// lint writes src as main.thrift next to shared.thrift, and returns problems as line:col rule: message
+func lint(t *testing.T, src string, opts Options) (res []string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{"shared.thrift": shared, "main.thrift": src} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	program := thrifter.NewProgram()
+	file, err := program.Load(filepath.Join(dir, "main.thrift"))
+	if file == nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, p := range Lint(program, file, opts) {
+		res = append(res, fmt.Sprintf("%d:%d %s: %s", p.Pos.Line, p.Pos.Column, p.Rule, p.Message))
+	}
+	return
+}
+
+func TestLint(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "valid",
+			src:  "include \"shared.thrift\"\nstruct A {\n  1: optional shared.Color c = shared.Color.RED\n  2: list<shared.Point> ps = [{}]\n  3: i64 n = shared.ONE\n}\nservice S extends shared.Base {\n  void f(1: A a) throws (1: shared.Missing m)\n}",
+		},
+		{
+			name: "duplicates",
+			src:  "struct A {\n  1: i32 a\n  1: i32 b\n  2: i32 a\n}\nenum A {\n  X = 1\n  Y = 1\n  X = 2\n}\nservice S {\n  void f(1: i32 a, 1: i32 b)\n  void f()\n}",
+			want: []string{
+				"3:10 duplicate-id: field id 1 of A is used more than once",
+				"4:10 duplicate-name: field a of A is declared more than once",
+				"6:6 duplicate-name: A is declared more than once",
+				"8:3 duplicate-value: value 1 of A.Y is the same as X",
+				"9:3 duplicate-name: element A.X is declared more than once",
+				"12:27 duplicate-id: field id 1 of S.f is used more than once",
+				"13:8 duplicate-name: function S.f is declared more than once",
+			},
+		},
+		{
+			name: "invalid values",
+			src:  "struct A {\n  1: i8 a = 300\n  2: string b = 1\n}\nconst list<i32> L = [1, \"x\"]\nconst map<string, i32> M = {\"a\": 1, \"a\": 2}",
+			want: []string{
+				"2:9 invalid-value: invalid default value of a: 300 overflows i8",
+				"3:13 invalid-value: invalid default value of b: expected string, got integer 1",
+				"5:17 invalid-value: invalid value of L: expected i32, got string \"x\"",
+				"6:24 invalid-value: invalid value of M: duplicate key \"a\" in map",
+			},
+		},
+		{
+			name: "empty sets and uncomparable keys",
+			src:  "struct A {\n  1: set<binary> a = {}\n  2: map<binary, i32> b = {\"x\": 1}\n  3: map<set<i32>, i32> c = {[1]: 1}\n}\nconst map<A, i32> M = {{\"b\": {}}: 1}",
+		},
+		{
+			name: "unresolved",
+			src:  "include \"missing.thrift\"\nstruct A {\n  1: Foo a\n  2: i32 b = BAR\n}\nservice S extends Base {}",
+			want: []string{
+				"1:1 unresolved: included file \"missing.thrift\" not found",
+				"3:6 unresolved: unknown type Foo",
+				"4:14 unresolved: unknown const BAR",
+				"6:19 unresolved: unknown service Base",
+			},
+		},
+		{
+			name: "wrong kind",
+			src:  "include \"shared.thrift\"\nstruct A {\n  1: shared.Base a\n  2: i32 b = shared.Point\n}\nservice S extends shared.Point {\n  void f() throws (1: shared.Point p)\n}",
+			want: []string{
+				"3:6 wrong-kind: shared.Base is not a type",
+				"4:14 wrong-kind: shared.Point is not a const or an enum element",
+				"6:19 wrong-kind: shared.Point is not a service",
+				"7:23 wrong-kind: shared.Point in throws is not an exception",
+			},
+		},
+		{
+			name: "required",
+			src:  "struct A {\n  1: required i32 a\n}",
+			want: []string{"2:19 required-field: field a of A is required"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := strings.Join(lint(t, c.src, Options{}), "\n")
+			if want := strings.Join(c.want, "\n"); got != want {
+				t.Errorf("got [\n%v\n] want [\n%v\n]", got, want)
+			}
+		})
+	}
+}
+
+func TestLint_disabled(t *testing.T) {
+	got := lint(t, "struct A {\n  1: required i32 a\n  1: required i32 b\n}", Options{Disabled: []string{"required-field"}})
+	if want := []string{"3:19 duplicate-id: field id 1 of A is used more than once"}; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestSeverity_text(t *testing.T) {
+	for _, s := range []Severity{Warning, Error} {
+		text, _ := s.MarshalText()
+		var got Severity
+		if err := got.UnmarshalText(text); err != nil || got != s {
+			t.Errorf("got [%v %v] want [%v]", got, err, s)
+		}
+	}
+	var s Severity
+	if err := s.UnmarshalText([]byte("fatal")); err == nil {
+		t.Errorf("want error")
+	}
+}
@@ -63,7 +63,7 @@ func (r *MapType) parseKeyAndValue(p *Parser) (err error) {
 		return
 	}
 	ru := p.peekNonWhitespace()
-	commaTok := toToken(string(ru))
+	commaTok := runeToken(ru)
 	if commaTok != T_COMMA {
 		err = p.unexpected(string(ru), ",")
 		return
@@ -0,0 +1,184 @@
+package merge
+
+import (
+	"fmt"
+	"strings"
+	"text/scanner"
+)
+
+// MergeLines merges changes from base to theirs into ours line by line, like git merge-file. It works on any text,
+// so it's the fallback for files which can't be parsed. Each region changed differently by both sides is a conflict, keyed by its lines in ours.
+func MergeLines(base, ours, theirs string, opts Options) *Result {
+	if opts.OursLabel == "" {
+		opts.OursLabel = "ours"
+	}
+	if opts.TheirsLabel == "" {
+		opts.TheirsLabel = "theirs"
+	}
+	b, o, t := splitLines(base), splitLines(ours), splitLines(theirs)
+	toOurs, toTheirs := matchLines(b, o), matchLines(b, t)
+
+	res := &Result{}
+	var text strings.Builder
+	i, j, k := 0, 0, 0
+	for i < len(b) || j < len(o) || k < len(t) {
+		// lines kept by both sides
+		if i < len(b) && toOurs[i] == j && toTheirs[i] == k {
+			text.WriteString(b[i])
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+		// changed region ends at the next base line kept by both sides
+		ni, nj, nk := i, len(o), len(t)
+		for ; ni < len(b); ni++ {
+			if toOurs[ni] >= 0 && toTheirs[ni] >= 0 {
+				nj, nk = toOurs[ni], toTheirs[ni]
+				break
+			}
+		}
+		baseText, oursText, theirsText := strings.Join(b[i:ni], ""), strings.Join(o[j:nj], ""), strings.Join(t[k:nk], "")
+		switch {
+		case oursText == baseText || oursText == theirsText:
+			text.WriteString(theirsText)
+		case theirsText == baseText:
+			text.WriteString(oursText)
+		default:
+			// lines both sides agree on at the edges are kept out of conflict markers
+			ours, theirs := o[j:nj], t[k:nk]
+			prefix := 0
+			for prefix < len(ours) && prefix < len(theirs) && ours[prefix] == theirs[prefix] {
+				prefix++
+			}
+			suffix := 0
+			for suffix < len(ours)-prefix && suffix < len(theirs)-prefix && ours[len(ours)-1-suffix] == theirs[len(theirs)-1-suffix] {
+				suffix++
+			}
+			oursText, theirsText = strings.Join(ours[prefix:len(ours)-suffix], ""), strings.Join(theirs[prefix:len(theirs)-suffix], "")
+			res.Conflicts = append(res.Conflicts, Conflict{
+				Key:     fmt.Sprintf("lines %d-%d", j+prefix+1, nj-suffix),
+				Ours:    oursText,
+				Theirs:  theirsText,
+				OursPos: scanner.Position{Filename: "ours", Line: j + prefix + 1, Column: 1},
+			})
+			text.WriteString(strings.Join(ours[:prefix], ""))
+			if text.Len() > 0 && !strings.HasSuffix(text.String(), "\n") {
+				text.WriteByte('\n')
+			}
+			fmt.Fprintf(&text, "<<<<<<< %s\n%s=======\n%s>>>>>>> %s\n", opts.OursLabel, withLineBreak(oursText), withLineBreak(theirsText), opts.TheirsLabel)
+			text.WriteString(strings.Join(ours[len(ours)-suffix:], ""))
+		}
+		i, j, k = ni, nj, nk
+	}
+	res.Text = text.String()
+	return res
+}
+
+// splitLines splits text after each line break, so joining lines gives text back
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	res := strings.SplitAfter(text, "\n")
+	if res[len(res)-1] == "" {
+		res = res[:len(res)-1]
+	}
+	return res
+}
+
+// matchLines returns the index in b of each line in a on a shortest edit script from a to b, or -1 for removed lines.
+// It's the linear space variant of Myers' diff algorithm, which splits both sides where the forward and backward searches meet, and recurses on both halves.
+func matchLines(a, b []string) []int {
+	res := make([]int, len(a))
+	for i := range res {
+		res[i] = -1
+	}
+	var diff func(a0, a1, b0, b1 int)
+	diff = func(a0, a1, b0, b1 int) {
+		for a0 < a1 && b0 < b1 && a[a0] == b[b0] {
+			res[a0] = b0
+			a0, b0 = a0+1, b0+1
+		}
+		for a0 < a1 && b0 < b1 && a[a1-1] == b[b1-1] {
+			a1, b1 = a1-1, b1-1
+			res[a1] = b1
+		}
+		if a0 == a1 || b0 == b1 {
+			return
+		}
+		if x, y, ok := middle(a[a0:a1], b[b0:b1]); ok {
+			diff(a0, a0+x, b0, b0+y)
+			diff(a0+x, a1, b0+y, b1)
+		}
+	}
+	diff(0, len(a), 0, len(b))
+	return res
+}
+
+// middle returns the point (x, y) on a shortest edit script from a to b where the forward search from the start and the backward search from the end meet,
+// or false if a and b have no line in common. a and b must be non-empty, and differ in their first and last lines.
+func middle(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// furthest x on each diagonal k = x - y, counted from the start forward, and from the end backward
+	fwd, bwd := make([]int, 2*maxD+2), make([]int, 2*maxD+2)
+	for i := range fwd {
+		fwd[i], bwd[i] = -1, -1
+	}
+	fwd[offset+1], bwd[offset+1] = 0, 0
+	delta := n - m
+	// if delta is odd, the searches meet on a forward step, otherwise on a backward step
+	front := delta%2 != 0
+	// diagonals beyond the edges of a and b are skipped
+	var fwdStart, fwdEnd, bwdStart, bwdEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + fwdStart; k <= d-fwdEnd; k += 2 {
+			i := offset + k
+			if k == -d || k != d && fwd[i-1] < fwd[i+1] {
+				x = fwd[i+1]
+			} else {
+				x = fwd[i-1] + 1
+			}
+			y = x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			fwd[i] = x
+			switch {
+			case x > n:
+				fwdEnd += 2
+			case y > m:
+				fwdStart += 2
+			case front:
+				if j := offset + delta - k; j >= 0 && j < len(bwd) && bwd[j] != -1 && x >= n-bwd[j] {
+					return x, y, true
+				}
+			}
+		}
+		for k := -d + bwdStart; k <= d-bwdEnd; k += 2 {
+			i := offset + k
+			var bx int
+			if k == -d || k != d && bwd[i-1] < bwd[i+1] {
+				bx = bwd[i+1]
+			} else {
+				bx = bwd[i-1] + 1
+			}
+			by := bx - k
+			for bx < n && by < m && a[n-bx-1] == b[m-by-1] {
+				bx, by = bx+1, by+1
+			}
+			bwd[i] = bx
+			switch {
+			case bx > n:
+				bwdEnd += 2
+			case by > m:
+				bwdStart += 2
+			case !front:
+				if j := offset + delta - k; j >= 0 && j < len(fwd) && fwd[j] != -1 && fwd[j] >= n-bx {
+					return fwd[j], fwd[j] - (j - offset), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
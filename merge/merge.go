@@ -0,0 +1,424 @@
+// Package merge implements three-way merge of thrift files on their ASTs.
+//
+// Files are split into units, which are top-level declarations, and fields, enum elements and functions inside them, together with their doc comments.
+// Units are matched by name or field id, a unit changed by one side only is taken from that side verbatim, and the result is built by editing "ours" source,
+// so formatting and comments are preserved. When both sides change the same struct, enum or service differently, they are merged member by member,
+// only a member changed differently by both sides, or changed by one side and removed by the other, is a conflict.
+package merge
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Options of merge.
+type Options struct {
+	OursLabel   string // label after <<<<<<< conflict marker, "ours" by default
+	TheirsLabel string // label after >>>>>>> conflict marker, "theirs" by default
+}
+
+// Conflict is a unit changed differently by ours and theirs, its text is empty for the side removing it.
+type Conflict struct {
+	Key     string // name or field id of unit, qualified by its parent, e.g. User.1, or lines in ours for MergeLines, e.g. lines 3-5
+	Ours    string
+	Theirs  string
+	OursPos scanner.Position // position in ours, invalid if ours removed it
+}
+
+// Result of merge, Text contains conflict markers if there are conflicts.
+type Result struct {
+	Text      string
+	Conflicts []Conflict
+}
+
+// Clean reports whether merge has no conflicts.
+func (r *Result) Clean() bool {
+	return len(r.Conflicts) == 0
+}
+
+// Merge merges changes from base to theirs into ours.
+func Merge(base, ours, theirs *thrifter.Thrift, opts Options) *Result {
+	if opts.OursLabel == "" {
+		opts.OursLabel = "ours"
+	}
+	if opts.TheirsLabel == "" {
+		opts.TheirsLabel = "theirs"
+	}
+	m := &merger{
+		opts:   opts,
+		base:   newSide(base),
+		ours:   newSide(ours),
+		theirs: newSide(theirs),
+		res:    &Result{},
+	}
+	oursUnits := m.ours.units("", ours.Nodes, 0, len(m.ours.src))
+	edits := m.mergeUnits(
+		m.base.units("", base.Nodes, 0, len(m.base.src)),
+		oursUnits,
+		m.theirs.units("", theirs.Nodes, 0, len(m.theirs.src)),
+		len(m.ours.src),
+	)
+	m.res.Text = thrifter.ApplyEdits(m.ours.src, edits)
+	return m.res
+}
+
+// MergeBytes parses base, ours and theirs, and merges them, see Merge.
+func MergeBytes(base, ours, theirs []byte, opts Options) (*Result, error) {
+	files := make([]*thrifter.Thrift, 3)
+	for i, src := range [][]byte{base, ours, theirs} {
+		file, err := thrifter.NewParserBytes(src, false).Parse([]string{"base", "ours", "theirs"}[i])
+		if err != nil {
+			return nil, err
+		}
+		files[i] = file
+	}
+	return Merge(files[0], files[1], files[2], opts), nil
+}
+
+// source of a version
+type side struct {
+	src string
+}
+
+func newSide(file *thrifter.Thrift) *side {
+	res := &side{}
+	if file.StartToken != nil {
+		res.src = file.String()
+	}
+	return res
+}
+
+// unit is a mergeable part of source, i.e. a node with its doc comments, expanded to whole lines if it occupies them alone.
+type unit struct {
+	key   string
+	node  thrifter.Node
+	start int
+	end   int
+	text  string
+}
+
+// units of nodes, whose spans are limited to [lo, hi), nodes without key, e.g. options, are skipped
+func (s *side) units(parent string, nodes []thrifter.Node, lo int, hi int) (res []*unit) {
+	for _, node := range nodes {
+		key := unitKey(node)
+		if key == "" {
+			continue
+		}
+		if parent != "" {
+			key = parent + "." + key
+		}
+		common := node.CommonField()
+		first, last := common.StartToken, common.EndToken
+		if comments := common.LeadingComments(); len(comments) > 0 {
+			first = comments[0]
+		}
+		if tok := common.TrailingComment(); tok != nil {
+			last = tok
+		}
+		start, end := first.Start, last.End
+		// expand to whole lines
+		lineStart := start
+		for lineStart > lo && (s.src[lineStart-1] == ' ' || s.src[lineStart-1] == '\t') {
+			lineStart--
+		}
+		if lineStart == lo || s.src[lineStart-1] == '\n' {
+			start = lineStart
+		}
+		lineEnd := end
+		for lineEnd < hi && (s.src[lineEnd] == ' ' || s.src[lineEnd] == '\t' || s.src[lineEnd] == '\r') {
+			lineEnd++
+		}
+		if lineEnd == hi {
+			end = lineEnd
+		} else if s.src[lineEnd] == '\n' {
+			end = lineEnd + 1
+		}
+		if n := len(res); n > 0 && start < res[n-1].end {
+			start = res[n-1].end
+		}
+		res = append(res, &unit{key: key, node: node, start: start, end: end, text: s.src[start:end]})
+	}
+	return
+}
+
+func unitKey(node thrifter.Node) string {
+	switch n := node.(type) {
+	case *thrifter.Include:
+		return "include " + n.FilePath
+	case *thrifter.Namespace:
+		return "namespace " + n.Name
+	case *thrifter.Struct:
+		return n.Ident
+	case *thrifter.Enum:
+		return n.Ident
+	case *thrifter.Service:
+		return n.Ident
+	case *thrifter.TypeDef:
+		return n.Ident
+	case *thrifter.Const:
+		return n.Ident
+	case *thrifter.Field:
+		return strconv.Itoa(n.ID)
+	case *thrifter.EnumElement:
+		return n.Ident
+	case *thrifter.Function:
+		return n.Ident
+	}
+	return ""
+}
+
+type merger struct {
+	opts   Options
+	base   *side
+	ours   *side
+	theirs *side
+	res    *Result
+}
+
+// mergeUnits returns edits on ours source, which apply changes of theirs to ours units, end is where units are inserted if there are no ours units
+func (m *merger) mergeUnits(bases, ours, theirs []*unit, end int) (edits []thrifter.TextEdit) {
+	baseByKey, oursByKey, theirsByKey := byKey(bases), byKey(ours), byKey(theirs)
+	deleted := map[string]bool{}
+	for i, o := range ours {
+		b, inBase := baseByKey[o.key]
+		t, inTheirs := theirsByKey[o.key]
+		switch {
+		case !inBase && !inTheirs:
+			// added by ours
+		case !inBase:
+			// added by both
+			if o.text != t.text {
+				edits = append(edits, m.conflict(o, o.text, t.text))
+			}
+		case !inTheirs:
+			// removed by theirs
+			if similar(o, b) {
+				edits = append(edits, m.delete(ours, i, deleted))
+				deleted[o.key] = true
+			} else {
+				edits = append(edits, m.conflict(o, o.text, ""))
+			}
+		case o.text == b.text:
+			if t.text != b.text {
+				edits = append(edits, thrifter.TextEdit{Start: o.start, End: o.end, NewText: t.text})
+			}
+		case similar(t, b) || t.text == o.text:
+			// changed by ours only, or same change, formatting changes of theirs are dropped
+		case similar(o, b):
+			// formatting changes of ours are dropped
+			edits = append(edits, thrifter.TextEdit{Start: o.start, End: o.end, NewText: t.text})
+		default:
+			if containerEdits, ok := m.mergeContainers(b, o, t); ok {
+				edits = append(edits, containerEdits...)
+			} else {
+				edits = append(edits, m.conflict(o, o.text, t.text))
+			}
+		}
+	}
+
+	for i, t := range theirs {
+		if _, ok := oursByKey[t.key]; ok {
+			continue
+		}
+		text := t.text
+		if b, inBase := baseByKey[t.key]; inBase {
+			// removed by ours
+			if t.text == b.text {
+				continue
+			}
+			text = m.conflictText(nil, "", t.text)
+			m.res.Conflicts = append(m.res.Conflicts, Conflict{Key: t.key, Theirs: t.text})
+		}
+		// insert after the closest preceding unit which is kept in ours
+		anchor := -1
+		for j := i - 1; j >= 0 && anchor < 0; j-- {
+			if o, ok := oursByKey[theirs[j].key]; ok && !deleted[o.key] {
+				// units added by ours right after the preceding unit go first
+				k := indexOf(ours, o)
+				for k+1 < len(ours) && isAdded(ours[k+1], baseByKey, theirsByKey) {
+					k++
+				}
+				anchor = ours[k].end
+				text = whitespaceGap(m.theirs.src, theirs[j].end, t.start) + text
+			}
+		}
+		if anchor < 0 {
+			anchor = end
+			for _, o := range ours {
+				if !deleted[o.key] {
+					anchor = o.start
+					if i+1 < len(theirs) {
+						text += whitespaceGap(m.theirs.src, t.end, theirs[i+1].start)
+					}
+					break
+				}
+			}
+		}
+		edits = append(edits, thrifter.TextEdit{Start: anchor, End: anchor, NewText: text})
+	}
+	return
+}
+
+// delete ours[i] along with white spaces before it, or white spaces after it if it follows deleted units or the beginning
+func (m *merger) delete(ours []*unit, i int, deleted map[string]bool) thrifter.TextEdit {
+	start, end := ours[i].start, ours[i].end
+	if i > 0 && !deleted[ours[i-1].key] {
+		if whitespaceGap(m.ours.src, ours[i-1].end, start) != "" {
+			start = ours[i-1].end
+		}
+	} else if i+1 < len(ours) && whitespaceGap(m.ours.src, end, ours[i+1].start) != "" {
+		end = ours[i+1].start
+	}
+	return thrifter.TextEdit{Start: start, End: end}
+}
+
+// mergeContainers merges members of struct, enum or service changed by both sides, it returns false if they can't be merged member by member
+func (m *merger) mergeContainers(b, o, t *unit) ([]thrifter.TextEdit, bool) {
+	bb, ob, tb := m.base.body(b), m.ours.body(o), m.theirs.body(t)
+	if bb == nil || ob == nil || tb == nil {
+		return nil, false
+	}
+	bh, oh, th := bb.header(m.base.src, b), ob.header(m.ours.src, o), tb.header(m.theirs.src, t)
+	if oh != bh && th != bh && oh != th {
+		return nil, false
+	}
+	end := ob.close
+	if lineStart := strings.LastIndexByte(m.ours.src[:end], '\n') + 1; strings.TrimSpace(m.ours.src[lineStart:end]) == "" && lineStart > ob.open {
+		end = lineStart
+	}
+	edits := m.mergeUnits(
+		m.base.units(b.key, bb.members, bb.open, bb.close),
+		m.ours.units(o.key, ob.members, ob.open, ob.close),
+		m.theirs.units(t.key, tb.members, tb.open, tb.close),
+		end,
+	)
+	if th == bh || oh == th {
+		return edits, true
+	}
+	// header is changed by theirs only, rebuild the container with theirs header and merged members
+	for i := range edits {
+		edits[i].Start -= ob.open
+		edits[i].End -= ob.open
+	}
+	members := thrifter.ApplyEdits(m.ours.src[ob.open:ob.close], edits)
+	text := m.theirs.src[t.start:tb.open] + members + m.theirs.src[tb.close:t.end]
+	return []thrifter.TextEdit{{Start: o.start, End: o.end, NewText: text}}, true
+}
+
+// body of a container, open is the offset right after {, and close is the offset of }
+type body struct {
+	open    int
+	close   int
+	members []thrifter.Node
+}
+
+func (s *side) body(u *unit) *body {
+	var members []thrifter.Node
+	switch n := u.node.(type) {
+	case *thrifter.Struct:
+		for _, field := range n.Elems {
+			members = append(members, field)
+		}
+	case *thrifter.Enum:
+		for _, elem := range n.Elems {
+			members = append(members, elem)
+		}
+	case *thrifter.Service:
+		for _, function := range n.Elems {
+			members = append(members, function)
+		}
+	default:
+		return nil
+	}
+	common := u.node.CommonField()
+	res := &body{open: -1, close: -1}
+	for tok := common.StartToken; tok != nil; tok = tok.Next {
+		if tok.Type == thrifter.T_LEFTCURLY && res.open < 0 {
+			res.open = tok.End
+		}
+		if tok.Type == thrifter.T_RIGHTCURLY {
+			res.close = tok.Start
+		}
+		if tok == common.EndToken {
+			break
+		}
+	}
+	if res.open < 0 || res.close < res.open {
+		return nil
+	}
+	res.members = members
+	return res
+}
+
+// source of container without members
+func (b *body) header(src string, u *unit) string {
+	return src[u.start:b.open] + src[b.close:u.end]
+}
+
+// conflict replaces ours unit o with conflict markers
+func (m *merger) conflict(o *unit, oursText string, theirsText string) thrifter.TextEdit {
+	m.res.Conflicts = append(m.res.Conflicts, Conflict{
+		Key:     o.key,
+		Ours:    oursText,
+		Theirs:  theirsText,
+		OursPos: o.node.CommonField().StartToken.Pos,
+	})
+	return thrifter.TextEdit{Start: o.start, End: o.end, NewText: m.conflictText(o, oursText, theirsText)}
+}
+
+func (m *merger) conflictText(o *unit, oursText string, theirsText string) string {
+	var res strings.Builder
+	if o != nil && o.start > 0 && m.ours.src[o.start-1] != '\n' {
+		res.WriteByte('\n')
+	}
+	fmt.Fprintf(&res, "<<<<<<< %s\n%s=======\n%s>>>>>>> %s\n", m.opts.OursLabel, withLineBreak(oursText), withLineBreak(theirsText), m.opts.TheirsLabel)
+	return res.String()
+}
+
+func withLineBreak(text string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		return text + "\n"
+	}
+	return text
+}
+
+// src[start:end] if it only contains white spaces, otherwise empty string
+func whitespaceGap(src string, start int, end int) string {
+	if start >= end || strings.TrimSpace(src[start:end]) != "" {
+		return ""
+	}
+	return src[start:end]
+}
+
+// whether two units only differ in white spaces
+func similar(a, b *unit) bool {
+	return a.text == b.text || strings.Join(strings.Fields(a.text), " ") == strings.Join(strings.Fields(b.text), " ")
+}
+
+// whether u is added by ours
+func isAdded(u *unit, baseByKey, theirsByKey map[string]*unit) bool {
+	_, inBase := baseByKey[u.key]
+	_, inTheirs := theirsByKey[u.key]
+	return !inBase && !inTheirs
+}
+
+func indexOf(units []*unit, u *unit) int {
+	for i := range units {
+		if units[i] == u {
+			return i
+		}
+	}
+	return -1
+}
+
+func byKey(units []*unit) map[string]*unit {
+	res := make(map[string]*unit, len(units))
+	for _, u := range units {
+		res[u.key] = u
+	}
+	return res
+}
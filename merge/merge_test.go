@@ -0,0 +1,294 @@
+package merge
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	cases := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		{
+			name: "declarations changed by different sides",
+			base: `struct A {
+  1: i32 a
+}
+
+enum E {
+  X
+}
+`,
+			ours: `// A is changed by ours
+struct A {
+  1: i64 a
+}
+
+enum E {
+  X
+}
+`,
+			theirs: `struct A {
+  1: i32 a
+}
+
+enum E {
+  X
+  Y
+}
+
+struct C {}
+`,
+			want: `// A is changed by ours
+struct A {
+  1: i64 a
+}
+
+enum E {
+  X
+  Y
+}
+
+struct C {}
+`,
+		},
+		{
+			name: "fields of the same struct",
+			base: `struct A {
+  1: i32 a
+  2: i32 b
+  3: i32 c
+}
+`,
+			ours: `struct A {
+  1: i32 a // changed by ours
+  2: i32 b
+  3: i32 c
+  4: i32 d
+}
+`,
+			theirs: `struct A {
+	1: i32 a
+	3: string c
+	5: i32 e
+}
+`,
+			want: `struct A {
+  1: i32 a // changed by ours
+	3: string c
+  4: i32 d
+	5: i32 e
+}
+`,
+		},
+		{
+			name:   "same field changed differently",
+			base:   "struct A {\n  1: i32 a\n  2: i32 b\n}\n",
+			ours:   "struct A {\n  1: i64 a\n  2: i32 b\n}\n",
+			theirs: "struct A {\n  1: string a\n  2: i32 b\n  3: i32 c\n}\n",
+			want: `struct A {
+<<<<<<< ours
+  1: i64 a
+=======
+  1: string a
+>>>>>>> theirs
+  2: i32 b
+  3: i32 c
+}
+`,
+			conflicts: 1,
+		},
+		{
+			name:   "changed and removed",
+			base:   "enum E {\n  X\n  Y\n}\nconst i32 C = 1\n",
+			ours:   "enum E {\n  X\n  Y = 2\n}\n",
+			theirs: "enum E {\n  X\n}\nconst i32 C = 2\n",
+			want: `enum E {
+  X
+<<<<<<< ours
+  Y = 2
+=======
+>>>>>>> theirs
+}
+<<<<<<< ours
+=======
+const i32 C = 2
+>>>>>>> theirs
+`,
+			conflicts: 2,
+		},
+		{
+			name:   "same change by both",
+			base:   "service S {\n  void a()\n}\n",
+			ours:   "service S {\n  void a()\n  void b()\n}\n",
+			theirs: "service S {\n  void a()\n  void b()\n}\n",
+			want:   "service S {\n  void a()\n  void b()\n}\n",
+		},
+		{
+			name:   "header changed by theirs",
+			base:   "struct A {\n  1: i32 a\n}\n",
+			ours:   "struct A {\n  1: i32 a\n  2: i32 b\n}\n",
+			theirs: "/** doc */\nunion A {\n  1: i32 a\n} (x = \"y\")\n",
+			want:   "/** doc */\nunion A {\n  1: i32 a\n  2: i32 b\n} (x = \"y\")\n",
+		},
+		{
+			name:      "header changed by both",
+			base:      "struct A {\n  1: i32 a\n}\n",
+			ours:      "union A {\n  1: i32 a\n}\n",
+			theirs:    "exception A {\n  1: i32 a\n}\n",
+			want:      "<<<<<<< ours\nunion A {\n  1: i32 a\n}\n=======\nexception A {\n  1: i32 a\n}\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+		{
+			name:   "removed declarations",
+			base:   "include \"a.thrift\"\n\nstruct A {}\n\nstruct B {}\n\nstruct C {}\n",
+			ours:   "include \"a.thrift\"\n\nstruct A {}\n\nstruct B {}\n\nstruct C {}\n\nstruct D {}\n",
+			theirs: "struct A {}\n\nstruct C {}\n",
+			want:   "struct A {}\n\nstruct C {}\n\nstruct D {}\n",
+		},
+	}
+	for _, c := range cases {
+		res, err := MergeBytes([]byte(c.base), []byte(c.ours), []byte(c.theirs), Options{})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if res.Text != c.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, res.Text, c.want)
+		}
+		if got, want := len(res.Conflicts), c.conflicts; got != want {
+			t.Errorf("%s: got [%v] want [%v]", c.name, got, want)
+		}
+	}
+}
+
+func TestMerge_labels(t *testing.T) {
+	res, err := MergeBytes([]byte("const i32 A = 1"), []byte("const i32 A = 2"), []byte("const i32 A = 3"), Options{OursLabel: "HEAD", TheirsLabel: "feature"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res.Text, "<<<<<<< HEAD\nconst i32 A = 2\n=======\nconst i32 A = 3\n>>>>>>> feature\n"; got != want {
+		t.Errorf("got [%q] want [%q]", got, want)
+	}
+	if res.Clean() || res.Conflicts[0].Key != "A" || res.Conflicts[0].OursPos.Line != 1 {
+		t.Errorf("got [%+v]", res.Conflicts)
+	}
+}
+
+func TestMergeBytes_error(t *testing.T) {
+	if _, err := MergeBytes([]byte("struct A {}"), []byte("struct A {"), []byte("struct A {}"), Options{}); err == nil {
+		t.Errorf("expect parse error of ours")
+	}
+}
+
+func TestMergeLines(t *testing.T) {
+	cases := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		{
+			name:   "changes of different lines",
+			base:   "a\nb\nc\nd\n",
+			ours:   "a\nB\nc\nd\n",
+			theirs: "a\nb\nc\nd\ne\n",
+			want:   "a\nB\nc\nd\ne\n",
+		},
+		{
+			name:   "same change on both sides",
+			base:   "a\nb\n",
+			ours:   "a\nc\n",
+			theirs: "a\nc\n",
+			want:   "a\nc\n",
+		},
+		{
+			name:   "removed by one side",
+			base:   "a\nb\nc\n",
+			ours:   "a\nc\n",
+			theirs: "x\na\nb\nc\n",
+			want:   "x\na\nc\n",
+		},
+		{
+			name:      "conflict",
+			base:      "struct A {\n  1: i32 a\n}",
+			ours:      "struct A {\n  1: i64 a\n}",
+			theirs:    "struct A {\n  1: string a\n",
+			want:      "struct A {\n<<<<<<< ours\n  1: i64 a\n}\n=======\n  1: string a\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+		{
+			name:   "empty base",
+			base:   "",
+			ours:   "a\n",
+			theirs: "",
+			want:   "a\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res := MergeLines(c.base, c.ours, c.theirs, Options{})
+			if got := res.Text; got != c.want {
+				t.Errorf("got [%q] want [%q]", got, c.want)
+			}
+			if got := len(res.Conflicts); got != c.conflicts {
+				t.Errorf("got [%v] want [%v]", got, c.conflicts)
+			}
+		})
+	}
+}
+
+func TestMatchLines(t *testing.T) {
+	a, b := splitLines("a\nb\nc\na\nb\nb\na\n"), splitLines("c\nb\na\nb\na\nc\n")
+	got := matchLines(a, b)
+	// every matched pair is equal, and matches are increasing
+	last, matched := -1, 0
+	for i, j := range got {
+		if j < 0 {
+			continue
+		}
+		if j <= last || a[i] != b[j] {
+			t.Fatalf("got [%v]", got)
+		}
+		last, matched = j, matched+1
+	}
+	// the longest common subsequence has 4 lines, e.g. b a b a
+	if matched != 4 {
+		t.Errorf("got [%v] want [4] in %v", matched, got)
+	}
+}
+
+func TestMatchLines_diverged(t *testing.T) {
+	// every other line differs, the edit script has as many edits as lines
+	var a, b []string
+	for i := 0; i < 5000; i++ {
+		a = append(a, fmt.Sprintf("same %d\n", i), fmt.Sprintf("ours %d\n", i))
+		b = append(b, fmt.Sprintf("same %d\n", i), fmt.Sprintf("theirs %d\n", i))
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	got := matchLines(a, b)
+	runtime.ReadMemStats(&after)
+
+	for i, j := range got {
+		want := -1
+		if i%2 == 0 {
+			want = i
+		}
+		if j != want {
+			t.Fatalf("line %d: got [%v] want [%v]", i, j, want)
+		}
+	}
+	// space is linear in lines, a trace of the search would take gigabytes
+	if got := after.TotalAlloc - before.TotalAlloc; got > 64<<20 {
+		t.Errorf("allocated %d bytes", got)
+	}
+}
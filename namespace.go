@@ -29,12 +29,19 @@ func (r *Namespace) String() string {
 }
 
 func (r *Namespace) parse(p *Parser) (err error) {
-	identTok := p.nextIdent(true)
+	identTok, err := p.expectIdent(true)
+	if err != nil {
+		return err
+	}
 	r.Name = identTok.Raw
-	identTok = p.nextIdent(true)
+	r.NodeID = genNodeID("", r.NodeType(), r.Name)
+	identTok, err = p.expectIdent(true)
+	if err != nil {
+		return err
+	}
 	r.Value = identTok.Raw
 	ru := p.peekNonWhitespace()
-	if toToken(string(ru)) != T_LEFTPAREN {
+	if runeToken(ru) != T_LEFTPAREN {
 		r.EndToken = identTok
 		return
 	}
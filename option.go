@@ -29,18 +29,21 @@ func (r *Option) String() string {
 
 func (r *Option) parse(p *Parser) (err error) {
 	// can't use keyword as option name
-	identTok := p.nextIdent(false)
-	if identTok == nil || identTok.Type != T_IDENT {
+	identTok, err := p.expectIdent(false)
+	if err != nil {
+		return err
+	}
+	if identTok.Type != T_IDENT {
 		return p.unexpected(identTok.Raw, "identifier")
 	}
 	r.StartToken = identTok
 	r.Name = identTok.Raw
 	r.EndToken = identTok
-	// if there is no = token
-	tok := p.nextNonWhitespace()
-	if tok.Type != T_EQUALS {
+	// if there is no = token, leave the following , or ) to parseOptions
+	if runeToken(p.peekNonWhitespace()) != T_EQUALS {
 		return
 	}
+	tok := p.next()
 	// find next string
 	nextRune := p.peekNonWhitespace()
 	if nextRune != singleQuoteRune && nextRune != quoteRune {
@@ -67,10 +70,14 @@ func parseOptions(p *Parser, parent Node) (res []*Option, rightParenTok *Token,
 	var currOption *Option
 	for {
 		ru := p.peekNonWhitespace()
-		if toToken(string(ru)) == T_RIGHTPAREN {
+		if runeToken(ru) == T_RIGHTPAREN {
 			rightParenTok = p.next()
 			break
 		}
+		if ru == eof {
+			err = p.unexpected("EOF", ")")
+			return
+		}
 
 		currOption = NewOption(parent)
 		err = currOption.parse(p)
@@ -80,7 +87,7 @@ func parseOptions(p *Parser, parent Node) (res []*Option, rightParenTok *Token,
 		res = append(res, currOption)
 
 		ru = p.peekNonWhitespace()
-		if toToken(string(ru)) == T_COMMA {
+		if runeToken(ru) == T_COMMA {
 			p.next() // consume comma
 		}
 	}
@@ -60,3 +60,21 @@ func TestOption_toString(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 }
+
+func TestOption_withoutValue(t *testing.T) {
+	parser := newParserOn(`struct A {} (a, b = "1", c)`)
+	res, err := parser.Parse("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := res.Nodes[0].(*Struct).Options
+	if got, want := len(options), 3; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	if got, want := options[2].Name, "c"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := options[2].Value, ""; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
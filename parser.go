@@ -3,40 +3,43 @@ package thrifter
 import (
 	"fmt"
 	"io"
-	"os"
 	"runtime"
 	"text/scanner"
 )
 
 func NewParser(rd io.Reader, debug bool) *Parser {
-	s := new(scanner.Scanner)
-	s.Init(rd)
-	s.Whitespace ^= 1<<'\t' | 1<<'\n' | 1<<'\r' | 1<<' ' // do not filter tab/lineBreak/return/space, since we want to be non-destructive
-	s.Mode = scanner.ScanIdents |
-		scanner.ScanInts |
-		scanner.ScanFloats |
-		// scanner.ScanChars |
-		// scanner.ScanStrings |
-		// scanner.ScanComments |
-		scanner.ScanRawStrings
+	src, err := io.ReadAll(rd)
+	res := NewParserBytes(src, debug)
+	if err != nil {
+		res.err = fmt.Errorf("read error: %v", err)
+	}
+	return res
+}
+
+// Initialize parser on source bytes directly, all tokens share a single copy of the source instead of allocating their own literal.
+func NewParserBytes(src []byte, debug bool) *Parser {
+	res := &Parser{lexer: newLexer(src), debug: debug}
 	// Scan error callback
-	s.Error = func(s *scanner.Scanner, msg string) {
-		fmt.Printf("Scan error: %v\n", msg)
-		os.Exit(1)
+	res.lexer.Error = func(l *lexer, msg string) {
+		res.scanError(&ParseError{Pos: l.tokPos, Msg: msg})
 	}
-	res := &Parser{scanner: s, debug: debug}
 	return res
 }
 
 type Parser struct {
 	debug     bool
-	scanner   *scanner.Scanner
+	lexer     *lexer
 	currToken *Token
 	buf       *Token
+	err       error // first error during scanning, parsing will go on until EOF and then report it
 }
 
 // parse a thrift file
 func (p *Parser) Parse(fileName string) (res *Thrift, err error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.lexer.filename = fileName
 	res = NewThrift(nil, fileName)
 	err = res.parse(p)
 	return
@@ -50,32 +53,24 @@ func (p *Parser) next() (res *Token) {
 		p.buf = nil
 		return
 	}
-	s := p.scanner
-	t := s.Scan()
-	if t == scanner.EOF {
+	l := p.lexer
+	t := l.scan()
+	if t == eof {
 		res = &Token{
-			Type: T_EOF,
-			Prev: p.currToken,
-			Pos:  p.scanner.Position,
+			Type:  T_EOF,
+			Prev:  p.currToken,
+			Pos:   l.tokPos,
+			Start: l.tokStart,
+			End:   l.tokEnd,
 		}
 	} else if isComment, ct := p.isComment(t); isComment {
 		var err error
 		res, err = p.nextComment(ct)
 		if err != nil {
-			fmt.Printf("Scan error: %v\n", err.Error())
-			os.Exit(1)
+			p.scanError(err)
 		}
 	} else {
-		str := s.TokenText()
-		token := toToken(str)
-
-		res = &Token{
-			Type:  token,
-			Prev:  p.currToken,
-			Raw:   str,
-			Value: str,
-			Pos:   p.scanner.Position,
-		}
+		res = p.newToken(toToken(l.tokenText()), l.tokStart, l.tokEnd, l.tokPos)
 	}
 	p.chainToken(res)
 	return
@@ -97,57 +92,40 @@ func (p *Parser) isComment(r rune) (res bool, commentType int) {
 	return false, 0
 }
 
-// Scan and return comment token.
+// Scan and return comment token, unterminated block comment will be returned along with an error.
+// Note: assume the first character of comment, / or #, is just consumed.
 func (p *Parser) nextComment(commentType int) (res *Token, err error) {
-	var r rune
-	var fullLit string
+	l := p.lexer
+	start, pos := l.offset-1, l.lastPos()
 	switch commentType {
-	case BASH_LIKE_COMMENT:
-		fullLit = "#"
-	case SINGLE_LINE_COMMENT:
-		fullLit = "/"
-	case MULTI_LINE_COMMENT:
-		fullLit = "/*"
-		p.scanner.Next() // consume * token
-	}
-
-	for {
-		r = p.peek()
-		if r == scanner.EOF {
-			if commentType == MULTI_LINE_COMMENT {
-				err = fmt.Errorf("unterminated block comment, at %+v", p.scanner.Position)
-				return
-			}
-			break
+	case BASH_LIKE_COMMENT, SINGLE_LINE_COMMENT:
+		for r := l.peek(); r != eof && r != '\n' && r != '\r'; r = l.peek() {
+			l.next() // consume next character
 		}
-		if commentType == BASH_LIKE_COMMENT || commentType == SINGLE_LINE_COMMENT {
-			if r == '\n' || r == '\r' {
+	case MULTI_LINE_COMMENT:
+		l.next() // consume * character
+		for {
+			r := l.next()
+			if r == eof {
+				err = &ParseError{Pos: pos, Msg: "unterminated block comment"}
 				break
-			} else {
-				p.scanner.Next() // consume next token
 			}
-		} else if commentType == MULTI_LINE_COMMENT {
-			if r == '*' {
-				p.scanner.Next() // consume next token
-				nextRune := p.peek()
-				if nextRune == '/' {
-					p.scanner.Next() // consume next token
-					fullLit += "*/"
-					break
-				}
-			} else {
-				p.scanner.Next() // consume next token
+			if r == '*' && l.peek() == '/' {
+				l.next() // consume /
+				break
 			}
 		}
-		fullLit += string(r)
 	}
 
+	raw := l.src[start:l.offset]
 	res = &Token{
-		Value: getCommentValue(fullLit, commentType),
-		Raw:   fullLit,
+		Value: getCommentValue(raw, commentType),
+		Raw:   raw,
 		Type:  T_COMMENT,
 		Prev:  p.currToken,
-		Pos:   p.scanner.Position,
+		Pos:   pos,
+		Start: start,
+		End:   l.offset,
 	}
 	return
 }
@@ -157,256 +135,191 @@ func (p *Parser) nextComment(commentType int) (res *Token, err error) {
 // 2. If keywordAllowed == true, it will allow keyword inside an identifier, e.g. enum.aaa.struct. In this case, the token for keyword will be replace to T_IDENT, since the meaning for it is no more a keyword.
 // 3. For dot-separated identifier, it will automatically connected to a single string, and return one single token.
 func (p *Parser) nextIdent(keywordAllowed bool) (res *Token) {
-	var fullLit string
-	var skipDot bool
 	// if buffer containers a token, consume buffer first
 	if p.buf != nil {
 		res = p.buf
 		p.buf = nil
 		return
-	} else {
-		p.peekNonWhitespace()
-		p.scanner.Scan()
-		lit := p.scanner.TokenText()
-		tok := toToken(lit)
-		if T_IDENT != tok && T_DOT != tok {
-			// can be keyword, change its token.Type
-			if IsKeyword(tok) && keywordAllowed {
-				tok = T_IDENT
-			} else {
-				// if its not valid ident or keyword or dot, save it to buffer until next scan
-				p.buf = &Token{
-					Type:  tok,
-					Raw:   lit,
-					Value: lit,
-					Prev:  p.currToken,
-				}
-				p.chainToken(p.buf)
-				return
-			}
-			// proceed with keyword as first literal
-		}
-		fullLit = lit
-		// if we have a leading dot, we need to skip dot handling in first iteration
-		skipDot = false
-		if tok == T_DOT {
-			skipDot = true
+	}
+	l := p.lexer
+	p.peekNonWhitespace()
+	l.scan()
+	tok := toToken(l.tokenText())
+	start, pos := l.tokStart, l.tokPos
+	if T_IDENT != tok && T_DOT != tok {
+		// can be keyword, change its token.Type
+		if IsKeyword(tok) && keywordAllowed {
+			tok = T_IDENT
+		} else {
+			// if its not valid ident or keyword or dot, save it to buffer until next scan
+			p.buf = p.newToken(tok, l.tokStart, l.tokEnd, l.tokPos)
+			p.chainToken(p.buf)
+			return
 		}
+		// proceed with keyword as first literal
 	}
+	// if we have a leading dot, we need to skip dot handling in first iteration
+	skipDot := tok == T_DOT
 
 	for {
 		if skipDot {
 			skipDot = false
-			fullLit = ""
 		} else {
-			r := p.peek()
-			if '.' != r {
+			if '.' != p.peek() {
 				break
 			}
-			p.scanner.Next() // consume dot
+			l.next() // consume dot
 		}
 		// scan next token, see if it's a identifier or keyword, if not, save it to p.buf until next p.next() calling
-		p.scanner.Scan()
-		lit := p.scanner.TokenText()
-		tok := toToken(lit)
+		l.scan()
+		tok := toToken(l.tokenText())
 		if IsKeyword(tok) && keywordAllowed {
 			tok = T_IDENT
 		}
 		if T_IDENT != tok {
-			fullLit = fmt.Sprintf("%s.", fullLit)
-			res = &Token{
-				Type:  T_IDENT,
-				Raw:   fullLit,
-				Value: fullLit,
-				Pos:   p.scanner.Position,
-				Prev:  p.currToken,
-			}
+			// identifier ends with the dot just consumed
+			res = p.newToken(T_IDENT, start, l.tokStart, pos)
 			p.chainToken(res)
-			p.buf = &Token{
-				Type:  tok,
-				Raw:   lit,
-				Value: lit,
-				Prev:  res,
-			}
+			p.buf = p.newToken(tok, l.tokStart, l.tokEnd, l.tokPos)
 			p.chainToken(p.buf)
 			return
 		}
-		fullLit = fmt.Sprintf("%s.%s", fullLit, lit)
 	}
 
-	res = &Token{
-		Type:  T_IDENT,
-		Raw:   fullLit,
-		Value: fullLit,
-		Pos:   p.scanner.Position,
-		Prev:  p.currToken,
-	}
+	// dot-separated identifier is contiguous in source, so just slice it
+	res = p.newToken(T_IDENT, start, l.tokEnd, pos)
 	p.chainToken(res)
 	return
 }
 
+// Find next identifier like nextIdent, but return an error if the next token is not an identifier.
+func (p *Parser) expectIdent(keywordAllowed bool) (*Token, error) {
+	res := p.nextIdent(keywordAllowed)
+	if res == nil {
+		// non-identifier token is buffered
+		return nil, p.unexpected(p.buf.Raw, "identifier")
+	}
+	return res, nil
+}
+
 func (p *Parser) peek() rune {
-	return p.scanner.Peek()
+	return p.lexer.peek()
 }
 
 // Scan next Unicode character, consumes white spaces, comments and first non-whitespaces token.
 func (p *Parser) nextNonWhitespace() (res *Token) {
-	r := p.peek()
-
-	// see if it's a comment
-	if string(r) == "/" || string(r) == "#" {
-		p.scanner.Next() // consume comment first unicode character
-		isComment, commentType := p.isComment(r)
-		if isComment {
-			var err error
+	l := p.lexer
+	for {
+		r := p.peek()
+		// see if it's a comment
+		if r == '/' || r == '#' {
+			l.next() // consume comment first unicode character
+			isComment, commentType := p.isComment(r)
+			if !isComment {
+				tok := p.newToken(T_IDENT, l.offset-1, l.offset, l.lastPos())
+				p.chainToken(tok)
+				return tok
+			}
 			tok, err := p.nextComment(commentType)
 			if err != nil {
-				fmt.Printf("Scan error: %v\n", err.Error())
-				os.Exit(1)
+				p.scanError(err)
 			}
 			p.chainToken(tok)
-			return p.nextNonWhitespace()
+		} else if isWhitespaceRune(r) {
+			l.next() // consume whitespaces
+			p.chainToken(p.newToken(runeToken(r), l.offset-1, l.offset, l.lastPos()))
 		} else {
-			tok := &Token{
-				Type:  T_IDENT,
-				Raw:   string(r),
-				Value: string(r),
-				Prev:  p.currToken,
-				Pos:   p.scanner.Position,
-			}
-			p.chainToken(tok)
-			return tok
-		}
-	} else if IsWhitespace(toToken(string(r))) {
-		r = p.scanner.Next() // consume whitespaces
-		tok := &Token{
-			Type:  toToken(string(r)),
-			Raw:   string(r),
-			Value: string(r),
-			Prev:  p.currToken,
-			Pos:   p.scanner.Position,
+			return p.next()
 		}
-		p.chainToken(tok)
-		return p.nextNonWhitespace()
 	}
-	return p.next()
 }
 
 // Scan next Unicode character, only consume white spaces, will not consume first non-whitespaces character.
 func (p *Parser) peekNonWhitespace() (r rune) {
-	r = p.peek()
-
-	// see if it's a comment
-	if string(r) == "/" || string(r) == "#" {
-		p.scanner.Next() // consume comment first unicode character
-		isComment, commentType := p.isComment(r)
-		if isComment {
-			var err error
+	l := p.lexer
+	for {
+		r = p.peek()
+		// see if it's a comment, a slash which doesn't start a comment is left to the caller
+		if r == '#' || r == '/' && (l.isByteAt(l.offset+1, '/') || l.isByteAt(l.offset+1, '*')) {
+			l.next() // consume comment first unicode character
+			_, commentType := p.isComment(r)
 			tok, err := p.nextComment(commentType)
 			if err != nil {
-				fmt.Printf("Scan error: %v\n", err.Error())
-				os.Exit(1)
+				p.scanError(err)
 			}
 			p.chainToken(tok)
-			return p.peekNonWhitespace()
+		} else if isWhitespaceRune(r) {
+			l.next() // consume whitespaces
+			p.chainToken(p.newToken(runeToken(r), l.offset-1, l.offset, l.lastPos()))
 		} else {
 			return r
 		}
-	} else if IsWhitespace(toToken(string(r))) {
-		r = p.scanner.Next() // consume whitespaces
-		tok := &Token{
-			Type:  toToken(string(r)),
-			Raw:   string(r),
-			Value: string(r),
-			Prev:  p.currToken,
-			Pos:   p.scanner.Position,
-		}
-		p.chainToken(tok)
-		return p.peekNonWhitespace()
 	}
-	return r
 }
 
-// Note: assume we found next token is ' or ", concat unicode character into a single string token.
+// Note: assume we found next token is ' or ", scan until the ending quote into a single string token.
 // we can't use p.next() to scan token, because if string contains // or /* characters it will be parsed as comment.
 func (p *Parser) nextString() (res *Token, err error) {
-	r := p.scanner.Next()
-	tok := toToken(string(r))
-	if tok != T_SINGLEQUOTE && tok != T_QUOTE {
+	l := p.lexer
+	start, pos := l.offset, l.pos()
+	r := l.next()
+	quoteType := runeToken(r)
+	if quoteType != T_SINGLEQUOTE && quoteType != T_QUOTE {
 		err = p.unexpected(string(r), "single quote || quote")
 		return
 	}
-	quoteType := tok
-	var fullLit string
-	if quoteType == T_SINGLEQUOTE {
-		fullLit = singleQuoteString
-	} else {
-		fullLit = quoteString
-	}
 
 	for {
-		r := p.scanner.Next()
+		r := l.next()
 		// invalid string
-		if r == scanner.EOF || r == '\n' || r == '\r' {
+		if r == eof || r == '\n' || r == '\r' {
 			err = p.unexpected("EOF or LineBreak", "single quote || quote")
 			return
 		}
-		fullLit += string(r)
 		// find the ending quote
-		if toToken(string(r)) == quoteType {
+		if runeToken(r) == quoteType {
 			break
 		}
 	}
 
-	val, _ := unQuote(fullLit)
+	raw := l.src[start:l.offset]
+	val, _ := unQuote(raw)
 	res = &Token{
 		Type:  T_STRING,
-		Raw:   fullLit,
+		Raw:   raw,
 		Value: val,
 		Prev:  p.currToken,
-		Pos:   p.scanner.Position,
+		Pos:   pos,
+		Start: start,
+		End:   l.offset,
 	}
 	p.chainToken(res)
 	return
 }
 
-// assume we found next token is a number, if it is minus number, concat minus symbol and digits into one token
+// assume we found next token is a number, if it is minus number, minus symbol and digits will be one token
 func (p *Parser) nextNumber() (res *Token, err error, isFloat bool, isInt bool) {
-	var fullLit string
+	l := p.lexer
 	r := p.peekNonWhitespace()
 	if IsDigit(r) {
-		p.scanner.Scan()
-		fullLit = p.scanner.TokenText()
+		l.scan()
+		fullLit := l.tokenText()
 		if isFloat, isInt = IsNumber(fullLit); !isFloat && !isInt {
 			err = p.unexpected("digit", fullLit)
 			return
 		}
-		res = &Token{
-			Type:  T_NUMBER,
-			Raw:   fullLit,
-			Value: fullLit,
-			Prev:  p.currToken,
-			Pos:   p.scanner.Position,
-		}
+		res = p.newToken(T_NUMBER, l.tokStart, l.tokEnd, l.tokPos)
 		p.chainToken(res)
-	} else if toToken(string(r)) == T_MINUS {
-		p.scanner.Next() // consume minus symbol
-		fullLit = "-"
-		p.scanner.Scan()
-		num := p.scanner.TokenText()
+	} else if runeToken(r) == T_MINUS {
+		start, pos := l.offset, l.pos()
+		l.next() // consume minus symbol
+		l.scan()
+		num := l.tokenText()
 		if isFloat, isInt = IsNumber(num); !isFloat && !isInt {
 			err = p.unexpected("digit", num)
 			return
-		} else {
-			fullLit += num
-		}
-		res = &Token{
-			Type:  T_NUMBER,
-			Raw:   fullLit,
-			Value: fullLit,
-			Prev:  p.currToken,
-			Pos:   p.scanner.Position,
 		}
+		res = p.newToken(T_NUMBER, start, l.tokEnd, pos)
 		p.chainToken(res)
 	} else {
 		err = p.unexpected("- symbol or digit", string(r))
@@ -415,6 +328,27 @@ func (p *Parser) nextNumber() (res *Token, err error, isFloat bool, isInt bool)
 	return
 }
 
+// create a token sliced from source between start and end offset, its Raw and Value are the same
+func (p *Parser) newToken(t token, start int, end int, pos scanner.Position) *Token {
+	lit := p.lexer.src[start:end]
+	return &Token{
+		Type:  t,
+		Raw:   lit,
+		Value: lit,
+		Prev:  p.currToken,
+		Pos:   pos,
+		Start: start,
+		End:   end,
+	}
+}
+
+// record the first scan error, it will be reported once parsing reaches EOF
+func (p *Parser) scanError(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
 // chain token to current token's next pointer
 func (p *Parser) chainToken(tok *Token) {
 	if p.currToken != nil {
@@ -430,5 +364,23 @@ func (p *Parser) unexpected(found, expected string) error {
 		_, file, line, _ := runtime.Caller(1)
 		debug = fmt.Sprintf(" at %s:%d", file, line)
 	}
-	return fmt.Errorf("%v: found %q but expected [%s], debug info %s", p.scanner.Position, found, expected, debug)
+	// the last scanned token is unexpected if nothing is consumed after it, otherwise it's the next character
+	pos := p.lexer.pos()
+	if p.lexer.tokEnd == p.lexer.offset {
+		pos = p.lexer.tokPos
+	}
+	return &ParseError{
+		Pos: pos,
+		Msg: fmt.Sprintf("found %q but expected [%s], debug info %s", found, expected, debug),
+	}
+}
+
+// ParseError is returned when source is not a valid thrift definition, Pos is where parser finds the error.
+type ParseError struct {
+	Pos scanner.Position
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Pos, e.Msg)
 }
@@ -3,6 +3,7 @@ package thrifter
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func newParserOn(def string) *Parser {
@@ -148,8 +149,9 @@ func TestNextString_errString(t *testing.T) {
 }
 
 func TestNextComment_singleLineBasic(t *testing.T) {
-	parser := newParserOn(`/123123 asasd
+	parser := newParserOn(`//123123 asasd
 	`)
+	parser.lexer.next() // consume first /
 	tok, _ := parser.nextComment(SINGLE_LINE_COMMENT)
 
 	if got, want := tok.Type, T_COMMENT; got != want {
@@ -164,8 +166,9 @@ func TestNextComment_singleLineBasic(t *testing.T) {
 }
 
 func TestNextComment_bashBasic(t *testing.T) {
-	parser := newParserOn(`123123 asasd
+	parser := newParserOn(`#123123 asasd
 	`)
+	parser.lexer.next() // consume #
 	tok, _ := parser.nextComment(BASH_LIKE_COMMENT)
 
 	if got, want := tok.Type, T_COMMENT; got != want {
@@ -180,8 +183,9 @@ func TestNextComment_bashBasic(t *testing.T) {
 }
 
 func TestNextComment_multiLineBasic(t *testing.T) {
-	parser := newParserOn(`*123123 asasd
+	parser := newParserOn(`/*123123 asasd
 	*/`)
+	parser.lexer.next() // consume /
 	tok, _ := parser.nextComment(MULTI_LINE_COMMENT)
 
 	if got, want := tok.Type, T_COMMENT; got != want {
@@ -198,9 +202,9 @@ func TestNextComment_multiLineBasic(t *testing.T) {
 }
 
 func TestIsComment_multiLineBasic(t *testing.T) {
-	parser := newParserOn(`*123123 asasd
+	parser := newParserOn(`/*123123 asasd
 	*/`)
-	_, ct := parser.isComment('/')
+	_, ct := parser.isComment(parser.lexer.next())
 	tok, _ := parser.nextComment(ct)
 
 	if got, want := tok.Type, T_COMMENT; got != want {
@@ -263,3 +267,75 @@ func TestIsComment_nextNumberNegativeFloat(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 }
+
+func TestParse_missingIdent(t *testing.T) {
+	for _, def := range []string{
+		"struct {",
+		"struct A {\n  1: i64 }",
+		"enum {}",
+		"enum E { = 1 }",
+		"const i32 = 1",
+		"const i32 A = }",
+		"service { }",
+		"service A extends { }",
+		"service A { void () }",
+		"service A { oneway () }",
+		"typedef i64 {",
+		"namespace { }",
+	} {
+		_, err := newParserOn(def).Parse("")
+		if err == nil || !strings.Contains(err.Error(), "identifier") {
+			t.Errorf("%q: got [%v] want [%v]", def, err, "identifier")
+		}
+	}
+}
+
+func TestParse_slash(t *testing.T) {
+	// a slash which doesn't start a comment is either kept in the token chain or reported
+	for _, def := range []string{
+		"struct A {\n  1: i32 a /\n}\n",
+		"struct A {\n  1: i32 a = 1 /\n}\n",
+		"enum E {\n  A /\n}\n",
+		"service S {\n  void f() / \n}\n",
+	} {
+		res, err := newParserOn(def).Parse("")
+		if err != nil {
+			continue
+		}
+		if got, want := res.String(), def; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+}
+
+func TestParse_unexpectedEOF(t *testing.T) {
+	for _, def := range []string{
+		"struct A {} (",
+		"enum E { A (",
+		"struct A { 1: i32 a (x",
+		"struct A { 1: i32 a (x = 'y',",
+		"const i32 A = [",
+		"const list<i32> A = [1, 2",
+		"struct A { 1: list<i32> a = [",
+		"service S { void f(",
+		"service S { void f() (a",
+		"struct A {",
+		"enum E {",
+		"service S {",
+		"const map<i32, i32> A = {",
+	} {
+		done := make(chan error)
+		go func() {
+			_, err := newParserOn(def).Parse("")
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil || !strings.Contains(err.Error(), "EOF") {
+				t.Errorf("%q: got [%v] want [%v]", def, err, "EOF")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q: parser doesn't stop at EOF", def)
+		}
+	}
+}
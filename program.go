@@ -0,0 +1,186 @@
+package thrifter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Program is a set of thrift files linked by includes, which is able to resolve identifiers across files.
+// It's not safe for concurrent use.
+type Program struct {
+	Files       map[string]*Thrift // cleaned file path => file
+	IncludeDirs []string           // directories to search included files, after the directory of including file
+}
+
+func NewProgram(includeDirs ...string) *Program {
+	return &Program{
+		Files:       map[string]*Thrift{},
+		IncludeDirs: includeDirs,
+	}
+}
+
+// Load parses the file at path and files it includes transitively, files already in program are not parsed again.
+// Errors of included files are joined into the returned error, the result is still valid as long as the file itself is parsed.
+func (p *Program) Load(path string) (res *Thrift, err error) {
+	path = filepath.Clean(path)
+	if res = p.Files[path]; res != nil {
+		return
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res, err = NewParserBytes(src, false).Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	err = p.Add(res)
+	return
+}
+
+// Add adds a parsed file to program, replacing the file with the same FileName, and loads files it includes.
+func (p *Program) Add(file *Thrift) error {
+	p.Files[filepath.Clean(file.FileName)] = file
+	var errs []error
+	for _, inc := range file.Includes() {
+		path, ok := p.IncludePath(file, inc)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%v: included file %q not found", inc.StartToken.Pos, inc.FilePath))
+			continue
+		}
+		if _, err := p.Load(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IncludePath returns cleaned path of the file included by inc, it's searched in the directory of from, then IncludeDirs.
+func (p *Program) IncludePath(from *Thrift, inc *Include) (string, bool) {
+	var candidates []string
+	if filepath.IsAbs(inc.FilePath) {
+		candidates = append(candidates, inc.FilePath)
+	} else {
+		candidates = append(candidates, filepath.Join(filepath.Dir(from.FileName), inc.FilePath))
+		for _, dir := range p.IncludeDirs {
+			candidates = append(candidates, filepath.Join(dir, inc.FilePath))
+		}
+	}
+	for _, path := range candidates {
+		path = filepath.Clean(path)
+		if _, ok := p.Files[path]; ok {
+			return path, true
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// Included returns the file included by from whose include prefix is prefix, or nil if not found.
+func (p *Program) Included(from *Thrift, prefix string) *Thrift {
+	for _, inc := range from.Includes() {
+		if inc.Prefix() != prefix {
+			continue
+		}
+		if path, ok := p.IncludePath(from, inc); ok {
+			if res := p.Files[path]; res != nil {
+				return res
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve finds the declaration referred by name in file from, name can be qualified by include prefix, e.g. shared.User, and can refer to enum elements, e.g. Numberz.ONE or shared.Numberz.ONE.
+// It returns the declaration and the file it belongs to, or nil if not found.
+func (p *Program) Resolve(from *Thrift, name string) (Node, *Thrift) {
+	if decl := lookupDeclaration(from, name); decl != nil {
+		return decl, from
+	}
+	if dot := strings.IndexByte(name, '.'); dot > 0 {
+		if file := p.Included(from, name[:dot]); file != nil {
+			if decl := lookupDeclaration(file, name[dot+1:]); decl != nil {
+				return decl, file
+			}
+		}
+	}
+	return nil, nil
+}
+
+func lookupDeclaration(file *Thrift, name string) Node {
+	if decl := file.Declaration(name); decl != nil {
+		return decl
+	}
+	if dot := strings.LastIndexByte(name, '.'); dot > 0 {
+		if enum, ok := file.Declaration(name[:dot]).(*Enum); ok {
+			if elem := enum.ElementByName(name[dot+1:]); elem != nil {
+				return elem
+			}
+		}
+	}
+	return nil
+}
+
+// Reference is an identifier referring to a declaration, e.g. a FieldType of struct, a ConstValue of enum element, or a service name after extends.
+type Reference struct {
+	File   *Thrift
+	Node   Node   // FieldType, ConstValue, or Service for extends
+	Token  *Token // the identifier token
+	Target Node   // resolved declaration, nil if not found
+}
+
+// FileReferences returns all references in file, in order of their position.
+func (p *Program) FileReferences(file *Thrift) (res []Reference) {
+	Inspect(file, func(node Node) bool {
+		var tok *Token
+		switch n := node.(type) {
+		case *FieldType:
+			tok = IdentToken(n)
+		case *ConstValue:
+			// true/false are parsed as identifiers
+			if n.Type == CONST_VALUE_IDENT && n.Value != "true" && n.Value != "false" {
+				tok = IdentToken(n)
+			}
+		case *Service:
+			tok = ExtendsToken(n)
+		}
+		if tok != nil {
+			target, _ := p.Resolve(file, tok.Raw)
+			res = append(res, Reference{File: file, Node: node, Token: tok, Target: target})
+		}
+		return true
+	})
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Token.Start < res[j].Token.Start
+	})
+	return
+}
+
+// References returns references to decl across all files in program, sorted by file name and position.
+// For an enum, references to its elements are included too.
+func (p *Program) References(decl Node) (res []Reference) {
+	paths := make([]string, 0, len(p.Files))
+	for path := range p.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, ref := range p.FileReferences(p.Files[path]) {
+			if ref.Target == nil {
+				continue
+			}
+			if ref.Target == decl {
+				res = append(res, ref)
+			} else if elem, ok := ref.Target.(*EnumElement); ok && elem.Parent == decl {
+				res = append(res, ref)
+			}
+		}
+	}
+	return
+}
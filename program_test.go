@@ -0,0 +1,98 @@
+package thrifter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writes files into a temporary directory and returns the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestProgram_resolve(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.thrift": `include "shared.thrift"
+		include "common/base.thrift"
+		struct User {
+			1: shared.Color color = shared.Color.RED
+			2: base.Id id
+			3: Local local
+		}
+		struct Local {}
+		service S extends shared.Svc {}`,
+		"shared.thrift": `enum Color {
+			RED
+		}
+		service Svc {}`,
+		"inc/common/base.thrift": `typedef i64 Id`,
+	})
+	program := NewProgram(filepath.Join(dir, "inc"))
+	main, err := program.Load(filepath.Join(dir, "main.thrift"))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if got, want := len(program.Files), 3; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	shared := program.Files[filepath.Join(dir, "shared.thrift")]
+
+	refs := program.FileReferences(main)
+	want := []struct {
+		raw    string
+		target Node
+	}{
+		{"shared.Color", shared.Declaration("Color")},
+		{"shared.Color.RED", shared.Declaration("Color").(*Enum).ElementByName("RED")},
+		{"base.Id", program.Files[filepath.Join(dir, "inc/common/base.thrift")].Declaration("Id")},
+		{"Local", main.Declaration("Local")},
+		{"shared.Svc", shared.Declaration("Svc")},
+	}
+	if got, want := len(refs), len(want); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+		return
+	}
+	for i, ref := range refs {
+		if got, want := ref.Token.Raw, want[i].raw; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+		if ref.Target == nil || ref.Target != want[i].target {
+			t.Errorf("%s: got [%v] want [%v]", ref.Token.Raw, ref.Target, want[i].target)
+		}
+	}
+
+	if got, want := len(program.References(shared.Declaration("Color"))), 2; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if decl, _ := program.Resolve(main, "shared.Unknown"); decl != nil {
+		t.Errorf("got [%v] want [nil]", decl)
+	}
+}
+
+func TestProgram_missingInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.thrift": `include "missing.thrift"
+		struct A {}`,
+	})
+	program := NewProgram()
+	main, err := program.Load(filepath.Join(dir, "main.thrift"))
+	if err == nil {
+		t.Errorf("expect error of missing include")
+	}
+	if main == nil || main.Declaration("A") == nil {
+		t.Errorf("expect main file to be loaded")
+	}
+}
@@ -0,0 +1,238 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+var validKinds = map[string]bool{
+	"file": true, "struct": true, "union": true, "exception": true, "enum": true, "element": true, "service": true, "function": true,
+	"field": true, "arg": true, "throws": true, "const": true, "typedef": true, "include": true, "namespace": true, "option": true,
+}
+
+// Kind returns the kind of node used in selectors, e.g. struct or arg, or "" if node can't be selected, e.g. FieldType or ConstValue.
+func Kind(node thrifter.Node) string {
+	switch n := node.(type) {
+	case *thrifter.Thrift:
+		return "file"
+	case *thrifter.Struct:
+		return strings.ToLower(n.NodeType())
+	case *thrifter.Enum:
+		return "enum"
+	case *thrifter.EnumElement:
+		return "element"
+	case *thrifter.Service:
+		return "service"
+	case *thrifter.Function:
+		return "function"
+	case *thrifter.Field:
+		if fn, ok := n.Parent.(*thrifter.Function); ok {
+			for _, throw := range fn.Throws {
+				if throw == n {
+					return "throws"
+				}
+			}
+			return "arg"
+		}
+		return "field"
+	case *thrifter.Const:
+		return "const"
+	case *thrifter.TypeDef:
+		return "typedef"
+	case *thrifter.Include:
+		return "include"
+	case *thrifter.Namespace:
+		return "namespace"
+	case *thrifter.Option:
+		return "option"
+	}
+	return ""
+}
+
+func isKind(node thrifter.Node, kind string) bool {
+	k := Kind(node)
+	return k == kind || kind == "field" && (k == "arg" || k == "throws")
+}
+
+// Attr returns the value of attribute name of node, and whether node has it. Attributes are:
+//
+//	name          identifier of declarations, fields and functions, language of namespace, prefix of include, or name of option
+//	id            field id
+//	requiredness  required, optional or default of fields
+//	type          type of fields, consts and typedefs, or return type of functions, without white spaces, e.g. map<string,i64>
+//	default       default value of fields
+//	value         value of consts, enum elements, namespaces and options, enum elements without explicit value are counted
+//	extends       service extended by a service
+//	oneway        true or false for functions
+//	args, throws  number of arguments and exceptions of functions
+//	path          file path of includes or files
+//	doc           documentation comment, see thrifter.NodeCommonField.Doc
+//	@annotation   value of annotation, e.g. @go.tag
+func Attr(node thrifter.Node, name string) (string, bool) {
+	if strings.HasPrefix(name, "@") {
+		for _, option := range options(node) {
+			if option.Name == name[1:] {
+				return unquote(option.Value), true
+			}
+		}
+		return "", false
+	}
+	if name == "doc" {
+		if doc := node.CommonField().Doc(); doc != "" {
+			return doc, true
+		}
+		return "", false
+	}
+	switch n := node.(type) {
+	case *thrifter.Thrift:
+		if name == "path" {
+			return n.FileName, true
+		}
+	case *thrifter.Struct:
+		if name == "name" {
+			return n.Ident, true
+		}
+	case *thrifter.Enum:
+		if name == "name" {
+			return n.Ident, true
+		}
+	case *thrifter.EnumElement:
+		switch name {
+		case "name":
+			return n.Ident, true
+		case "value":
+			return strconv.Itoa(n.Value()), true
+		}
+	case *thrifter.Service:
+		switch name {
+		case "name":
+			return n.Ident, true
+		case "extends":
+			return n.Extends, n.Extends != ""
+		}
+	case *thrifter.Function:
+		switch name {
+		case "name":
+			return n.Ident, true
+		case "type":
+			if n.Void {
+				return "void", true
+			}
+			return typeString(n.FunctionType), true
+		case "oneway":
+			return strconv.FormatBool(n.Oneway), true
+		case "args":
+			return strconv.Itoa(len(n.Args)), true
+		case "throws":
+			return strconv.Itoa(len(n.Throws)), true
+		}
+	case *thrifter.Field:
+		switch name {
+		case "name":
+			return n.Ident, true
+		case "id":
+			return strconv.Itoa(n.ID), true
+		case "requiredness":
+			if n.Requiredness == "" {
+				return "default", true
+			}
+			return n.Requiredness, true
+		case "type":
+			return typeString(n.FieldType), true
+		case "default":
+			if n.DefaultValue == nil {
+				return "", false
+			}
+			return strings.Join(strings.Fields(n.DefaultValue.String()), " "), true
+		}
+	case *thrifter.Const:
+		switch name {
+		case "name":
+			return n.Ident, true
+		case "type":
+			return typeString(n.Type), true
+		case "value":
+			return strings.Join(strings.Fields(n.Value.String()), " "), true
+		}
+	case *thrifter.TypeDef:
+		switch name {
+		case "name":
+			return n.Ident, true
+		case "type":
+			return typeString(n.Type), true
+		}
+	case *thrifter.Include:
+		switch name {
+		case "name":
+			return n.Prefix(), true
+		case "path":
+			return n.FilePath, true
+		}
+	case *thrifter.Namespace:
+		switch name {
+		case "name":
+			return n.Name, true
+		case "value":
+			return n.Value, true
+		}
+	case *thrifter.Option:
+		switch name {
+		case "name":
+			return n.Name, true
+		case "value":
+			return unquote(n.Value), true
+		}
+	}
+	return "", false
+}
+
+func options(node thrifter.Node) []*thrifter.Option {
+	switch n := node.(type) {
+	case *thrifter.Struct:
+		return n.Options
+	case *thrifter.Enum:
+		return n.Options
+	case *thrifter.EnumElement:
+		return n.Options
+	case *thrifter.Service:
+		return n.Options
+	case *thrifter.Function:
+		return n.Options
+	case *thrifter.Field:
+		return n.Options
+	case *thrifter.TypeDef:
+		return n.Options
+	case *thrifter.Namespace:
+		return n.Options
+	}
+	return nil
+}
+
+// type without white spaces and annotations, e.g. map<string,list<i64>>
+func typeString(ft *thrifter.FieldType) string {
+	if ft == nil {
+		return ""
+	}
+	switch ft.Type {
+	case thrifter.FIELD_TYPE_BASE:
+		return ft.BaseType
+	case thrifter.FIELD_TYPE_IDENT:
+		return ft.Ident
+	case thrifter.FIELD_TYPE_MAP:
+		return "map<" + typeString(ft.Map.Key) + "," + typeString(ft.Map.Value) + ">"
+	case thrifter.FIELD_TYPE_LIST:
+		return "list<" + typeString(ft.List.Elem) + ">"
+	case thrifter.FIELD_TYPE_SET:
+		return "set<" + typeString(ft.Set.Elem) + ">"
+	}
+	return ""
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,459 @@
+// Package query selects nodes of thrift definitions by CSS-like selectors, e.g.
+//
+//	struct > field[requiredness=optional][type=i64][name$=_id]
+//	service[extends=BaseService] > function:not(:has(> throws))
+//
+// A selector is a list of complex selectors separated by commas, a complex selector is compound selectors joined by combinators,
+// i.e. white spaces for descendants and > for children, and a compound selector is an optional kind followed by attribute selectors and pseudo-classes.
+//
+// Kinds are file, struct, union, exception, enum, element, service, function, field, arg, throws, const, typedef, include, namespace and option, or * for any of them.
+// field matches fields of structs, arguments and exceptions of functions, while arg and throws match only the latter two.
+//
+// Attribute selectors are [attr] for existence, and [attr op value] where op is one of =, !=, ^= (prefix), $= (suffix), *= (substring), ~= (regular expression),
+// or <, <=, >, >= for numbers. Values can be quoted by ' or ", otherwise they span until ].
+// Attributes are listed in Attr, annotations are selected by @ followed by their names, e.g. [@go.tag].
+//
+// Pseudo-classes are :not(selector), and :has(selector) which matches if a descendant matches selector relative to the node, e.g. :has(> throws) for children.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Selector is a compiled query, it's safe for concurrent use.
+type Selector struct {
+	expr string
+	alts []*complexSelector
+}
+
+// SyntaxError is returned when a query is malformed, Offset is the byte offset in the query where the error is found.
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("query: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Query returns nodes under root, including root itself, matching expr in depth-first order.
+func Query(root thrifter.Node, expr string) ([]thrifter.Node, error) {
+	sel, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return sel.Select(root), nil
+}
+
+// Compile parses expr into a Selector.
+func Compile(expr string) (*Selector, error) {
+	p := &parser{src: expr}
+	res, err := p.selectorList(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.offset < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.offset:])
+	}
+	res.expr = expr
+	return res, nil
+}
+
+// MustCompile is like Compile but panics if expr is malformed.
+func MustCompile(expr string) *Selector {
+	res, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (s *Selector) String() string {
+	return s.expr
+}
+
+// Select returns nodes under root, including root itself, matching the selector in depth-first order.
+func (s *Selector) Select(root thrifter.Node) (res []thrifter.Node) {
+	thrifter.Inspect(root, func(node thrifter.Node) bool {
+		if s.Match(node) {
+			res = append(res, node)
+		}
+		return true
+	})
+	return
+}
+
+// Match reports whether node matches the selector, ancestors of node are matched against combinators regardless of where selecting starts.
+func (s *Selector) Match(node thrifter.Node) bool {
+	return s.match(node, nil)
+}
+
+func (s *Selector) match(node thrifter.Node, scope thrifter.Node) bool {
+	if Kind(node) == "" {
+		return false
+	}
+	for _, alt := range s.alts {
+		if alt.matchAt(len(alt.parts)-1, node, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+type complexSelector struct {
+	parts []part
+}
+
+// compound selector with the combinator before it
+type part struct {
+	combinator byte // ' ' for descendant, '>' for child, 0 for the first one
+	scope      bool // matches the node of :has only
+	kind       string
+	attrs      []attrSelector
+	pseudos    []pseudoClass
+}
+
+type attrSelector struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+	num   float64
+}
+
+type pseudoClass struct {
+	name string
+	sel  *Selector
+}
+
+func (c *complexSelector) matchAt(i int, node thrifter.Node, scope thrifter.Node) bool {
+	p := &c.parts[i]
+	if !p.match(node, scope) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch p.combinator {
+	case '>':
+		parent := parentOf(node)
+		return parent != nil && c.matchAt(i-1, parent, scope)
+	default:
+		for ancestor := parentOf(node); ancestor != nil; ancestor = parentOf(ancestor) {
+			if c.matchAt(i-1, ancestor, scope) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (p *part) match(node thrifter.Node, scope thrifter.Node) bool {
+	if p.scope {
+		return node == scope
+	}
+	if p.kind != "" && p.kind != "*" && !isKind(node, p.kind) {
+		return false
+	}
+	for _, attr := range p.attrs {
+		if !attr.match(node) {
+			return false
+		}
+	}
+	for _, pseudo := range p.pseudos {
+		switch pseudo.name {
+		case "not":
+			if pseudo.sel.match(node, scope) {
+				return false
+			}
+		case "has":
+			found := false
+			thrifter.Inspect(node, func(n thrifter.Node) bool {
+				if !found && n != node && pseudo.sel.match(n, node) {
+					found = true
+				}
+				return !found
+			})
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (a *attrSelector) match(node thrifter.Node) bool {
+	value, ok := Attr(node, a.name)
+	if !ok {
+		return false
+	}
+	switch a.op {
+	case "":
+		return true
+	case "=":
+		return value == a.value
+	case "!=":
+		return value != a.value
+	case "^=":
+		return strings.HasPrefix(value, a.value)
+	case "$=":
+		return strings.HasSuffix(value, a.value)
+	case "*=":
+		return strings.Contains(value, a.value)
+	case "~=":
+		return a.re.MatchString(value)
+	}
+	num, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	switch a.op {
+	case "<":
+		return num < a.num
+	case "<=":
+		return num <= a.num
+	case ">":
+		return num > a.num
+	case ">=":
+		return num >= a.num
+	}
+	return false
+}
+
+// the nearest ancestor which can be selected
+func parentOf(node thrifter.Node) thrifter.Node {
+	for {
+		node = node.CommonField().Parent
+		if node == nil || Kind(node) != "" {
+			return node
+		}
+	}
+}
+
+type parser struct {
+	src    string
+	offset int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Offset: p.offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) peek() byte {
+	if p.offset < len(p.src) {
+		return p.src[p.offset]
+	}
+	return 0
+}
+
+func (p *parser) skipSpaces() bool {
+	start := p.offset
+	for p.offset < len(p.src) && isSpace(p.src[p.offset]) {
+		p.offset++
+	}
+	return p.offset > start
+}
+
+// selectorList parses complex selectors separated by commas, relative selectors start with the node of :has.
+func (p *parser) selectorList(relative bool) (*Selector, error) {
+	res := &Selector{}
+	for {
+		p.skipSpaces()
+		alt, err := p.complexSelector(relative)
+		if err != nil {
+			return nil, err
+		}
+		res.alts = append(res.alts, alt)
+		p.skipSpaces()
+		if p.peek() != ',' {
+			return res, nil
+		}
+		p.offset++
+	}
+}
+
+func (p *parser) complexSelector(relative bool) (*complexSelector, error) {
+	res := &complexSelector{}
+	var combinator byte
+	if relative {
+		res.parts = append(res.parts, part{scope: true})
+		combinator = ' '
+		if p.peek() == '>' {
+			combinator = '>'
+			p.offset++
+			p.skipSpaces()
+		}
+	}
+	for {
+		compound, err := p.compound()
+		if err != nil {
+			return nil, err
+		}
+		compound.combinator = combinator
+		res.parts = append(res.parts, compound)
+
+		spaces := p.skipSpaces()
+		switch c := p.peek(); {
+		case c == '>':
+			combinator = '>'
+			p.offset++
+			p.skipSpaces()
+		case spaces && c != 0 && c != ',' && c != ')':
+			combinator = ' '
+		default:
+			return res, nil
+		}
+	}
+}
+
+func (p *parser) compound() (res part, err error) {
+	start := p.offset
+	if p.peek() == '*' {
+		p.offset++
+		res.kind = "*"
+	} else if name := p.name(); name != "" {
+		if !validKinds[name] {
+			p.offset = start
+			return res, p.errorf("unknown kind %q", name)
+		}
+		res.kind = name
+	}
+	for {
+		switch p.peek() {
+		case '[':
+			attr, err := p.attrSelector()
+			if err != nil {
+				return res, err
+			}
+			res.attrs = append(res.attrs, attr)
+		case ':':
+			pseudo, err := p.pseudoClass()
+			if err != nil {
+				return res, err
+			}
+			res.pseudos = append(res.pseudos, pseudo)
+		default:
+			if p.offset == start {
+				if p.offset >= len(p.src) {
+					return res, p.errorf("selector expected")
+				}
+				return res, p.errorf("unexpected %q", p.src[p.offset:])
+			}
+			return res, nil
+		}
+	}
+}
+
+func (p *parser) attrSelector() (res attrSelector, err error) {
+	p.offset++ // consume [
+	p.skipSpaces()
+	if p.peek() == '@' {
+		p.offset++
+		res.name = "@"
+	}
+	res.name += p.name()
+	if res.name == "" || res.name == "@" {
+		return res, p.errorf("attribute name expected")
+	}
+	p.skipSpaces()
+	for _, op := range []string{"!=", "^=", "$=", "*=", "~=", "<=", ">=", "=", "<", ">"} {
+		if strings.HasPrefix(p.src[p.offset:], op) {
+			res.op = op
+			p.offset += len(op)
+			break
+		}
+	}
+	if res.op != "" {
+		p.skipSpaces()
+		valueStart := p.offset
+		if res.value, err = p.value(); err != nil {
+			return
+		}
+		if res.name == "type" {
+			res.value = strings.Join(strings.Fields(res.value), "")
+		}
+		switch res.op {
+		case "~=":
+			if res.re, err = regexp.Compile(res.value); err != nil {
+				p.offset = valueStart
+				return res, p.errorf("invalid regular expression: %v", err)
+			}
+		case "<", "<=", ">", ">=":
+			if res.num, err = strconv.ParseFloat(res.value, 64); err != nil {
+				p.offset = valueStart
+				return res, p.errorf("number expected for %s", res.op)
+			}
+		}
+	}
+	p.skipSpaces()
+	if p.peek() != ']' {
+		return res, p.errorf("] expected")
+	}
+	p.offset++
+	return res, nil
+}
+
+// quoted value, or raw value until ]
+func (p *parser) value() (string, error) {
+	if quote := p.peek(); quote == '"' || quote == '\'' {
+		end := strings.IndexByte(p.src[p.offset+1:], quote)
+		if end < 0 {
+			return "", p.errorf("unterminated string")
+		}
+		res := p.src[p.offset+1 : p.offset+1+end]
+		p.offset += end + 2
+		return res, nil
+	}
+	end := strings.IndexByte(p.src[p.offset:], ']')
+	if end < 0 {
+		return "", p.errorf("] expected")
+	}
+	res := strings.TrimSpace(p.src[p.offset : p.offset+end])
+	p.offset += end
+	return res, nil
+}
+
+func (p *parser) pseudoClass() (res pseudoClass, err error) {
+	p.offset++ // consume :
+	start := p.offset
+	res.name = p.name()
+	if res.name != "not" && res.name != "has" {
+		p.offset = start
+		return res, p.errorf("unknown pseudo-class %q", res.name)
+	}
+	if p.peek() != '(' {
+		return res, p.errorf("( expected")
+	}
+	p.offset++
+	if res.sel, err = p.selectorList(res.name == "has"); err != nil {
+		return
+	}
+	if p.peek() != ')' {
+		return res, p.errorf(") expected")
+	}
+	p.offset++
+	return res, nil
+}
+
+// name of kind, attribute or pseudo-class, which can contain dots, e.g. annotation names
+func (p *parser) name() string {
+	start := p.offset
+	for p.offset < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.offset:])
+		if !(r == '_' || r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= utf8.RuneSelf) {
+			break
+		}
+		p.offset += size
+	}
+	return p.src[start:p.offset]
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
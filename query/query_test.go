@@ -0,0 +1,124 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+const src = `namespace go audit
+
+struct User {
+  1: required i64 user_id
+  2: optional i64 group_id
+  3: optional string name (go.tag = 'json:"name"')
+  4: optional map<string, list<i64>> scores
+}
+
+/** deprecated, use User */
+union Account {
+  1: i64 account_id
+}
+
+exception NotFound {
+  1: string message
+}
+
+enum Color {
+  RED
+  GREEN = 5
+  BLUE
+}
+
+service BaseService {
+  void ping()
+}
+
+service UserService extends BaseService {
+  User get(1: i64 user_id) throws (1: NotFound e)
+  oneway void touch(1: i64 user_id)
+  list<User> all()
+}
+`
+
+func parse(t *testing.T) *thrifter.Thrift {
+	t.Helper()
+	res, err := thrifter.NewParserBytes([]byte(src), false).Parse("audit.thrift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestQuery(t *testing.T) {
+	file := parse(t)
+	cases := []struct {
+		expr string
+		want []string
+	}{
+		{"struct > field[requiredness=optional][type=i64]", []string{"Struct(User).Field(2)"}},
+		{"field[name$=_id]", []string{"Struct(User).Field(1)", "Struct(User).Field(2)", "Union(Account).Field(1)", "Service(UserService).Function(get).Arg(1)", "Service(UserService).Function(touch).Arg(1)"}},
+		{"struct field[name$=_id]", []string{"Struct(User).Field(1)", "Struct(User).Field(2)"}},
+		{"service[extends=BaseService] > function:not(:has(> throws))", []string{"Service(UserService).Function(touch)", "Service(UserService).Function(all)"}},
+		{"service function[throws=0][oneway=false]", []string{"Service(BaseService).Function(ping)", "Service(UserService).Function(all)"}},
+		{"function[type='list<User>']", []string{"Service(UserService).Function(all)"}},
+		{"field[type='map<string, list<i64>>']", []string{"Struct(User).Field(4)"}},
+		{"throws", []string{"Service(UserService).Function(get).Throws(1)"}},
+		{"arg[id>=1]", []string{"Service(UserService).Function(get).Arg(1)", "Service(UserService).Function(touch).Arg(1)"}},
+		{"element[value>5]", []string{"Enum(Color).EnumElement(BLUE)"}},
+		{"enum > element[name~=^(RED|BLUE)$]", []string{"Enum(Color).EnumElement(RED)", "Enum(Color).EnumElement(BLUE)"}},
+		{"field[@go.tag^='json:']", []string{"Struct(User).Field(3)"}},
+		{"*[doc*=deprecated]", []string{"Union(Account)"}},
+		{"union, exception", []string{"Union(Account)", "Exception(NotFound)"}},
+		{"file > namespace[name=go][value=audit]", []string{"Namespace(go)"}},
+		{"struct:has(field[requiredness=required])", []string{"Struct(User)"}},
+		{"service[extends]", []string{"Service(UserService)"}},
+	}
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			nodes, err := Query(file, c.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, node := range nodes {
+				got = append(got, node.CommonField().NodeID)
+			}
+			if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+				t.Errorf("got %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCompile_errors(t *testing.T) {
+	cases := []struct {
+		expr   string
+		offset int
+	}{
+		{"", 0},
+		{"table", 0},
+		{"struct >", 8},
+		{"field[", 6},
+		{"field[id", 8},
+		{"field[id>x]", 9},
+		{"field[name~=(]", 12},
+		{"field[name='a]", 11},
+		{"struct:is(field)", 7},
+		{"struct:has(field", 16},
+		{"struct, ", 8},
+		{"struct )", 7},
+	}
+	for _, c := range cases {
+		_, err := Compile(c.expr)
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("Compile(%q) error = %v, want SyntaxError", c.expr, err)
+			continue
+		}
+		if serr.Offset != c.offset {
+			t.Errorf("Compile(%q) error = %v, want offset %d", c.expr, err, c.offset)
+		}
+	}
+}
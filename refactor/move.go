@@ -0,0 +1,391 @@
+package refactor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Move moves top-level declarations, along with their leading comments and trailing comment, from their files to the end of file to.
+// References in all files of program are rewritten to the qualified form, e.g. shared.User, or to the bare name when the reference and the declaration end up in the same file.
+// Includes are added where needed, and includes used before but not anymore are removed.
+// Tokens are spliced between token chains, so formatting of moved declarations is kept, then changed files are relocated and reindexed.
+// It returns changed files sorted by file name, and changes nothing if an error is returned, e.g. a name conflict in to, or moving would introduce circular includes.
+func Move(program *thrifter.Program, to *thrifter.Thrift, decls ...thrifter.Node) ([]*thrifter.Thrift, error) {
+	if program.Files[filepath.Clean(to.FileName)] != to {
+		return nil, fmt.Errorf("file %s is not in program", to.FileName)
+	}
+	m := &mover{
+		program: program,
+		to:      to,
+		moved:   map[thrifter.Node]*thrifter.Thrift{},
+		changed: map[*thrifter.Thrift]bool{},
+	}
+	if err := m.plan(decls); err != nil {
+		return nil, err
+	}
+	m.apply()
+	return finish(m.changed), nil
+}
+
+// Split moves declarations of file from into a new file at path, which has the same namespaces as from.
+// The new file is added to program, and it's returned along with changed files, see Move for details.
+func Split(program *thrifter.Program, from *thrifter.Thrift, path string, decls ...thrifter.Node) (*thrifter.Thrift, []*thrifter.Thrift, error) {
+	path = filepath.Clean(path)
+	if _, ok := program.Files[path]; ok {
+		return nil, nil, fmt.Errorf("file %s already exists", path)
+	}
+	for _, decl := range decls {
+		if decl.CommonField().Parent != from {
+			return nil, nil, fmt.Errorf("%s %s is not a declaration of %s", strings.ToLower(decl.NodeType()), declarationIdent(decl), from.FileName)
+		}
+	}
+	var src strings.Builder
+	for _, node := range from.Nodes {
+		if ns, ok := node.(*thrifter.Namespace); ok {
+			src.WriteString(ns.String())
+			src.WriteString("\n")
+		}
+	}
+	file, err := thrifter.NewParserBytes([]byte(src.String()), false).Parse(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	program.Files[path] = file
+	changed, err := Move(program, file, decls...)
+	if err != nil {
+		delete(program.Files, path)
+		return nil, nil, err
+	}
+	return file, changed, nil
+}
+
+// MergeFiles moves all declarations of file from into file into, and removes from from program, see Move for details.
+// Includes and namespaces of from are dropped, the file itself is not deleted from disk.
+func MergeFiles(program *thrifter.Program, from *thrifter.Thrift, into *thrifter.Thrift) ([]*thrifter.Thrift, error) {
+	if from == into {
+		return nil, fmt.Errorf("can't merge %s into itself", from.FileName)
+	}
+	var decls []thrifter.Node
+	for _, node := range from.Nodes {
+		if declarationIdent(node) != "" {
+			decls = append(decls, node)
+		}
+	}
+	changed, err := Move(program, into, decls...)
+	if err != nil {
+		return nil, err
+	}
+	delete(program.Files, filepath.Clean(from.FileName))
+	res := changed[:0]
+	for _, file := range changed {
+		if file != from {
+			res = append(res, file)
+		}
+	}
+	return res, nil
+}
+
+type mover struct {
+	program *thrifter.Program
+	to      *thrifter.Thrift
+	decls   []thrifter.Node
+	moved   map[thrifter.Node]*thrifter.Thrift // moved declaration => file it comes from
+	changed map[*thrifter.Thrift]bool
+
+	rewrites []rewrite
+	includes map[*thrifter.Thrift][]string            // new include paths by file
+	prefixes map[*thrifter.Thrift]map[string]string   // include prefix by file and path of included file, after moving
+	drops    map[*thrifter.Thrift][]*thrifter.Include // includes not used anymore
+}
+
+type rewrite struct {
+	ref    thrifter.Reference
+	newRaw string
+}
+
+// a reference with its resolved files before and after moving
+type movedRef struct {
+	ref              thrifter.Reference
+	from, target     *thrifter.Thrift
+	newFrom, newTarg *thrifter.Thrift
+}
+
+func (m *mover) plan(decls []thrifter.Node) error {
+	for _, decl := range decls {
+		file, ok := decl.CommonField().Parent.(*thrifter.Thrift)
+		name := declarationIdent(decl)
+		if !ok || name == "" {
+			return fmt.Errorf("%s is not a top-level declaration", decl.NodeType())
+		}
+		if m.program.Files[filepath.Clean(file.FileName)] != file {
+			return fmt.Errorf("file %s is not in program", file.FileName)
+		}
+		if _, ok := m.moved[decl]; ok || file == m.to {
+			continue
+		}
+		if existing := m.to.Declaration(name); existing != nil {
+			return fmt.Errorf("%v: %s %s already declared", existing.CommonField().StartToken.Pos, strings.ToLower(existing.NodeType()), name)
+		}
+		for _, other := range m.decls {
+			if declarationIdent(other) == name {
+				return fmt.Errorf("%s is declared in both %s and %s", name, m.moved[other].FileName, file.FileName)
+			}
+		}
+		m.moved[decl] = file
+		m.decls = append(m.decls, decl)
+	}
+	if len(m.decls) == 0 {
+		return nil
+	}
+
+	// resolve all references before changing anything
+	var refs []movedRef
+	usedBefore := map[*thrifter.Thrift]map[*thrifter.Thrift]bool{}
+	usedAfter := map[*thrifter.Thrift]map[*thrifter.Thrift]bool{}
+	for _, path := range sortedPaths(m.program) {
+		for _, ref := range m.program.FileReferences(m.program.Files[path]) {
+			if ref.Target == nil {
+				continue
+			}
+			r := movedRef{ref: ref, from: ref.File, target: thrifter.Root(ref.Target)}
+			r.newFrom, r.newTarg = r.from, r.target
+			if _, ok := m.moved[thrifter.TopLevel(ref.Node)]; ok {
+				r.newFrom = m.to
+			}
+			if _, ok := m.moved[thrifter.TopLevel(ref.Target)]; ok {
+				r.newTarg = m.to
+			}
+			addEdge(usedBefore, r.from, r.target)
+			addEdge(usedAfter, r.newFrom, r.newTarg)
+			refs = append(refs, r)
+		}
+	}
+
+	// includes kept or added in files whose references change
+	m.includes = map[*thrifter.Thrift][]string{}
+	m.prefixes = map[*thrifter.Thrift]map[string]string{}
+	m.drops = map[*thrifter.Thrift][]*thrifter.Include{}
+	edges := map[*thrifter.Thrift]map[*thrifter.Thrift]bool{}
+	for _, path := range sortedPaths(m.program) {
+		file := m.program.Files[path]
+		prefixes := map[string]string{}
+		for _, inc := range file.Includes() {
+			target := m.included(file, inc)
+			if target != nil && usedBefore[file][target] && !usedAfter[file][target] {
+				m.drops[file] = append(m.drops[file], inc)
+				continue
+			}
+			if target != nil {
+				prefixes[filepath.Clean(target.FileName)] = inc.Prefix()
+				addEdge(edges, file, target)
+			}
+		}
+		m.prefixes[file] = prefixes
+	}
+	for _, path := range sortedPaths(m.program) {
+		file := m.program.Files[path]
+		for _, target := range sortedFiles(usedAfter[file]) {
+			if target == file || m.prefixes[file][filepath.Clean(target.FileName)] != "" {
+				continue
+			}
+			incPath := includePath(file, target)
+			prefix := strings.TrimSuffix(filepath.Base(incPath), filepath.Ext(incPath))
+			for other, p := range m.prefixes[file] {
+				if p == prefix {
+					return fmt.Errorf("%s can't include %s, prefix %s is taken by %s", file.FileName, target.FileName, prefix, other)
+				}
+			}
+			if reaches(edges, target, file) {
+				return fmt.Errorf("%s can't include %s, which includes it", file.FileName, target.FileName)
+			}
+			m.includes[file] = append(m.includes[file], incPath)
+			m.prefixes[file][filepath.Clean(target.FileName)] = prefix
+			addEdge(edges, file, target)
+		}
+	}
+
+	for _, r := range refs {
+		if r.newFrom == r.from && r.newTarg == r.target {
+			continue
+		}
+		name := qualifiedName(r.ref.Target)
+		if r.newFrom != r.newTarg {
+			name = m.prefixes[r.newFrom][filepath.Clean(r.newTarg.FileName)] + "." + name
+		}
+		if name != r.ref.Token.Raw {
+			m.rewrites = append(m.rewrites, rewrite{r.ref, name})
+		}
+	}
+	return nil
+}
+
+func (m *mover) apply() {
+	for _, rw := range m.rewrites {
+		setReference(rw.ref, rw.newRaw)
+		m.changed[rw.ref.File] = true
+	}
+	for _, decl := range m.decls {
+		from := m.moved[decl]
+		first, last := nodeSpan(decl)
+		cut(from, first, last)
+		removeNode(from, decl)
+		appendTokens(m.to, first, last)
+		m.to.Nodes = append(m.to.Nodes, decl)
+		decl.CommonField().Parent = m.to
+		m.changed[from] = true
+		m.changed[m.to] = true
+	}
+	for file, incs := range m.drops {
+		for _, inc := range incs {
+			first, last := nodeSpan(inc)
+			cut(file, first, last)
+			removeNode(file, inc)
+		}
+		m.changed[file] = true
+	}
+	for file, paths := range m.includes {
+		for _, path := range paths {
+			addInclude(file, path)
+		}
+		m.changed[file] = true
+	}
+}
+
+// the file included by inc, or nil if it's not loaded
+func (m *mover) included(from *thrifter.Thrift, inc *thrifter.Include) *thrifter.Thrift {
+	path, ok := m.program.IncludePath(from, inc)
+	if !ok {
+		return nil
+	}
+	return m.program.Files[path]
+}
+
+// addInclude adds an include after the last include of file, or before its first node if there are no includes.
+func addInclude(file *thrifter.Thrift, path string) {
+	inc := thrifter.NewInclude(&thrifter.Token{Type: thrifter.T_INCLUDE, Raw: "include", Value: "include"}, file)
+	inc.EndToken = &thrifter.Token{Type: thrifter.T_STRING, Raw: `"` + path + `"`, Value: path}
+	inc.FilePath = path
+	link(inc.StartToken, &thrifter.Token{Type: thrifter.T_SPACE, Raw: " ", Value: " "})
+	link(inc.StartToken.Next, inc.EndToken)
+	lineBreak := newLineBreak()
+	link(inc.EndToken, lineBreak)
+
+	idx := -1
+	for i, node := range file.Nodes {
+		if _, ok := node.(*thrifter.Include); ok {
+			idx = i
+		}
+	}
+	if idx >= 0 {
+		_, last := nodeSpan(file.Nodes[idx])
+		if last.Type != thrifter.T_LINEBREAK {
+			last = insertAfter(file, last, newLineBreak(), nil)
+		}
+		insertAfter(file, last, inc.StartToken, lineBreak)
+	} else {
+		var before *thrifter.Token
+		if len(file.Nodes) > 0 {
+			first, _ := nodeSpan(file.Nodes[0])
+			before = first.Prev
+			// separate the include from following nodes by a blank line
+			if blankLineFrom(first) == nil {
+				lineBreak = insertAfter(file, lineBreak, newLineBreak(), nil)
+			}
+		}
+		insertAfter(file, before, inc.StartToken, lineBreak)
+	}
+	file.Nodes = append(file.Nodes[:idx+1], append([]thrifter.Node{inc}, file.Nodes[idx+1:]...)...)
+}
+
+func removeNode(file *thrifter.Thrift, node thrifter.Node) {
+	for i, n := range file.Nodes {
+		if n == node {
+			file.Nodes = append(file.Nodes[:i], file.Nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+// name of declaration within its file, e.g. User or Color.RED for enum elements
+func qualifiedName(decl thrifter.Node) string {
+	if elem, ok := decl.(*thrifter.EnumElement); ok {
+		if enum, ok := elem.Parent.(*thrifter.Enum); ok {
+			return enum.Ident + "." + elem.Ident
+		}
+	}
+	return declarationIdent(decl)
+}
+
+func declarationIdent(decl thrifter.Node) string {
+	switch decl.(type) {
+	case *thrifter.Struct, *thrifter.Enum, *thrifter.Service, *thrifter.TypeDef, *thrifter.Const:
+		name, _ := declarationName(decl)
+		return name
+	}
+	return ""
+}
+
+// path to include target from file, relative to the directory of file if possible
+func includePath(file *thrifter.Thrift, target *thrifter.Thrift) string {
+	rel, err := filepath.Rel(filepath.Dir(file.FileName), target.FileName)
+	if err != nil {
+		return filepath.ToSlash(target.FileName)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func addEdge(edges map[*thrifter.Thrift]map[*thrifter.Thrift]bool, from *thrifter.Thrift, to *thrifter.Thrift) {
+	if from == to {
+		return
+	}
+	if edges[from] == nil {
+		edges[from] = map[*thrifter.Thrift]bool{}
+	}
+	edges[from][to] = true
+}
+
+// whether file to is reachable from file from through edges
+func reaches(edges map[*thrifter.Thrift]map[*thrifter.Thrift]bool, from *thrifter.Thrift, to *thrifter.Thrift) bool {
+	visited := map[*thrifter.Thrift]bool{}
+	var visit func(file *thrifter.Thrift) bool
+	visit = func(file *thrifter.Thrift) bool {
+		if file == to {
+			return true
+		}
+		if visited[file] {
+			return false
+		}
+		visited[file] = true
+		for next := range edges[file] {
+			if visit(next) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(from)
+}
+
+func sortedPaths(program *thrifter.Program) []string {
+	res := make([]string, 0, len(program.Files))
+	for path := range program.Files {
+		res = append(res, path)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func sortedFiles(files map[*thrifter.Thrift]bool) []*thrifter.Thrift {
+	res := make([]*thrifter.Thrift, 0, len(files))
+	for file := range files {
+		res = append(res, file)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].FileName < res[j].FileName
+	})
+	return res
+}
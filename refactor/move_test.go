@@ -0,0 +1,337 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+func TestMove(t *testing.T) {
+	program, dir := load(t, map[string]string{
+		"main.thrift": `include "shared.thrift"
+
+namespace go main
+
+// Profile of user
+struct Profile {
+  1: shared.User user // owner
+  2: Address address
+}
+
+struct Address {
+  1: string city
+}
+
+service ProfileService {
+  Profile get(1: i64 id)
+}
+`,
+		"shared.thrift": `struct User {
+  1: i64 id
+}
+`,
+	}, "main.thrift")
+	main, shared := program.Files[filepath.Join(dir, "main.thrift")], program.Files[filepath.Join(dir, "shared.thrift")]
+
+	changed, err := Move(program, shared, main.Declaration("Profile"), main.Declaration("Address"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 || changed[0] != main || changed[1] != shared {
+		t.Errorf("changed files = %v", changed)
+	}
+	wantMain := `include "shared.thrift"
+
+namespace go main
+
+service ProfileService {
+  shared.Profile get(1: i64 id)
+}
+`
+	if got := main.String(); got != wantMain {
+		t.Errorf("main.thrift:\n%s\nwant:\n%s", got, wantMain)
+	}
+	wantShared := `struct User {
+  1: i64 id
+}
+
+// Profile of user
+struct Profile {
+  1: User user // owner
+  2: Address address
+}
+
+struct Address {
+  1: string city
+}
+`
+	if got := shared.String(); got != wantShared {
+		t.Errorf("shared.thrift:\n%s\nwant:\n%s", got, wantShared)
+	}
+	if decl := shared.Declaration("Profile"); decl == nil || decl.CommonField().NodeID != "Struct(Profile)" || thrifter.Root(decl) != shared {
+		t.Errorf("Profile is not moved into shared.thrift")
+	}
+	if main.Declaration("Profile") != nil {
+		t.Errorf("Profile is still in main.thrift")
+	}
+	assertReparsed(t, main, shared)
+
+	// move back, shared.thrift doesn't need to include main.thrift since nothing in it refers to main.thrift
+	if _, err := Move(program, main, shared.Declaration("Profile"), shared.Declaration("Address")); err != nil {
+		t.Fatal(err)
+	}
+	wantMain = `include "shared.thrift"
+
+namespace go main
+
+service ProfileService {
+  Profile get(1: i64 id)
+}
+
+// Profile of user
+struct Profile {
+  1: shared.User user // owner
+  2: Address address
+}
+
+struct Address {
+  1: string city
+}
+`
+	if got := main.String(); got != wantMain {
+		t.Errorf("main.thrift:\n%s\nwant:\n%s", got, wantMain)
+	}
+	if got, want := shared.String(), "struct User {\n  1: i64 id\n}\n"; got != want {
+		t.Errorf("shared.thrift:\n%s\nwant:\n%s", got, want)
+	}
+	assertReparsed(t, main, shared)
+}
+
+func TestMove_includes(t *testing.T) {
+	program, dir := load(t, map[string]string{
+		"main.thrift": `include "a.thrift"
+
+struct S {
+  1: a.E e = a.E.ONE
+}
+`,
+		"a.thrift": `enum E {
+  ONE
+}
+`,
+		"b.thrift": `struct B {
+  1: i32 x
+}
+`,
+	}, "main.thrift")
+	b, err := program.Load(filepath.Join(dir, "b.thrift"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, a := program.Files[filepath.Join(dir, "main.thrift")], program.Files[filepath.Join(dir, "a.thrift")]
+
+	// the include of a.thrift is not used anymore, and main.thrift includes b.thrift instead
+	if _, err := Move(program, b, a.Declaration("E")); err != nil {
+		t.Fatal(err)
+	}
+	want := `include "b.thrift"
+
+struct S {
+  1: b.E e = b.E.ONE
+}
+`
+	if got := main.String(); got != want {
+		t.Errorf("main.thrift:\n%s\nwant:\n%s", got, want)
+	}
+	if got := a.String(); got != "" {
+		t.Errorf("a.thrift:\n%s\nwant empty", got)
+	}
+	if _, err := Move(program, b, main.Declaration("S")); err != nil {
+		t.Fatal(err)
+	}
+	if got := main.String(); got != "" {
+		t.Errorf("main.thrift:\n%s\nwant empty", got)
+	}
+	// b.thrift has no includes, so the include is added before its first node
+	if _, err := Move(program, a, b.Declaration("E")); err != nil {
+		t.Fatal(err)
+	}
+	want = `include "a.thrift"
+
+struct B {
+  1: i32 x
+}
+
+struct S {
+  1: a.E e = a.E.ONE
+}
+`
+	if got := b.String(); got != want {
+		t.Errorf("b.thrift:\n%s\nwant:\n%s", got, want)
+	}
+	assertReparsed(t, main, a, b)
+}
+
+func TestMove_errors(t *testing.T) {
+	program, dir := load(t, map[string]string{
+		"main.thrift": `include "shared.thrift"
+
+struct User {
+  1: i64 id
+}
+
+struct Profile {
+  1: shared.Settings settings
+  2: User user
+}
+
+struct Account {
+  1: shared.User user
+}
+`,
+		"shared.thrift": `struct User {
+  1: i64 id
+}
+
+struct Settings {
+  1: string theme
+}
+`,
+	}, "main.thrift")
+	main, shared := program.Files[filepath.Join(dir, "main.thrift")], program.Files[filepath.Join(dir, "shared.thrift")]
+	before := map[*thrifter.Thrift]string{main: main.String(), shared: shared.String()}
+
+	cases := []struct {
+		name  string
+		decls []thrifter.Node
+		want  string
+	}{
+		{"conflict", []thrifter.Node{main.Declaration("User")}, "already declared"},
+		// Profile still refers to User in main.thrift, which includes shared.thrift for Account
+		{"circular include", []thrifter.Node{main.Declaration("Profile")}, "which includes it"},
+		{"not top-level", []thrifter.Node{main.Declaration("Profile").(*thrifter.Struct).Elems[0]}, "not a top-level declaration"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Move(program, shared, c.decls...)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("error = %v, want containing %q", err, c.want)
+			}
+		})
+	}
+	for file, src := range before {
+		if file.String() != src {
+			t.Errorf("%s is changed:\n%s", file.FileName, file.String())
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	program, dir := load(t, map[string]string{
+		"main.thrift": `namespace go main
+namespace java com.main
+
+struct User {
+  1: i64 id
+}
+
+service UserService {
+  User get(1: i64 id)
+}
+`,
+	}, "main.thrift")
+	main := program.Files[filepath.Join(dir, "main.thrift")]
+
+	file, changed, err := Split(program, main, filepath.Join(dir, "user.thrift"), main.Declaration("User"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if program.Files[filepath.Join(dir, "user.thrift")] != file || len(changed) != 2 {
+		t.Errorf("new file is not added to program, changed files = %v", changed)
+	}
+	want := `namespace go main
+namespace java com.main
+
+struct User {
+  1: i64 id
+}
+`
+	if got := file.String(); got != want {
+		t.Errorf("user.thrift:\n%s\nwant:\n%s", got, want)
+	}
+	want = `include "user.thrift"
+
+namespace go main
+namespace java com.main
+
+service UserService {
+  user.User get(1: i64 id)
+}
+`
+	if got := main.String(); got != want {
+		t.Errorf("main.thrift:\n%s\nwant:\n%s", got, want)
+	}
+	assertReparsed(t, main, file)
+
+	if _, _, err := Split(program, main, filepath.Join(dir, "user.thrift"), main.Declaration("UserService")); err == nil {
+		t.Errorf("split into existing file should fail")
+	}
+
+	// merge it back
+	changed, err = MergeFiles(program, file, main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := program.Files[filepath.Join(dir, "user.thrift")]; ok || len(changed) != 1 || changed[0] != main {
+		t.Errorf("merged file is not removed from program, changed files = %v", changed)
+	}
+	want = `namespace go main
+namespace java com.main
+
+service UserService {
+  User get(1: i64 id)
+}
+
+struct User {
+  1: i64 id
+}
+`
+	if got := main.String(); got != want {
+		t.Errorf("main.thrift:\n%s\nwant:\n%s", got, want)
+	}
+	assertReparsed(t, main)
+}
+
+// tokens of edited files should be the same as parsing their source again
+func assertReparsed(t *testing.T, files ...*thrifter.Thrift) {
+	t.Helper()
+	for _, file := range files {
+		src := file.String()
+		res, err := thrifter.NewParserBytes([]byte(src), false).Parse(file.FileName)
+		if err != nil {
+			t.Errorf("%s: %v", file.FileName, err)
+			continue
+		}
+		want, got := res.StartToken, file.StartToken
+		for want != nil && got != nil {
+			if want.Raw != got.Raw || want.Start != got.Start || want.Pos != got.Pos {
+				t.Errorf("%s: token %q at %v, want %q at %v", file.FileName, got.Raw, got.Pos, want.Raw, want.Pos)
+				break
+			}
+			if want.Next == nil && got.Next != nil || want.Next != nil && got.Next == nil {
+				t.Errorf("%s: token chain ends differently after %q", file.FileName, got.Raw)
+			}
+			want, got = want.Next, got.Next
+		}
+		if len(res.Nodes) != len(file.Nodes) {
+			t.Errorf("%s: %d nodes, want %d", file.FileName, len(file.Nodes), len(res.Nodes))
+		}
+		for i := 0; i < len(res.Nodes) && i < len(file.Nodes); i++ {
+			if got, want := file.Nodes[i].CommonField().NodeID, res.Nodes[i].CommonField().NodeID; got != want {
+				t.Errorf("%s: node %d is %s, want %s", file.FileName, i, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package refactor implements refactorings of thrift definitions, which edit the token chain in place, so formatting and comments are kept.
+package refactor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// Rename renames a declaration, i.e. a struct, union, exception, enum, service, typedef or const, or an enum element, to newName.
+// References to it in all files of program are rewritten, including include-qualified ones, e.g. shared.User, and enum values, e.g. Color.RED.
+// Only identifier tokens are changed, then tokens of changed files are relocated and nodes are reindexed.
+// It returns changed files sorted by file name, and changes nothing if an error is returned.
+func Rename(program *thrifter.Program, decl thrifter.Node, newName string) ([]*thrifter.Thrift, error) {
+	if !thrifter.IsIdent(newName) {
+		return nil, fmt.Errorf("invalid name %q", newName)
+	}
+	file := thrifter.Root(decl)
+	if file == nil {
+		return nil, fmt.Errorf("%s is not attached to a file", decl.NodeType())
+	}
+	oldName, err := declarationName(decl)
+	if err != nil {
+		return nil, err
+	}
+	if oldName == newName {
+		return nil, nil
+	}
+	if err := checkConflict(file, decl, newName); err != nil {
+		return nil, err
+	}
+	identTok := thrifter.IdentToken(decl)
+	if identTok == nil {
+		return nil, fmt.Errorf("identifier of %s %s not found", decl.NodeType(), oldName)
+	}
+
+	// collect all rewrites before changing anything, so that references are resolved against the original names
+	type rewrite struct {
+		ref    thrifter.Reference
+		newRaw string
+	}
+	var rewrites []rewrite
+	for _, ref := range program.References(decl) {
+		newRaw, ok := rename(ref.Token.Raw, ref.Target, decl, oldName, newName)
+		if !ok {
+			return nil, fmt.Errorf("%v: can't rename reference %q", ref.Token.Pos, ref.Token.Raw)
+		}
+		rewrites = append(rewrites, rewrite{ref, newRaw})
+	}
+
+	changed := map[*thrifter.Thrift]bool{file: true}
+	setIdent(decl, newName)
+	setToken(identTok, newName)
+	for _, rw := range rewrites {
+		setReference(rw.ref, rw.newRaw)
+		changed[rw.ref.File] = true
+	}
+
+	return finish(changed), nil
+}
+
+// relocate and reindex changed files, and return them sorted by file name
+func finish(changed map[*thrifter.Thrift]bool) []*thrifter.Thrift {
+	res := make([]*thrifter.Thrift, 0, len(changed))
+	for file := range changed {
+		file.Relocate()
+		file.Reindex()
+		res = append(res, file)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].FileName < res[j].FileName
+	})
+	return res
+}
+
+func declarationName(decl thrifter.Node) (string, error) {
+	switch n := decl.(type) {
+	case *thrifter.Struct:
+		return n.Ident, nil
+	case *thrifter.Enum:
+		return n.Ident, nil
+	case *thrifter.Service:
+		return n.Ident, nil
+	case *thrifter.TypeDef:
+		return n.Ident, nil
+	case *thrifter.Const:
+		return n.Ident, nil
+	case *thrifter.EnumElement:
+		return n.Ident, nil
+	}
+	return "", fmt.Errorf("can't rename %s", decl.NodeType())
+}
+
+func checkConflict(file *thrifter.Thrift, decl thrifter.Node, newName string) error {
+	if elem, ok := decl.(*thrifter.EnumElement); ok {
+		if enum, ok := elem.Parent.(*thrifter.Enum); ok && enum.ElementByName(newName) != nil {
+			return fmt.Errorf("enum %s already has element %s", enum.Ident, newName)
+		}
+		return nil
+	}
+	if existing := file.Declaration(newName); existing != nil {
+		return fmt.Errorf("%v: %s %s already declared", existing.CommonField().StartToken.Pos, strings.ToLower(existing.NodeType()), newName)
+	}
+	return nil
+}
+
+// rename returns raw of a reference token with the renamed part replaced, target is what the reference resolves to
+func rename(raw string, target thrifter.Node, decl thrifter.Node, oldName string, newName string) (string, bool) {
+	if target == decl {
+		// Name, prefix.Name, or Enum.ELEM and prefix.Enum.ELEM for enum elements
+		if raw == oldName {
+			return newName, true
+		}
+		if strings.HasSuffix(raw, "."+oldName) {
+			return raw[:len(raw)-len(oldName)] + newName, true
+		}
+		return "", false
+	}
+	// reference to an element of renamed enum, e.g. Color.RED or shared.Color.RED
+	elem, ok := target.(*thrifter.EnumElement)
+	if !ok {
+		return "", false
+	}
+	suffix := oldName + "." + elem.Ident
+	if raw == suffix {
+		return newName + "." + elem.Ident, true
+	}
+	if strings.HasSuffix(raw, "."+suffix) {
+		return raw[:len(raw)-len(suffix)] + newName + "." + elem.Ident, true
+	}
+	return "", false
+}
+
+func setIdent(decl thrifter.Node, name string) {
+	switch n := decl.(type) {
+	case *thrifter.Struct:
+		n.Ident = name
+	case *thrifter.Enum:
+		n.Ident = name
+	case *thrifter.Service:
+		n.Ident = name
+	case *thrifter.TypeDef:
+		n.Ident = name
+	case *thrifter.Const:
+		n.Ident = name
+	case *thrifter.EnumElement:
+		n.Ident = name
+	}
+}
+
+// rewrite identifier of a reference, along with the field of node holding it
+func setReference(ref thrifter.Reference, raw string) {
+	setToken(ref.Token, raw)
+	switch n := ref.Node.(type) {
+	case *thrifter.FieldType:
+		n.Ident = raw
+	case *thrifter.ConstValue:
+		n.Value = raw
+	case *thrifter.Service:
+		n.Extends = raw
+	}
+}
+
+func setToken(tok *thrifter.Token, raw string) {
+	tok.Raw = raw
+	tok.Value = raw
+}
@@ -0,0 +1,168 @@
+package refactor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+// writes files into a temporary directory, and loads the first file with files it includes
+func load(t *testing.T, files map[string]string, main string) (*thrifter.Program, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	program := thrifter.NewProgram()
+	if _, err := program.Load(filepath.Join(dir, main)); err != nil {
+		t.Fatal(err)
+	}
+	return program, dir
+}
+
+func TestRename(t *testing.T) {
+	program, dir := load(t, map[string]string{
+		"main.thrift": `include "shared.thrift"
+
+struct Profile {
+  1: shared.User   user    // aligned
+  2: list<shared.User> friends
+  3: shared.Color color = shared.Color.RED
+}
+
+service ProfileService extends shared.UserService {
+  shared.User get(1: i64 id) throws (1: shared.UserError e)
+}
+`,
+		"shared.thrift": `struct User {
+  1: i64 id
+}
+
+exception UserError {
+  1: User user
+}
+
+enum Color {
+  RED
+}
+
+const Color DEFAULT_COLOR = Color.RED
+
+service UserService {
+  User get(1: i64 id)
+}
+`,
+	}, "main.thrift")
+	main, shared := program.Files[filepath.Join(dir, "main.thrift")], program.Files[filepath.Join(dir, "shared.thrift")]
+
+	// later declarations are looked up after earlier renames
+	cases := []struct {
+		decl    func() thrifter.Node
+		newName string
+	}{
+		{func() thrifter.Node { return shared.Declaration("User") }, "Account"},
+		{func() thrifter.Node { return shared.Declaration("UserError") }, "AccountError"},
+		{func() thrifter.Node { return shared.Declaration("Color") }, "Colour"},
+		{func() thrifter.Node { return shared.Declaration("Colour").(*thrifter.Enum).ElementByName("RED") }, "CRIMSON"},
+		{func() thrifter.Node { return shared.Declaration("UserService") }, "AccountService"},
+	}
+	for _, c := range cases {
+		changed, err := Rename(program, c.decl(), c.newName)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := len(changed), 2; got != want {
+			t.Errorf("%s: got [%v] want [%v]", c.newName, got, want)
+		}
+	}
+
+	wantMain := `include "shared.thrift"
+
+struct Profile {
+  1: shared.Account   user    // aligned
+  2: list<shared.Account> friends
+  3: shared.Colour color = shared.Colour.CRIMSON
+}
+
+service ProfileService extends shared.AccountService {
+  shared.Account get(1: i64 id) throws (1: shared.AccountError e)
+}
+`
+	wantShared := `struct Account {
+  1: i64 id
+}
+
+exception AccountError {
+  1: Account user
+}
+
+enum Colour {
+  CRIMSON
+}
+
+const Colour DEFAULT_COLOR = Colour.CRIMSON
+
+service AccountService {
+  Account get(1: i64 id)
+}
+`
+	if got := main.String(); got != wantMain {
+		t.Errorf("got\n%s\nwant\n%s", got, wantMain)
+	}
+	if got := shared.String(); got != wantShared {
+		t.Errorf("got\n%s\nwant\n%s", got, wantShared)
+	}
+
+	// the result is the same as parsing the renamed source
+	reparsed, err := thrifter.NewParserBytes([]byte(wantMain), false).Parse(main.FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for got, want := main.StartToken, reparsed.StartToken; got != nil || want != nil; got, want = got.Next, want.Next {
+		if got == nil || want == nil || got.Raw != want.Raw || got.Pos != want.Pos || got.Start != want.Start {
+			t.Fatalf("got [%+v] want [%+v]", got, want)
+		}
+	}
+	profile := main.Declaration("Profile").(*thrifter.Struct)
+	if got, want := profile.FieldByID(1).FieldType.Ident, "shared.Account"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := shared.Declaration("Account").CommonField().NodeID, "Struct(Account)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := main.Declaration("ProfileService").(*thrifter.Service).Extends, "shared.AccountService"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestRename_errors(t *testing.T) {
+	program, dir := load(t, map[string]string{
+		"main.thrift": "struct A {\n  1: B b\n}\nstruct B {}\nenum E {\n  X\n  Y\n}\n",
+	}, "main.thrift")
+	main := program.Files[filepath.Join(dir, "main.thrift")]
+	src := main.String()
+
+	cases := []struct {
+		decl    thrifter.Node
+		newName string
+	}{
+		{main.Declaration("B"), "A"},
+		{main.Declaration("B"), "struct"},
+		{main.Declaration("B"), "i32"},
+		{main.Declaration("B"), "a.b"},
+		{main.Declaration("E").(*thrifter.Enum).ElementByName("X"), "Y"},
+		{main.Declaration("A").(*thrifter.Struct).FieldByID(1), "c"},
+	}
+	for _, c := range cases {
+		if _, err := Rename(program, c.decl, c.newName); err == nil {
+			t.Errorf("%s: expect error", c.newName)
+		}
+	}
+	if got := main.String(); got != src {
+		t.Errorf("got [%v] want [%v]", got, src)
+	}
+}
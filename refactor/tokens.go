@@ -0,0 +1,154 @@
+package refactor
+
+import "github.com/YYCoder/thrifter"
+
+// nodeSpan returns the first and last token of a top-level node with its leading comments and trailing comment,
+// expanded to whole lines, i.e. including indentation and the line break, if the node occupies them alone.
+func nodeSpan(node thrifter.Node) (first *thrifter.Token, last *thrifter.Token) {
+	common := node.CommonField()
+	first, last = common.StartToken, common.EndToken
+	if comments := common.LeadingComments(); len(comments) > 0 {
+		first = comments[0]
+	}
+	if tok := common.TrailingComment(); tok != nil {
+		last = tok
+	}
+
+	start := first
+	for prev := first.Prev; prev != nil && isIndent(prev); prev = prev.Prev {
+		start = prev
+	}
+	if start.Prev == nil || start.Prev.Type == thrifter.T_LINEBREAK {
+		first = start
+	}
+	end := last
+	for next := last.Next; next != nil && (isIndent(next) || next.Type == thrifter.T_RETURN); next = next.Next {
+		end = next
+	}
+	if end.Next != nil && end.Next.Type == thrifter.T_LINEBREAK {
+		last = end.Next
+	} else if end.Next == nil || end.Next.Type == thrifter.T_EOF {
+		last = end
+	}
+	return
+}
+
+// cut removes tokens from first to last out of the token chain of file, and removes the blank line left behind if it's redundant.
+func cut(file *thrifter.Thrift, first *thrifter.Token, last *thrifter.Token) {
+	prev, next := first.Prev, last.Next
+	link(prev, next)
+	first.Prev, last.Next = nil, nil
+	if file.StartToken == first {
+		file.StartToken = next
+	}
+	if next == nil || next.Type == thrifter.T_EOF {
+		trimEnd(file)
+		return
+	}
+	// a blank line after the gap is redundant if the gap is at the beginning of file, or right after another blank line
+	if lineBreak := blankLineFrom(next); lineBreak != nil && (prev == nil || prev.Type == thrifter.T_LINEBREAK && blankLineBefore(prev)) {
+		cut(file, next, lineBreak)
+	}
+}
+
+// trimEnd removes blank lines at the end of file, the line break ending the last line is kept.
+func trimEnd(file *thrifter.Thrift) {
+	eof := file.EndToken
+	keep := eof.Prev
+	for keep != nil && thrifter.IsWhitespace(keep.Type) {
+		keep = keep.Prev
+	}
+	if keep == nil {
+		file.StartToken = eof
+		eof.Prev = nil
+		return
+	}
+	for next := keep.Next; next != eof; next = next.Next {
+		if next.Type == thrifter.T_LINEBREAK {
+			keep = next
+			break
+		}
+	}
+	link(keep, eof)
+}
+
+// appendTokens appends tokens from first to last to the end of file, right before EOF, separated from existing content by a blank line.
+func appendTokens(file *thrifter.Thrift, first *thrifter.Token, last *thrifter.Token) {
+	eof := file.EndToken
+	before := eof.Prev
+	if before != nil {
+		if before.Type != thrifter.T_LINEBREAK {
+			before = insertAfter(file, before, newLineBreak(), nil)
+		}
+		if !blankLineBefore(before) {
+			before = insertAfter(file, before, newLineBreak(), nil)
+		}
+	}
+	last = insertAfter(file, before, first, last)
+	if last.Type != thrifter.T_LINEBREAK {
+		insertAfter(file, last, newLineBreak(), nil)
+	}
+}
+
+// insertAfter inserts tokens from first to last after tok, or at the beginning of file if tok is nil, last can be nil for a single token.
+// It returns the last inserted token.
+func insertAfter(file *thrifter.Thrift, tok *thrifter.Token, first *thrifter.Token, last *thrifter.Token) *thrifter.Token {
+	if last == nil {
+		last = first
+	}
+	var next *thrifter.Token
+	if tok == nil {
+		next = file.StartToken
+		file.StartToken = first
+	} else {
+		next = tok.Next
+	}
+	link(tok, first)
+	link(last, next)
+	return last
+}
+
+func link(prev *thrifter.Token, next *thrifter.Token) {
+	if prev != nil {
+		prev.Next = next
+	}
+	if next != nil {
+		next.Prev = prev
+	}
+}
+
+// if tokens from tok are a blank line, it returns the line break ending it
+func blankLineFrom(tok *thrifter.Token) *thrifter.Token {
+	for ; tok != nil; tok = tok.Next {
+		switch {
+		case tok.Type == thrifter.T_LINEBREAK:
+			return tok
+		case isIndent(tok) || tok.Type == thrifter.T_RETURN:
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// whether line break lineBreak ends a blank line, or it's at the beginning of file
+func blankLineBefore(lineBreak *thrifter.Token) bool {
+	for tok := lineBreak.Prev; tok != nil; tok = tok.Prev {
+		switch {
+		case tok.Type == thrifter.T_LINEBREAK:
+			return true
+		case isIndent(tok) || tok.Type == thrifter.T_RETURN:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isIndent(tok *thrifter.Token) bool {
+	return tok.Type == thrifter.T_SPACE || tok.Type == thrifter.T_TAB
+}
+
+func newLineBreak() *thrifter.Token {
+	return &thrifter.Token{Type: thrifter.T_LINEBREAK, Raw: "\n", Value: "\n"}
+}
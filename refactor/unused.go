@@ -0,0 +1,107 @@
+package refactor
+
+import "github.com/YYCoder/thrifter"
+
+// Unused is a declaration or an include reported by FindUnused.
+type Unused struct {
+	File *thrifter.Thrift
+	Node thrifter.Node // *Struct, *Enum, *TypeDef, *Const, *Service or *Include
+}
+
+// UnusedOptions configures FindUnused.
+type UnusedOptions struct {
+	// Roots are the only services reachability starts from, all services in program by default.
+	Roots []*thrifter.Service
+	// Services reports services not reachable from Roots too, so that RemoveUnused deletes them.
+	// Without it, services are never reported, though declarations only they refer to are.
+	Services bool
+}
+
+// FindUnused reports declarations in program not reachable from root services, and includes not used by reachable declarations of their files.
+//
+// Reachability follows references in reachable declarations, i.e. types of function args, returns and throws, types of struct fields and typedefs,
+// key and element types of containers, extended services, and identifiers in const values, e.g. field defaults referring to consts or enum elements.
+// Services are only reported with opts.Services. Results are sorted by file name and position.
+func FindUnused(program *thrifter.Program, opts UnusedOptions) (res []Unused) {
+	refs := map[thrifter.Node][]thrifter.Reference{}
+	var queue []thrifter.Node
+	for _, path := range sortedPaths(program) {
+		file := program.Files[path]
+		for _, ref := range program.FileReferences(file) {
+			decl := thrifter.TopLevel(ref.Node)
+			refs[decl] = append(refs[decl], ref)
+		}
+		if len(opts.Roots) == 0 {
+			for _, node := range file.Nodes {
+				if _, ok := node.(*thrifter.Service); ok {
+					queue = append(queue, node)
+				}
+			}
+		}
+	}
+	for _, root := range opts.Roots {
+		queue = append(queue, root)
+	}
+
+	reachable := map[thrifter.Node]bool{}
+	for _, decl := range queue {
+		reachable[decl] = true
+	}
+	for len(queue) > 0 {
+		decl := queue[0]
+		queue = queue[1:]
+		for _, ref := range refs[decl] {
+			if target := thrifter.TopLevel(ref.Target); target != nil && !reachable[target] {
+				reachable[target] = true
+				queue = append(queue, target)
+			}
+		}
+	}
+
+	for _, path := range sortedPaths(program) {
+		file := program.Files[path]
+		// files referred by reachable declarations of file
+		used := map[*thrifter.Thrift]bool{}
+		for _, node := range file.Nodes {
+			if reachable[node] {
+				for _, ref := range refs[node] {
+					if ref.Target != nil {
+						used[thrifter.Root(ref.Target)] = true
+					}
+				}
+			}
+		}
+		for _, node := range file.Nodes {
+			switch n := node.(type) {
+			case *thrifter.Struct, *thrifter.Enum, *thrifter.TypeDef, *thrifter.Const, *thrifter.Service:
+				if _, ok := n.(*thrifter.Service); ok && !opts.Services {
+					continue
+				}
+				if !reachable[node] {
+					res = append(res, Unused{file, node})
+				}
+			case *thrifter.Include:
+				if n.IsCpp() {
+					continue
+				}
+				path, ok := program.IncludePath(file, n)
+				if included := program.Files[path]; ok && included != nil && !used[included] {
+					res = append(res, Unused{file, node})
+				}
+			}
+		}
+	}
+	return
+}
+
+// RemoveUnused removes nodes reported by FindUnused along with their comments, and returns changed files sorted by file name.
+func RemoveUnused(unused []Unused) []*thrifter.Thrift {
+	changed := map[*thrifter.Thrift]bool{}
+	for _, u := range unused {
+		first, last := nodeSpan(u.Node)
+		cut(u.File, first, last)
+		removeNode(u.File, u.Node)
+		changed[u.File] = true
+	}
+	return finish(changed)
+}
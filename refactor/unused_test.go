@@ -0,0 +1,146 @@
+package refactor
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/YYCoder/thrifter"
+)
+
+var unusedFiles = map[string]string{
+	"main.thrift": `include "shared.thrift"
+include "legacy.thrift"
+
+namespace go main
+
+const i32 LIMIT = 10
+
+// Order of user
+struct Order {
+  1: list<Item> items
+  2: shared.Status status = shared.Status.ACTIVE
+}
+
+struct Item {
+  1: i64 id
+}
+
+// not used anymore
+struct OldOrder {
+  1: legacy.Cart cart
+}
+
+service OrderService extends shared.BaseService {
+  Order get(1: shared.ID id) throws (1: shared.NotFound e)
+}
+
+service AdminService {
+  void reset()
+}
+`,
+	"shared.thrift": `typedef i64 ID
+
+enum Status {
+  ACTIVE = 1
+}
+
+const Status DEFAULT = Status.ACTIVE
+
+exception NotFound {
+  1: map<ID, Reason> reasons
+}
+
+typedef string Reason
+
+service BaseService {
+  void ping(1: Token token)
+}
+
+struct Token {
+  1: string value
+}
+`,
+	"legacy.thrift": `struct Cart {
+  1: i64 id
+}
+`,
+}
+
+func unusedIDs(unused []Unused) string {
+	var res []string
+	for _, u := range unused {
+		res = append(res, filepath.Base(u.File.FileName)+":"+u.Node.CommonField().NodeID)
+	}
+	return strings.Join(res, ",")
+}
+
+func TestFindUnused(t *testing.T) {
+	program, dir := load(t, unusedFiles, "main.thrift")
+	main := program.Files[filepath.Join(dir, "main.thrift")]
+
+	root := main.Declaration("OrderService").(*thrifter.Service)
+	got := unusedIDs(FindUnused(program, UnusedOptions{Roots: []*thrifter.Service{root}, Services: true}))
+	want := "legacy.thrift:Struct(Cart),main.thrift:Include(legacy.thrift),main.thrift:Const(LIMIT),main.thrift:Struct(OldOrder),main.thrift:Service(AdminService),shared.thrift:Const(DEFAULT)"
+	if got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+
+	// services are kept without Services, declarations only they refer to are not
+	admin := main.Declaration("AdminService").(*thrifter.Service)
+	got = unusedIDs(FindUnused(program, UnusedOptions{Roots: []*thrifter.Service{admin}}))
+	want = "legacy.thrift:Struct(Cart),main.thrift:Include(shared.thrift),main.thrift:Include(legacy.thrift),main.thrift:Const(LIMIT),main.thrift:Struct(Order),main.thrift:Struct(Item),main.thrift:Struct(OldOrder)," +
+		"shared.thrift:TypeDef(ID),shared.thrift:Enum(Status),shared.thrift:Const(DEFAULT),shared.thrift:Exception(NotFound),shared.thrift:TypeDef(Reason),shared.thrift:Struct(Token)"
+	if got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+
+	// all services are roots by default
+	got = unusedIDs(FindUnused(program, UnusedOptions{Services: true}))
+	want = "legacy.thrift:Struct(Cart),main.thrift:Include(legacy.thrift),main.thrift:Const(LIMIT),main.thrift:Struct(OldOrder),shared.thrift:Const(DEFAULT)"
+	if got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
+
+func TestRemoveUnused(t *testing.T) {
+	program, dir := load(t, unusedFiles, "main.thrift")
+	main, legacy := program.Files[filepath.Join(dir, "main.thrift")], program.Files[filepath.Join(dir, "legacy.thrift")]
+
+	changed := RemoveUnused(FindUnused(program, UnusedOptions{}))
+	if len(changed) != 3 || changed[0] != legacy || changed[1] != main {
+		t.Errorf("changed files = %v", changed)
+	}
+	wantMain := `include "shared.thrift"
+
+namespace go main
+
+// Order of user
+struct Order {
+  1: list<Item> items
+  2: shared.Status status = shared.Status.ACTIVE
+}
+
+struct Item {
+  1: i64 id
+}
+
+service OrderService extends shared.BaseService {
+  Order get(1: shared.ID id) throws (1: shared.NotFound e)
+}
+
+service AdminService {
+  void reset()
+}
+`
+	if got := main.String(); got != wantMain {
+		t.Errorf("main.thrift:\n%s\nwant:\n%s", got, wantMain)
+	}
+	if got := legacy.String(); got != "" {
+		t.Errorf("legacy.thrift:\n%s", got)
+	}
+	assertReparsed(t, changed...)
+	if unused := FindUnused(program, UnusedOptions{}); len(unused) != 0 {
+		t.Errorf("unused after removal: %s", unusedIDs(unused))
+	}
+}
@@ -0,0 +1,179 @@
+package thrifter
+
+import (
+	"fmt"
+	"text/scanner"
+)
+
+// TextEdit replaces source between byte offset Start and End with NewText, offsets are relative to the source before the edit.
+type TextEdit struct {
+	Start   int
+	End     int
+	NewText string
+}
+
+// Reparse applies the edit to the source of r, and updates the tree in place, as if the edited source was parsed from scratch.
+//
+// Only the top-level declarations touched by the edit, along with white spaces and comments around them, are parsed again, their new tokens are linked into the existing token chain and replace the old ones in Nodes.
+// Other declarations are kept as they are, only the offsets and positions of their tokens are updated. The touched declarations are parsed along with the declaration following them,
+// if they can't be parsed that way, or the following declaration is not parsed the same as before, e.g. an unterminated block comment swallows it, the whole source is parsed again.
+//
+// It relies on the offsets of tokens, so r must be produced by Parse or Reparse, and not be modified manually.
+// If parsing fails, r is left unchanged and the error is returned.
+func (r *Thrift) Reparse(edit TextEdit) (err error) {
+	if r.StartToken == nil || r.EndToken == nil {
+		return fmt.Errorf("thrift %s has not been parsed", r.FileName)
+	}
+	size := r.EndToken.End
+	if edit.Start < 0 || edit.Start > edit.End || edit.End > size {
+		return fmt.Errorf("invalid edit range [%d, %d], source size %d", edit.Start, edit.End, size)
+	}
+
+	// find the nodes touched by edit, nodes[first:last] will be replaced
+	first, last := len(r.Nodes), len(r.Nodes)
+	for idx, node := range r.Nodes {
+		common := node.CommonField()
+		if common.EndToken.End < edit.Start {
+			continue
+		}
+		if first == len(r.Nodes) {
+			first = idx
+		}
+		if common.StartToken.Start > edit.End {
+			last = idx
+			break
+		}
+	}
+
+	// the window of tokens to parse again is between prevTok and nextTok, exclusively
+	var prevTok, nextTok *Token
+	if first > 0 {
+		prevTok = r.Nodes[first-1].CommonField().EndToken
+	}
+	if last < len(r.Nodes) {
+		nextTok = r.Nodes[last].CommonField().StartToken
+	} else {
+		nextTok = r.EndToken
+	}
+	startTok := r.StartToken
+	if prevTok != nil {
+		startTok = prevTok.Next
+	}
+	start, startPos := startTok.Start, startTok.Pos
+	end := nextTok.Start
+
+	var oldText string
+	if startTok != nextTok {
+		oldText = toString(startTok, nextTok)[:end-start]
+	}
+	newText := oldText[:edit.Start-start] + edit.NewText + oldText[edit.End-start:]
+
+	// the declaration following window is parsed along with it, so that the end of window doesn't act as EOF,
+	// e.g. a declaration cut short by the edit might parse on its own, but swallow the following declaration in the whole source
+	var followText string
+	if last < len(r.Nodes) {
+		followText = toString(nextTok, r.Nodes[last].CommonField().EndToken)
+	}
+	window, err := NewParserBytes([]byte(newText+followText), false).Parse(r.FileName)
+	if err != nil || window.EndToken == nil {
+		// declarations in window might depend on the rest of source, parse the whole source instead
+		return r.reparseAll(edit)
+	}
+	stopTok := window.EndToken
+	if followText != "" {
+		// the following declaration must be parsed from its start to its end as before, and is kept as it is
+		n := len(window.Nodes)
+		if n == 0 {
+			return r.reparseAll(edit)
+		}
+		follow := window.Nodes[n-1].CommonField()
+		if follow.StartToken.Start != len(newText) || follow.EndToken.End != len(newText)+len(followText) {
+			return r.reparseAll(edit)
+		}
+		window.Nodes = window.Nodes[:n-1]
+		stopTok = follow.StartToken
+	}
+
+	// link new tokens into token chain, excluding the following declaration and EOF token of window
+	var firstTok, lastTok *Token
+	for tok := window.StartToken; tok != stopTok; tok = tok.Next {
+		if firstTok == nil {
+			firstTok = tok
+		}
+		lastTok = tok
+	}
+	if firstTok == nil {
+		firstTok = nextTok
+	} else {
+		firstTok.Prev = prevTok
+		lastTok.Next = nextTok
+		nextTok.Prev = lastTok
+	}
+	if prevTok == nil {
+		r.StartToken = firstTok
+	} else {
+		prevTok.Next = firstTok
+	}
+	relocateTokens(firstTok, start, startPos, r.FileName)
+
+	nodes := make([]Node, 0, len(r.Nodes)-(last-first)+len(window.Nodes))
+	nodes = append(nodes, r.Nodes[:first]...)
+	for _, node := range window.Nodes {
+		node.CommonField().Parent = r
+		nodes = append(nodes, node)
+	}
+	nodes = append(nodes, r.Nodes[last:]...)
+	r.Nodes = nodes
+	return
+}
+
+func (r *Thrift) reparseAll(edit TextEdit) (err error) {
+	src := r.String()
+	src = src[:edit.Start] + edit.NewText + src[edit.End:]
+	res, err := NewParserBytes([]byte(src), false).Parse(r.FileName)
+	if err != nil {
+		return err
+	}
+	for _, node := range res.Nodes {
+		node.CommonField().Parent = r
+	}
+	r.Nodes = res.Nodes
+	r.StartToken = res.StartToken
+	r.EndToken = res.EndToken
+	return
+}
+
+// Relocate recomputes offsets and positions of all tokens, call it after changing Raw of tokens or splicing the token chain directly.
+func (r *Thrift) Relocate() {
+	if r.StartToken == nil {
+		return
+	}
+	relocateTokens(r.StartToken, 0, scanner.Position{Filename: r.FileName, Line: 1, Column: 1}, r.FileName)
+}
+
+// Recompute offsets and positions from tok to the end of token chain, given the offset and position of tok.
+func relocateTokens(tok *Token, offset int, pos scanner.Position, fileName string) {
+	line, column := pos.Line, pos.Column
+	for ; tok != nil; tok = tok.Next {
+		tok.Start = offset
+		tok.End = offset + len(tok.Raw)
+		tok.Pos = scanner.Position{
+			Filename: fileName,
+			Offset:   offset,
+			Line:     line,
+			Column:   column,
+		}
+		for _, ru := range tok.Raw {
+			if ru == '\n' {
+				line++
+				column = 1
+			} else {
+				column++
+			}
+		}
+		offset = tok.End
+		if tok.Type == T_EOF {
+			break
+		}
+	}
+}
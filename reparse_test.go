@@ -0,0 +1,223 @@
+package thrifter
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// dump tokens and nodes of a tree, so that trees can be compared regardless of pointers
+func dumpThrift(r *Thrift) string {
+	var res strings.Builder
+	for tok := r.StartToken; tok != nil; tok = tok.Next {
+		fmt.Fprintf(&res, "%d %q %q %v %d %d\n", tok.Type, tok.Raw, tok.Value, tok.Pos, tok.Start, tok.End)
+		if tok == r.EndToken {
+			break
+		}
+	}
+	for _, node := range r.Nodes {
+		common := node.CommonField()
+		fmt.Fprintf(&res, "%s %s %d %d %v %q\n", node.NodeType(), common.NodeID, common.StartToken.Start, common.EndToken.End, common.Parent == r, node.String())
+	}
+	return res.String()
+}
+
+func TestThrift_reparse(t *testing.T) {
+	src, err := readFile("./examples/ThriftTest.thrift")
+	if err != nil {
+		t.Errorf("readFile error: %v", err)
+		return
+	}
+	res, err := NewParser(strings.NewReader(src), false).Parse("ThriftTest.thrift")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	edits := []struct {
+		name    string
+		find    string
+		replace string
+	}{
+		{"rename field", "2: i32 type", "2: i64 kind"},
+		{"edit inside comment", "used to be byte", "used to be a byte"},
+		{"insert declaration between declarations", "typedef i64 UserId\n", "typedef i64 UserId\n\nstruct Added {\n  1: i32 a\n}\n"},
+		{"remove declaration", "struct Bools {\n  1: bool im_true,\n  2: bool im_false,\n}\n", ""},
+		{"merge two declarations", "  3: i32    i32_thing\n}\n\nstruct Xtruct3\n{\n", "  3: i32    i32_thing\n"},
+		{"edit options", `(python.immutable= "")`, `(python.immutable= "yes", a = "b")`},
+		{"edit at start of file", "", "// leading comment\n"},
+	}
+	for _, edit := range edits {
+		idx := strings.Index(src, edit.find)
+		if idx < 0 {
+			t.Fatalf("%s: %q not found", edit.name, edit.find)
+		}
+		if err := res.Reparse(TextEdit{Start: idx, End: idx + len(edit.find), NewText: edit.replace}); err != nil {
+			t.Errorf("%s: unexpected error: %v", edit.name, err)
+			return
+		}
+		src = src[:idx] + edit.replace + src[idx+len(edit.find):]
+		full, err := NewParser(strings.NewReader(src), false).Parse("ThriftTest.thrift")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", edit.name, err)
+			return
+		}
+
+		if got, want := res.String(), src; got != want {
+			t.Errorf("%s: got [%v] want [%v]", edit.name, got, want)
+		}
+		if got, want := dumpThrift(res), dumpThrift(full); got != want {
+			t.Errorf("%s: got [%v] want [%v]", edit.name, got, want)
+		}
+	}
+}
+
+func TestThrift_reparseKeepsUntouchedNodes(t *testing.T) {
+	src := "struct A {\n  1: i32 a\n}\n\nstruct B {\n  1: i32 b\n}\n"
+	res, err := NewParser(strings.NewReader(src), false).Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	a, b := res.Nodes[0], res.Nodes[1]
+	idx := strings.Index(src, "i32 b")
+	if err := res.Reparse(TextEdit{Start: idx, End: idx + 3, NewText: "string"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	if got, want := res.Nodes[0], a; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := res.Nodes[1]; got == b {
+		t.Errorf("got [%v] want a new node", got)
+	}
+	if got, want := res.Nodes[1].(*Struct).Elems[0].FieldType.BaseType, "string"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestThrift_reparseFallback(t *testing.T) {
+	src := "struct A {}\n/* c */\nstruct B {}\n/* d */\n"
+	res, err := NewParser(strings.NewReader(src), false).Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	// block comment now swallows struct B
+	idx := strings.Index(src, "c */")
+	if err := res.Reparse(TextEdit{Start: idx + 2, End: idx + 4, NewText: ""}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	src = src[:idx+2] + src[idx+4:]
+	full, err := NewParser(strings.NewReader(src), false).Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	if got, want := len(res.Nodes), 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := dumpThrift(res), dumpThrift(full); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestThrift_reparseError(t *testing.T) {
+	src := "struct A {\n  1: i32 a\n}\n"
+	res, err := NewParser(strings.NewReader(src), false).Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	if err := res.Reparse(TextEdit{Start: 9, End: 10, NewText: "["}); err == nil {
+		t.Errorf("expected error")
+	}
+	if err := res.Reparse(TextEdit{Start: 10, End: 100, NewText: ""}); err == nil {
+		t.Errorf("expected error")
+	}
+
+	if got, want := res.String(), src; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestThrift_reparseFollowing(t *testing.T) {
+	src, err := readFile("./examples/fb303.thrift")
+	if err != nil {
+		t.Fatalf("readFile error: %v", err)
+	}
+	res, err := NewParser(strings.NewReader(src), false).Parse("fb303.thrift")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// namespace pFB303 parses on its own, but not followed by the next namespace
+	idx := strings.Index(src, "erl Facebook.")
+	if err := res.Reparse(TextEdit{Start: idx, End: idx + len("erl Facebook."), NewText: ""}); err == nil {
+		t.Errorf("expected error")
+	}
+	if got, want := res.String(), src; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+// Reparse must give the same tree as parsing the edited source from scratch, or fail along with it
+func TestThrift_reparseRandom(t *testing.T) {
+	runs := 100
+	if testing.Short() {
+		runs = 10
+	}
+	for _, path := range []string{"./examples/ThriftTest.thrift", "./examples/fb303.thrift", "./examples/cassandra.thrift", "./examples/annotation_test.thrift"} {
+		src, err := readFile(path)
+		if err != nil {
+			t.Fatalf("readFile error: %v", err)
+		}
+		rnd := rand.New(rand.NewSource(1))
+		for i := 0; i < runs; i++ {
+			res, err := NewParser(strings.NewReader(src), false).Parse(path)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", path, err)
+			}
+			// a few edits in a row, each removes up to 16 bytes and inserts a piece of source
+			edited := src
+			for j := 0; j < 3; j++ {
+				start := rnd.Intn(len(edited) + 1)
+				end := start + rnd.Intn(17)
+				if end > len(edited) {
+					end = len(edited)
+				}
+				from := rnd.Intn(len(src) + 1)
+				to := from + rnd.Intn(17)
+				if to > len(src) || rnd.Intn(2) == 0 {
+					to = from
+				}
+				edit := TextEdit{Start: start, End: end, NewText: src[from:to]}
+				want := edited[:start] + edit.NewText + edited[end:]
+				full, fullErr := NewParser(strings.NewReader(want), false).Parse(path)
+
+				err := res.Reparse(edit)
+				if fullErr != nil {
+					if err == nil {
+						t.Errorf("%s: %+v: got [nil] want [%v]", path, edit, fullErr)
+					}
+					if got := res.String(); got != edited {
+						t.Errorf("%s: %+v: got [%v] want unchanged source", path, edit, got)
+					}
+					break
+				}
+				if err != nil {
+					t.Errorf("%s: %+v: unexpected error: %v", path, edit, err)
+					break
+				}
+				if got, want := dumpThrift(res), dumpThrift(full); got != want {
+					t.Errorf("%s: %+v: got [%v] want [%v]", path, edit, got, want)
+					break
+				}
+				edited = want
+			}
+		}
+	}
+}
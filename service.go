@@ -1,12 +1,14 @@
 package thrifter
 
+import "strconv"
+
 type Service struct {
 	NodeCommonField
 	Ident    string
 	Elems    []*Function
 	Extends  string
 	Options  []*Option
-	ElemsMap map[string]*Function // startToken hash => Function node
+	ElemsMap map[string]*Function // node id => Function node
 }
 
 func NewService(start *Token, parent Node) *Service {
@@ -33,8 +35,12 @@ func (r *Service) String() string {
 
 func (r *Service) parse(p *Parser) (err error) {
 	p.peekNonWhitespace()
-	identTok := p.nextIdent(false)
+	identTok, err := p.expectIdent(false)
+	if err != nil {
+		return err
+	}
 	r.Ident = identTok.Raw
+	r.NodeID = genNodeID("", r.NodeType(), r.Ident)
 	tok := p.nextNonWhitespace()
 	if tok.Type == T_LEFTCURLY {
 		r.Elems, err = r.parseFunctions(p)
@@ -43,7 +49,7 @@ func (r *Service) parse(p *Parser) (err error) {
 		}
 		// parse options
 		ru := p.peekNonWhitespace()
-		if toToken(string(ru)) != T_LEFTPAREN {
+		if runeToken(ru) != T_LEFTPAREN {
 			return
 		}
 		p.next() // consume (
@@ -52,7 +58,10 @@ func (r *Service) parse(p *Parser) (err error) {
 			return err
 		}
 	} else if tok.Value == "extends" {
-		identTok := p.nextIdent(false)
+		identTok, err := p.expectIdent(false)
+		if err != nil {
+			return err
+		}
 		r.Extends = identTok.Raw
 		tok := p.nextNonWhitespace()
 		if tok.Type == T_LEFTCURLY {
@@ -77,10 +86,13 @@ func (r *Service) parseFunctions(p *Parser) (funcs []*Function, err error) {
 			break
 		}
 		ru := p.peekNonWhitespace()
-		if toToken(string(ru)) == T_RIGHTCURLY {
+		if runeToken(ru) == T_RIGHTCURLY {
 			r.EndToken = p.next()
 			break
 		}
+		if ru == eof {
+			return nil, p.unexpected("EOF", "}")
+		}
 		elem := NewFunction(r)
 		if err = elem.parse(p); err != nil {
 			return nil, err
@@ -91,6 +103,26 @@ func (r *Service) parseFunctions(p *Parser) (funcs []*Function, err error) {
 	return
 }
 
+// Reindex regenerates node ids of the service and its functions, and rebuilds ElemsMap and each function's ArgsMap/ThrowsMap.
+// Call it after changing Ident, function identifiers or Elems directly.
+func (r *Service) Reindex() {
+	r.NodeID = genNodeID("", r.NodeType(), r.Ident)
+	r.ElemsMap = make(map[string]*Function, len(r.Elems))
+	for _, elem := range r.Elems {
+		elem.patchToParentMap()
+		elem.Reindex()
+	}
+}
+
+// FunctionByName returns the function with the given identifier, or nil if it does not exist.
+// It looks up ElemsMap without modifying the service, call Reindex first after changing function identifiers or Elems directly.
+func (r *Service) FunctionByName(name string) *Function {
+	if elem, ok := r.ElemsMap[genNodeID(r.NodeID, "Function", name)]; ok && elem.Ident == name {
+		return elem
+	}
+	return nil
+}
+
 const (
 	FIELD_PARENT_TYPE_ARGS = iota + 1
 	FIELD_PARENT_TYPE_THROWS
@@ -105,8 +137,8 @@ type Function struct {
 	Void         bool
 	Args         []*Field
 	Options      []*Option
-	ArgsMap      map[string]*Field // startToken hash => Argument Field
-	ThrowsMap    map[string]*Field // startToken hash => Throws Field
+	ArgsMap      map[string]*Field // node id => Argument Field
+	ThrowsMap    map[string]*Field // node id => Throws Field
 }
 
 func NewFunction(parent Node) *Function {
@@ -132,19 +164,46 @@ func (r *Function) String() string {
 }
 
 func (r *Function) patchToParentMap() {
-	hash := GenTokenHash(r.StartToken)
 	parent := r.Parent.(*Service)
-	parent.ElemsMap[hash] = r
+	r.NodeID = genNodeID(parent.NodeID, "Function", r.Ident)
+	parent.ElemsMap[r.NodeID] = r
+}
+
+func (r *Function) genNodeID() string {
+	var parentID string
+	if parent, ok := r.Parent.(*Service); ok {
+		parentID = parent.NodeID
+	}
+	return genNodeID(parentID, "Function", r.Ident)
+}
+
+// Reindex regenerates node ids of the function and its arguments and exceptions, and rebuilds ArgsMap and ThrowsMap.
+func (r *Function) Reindex() {
+	r.NodeID = r.genNodeID()
+	r.ArgsMap = make(map[string]*Field, len(r.Args))
+	for _, elem := range r.Args {
+		r.patchFieldToMap(FIELD_PARENT_TYPE_ARGS, elem)
+	}
+	r.ThrowsMap = make(map[string]*Field, len(r.Throws))
+	for _, elem := range r.Throws {
+		r.patchFieldToMap(FIELD_PARENT_TYPE_THROWS, elem)
+	}
 }
 
 func (r *Function) parse(p *Parser) (err error) {
 	p.peekNonWhitespace()
-	identTok := p.nextIdent(true)
+	identTok, err := p.expectIdent(true)
+	if err != nil {
+		return err
+	}
 	if identTok.Raw == "oneway" {
 		r.StartToken = identTok
 		r.Oneway = true
 		p.peekNonWhitespace()
-		identTok := p.nextIdent(true)
+		identTok, err := p.expectIdent(true)
+		if err != nil {
+			return err
+		}
 		if identTok.Raw == "void" {
 			r.Void = true
 		} else {
@@ -165,8 +224,12 @@ func (r *Function) parse(p *Parser) (err error) {
 		r.StartToken = r.FunctionType.StartToken
 	}
 	p.peekNonWhitespace()
-	identTok = p.nextIdent(false)
+	identTok, err = p.expectIdent(false)
+	if err != nil {
+		return err
+	}
 	r.Ident = identTok.Raw
+	r.NodeID = r.genNodeID()
 
 	// parse argument fields
 	var rightParenTok *Token
@@ -177,7 +240,7 @@ func (r *Function) parse(p *Parser) (err error) {
 
 	// parse options
 	ru := p.peekNonWhitespace()
-	if toToken(string(ru)) == T_LEFTPAREN {
+	if runeToken(ru) == T_LEFTPAREN {
 		r.Options, rightParenTok, err = r.parseOptions(p)
 		if err != nil {
 			return err
@@ -211,10 +274,13 @@ func (r *Function) parseOptions(p *Parser) (res []*Option, rightParenTok *Token,
 	p.next() // consume (
 	for {
 		ru := p.peekNonWhitespace()
-		if toToken(string(ru)) == T_RIGHTPAREN {
+		if runeToken(ru) == T_RIGHTPAREN {
 			rightParenTok = p.next()
 			break
 		}
+		if ru == eof {
+			return nil, nil, p.unexpected("EOF", ")")
+		}
 		elem := NewOption(r)
 		if err = elem.parse(p); err != nil {
 			return nil, nil, err
@@ -222,7 +288,7 @@ func (r *Function) parseOptions(p *Parser) (res []*Option, rightParenTok *Token,
 		res = append(res, elem)
 
 		ru = p.peekNonWhitespace()
-		if toToken(string(ru)) == T_COMMA {
+		if runeToken(ru) == T_COMMA {
 			p.next() // consume comma
 		}
 	}
@@ -230,27 +296,31 @@ func (r *Function) parseOptions(p *Parser) (res []*Option, rightParenTok *Token,
 }
 
 func (r *Function) patchFieldToMap(t int, node *Field) {
-	hash := GenTokenHash(node.StartToken)
 	switch t {
 	case FIELD_PARENT_TYPE_ARGS:
-		r.ArgsMap[hash] = node
+		node.NodeID = genNodeID(r.NodeID, "Arg", strconv.Itoa(node.ID))
+		r.ArgsMap[node.NodeID] = node
 	case FIELD_PARENT_TYPE_THROWS:
-		r.ThrowsMap[hash] = node
+		node.NodeID = genNodeID(r.NodeID, "Throws", strconv.Itoa(node.ID))
+		r.ThrowsMap[node.NodeID] = node
 	}
 }
 
 func (r *Function) parseFields(p *Parser, t int) (fields []*Field, rightParenTok *Token, err error) {
 	ru := p.peekNonWhitespace()
-	if toToken(string(ru)) != T_LEFTPAREN {
+	if runeToken(ru) != T_LEFTPAREN {
 		return nil, nil, p.unexpected(string(ru), "(")
 	}
 	p.next() // consume (
 	for {
 		ru := p.peekNonWhitespace()
-		if toToken(string(ru)) == T_RIGHTPAREN {
+		if runeToken(ru) == T_RIGHTPAREN {
 			rightParenTok = p.next()
 			break
 		}
+		if ru == eof {
+			return nil, nil, p.unexpected("EOF", ")")
+		}
 		elem := NewField(r)
 		if err = elem.parse(p); err != nil {
 			return nil, nil, err
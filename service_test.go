@@ -51,8 +51,7 @@ func TestService_elemsMap(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 	for _, ele := range n.Elems {
-		hash := GenTokenHash(ele.StartToken)
-		if got, want := n.ElemsMap[hash], ele; got != want {
+		if got, want := n.ElemsMap[ele.NodeID], ele; got != want {
 			t.Errorf("got [%v] want [%v]", got, want)
 		}
 	}
@@ -224,8 +223,7 @@ func TestFunction_argsMap(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 	for _, ele := range n.Args {
-		hash := GenTokenHash(ele.StartToken)
-		if got, want := n.ArgsMap[hash], ele; got != want {
+		if got, want := n.ArgsMap[ele.NodeID], ele; got != want {
 			t.Errorf("got [%v] want [%v]", got, want)
 		}
 	}
@@ -243,8 +241,7 @@ func TestFunction_throwsMap(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 	for _, ele := range n.Throws {
-		hash := GenTokenHash(ele.StartToken)
-		if got, want := n.ThrowsMap[hash], ele; got != want {
+		if got, want := n.ThrowsMap[ele.NodeID], ele; got != want {
 			t.Errorf("got [%v] want [%v]", got, want)
 		}
 	}
@@ -352,3 +349,46 @@ func TestService_toStringWithOptions(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 }
+
+func TestService_functionByName(t *testing.T) {
+	parser := newParserOn(`service Calc {
+		i32 add(1: i32 a, 2: i32 b) throws (1: Overflow o)
+		void ping()
+	}`)
+	start := parser.next()
+	n := NewService(start, nil)
+	if err := n.parse(parser); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	add := n.FunctionByName("add")
+	if got, want := add, n.Elems[0]; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := add.NodeID, "Service(Calc).Function(add)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := add.Args[1].NodeID, "Service(Calc).Function(add).Arg(2)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := add.Throws[0].NodeID, "Service(Calc).Function(add).Throws(1)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := n.FunctionByName("sub"); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+
+	n.Elems = n.Elems[1:]
+	n.Ident = "Pinger"
+	n.Reindex()
+	if got := n.FunctionByName("add"); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+	if got, want := n.FunctionByName("ping").NodeID, "Service(Pinger).Function(ping)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(n.ElemsMap), 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -1,5 +1,7 @@
 package thrifter
 
+import "strconv"
+
 const (
 	STRUCT = iota + 1
 	UNION
@@ -12,7 +14,7 @@ type Struct struct {
 	Ident    string
 	Elems    []*Field
 	Options  []*Option
-	ElemsMap map[string]*Field // startToken hash => Field node
+	ElemsMap map[string]*Field // node id => Field node
 }
 
 func NewStruct(start *Token, parent Node) *Struct {
@@ -56,26 +58,63 @@ func (r *Struct) String() string {
 }
 
 func (r *Struct) patchFieldToMap(node *Field) {
-	hash := GenTokenHash(node.StartToken)
-	r.ElemsMap[hash] = node
+	node.NodeID = genNodeID(r.NodeID, "Field", strconv.Itoa(node.ID))
+	r.ElemsMap[node.NodeID] = node
+}
+
+// Reindex regenerates node ids of the struct and its fields, and rebuilds ElemsMap.
+// Call it after changing Ident, field ids or Elems directly.
+func (r *Struct) Reindex() {
+	r.NodeID = genNodeID("", r.NodeType(), r.Ident)
+	r.ElemsMap = make(map[string]*Field, len(r.Elems))
+	for _, elem := range r.Elems {
+		r.patchFieldToMap(elem)
+	}
+}
+
+// FieldByID returns the field with the given field id, or nil if it does not exist.
+// It looks up ElemsMap without modifying the struct, call Reindex first after changing field ids or Elems directly.
+func (r *Struct) FieldByID(id int) *Field {
+	if elem, ok := r.ElemsMap[genNodeID(r.NodeID, "Field", strconv.Itoa(id))]; ok && elem.ID == id {
+		return elem
+	}
+	return nil
+}
+
+// FieldByName returns the field with the given identifier, or nil if it does not exist.
+// Like FieldByID, only fields in ElemsMap are found.
+func (r *Struct) FieldByName(name string) *Field {
+	for _, elem := range r.Elems {
+		if elem.Ident == name && r.ElemsMap[elem.NodeID] == elem {
+			return elem
+		}
+	}
+	return nil
 }
 
 func (r *Struct) parse(p *Parser) (err error) {
 	p.peekNonWhitespace()
-	identTok := p.nextIdent(false)
+	identTok, err := p.expectIdent(false)
+	if err != nil {
+		return err
+	}
 	r.Ident = identTok.Raw
+	r.NodeID = genNodeID("", r.NodeType(), r.Ident)
 	ru := p.peekNonWhitespace()
-	if toToken(string(ru)) != T_LEFTCURLY {
+	if runeToken(ru) != T_LEFTCURLY {
 		return p.unexpected(string(ru), "{")
 	}
 	p.next() // consume {
 	var rightParenTok *Token
 	for {
 		ru := p.peekNonWhitespace()
-		if toToken(string(ru)) == T_RIGHTCURLY {
+		if runeToken(ru) == T_RIGHTCURLY {
 			rightParenTok = p.next()
 			break
 		}
+		if ru == eof {
+			return p.unexpected("EOF", "}")
+		}
 		elem := NewField(r)
 		if err = elem.parse(p); err != nil {
 			return err
@@ -86,7 +125,7 @@ func (r *Struct) parse(p *Parser) (err error) {
 
 	// parse options
 	ru = p.peekNonWhitespace()
-	if toToken(string(ru)) != T_LEFTPAREN {
+	if runeToken(ru) != T_LEFTPAREN {
 		r.EndToken = rightParenTok
 		return
 	}
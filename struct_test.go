@@ -1,6 +1,9 @@
 package thrifter
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
 
 func TestStruct_basic(t *testing.T) {
 	parser := newParserOn(`struct A {
@@ -49,8 +52,7 @@ func TestStruct_elemsMap(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 	for _, ele := range n.Elems {
-		hash := GenTokenHash(ele.StartToken)
-		if got, want := n.ElemsMap[hash], ele; got != want {
+		if got, want := n.ElemsMap[ele.NodeID], ele; got != want {
 			t.Errorf("got [%v] want [%v]", got, want)
 		}
 	}
@@ -197,3 +199,92 @@ func TestStruct_toString(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 }
+
+func TestStruct_nodeID(t *testing.T) {
+	parser := newParserOn(`struct User {
+		1: i64 id;
+		2: string name;
+	}`)
+	start := parser.next()
+	n := NewStruct(start, nil)
+	if err := n.parse(parser); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	if got, want := n.NodeID, "Struct(User)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := n.Elems[1].NodeID, "Struct(User).Field(2)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestStruct_lookupAfterMutation(t *testing.T) {
+	parser := newParserOn(`struct User {
+		1: i64 id;
+		2: string name;
+	}`)
+	start := parser.next()
+	n := NewStruct(start, nil)
+	if err := n.parse(parser); err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+
+	if got, want := n.FieldByID(2), n.Elems[1]; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := n.FieldByName("id"), n.Elems[0]; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := n.FieldByID(3); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+
+	// renumber a field and rename the struct, lookups don't see it until reindexed
+	n.Elems[1].ID = 3
+	n.Ident = "Account"
+	if got := n.FieldByID(3); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+	n.Reindex()
+	if got, want := n.FieldByID(3), n.Elems[1]; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := n.FieldByID(2); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+	if got, want := n.Elems[1].NodeID, "Struct(Account).Field(3)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(n.ElemsMap), 2; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestStruct_lookupConcurrent(t *testing.T) {
+	parser := newParserOn(`struct User {
+		1: i64 id;
+	}`)
+	n := NewStruct(parser.next(), nil)
+	if err := n.parse(parser); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// lookups of missing fields don't write indexes, which would race here
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := 0; id < 100; id++ {
+				n.FieldByID(id + 2)
+				n.FieldByName("name")
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := n.FieldByID(1), n.Elems[0]; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -29,6 +29,65 @@ func (r *Thrift) String() string {
 	return toString(r.StartToken, r.EndToken)
 }
 
+// Declaration returns the top-level declaration named name, e.g. Struct/Enum/Service/TypeDef/Const, or nil if not found.
+func (r *Thrift) Declaration(name string) Node {
+	for _, node := range r.Nodes {
+		if declarationIdent(node) == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// Includes returns include nodes of thrift files, cpp_include are excluded.
+func (r *Thrift) Includes() (res []*Include) {
+	for _, node := range r.Nodes {
+		if inc, ok := node.(*Include); ok && !inc.IsCpp() {
+			res = append(res, inc)
+		}
+	}
+	return
+}
+
+func declarationIdent(node Node) string {
+	switch n := node.(type) {
+	case *Struct:
+		return n.Ident
+	case *Enum:
+		return n.Ident
+	case *Service:
+		return n.Ident
+	case *TypeDef:
+		return n.Ident
+	case *Const:
+		return n.Ident
+	}
+	return ""
+}
+
+// Reindex regenerates node ids of all declarations, and rebuilds ElemsMap/ArgsMap/ThrowsMap of container nodes.
+// Call it after mutating the tree, e.g. renaming declarations or adding/removing elements.
+func (r *Thrift) Reindex() {
+	for _, node := range r.Nodes {
+		switch n := node.(type) {
+		case *Struct:
+			n.Reindex()
+		case *Enum:
+			n.Reindex()
+		case *Service:
+			n.Reindex()
+		case *Const:
+			n.NodeID = genNodeID("", n.NodeType(), n.Ident)
+		case *TypeDef:
+			n.NodeID = genNodeID("", n.NodeType(), n.Ident)
+		case *Include:
+			n.NodeID = genNodeID("", n.NodeType(), n.FilePath)
+		case *Namespace:
+			n.NodeID = genNodeID("", n.NodeType(), n.Name)
+		}
+	}
+}
+
 func (r *Thrift) parse(p *Parser) (err error) {
 	tok := p.next()
 
@@ -111,7 +170,7 @@ func (r *Thrift) parse(p *Parser) (err error) {
 		}
 	case tok.Type == T_EOF:
 		r.EndToken = tok
-		return
+		return p.err
 	default:
 		return p.unexpected(tok.Raw, ".thrift element {namespace|enum|const|service|struct|include|typedef|union|exception}")
 	}
@@ -3,9 +3,10 @@ package thrifter
 import (
 	"bytes"
 	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
-	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 type token int
@@ -85,106 +86,150 @@ const (
 	keywordEnd
 )
 
-// Get corresponding token from string literal, mostly used for generate token.
-func GetToken(literal string) token {
-	return toToken(literal)
-}
-
-func toToken(literal string) token {
-	switch literal {
+// tokens for single character literals, index by character
+var charTokens = [utf8.RuneSelf]token{
 	// white space
-	case "\n":
-		return T_LINEBREAK
-	case "\r":
-		return T_RETURN
-	case " ":
-		return T_SPACE
-	case "\t":
-		return T_TAB
+	'\n': T_LINEBREAK,
+	'\r': T_RETURN,
+	' ':  T_SPACE,
+	'\t': T_TAB,
 	// punctuator
-	case ";":
-		return T_SEMICOLON
-	case ":":
-		return T_COLON
-	case "=":
-		return T_EQUALS
-	case "\"":
-		return T_QUOTE
-	case "'":
-		return T_SINGLEQUOTE
-	case "(":
-		return T_LEFTPAREN
-	case ")":
-		return T_RIGHTPAREN
-	case "{":
-		return T_LEFTCURLY
-	case "}":
-		return T_RIGHTCURLY
-	case "[":
-		return T_LEFTSQUARE
-	case "]":
-		return T_RIGHTSQUARE
-	case "<":
-		return T_LESS
-	case ">":
-		return T_GREATER
-	case ",":
-		return T_COMMA
-	case ".":
-		return T_DOT
-	case "+":
-		return T_PLUS
-	case "-":
-		return T_MINUS
+	';':  T_SEMICOLON,
+	':':  T_COLON,
+	'=':  T_EQUALS,
+	'"':  T_QUOTE,
+	'\'': T_SINGLEQUOTE,
+	'(':  T_LEFTPAREN,
+	')':  T_RIGHTPAREN,
+	'{':  T_LEFTCURLY,
+	'}':  T_RIGHTCURLY,
+	'[':  T_LEFTSQUARE,
+	']':  T_RIGHTSQUARE,
+	'<':  T_LESS,
+	'>':  T_GREATER,
+	',':  T_COMMA,
+	'.':  T_DOT,
+	'+':  T_PLUS,
+	'-':  T_MINUS,
+}
 
+var keywords = map[string]token{
 	// declaration keywords
-	case "namespace":
-		return T_NAMESPACE
-	case "enum":
-		return T_ENUM
-	case "senum":
-		return T_SENUM
-	case "const":
-		return T_CONST
-	case "service":
-		return T_SERVICE
-	case "struct":
-		return T_STRUCT
-	case "include":
-		return T_INCLUDE
-	case "cpp_include":
-		return T_CPP_INCLUDE
-	case "typedef":
-		return T_TYPEDEF
-	case "union":
-		return T_UNION
-	case "exception":
-		return T_EXCEPTION
+	"namespace":   T_NAMESPACE,
+	"enum":        T_ENUM,
+	"senum":       T_SENUM,
+	"const":       T_CONST,
+	"service":     T_SERVICE,
+	"struct":      T_STRUCT,
+	"include":     T_INCLUDE,
+	"cpp_include": T_CPP_INCLUDE,
+	"typedef":     T_TYPEDEF,
+	"union":       T_UNION,
+	"exception":   T_EXCEPTION,
 
 	// field keywords
-	case "optional":
-		return T_OPTIONAL
-	case "required":
-		return T_REQUIRED
+	"optional": T_OPTIONAL,
+	"required": T_REQUIRED,
 
 	// type keywords
-	case "map":
-		return T_MAP
-	case "set":
-		return T_SET
-	case "list":
-		return T_LIST
+	"map":  T_MAP,
+	"set":  T_SET,
+	"list": T_LIST,
 
 	// function keywords
-	case "oneway":
-		return T_ONEWAY
-	case "void":
-		return T_VOID
-	case "throws":
-		return T_THROWS
-	default:
+	"oneway": T_ONEWAY,
+	"void":   T_VOID,
+	"throws": T_THROWS,
+}
+
+var tokenNames = map[token]string{
+	T_ILLEGAL:     "ILLEGAL",
+	T_EOF:         "EOF",
+	T_IDENT:       "IDENT",
+	T_STRING:      "STRING",
+	T_NUMBER:      "NUMBER",
+	T_SPACE:       "SPACE",
+	T_LINEBREAK:   "LINEBREAK",
+	T_RETURN:      "RETURN",
+	T_TAB:         "TAB",
+	T_SEMICOLON:   "SEMICOLON",
+	T_COLON:       "COLON",
+	T_EQUALS:      "EQUALS",
+	T_QUOTE:       "QUOTE",
+	T_SINGLEQUOTE: "SINGLEQUOTE",
+	T_LEFTPAREN:   "LEFTPAREN",
+	T_RIGHTPAREN:  "RIGHTPAREN",
+	T_LEFTCURLY:   "LEFTCURLY",
+	T_RIGHTCURLY:  "RIGHTCURLY",
+	T_LEFTSQUARE:  "LEFTSQUARE",
+	T_RIGHTSQUARE: "RIGHTSQUARE",
+	T_COMMENT:     "COMMENT",
+	T_LESS:        "LESS",
+	T_GREATER:     "GREATER",
+	T_COMMA:       "COMMA",
+	T_DOT:         "DOT",
+	T_PLUS:        "PLUS",
+	T_MINUS:       "MINUS",
+}
+
+// String returns the name of token, e.g. IDENT or COMMENT, keywords are named by themselves in upper case, e.g. STRUCT.
+func (t token) String() string {
+	if name, ok := tokenNames[t]; ok {
+		return name
+	}
+	for literal, tok := range keywords {
+		if tok == t {
+			return strings.ToUpper(literal)
+		}
+	}
+	return fmt.Sprintf("token(%d)", int(t))
+}
+
+// length range of keywords, literals out of it can skip keyword lookup
+const (
+	minKeywordLen = 3  // map, set
+	maxKeywordLen = 11 // cpp_include
+)
+
+// Get corresponding token from string literal, mostly used for generate token.
+func GetToken(literal string) token {
+	return toToken(literal)
+}
+
+func toToken(literal string) token {
+	if len(literal) == 1 {
+		return runeToken(rune(literal[0]))
+	}
+	if len(literal) < minKeywordLen || len(literal) > maxKeywordLen {
 		return T_IDENT
 	}
+	if tok, ok := keywords[literal]; ok {
+		return tok
+	}
+	return T_IDENT
+}
+
+// IsIdent reports whether s is a valid identifier without dots, which isn't a keyword or base type, e.g. a valid name of declaration.
+func IsIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	i := 0
+	for _, ru := range s {
+		if !isIdentRune(ru, i) {
+			return false
+		}
+		i++
+	}
+	return toToken(s) == T_IDENT && !isBaseTypeToken(s)
+}
+
+// Get corresponding token from a single character, same as toToken(string(r)) without allocating.
+func runeToken(r rune) token {
+	if r >= 0 && r < utf8.RuneSelf && charTokens[r] != T_ILLEGAL {
+		return charTokens[r]
+	}
+	return T_IDENT
 }
 
 // comment type
@@ -194,12 +239,13 @@ const (
 	BASH_LIKE_COMMENT              // # like this
 )
 
-// Generate hash from token.Type + token.Raw + token.Pos, for nodes like enum/struct/service to find their element node when iterate over token.
+// Generate hex encoded sha1 hash from token.Type + token.Raw + token.Pos.
+//
+// Deprecated: the hash depends on token position, which changes once the token chain is edited, use NodeCommonField.NodeID to identify nodes instead.
 func GenTokenHash(t *Token) (res string) {
 	h := sha1.New()
-	val := fmt.Sprintf("%d_%s_%+v", t.Type, t.Raw, t.Pos)
-	hash := h.Sum([]byte(val))
-	return string(hash)
+	fmt.Fprintf(h, "%d_%s_%+v", t.Type, t.Raw, t.Pos)
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 // isDigit returns true if the rune is a digit.
@@ -209,19 +255,46 @@ func IsDigit(lit rune) bool {
 
 // determine whether it is an integer or a float number
 func IsNumber(str string) (isFloat bool, isInt bool) {
-	isFloat, _ = regexp.MatchString("^\\d+\\.\\d+$", str)
-	isInt, _ = regexp.MatchString("^\\d+$", str)
-	return
+	dot := strings.IndexByte(str, '.')
+	if dot < 0 {
+		return false, isDigits(str)
+	}
+	return isDigits(str[:dot]) && isDigits(str[dot+1:]), false
+}
+
+func isDigits(str string) bool {
+	if len(str) == 0 {
+		return false
+	}
+	for i := 0; i < len(str); i++ {
+		if !IsDigit(rune(str[i])) {
+			return false
+		}
+	}
+	return true
 }
 
 func getCommentValue(raw string, commentType int) (res string) {
+	// prefer slicing raw value, fallback to replacing when prefix or suffix are not in place
 	switch commentType {
 	case SINGLE_LINE_COMMENT:
+		if strings.HasPrefix(raw, "//") {
+			return raw[2:]
+		}
 		res = strings.Replace(raw, "//", "", 1)
 	case MULTI_LINE_COMMENT:
+		if len(raw) >= 4 && strings.HasPrefix(raw, "/*") && strings.HasSuffix(raw, "*/") {
+			inner := raw[2 : len(raw)-2]
+			if !strings.Contains(inner, "/*") && !strings.Contains(inner, "*/") {
+				return inner
+			}
+		}
 		res = strings.ReplaceAll(raw, "/*", "")
 		res = strings.ReplaceAll(res, "*/", "")
 	case BASH_LIKE_COMMENT:
+		if strings.HasPrefix(raw, "#") {
+			return raw[1:]
+		}
 		res = strings.Replace(raw, "#", "", 1)
 	}
 	return
@@ -254,17 +327,17 @@ const quoteRune = '"'
 
 // UnQuote removes one matching leading and trailing single or double quote.
 // cannot use strconv.Unquote as this unescapes quotes.
+// quotes are single byte characters, so slicing bytes is safe here.
 func unQuote(lit string) (string, rune) {
 	if len(lit) < 2 {
 		return lit, quoteRune
 	}
-	chars := []rune(lit)
-	first, last := chars[0], chars[len(chars)-1]
+	first, last := rune(lit[0]), rune(lit[len(lit)-1])
 	if first != last {
 		return lit, quoteRune
 	}
-	if s := string(chars[0]); s == quoteString || s == singleQuoteString {
-		return string(chars[1 : len(chars)-1]), chars[0]
+	if first == quoteRune || first == singleQuoteRune {
+		return lit[1 : len(lit)-1], first
 	}
 	return lit, quoteRune
 }
@@ -1,6 +1,7 @@
 package thrifter
 
 import (
+	"strings"
 	"testing"
 	"text/scanner"
 )
@@ -85,3 +86,41 @@ func TestGenTokenHash(t *testing.T) {
 		t.Errorf("got [%v] want [%v]", got, want)
 	}
 }
+
+func TestGenTokenHash_hex(t *testing.T) {
+	hash := GenTokenHash(&Token{
+		Type: T_IDENT,
+		Raw:  strings.Repeat("a", 100),
+	})
+
+	if got, want := len(hash), 40; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestIsIdent(t *testing.T) {
+	for s, want := range map[string]bool{
+		"User":     true,
+		"_id2":     true,
+		"名字":       true,
+		"":         false,
+		"2id":      false,
+		"a.b":      false,
+		"a-b":      false,
+		"struct":   false,
+		"required": false,
+		"i32":      false,
+	} {
+		if got := IsIdent(s); got != want {
+			t.Errorf("%q: got [%v] want [%v]", s, got, want)
+		}
+	}
+}
+
+func TestToken_String(t *testing.T) {
+	for tok, want := range map[token]string{T_IDENT: "IDENT", T_LEFTCURLY: "LEFTCURLY", T_STRUCT: "STRUCT", T_SENUM: "SENUM", token(-100): "token(-100)"} {
+		if got := tok.String(); got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+}
@@ -38,13 +38,17 @@ func (r *TypeDef) parse(p *Parser) (err error) {
 		return p.unexpected(r.Type.Ident, "base type or map or list or set")
 	}
 
-	identTok := p.nextIdent(true)
+	identTok, err := p.expectIdent(true)
+	if err != nil {
+		return err
+	}
 	r.Ident = identTok.Raw
+	r.NodeID = genNodeID("", r.NodeType(), r.Ident)
 	r.EndToken = identTok
 
 	// parse options
 	ru := p.peekNonWhitespace()
-	if toToken(string(ru)) != T_LEFTPAREN {
+	if runeToken(ru) != T_LEFTPAREN {
 		return
 	}
 	p.next() // consume (
@@ -8,6 +8,9 @@ type NodeCommonField struct {
 	Prev       Node
 	StartToken *Token
 	EndToken   *Token
+	// NodeID is the stable identifier of the node, see genNodeID for the scheme.
+	// Only declarations and their elements carry an id, e.g. Struct/Field, Enum/EnumElement, Service/Function, other nodes leave it empty.
+	NodeID string
 }
 
 type Token struct {
@@ -17,6 +20,8 @@ type Token struct {
 	Next  *Token
 	Prev  *Token
 	Pos   scanner.Position
+	Start int // byte offset of the token in source
+	End   int // byte offset right after the token in source
 }
 
 type Node interface {
@@ -28,4 +33,27 @@ type Node interface {
 	NodeValue() interface{}
 	// get node type, value specified from each node
 	NodeType() string
+	// get common fields of the node, e.g. tokens and parent, without type assertion
+	CommonField() *NodeCommonField
+}
+
+func (r *NodeCommonField) CommonField() *NodeCommonField {
+	return r
+}
+
+// Generate node id from its parent id, node kind and a key which identifies the node among its siblings, e.g.
+//
+//	Struct(User)                          // top-level declarations use NodeType and Ident
+//	Struct(User).Field(1)                 // struct fields use field id
+//	Enum(Color).EnumElement(RED)          // enum elements use Ident
+//	Service(Calc).Function(add).Arg(1)    // function arguments use field id
+//	Service(Calc).Function(add).Throws(1) // function exceptions use field id
+//	Include(shared.thrift)                // includes use FilePath, namespaces use Name
+//
+// Since the id only depends on names and field ids, not on token positions, it survives editing the token chain or synthesizing new tokens.
+func genNodeID(parentID string, kind string, key string) string {
+	if parentID == "" {
+		return kind + "(" + key + ")"
+	}
+	return parentID + "." + kind + "(" + key + ")"
 }
@@ -0,0 +1,152 @@
+package thrifter
+
+// Inspect traverses the tree in depth-first order, it calls f(node) for each node, starting with root, if f returns true, Inspect continues with children of node.
+// Options are visited as children of the node they belong to.
+func Inspect(root Node, f func(node Node) bool) {
+	if isNilNode(root) || !f(root) {
+		return
+	}
+	for _, child := range children(root) {
+		Inspect(child, f)
+	}
+}
+
+// Root returns the Thrift node that node belongs to, or nil if node is not attached to any Thrift.
+func Root(node Node) *Thrift {
+	for !isNilNode(node) {
+		if res, ok := node.(*Thrift); ok {
+			return res
+		}
+		node = node.CommonField().Parent
+	}
+	return nil
+}
+
+// TopLevel returns the top-level declaration containing node, e.g. the Struct of a Field, or nil for a nil node.
+// A node not attached to any Thrift is its own top-level declaration.
+func TopLevel(node Node) Node {
+	for !isNilNode(node) {
+		parent := node.CommonField().Parent
+		if _, ok := parent.(*Thrift); ok || isNilNode(parent) {
+			return node
+		}
+		node = parent
+	}
+	return nil
+}
+
+// typed nil pointers are not nil Node, e.g. a FieldType without Map is (*MapType)(nil)
+func isNilNode(node Node) bool {
+	if node == nil {
+		return true
+	}
+	switch n := node.(type) {
+	case *Thrift:
+		return n == nil
+	case *Struct:
+		return n == nil
+	case *Field:
+		return n == nil
+	case *FieldType:
+		return n == nil
+	case *MapType:
+		return n == nil
+	case *ListType:
+		return n == nil
+	case *SetType:
+		return n == nil
+	case *Enum:
+		return n == nil
+	case *EnumElement:
+		return n == nil
+	case *Service:
+		return n == nil
+	case *Function:
+		return n == nil
+	case *Const:
+		return n == nil
+	case *ConstValue:
+		return n == nil
+	case *ConstMap:
+		return n == nil
+	case *ConstList:
+		return n == nil
+	case *TypeDef:
+		return n == nil
+	case *Namespace:
+		return n == nil
+	case *Include:
+		return n == nil
+	case *Option:
+		return n == nil
+	}
+	return false
+}
+
+func children(node Node) (res []Node) {
+	appendOptions := func(options []*Option) {
+		for _, option := range options {
+			res = append(res, option)
+		}
+	}
+	switch n := node.(type) {
+	case *Thrift:
+		res = append(res, n.Nodes...)
+	case *Struct:
+		for _, elem := range n.Elems {
+			res = append(res, elem)
+		}
+		appendOptions(n.Options)
+	case *Field:
+		res = append(res, n.FieldType, n.DefaultValue)
+		appendOptions(n.Options)
+	case *FieldType:
+		res = append(res, n.Map, n.List, n.Set)
+		appendOptions(n.Options)
+	case *MapType:
+		res = append(res, n.Key, n.Value)
+	case *ListType:
+		res = append(res, n.Elem)
+	case *SetType:
+		res = append(res, n.Elem)
+	case *Enum:
+		for _, elem := range n.Elems {
+			res = append(res, elem)
+		}
+		appendOptions(n.Options)
+	case *EnumElement:
+		appendOptions(n.Options)
+	case *Service:
+		for _, elem := range n.Elems {
+			res = append(res, elem)
+		}
+		appendOptions(n.Options)
+	case *Function:
+		res = append(res, n.FunctionType)
+		for _, arg := range n.Args {
+			res = append(res, arg)
+		}
+		for _, throw := range n.Throws {
+			res = append(res, throw)
+		}
+		appendOptions(n.Options)
+	case *Const:
+		res = append(res, n.Type, n.Value)
+	case *ConstValue:
+		res = append(res, n.Map, n.List)
+	case *ConstMap:
+		for i := range n.MapKeyList {
+			res = append(res, &n.MapKeyList[i], &n.MapValueList[i])
+		}
+	case *ConstList:
+		for _, elem := range n.Elems {
+			res = append(res, elem)
+		}
+	case *TypeDef:
+		res = append(res, n.Type)
+		appendOptions(n.Options)
+	case *Namespace:
+		appendOptions(n.Options)
+	}
+	return
+}
@@ -0,0 +1,99 @@
+package thrifter
+
+import "testing"
+
+func TestInspect_nodeTypes(t *testing.T) {
+	parser := newParserOn(`struct A {
+		1: map<string, list<B>> a = {"a": [1]} (x = "y")
+	}`)
+	res, err := parser.Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	var types []string
+	Inspect(res, func(node Node) bool {
+		types = append(types, node.NodeType())
+		return true
+	})
+
+	want := []string{"Thrift", "Struct", "Field", "FieldType", "MapType", "FieldType", "FieldType", "ListType", "FieldType", "ConstValue", "ConstMap", "ConstValue", "ConstValue", "ConstList", "ConstValue", "Option"}
+	if got, want := len(types), len(want); got != want {
+		t.Errorf("got [%v] want [%v]", types, want)
+		return
+	}
+	for i := range want {
+		if got, want := types[i], want[i]; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+}
+
+func TestInspect_skipChildren(t *testing.T) {
+	parser := newParserOn(`struct A {
+		1: i32 a
+	}
+	enum B {
+		C
+	}`)
+	res, err := parser.Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	count := 0
+	Inspect(res, func(node Node) bool {
+		count++
+		return node.NodeType() == "Thrift"
+	})
+
+	if got, want := count, 3; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestRoot(t *testing.T) {
+	parser := newParserOn(`struct A {
+		1: list<i32> a
+	}`)
+	res, err := parser.Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	elem := res.Nodes[0].(*Struct).Elems[0].FieldType.List.Elem
+
+	if got, want := Root(elem), res; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := Root(NewField(nil)); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+}
+
+func TestTopLevel(t *testing.T) {
+	parser := newParserOn(`struct A {
+		1: list<i32> a
+	}`)
+	res, err := parser.Parse("")
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return
+	}
+	node := res.Nodes[0]
+	elem := node.(*Struct).Elems[0].FieldType.List.Elem
+
+	if got, want := TopLevel(elem), node; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := TopLevel(node), node; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	field := NewField(nil)
+	if got, want := TopLevel(field), Node(field); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := TopLevel(nil); got != nil {
+		t.Errorf("got [%v] want [nil]", got)
+	}
+}